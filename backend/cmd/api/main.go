@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"github.com/Wikid82/charon/backend/internal/api/handlers"
 	"github.com/Wikid82/charon/backend/internal/api/middleware"
 	"github.com/Wikid82/charon/backend/internal/api/routes"
+	"github.com/Wikid82/charon/backend/internal/caddy"
 	"github.com/Wikid82/charon/backend/internal/config"
 	"github.com/Wikid82/charon/backend/internal/database"
 	"github.com/Wikid82/charon/backend/internal/logger"
@@ -90,6 +92,46 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "export-caddyfile" {
+		if len(os.Args) > 3 {
+			log.Fatalf("Usage: %s export-caddyfile [output-file]", os.Args[0])
+		}
+		// Keep stdout clean for the Caddyfile
+		log.SetOutput(io.MultiWriter(os.Stderr, rotator))
+		logger.Init(false, io.MultiWriter(os.Stderr, rotator))
+
+		cfg, err := config.Load()
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+
+		db, err := database.Connect(cfg.DatabasePath)
+		if err != nil {
+			log.Fatalf("connect database: %v", err)
+		}
+
+		manager := caddy.NewManager(caddy.NewClient(cfg.CaddyAdminAPI), db, cfg.CaddyConfigDir, cfg.FrontendDir, cfg.ACMEStaging, cfg.Security)
+		// The export leaves a placeholder for the permission endpoint secret, so a
+		// read-only command never needs to read or create it
+		manager.SetOnDemandAskURL(fmt.Sprintf("http://127.0.0.1:%s/api/v1/tls/ask", cfg.HTTPPort))
+		// Same directory as the server uses for static site releases
+		manager.SetSitesDir(filepath.Join(filepath.Dir(cfg.DatabasePath), "sites"))
+		caddyfile, err := manager.ExportCaddyfile(context.Background())
+		if err != nil {
+			log.Fatalf("export Caddyfile: %v", err)
+		}
+
+		if len(os.Args) == 3 {
+			if err := os.WriteFile(os.Args[2], []byte(caddyfile), 0o644); err != nil {
+				log.Fatalf("write Caddyfile: %v", err)
+			}
+			logger.Log().Infof("Caddyfile written to %s", os.Args[2])
+			return
+		}
+		fmt.Print(caddyfile)
+		return
+	}
+
 	logger.Log().Infof("starting %s backend on version %s", version.Name, version.Full())
 
 	cfg, err := config.Load()
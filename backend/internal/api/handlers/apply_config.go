@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// apply pushes the config to Caddy, writing a 500 and returning false if that fails.
+// Without an applier (a nil Caddy manager) there is nothing to push.
+func apply(c *gin.Context, applier services.ConfigApplier) bool {
+	if m, ok := applier.(*caddy.Manager); applier == nil || (ok && m == nil) {
+		return true
+	}
+	if err := applier.ApplyConfig(c.Request.Context()); err != nil {
+		middleware.GetRequestLogger(c).WithError(err).Error("Error applying config")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply configuration: " + err.Error()})
+		return false
+	}
+	return true
+}
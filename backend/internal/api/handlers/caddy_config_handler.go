@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// CaddyConfigHandler exposes the generated Caddy configuration: a dry-run
+// preview, a Caddyfile export, snapshots that can be diffed and rolled back to,
+// and the status of the Caddy process when Charon supervises it.
+type CaddyConfigHandler struct {
+	manager    *caddy.Manager
+	supervisor *caddy.Supervisor
+}
+
+// NewCaddyConfigHandler creates a new Caddy config handler.
+func NewCaddyConfigHandler(manager *caddy.Manager) *CaddyConfigHandler {
+	return &CaddyConfigHandler{manager: manager}
+}
+
+// SetSupervisor sets the supervisor reported by Process when Charon runs Caddy itself.
+func (h *CaddyConfigHandler) SetSupervisor(supervisor *caddy.Supervisor) {
+	h.supervisor = supervisor
+}
+
+// Process reports the state of the Caddy process. When Caddy is not supervised
+// by Charon only {"supervised": false} is returned.
+func (h *CaddyConfigHandler) Process(c *gin.Context) {
+	if h.supervisor == nil {
+		c.JSON(http.StatusOK, caddy.ProcessStatus{})
+		return
+	}
+	c.JSON(http.StatusOK, h.supervisor.Status())
+}
+
+// Preview generates the config without applying it and diffs it against the running config.
+// The optional body {"host": {...}} previews an unsaved proxy host; a host with an
+// existing UUID replaces that host, otherwise it is added.
+func (h *CaddyConfigHandler) Preview(c *gin.Context) {
+	if h.manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caddy manager not available"})
+		return
+	}
+
+	var req struct {
+		Host *models.ProxyHost `json:"host"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.manager.Preview(c.Request.Context(), req.Host)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// ExportCaddyfile returns the current proxy hosts and settings as a Caddyfile download.
+func (h *CaddyConfigHandler) ExportCaddyfile(c *gin.Context) {
+	if h.manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caddy manager not available"})
+		return
+	}
+	caddyfile, err := h.manager.ExportCaddyfile(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export Caddyfile"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="Caddyfile"`)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(caddyfile))
+}
+
+// ListSnapshots returns snapshot metadata, newest first.
+func (h *CaddyConfigHandler) ListSnapshots(c *gin.Context) {
+	if h.manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caddy manager not available"})
+		return
+	}
+	snaps, err := h.manager.ListSnapshots()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list snapshots"})
+		return
+	}
+	c.JSON(http.StatusOK, snaps)
+}
+
+// GetSnapshot returns one snapshot with its config.
+func (h *CaddyConfigHandler) GetSnapshot(c *gin.Context) {
+	id, ok := h.snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	snap, config, err := h.manager.GetSnapshot(id)
+	if err != nil {
+		h.snapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshot": snap, "config": config})
+}
+
+// CreateSnapshot stores the running config and database state under a name.
+func (h *CaddyConfigHandler) CreateSnapshot(c *gin.Context) {
+	if h.manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caddy manager not available"})
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	snap, err := h.manager.CreateSnapshot(c.Request.Context(), req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, snap)
+}
+
+// DiffSnapshot shows what rolling back to a snapshot would change, compared with the
+// running config or with the snapshot given by ?against=<id>.
+func (h *CaddyConfigHandler) DiffSnapshot(c *gin.Context) {
+	id, ok := h.snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var against uint
+	if raw := c.Query("against"); raw != "" {
+		if against, ok = h.snapshotID(c, raw); !ok {
+			return
+		}
+	}
+	diff, err := h.manager.DiffSnapshot(c.Request.Context(), id, against)
+	if err != nil {
+		h.snapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// RollbackSnapshot restores a snapshot's database state and applies the resulting config.
+func (h *CaddyConfigHandler) RollbackSnapshot(c *gin.Context) {
+	id, ok := h.snapshotID(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if err := h.manager.RollbackToSnapshot(c.Request.Context(), id); err != nil {
+		h.snapshotError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Rolled back to snapshot"})
+}
+
+func (h *CaddyConfigHandler) snapshotID(c *gin.Context, raw string) (uint, bool) {
+	if h.manager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caddy manager not available"})
+		return 0, false
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid snapshot id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *CaddyConfigHandler) snapshotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+	case errors.Is(err, caddy.ErrSnapshotNoState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
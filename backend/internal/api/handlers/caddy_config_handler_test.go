@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestCaddyConfigHandler_Preview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.SSLCertificate{}))
+
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	manager := caddy.NewManager(caddy.NewClient(caddyServer.URL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	h := NewCaddyConfigHandler(manager)
+	r := gin.New()
+	r.POST("/api/v1/caddy/preview", h.Preview)
+
+	// Empty body previews the saved state
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/caddy/preview", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	body := `{"host":{"domain_names":"preview.example.com","forward_host":"app","forward_port":80,"enabled":true}}`
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/caddy/preview", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp caddy.ConfigPreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Diff)
+	require.Len(t, resp.Diff.Routes, 1)
+	assert.Equal(t, "preview.example.com", resp.Diff.Routes[0].Key)
+	assert.Equal(t, caddy.DiffAdded, resp.Diff.Routes[0].Action)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/caddy/preview", strings.NewReader(`{"host":`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Missing manager
+	r2 := gin.New()
+	r2.POST("/preview", NewCaddyConfigHandler(nil).Preview)
+	w = httptest.NewRecorder()
+	r2.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/preview", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestCaddyConfigHandler_ExportCaddyfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.SSLCertificate{}, &models.AccessList{}, &models.ClientCA{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+
+	manager := caddy.NewManager(caddy.NewClient("http://127.0.0.1:1"), db, t.TempDir(), "", false, config.SecurityConfig{})
+	r := gin.New()
+	r.GET("/caddy/export/caddyfile", NewCaddyConfigHandler(manager).ExportCaddyfile)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caddy/export/caddyfile", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="Caddyfile"`)
+	assert.Contains(t, w.Body.String(), "a.example.com {\n")
+
+	r2 := gin.New()
+	r2.GET("/export", NewCaddyConfigHandler(nil).ExportCaddyfile)
+	w = httptest.NewRecorder()
+	r2.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestCaddyConfigHandler_Process(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewCaddyConfigHandler(nil)
+	r := gin.New()
+	r.GET("/caddy/process", h.Process)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caddy/process", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"supervised":false,"restarts":0,"health_failures":0}`, w.Body.String())
+
+	supervisor, err := caddy.NewSupervisor(nil, "caddy", t.TempDir())
+	require.NoError(t, err)
+	h.SetSupervisor(supervisor)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caddy/process", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"supervised":true,"state":"stopped","restarts":0,"health_failures":0}`, w.Body.String())
+}
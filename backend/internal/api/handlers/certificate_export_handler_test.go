@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+func setupCertExportRouter(t *testing.T, role string) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.SSLCertificate{}, &models.ProxyHost{}, &models.SecurityAudit{}))
+
+	svc := services.NewCertificateService(t.TempDir(), db)
+	h := NewCertificateHandler(svc, nil, nil)
+	h.SetAuditService(services.NewSecurityService(db))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("role", role)
+		c.Set("userID", uint(1))
+		c.Next()
+	})
+	r.GET("/api/certificates/:id/export", middleware.RequireRole("admin"), h.Export)
+	return r, db
+}
+
+func TestCertificateHandler_Export_PEM(t *testing.T) {
+	r, db := setupCertExportRouter(t, "admin")
+	certPEM, keyPEM, err := generateSelfSignedCertPEM()
+	require.NoError(t, err)
+	cert := models.SSLCertificate{UUID: "exp-1", Name: "mail", Provider: "custom", Domains: "mail.example.com", Certificate: certPEM, PrivateKey: keyPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/certificates/"+toStr(cert.ID)+"/export?format=pem", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "mail.pem")
+	assert.True(t, strings.Contains(w.Body.String(), "PRIVATE KEY"))
+
+	var audit models.SecurityAudit
+	require.NoError(t, db.Where("action = ?", "export_certificate").First(&audit).Error)
+	assert.Equal(t, "1", audit.Actor)
+	assert.Contains(t, audit.Details, "format=pem")
+}
+
+func TestCertificateHandler_Export_PKCS12PasswordHeader(t *testing.T) {
+	r, db := setupCertExportRouter(t, "admin")
+	certPEM, keyPEM, err := generateSelfSignedCertPEM()
+	require.NoError(t, err)
+	cert := models.SSLCertificate{UUID: "exp-2", Name: "appliance", Provider: "custom", Domains: "app.example.com", Certificate: certPEM, PrivateKey: keyPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	// Missing password
+	req := httptest.NewRequest(http.MethodGet, "/api/certificates/"+toStr(cert.ID)+"/export?format=pkcs12", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The password is never read from the query string
+	req = httptest.NewRequest(http.MethodGet, "/api/certificates/"+toStr(cert.ID)+"/export?format=pkcs12&password=pw", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/certificates/"+toStr(cert.ID)+"/export?format=pkcs12", nil)
+	req.Header.Set("X-Export-Password", "pw")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-pkcs12", w.Header().Get("Content-Type"))
+}
+
+func TestCertificateHandler_Export_Errors(t *testing.T) {
+	r, db := setupCertExportRouter(t, "admin")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/certificates/abc/export", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/certificates/999/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	certPEM, _, err := generateSelfSignedCertPEM()
+	require.NoError(t, err)
+	cert := models.SSLCertificate{UUID: "exp-3", Name: "nokey", Provider: "custom", Domains: "nokey.example.com", Certificate: certPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/certificates/"+toStr(cert.ID)+"/export", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var count int64
+	db.Model(&models.SecurityAudit{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestCertificateHandler_Export_RequiresAdmin(t *testing.T) {
+	r, _ := setupCertExportRouter(t, "user")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/certificates/1/export", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
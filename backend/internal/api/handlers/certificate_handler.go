@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"github.com/Wikid82/charon/backend/internal/models"
 	"github.com/Wikid82/charon/backend/internal/services"
 	"github.com/Wikid82/charon/backend/internal/util"
 )
@@ -24,6 +27,7 @@ type CertificateHandler struct {
 	service             *services.CertificateService
 	backupService       BackupServiceInterface
 	notificationService *services.NotificationService
+	auditService        *services.SecurityService
 }
 
 func NewCertificateHandler(service *services.CertificateService, backupService BackupServiceInterface, ns *services.NotificationService) *CertificateHandler {
@@ -34,6 +38,11 @@ func NewCertificateHandler(service *services.CertificateService, backupService B
 	}
 }
 
+// SetAuditService enables security audit entries for sensitive certificate operations (e.g. export).
+func (h *CertificateHandler) SetAuditService(svc *services.SecurityService) {
+	h.auditService = svc
+}
+
 func (h *CertificateHandler) List(c *gin.Context) {
 	certs, err := h.service.ListCertificates()
 	if err != nil {
@@ -171,3 +180,51 @@ func (h *CertificateHandler) Delete(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "certificate deleted"})
 }
+
+// Export downloads a certificate with its private key. The route is admin only.
+// Query parameters: format=pem|zip|pkcs12 (default pem). The pkcs12 password is
+// read from the X-Export-Password header only, so it stays out of access logs.
+func (h *CertificateHandler) Export(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.CertExportFormatPEM)
+	password := c.GetHeader("X-Export-Password")
+
+	export, err := h.service.ExportCertificate(uint(id), format, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "certificate not found"})
+		case errors.Is(err, services.ErrCertExportFormat), errors.Is(err, services.ErrCertExportPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCertKeyUnavailable), errors.Is(err, services.ErrCertExportInvalidData):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export certificate"})
+		}
+		return
+	}
+
+	if h.auditService != nil {
+		actor := ""
+		if userID, ok := c.Get("userID"); ok {
+			actor = fmt.Sprintf("%v", userID)
+		}
+		if actor == "" {
+			actor = c.ClientIP()
+		}
+		_ = h.auditService.LogAudit(&models.SecurityAudit{
+			Actor:   actor,
+			Action:  "export_certificate",
+			Details: fmt.Sprintf("id=%d name=%s format=%s", id, util.SanitizeForLog(export.Certificate.Name), util.SanitizeForLog(format)),
+		})
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// CertificateIssuanceHandler exposes ACME issuance status and pre-flight checks.
+type CertificateIssuanceHandler struct {
+	service *services.CertificateIssuanceService
+}
+
+// NewCertificateIssuanceHandler creates a new issuance handler.
+func NewCertificateIssuanceHandler(service *services.CertificateIssuanceService) *CertificateIssuanceHandler {
+	return &CertificateIssuanceHandler{service: service}
+}
+
+// RegisterRoutes registers the issuance status routes. Preflight sends requests
+// to the domains it is given and is registered separately as an admin route.
+func (h *CertificateIssuanceHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/certificates/issuance", h.List)
+	router.GET("/certificates/issuance/:domain", h.Get)
+}
+
+// List returns the issuance status for every domain Caddy has tried to obtain a certificate for.
+func (h *CertificateIssuanceHandler) List(c *gin.Context) {
+	// Pick up any events written since the last background poll
+	_ = h.service.PollLog()
+
+	list, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list issuance status"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Get returns the issuance status of one domain.
+func (h *CertificateIssuanceHandler) Get(c *gin.Context) {
+	_ = h.service.PollLog()
+
+	rec, err := h.service.Get(c.Param("domain"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no issuance attempts recorded for domain"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get issuance status"})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// Preflight checks that domains resolve and that the HTTP-01 challenge path reaches this instance.
+func (h *CertificateIssuanceHandler) Preflight(c *gin.Context) {
+	var req struct {
+		Domain      string `json:"domain"`
+		DomainNames string `json:"domain_names"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	domains := req.DomainNames
+	if domains == "" {
+		domains = req.Domain
+	}
+	if domains == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "domain or domain_names is required"})
+		return
+	}
+
+	results, ok := h.service.PreflightDomains(c.Request.Context(), domains)
+	c.JSON(http.StatusOK, gin.H{"ok": ok, "results": results})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// ClientCAHandler manages certificate authorities for client certificate (mTLS) verification.
+type ClientCAHandler struct {
+	service *services.ClientCAService
+}
+
+// NewClientCAHandler creates a new client CA handler.
+func NewClientCAHandler(service *services.ClientCAService) *ClientCAHandler {
+	return &ClientCAHandler{service: service}
+}
+
+// RegisterRoutes registers client CA routes.
+func (h *ClientCAHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/client-cas", h.List)
+	router.POST("/client-cas", h.Upload)
+	router.DELETE("/client-cas/:id", h.Delete)
+}
+
+// List returns all client CAs.
+func (h *ClientCAHandler) List(c *gin.Context) {
+	cas, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list client CAs"})
+		return
+	}
+	c.JSON(http.StatusOK, cas)
+}
+
+// Upload stores a PEM bundle of CA certificates (multipart: name, certificate_file).
+func (h *ClientCAHandler) Upload(c *gin.Context) {
+	name := c.PostForm("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	file, err := c.FormFile("certificate_file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "certificate_file is required"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open certificate file"})
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	// Limit size to avoid DoS (1MB)
+	data, err := io.ReadAll(io.LimitReader(src, 1024*1024))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read certificate file"})
+		return
+	}
+
+	ca, err := h.service.Create(name, string(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, ca)
+}
+
+// Delete removes a client CA that is not used by any proxy host.
+func (h *ClientCAHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrClientCAInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "client CA not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete client CA"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "client CA deleted"})
+}
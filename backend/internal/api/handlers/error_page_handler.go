@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// ErrorPageHandler handles CRUD operations for custom error pages.
+type ErrorPageHandler struct {
+	service      *services.ErrorPageService
+	caddyManager *caddy.Manager
+}
+
+// NewErrorPageHandler creates a new error page handler.
+func NewErrorPageHandler(service *services.ErrorPageService, caddyManager *caddy.Manager) *ErrorPageHandler {
+	return &ErrorPageHandler{service: service, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers error page routes.
+func (h *ErrorPageHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/error-pages", h.List)
+	router.POST("/error-pages", h.Create)
+	router.GET("/error-pages/:uuid", h.Get)
+	router.PUT("/error-pages/:uuid", h.Update)
+	router.DELETE("/error-pages/:uuid", h.Delete)
+}
+
+// List returns all error pages.
+func (h *ErrorPageHandler) List(c *gin.Context) {
+	pages, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list error pages"})
+		return
+	}
+	c.JSON(http.StatusOK, pages)
+}
+
+// Create stores a new error page and applies the config.
+func (h *ErrorPageHandler) Create(c *gin.Context) {
+	var page models.ErrorPage
+	if err := c.ShouldBindJSON(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Create(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !apply(c, h.caddyManager) {
+		// Rollback: an error page Caddy rejects must not stay behind
+		if err := h.service.Delete(page.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("error_page", page.UUID).WithError(err).Error("Critical: Failed to rollback error page")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, page)
+}
+
+// Get returns an error page by UUID.
+func (h *ErrorPageHandler) Get(c *gin.Context) {
+	page, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// Update merges the body into an error page, keeping its ID and UUID. The
+// status code must stay unique within the page's scope, global or one proxy
+// host, and the body may only use the known placeholders.
+func (h *ErrorPageHandler) Update(c *gin.Context) {
+	page, ok := h.find(c)
+	if !ok {
+		return
+	}
+	id, uuid, createdAt := page.ID, page.UUID, page.CreatedAt
+	if err := c.ShouldBindJSON(page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page.ID, page.UUID, page.CreatedAt = id, uuid, createdAt
+
+	if err := h.service.Update(page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// Delete removes an error page and applies the config.
+func (h *ErrorPageHandler) Delete(c *gin.Context) {
+	page, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(page.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete error page"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "error page deleted"})
+}
+
+// find loads the error page named in the URL, writing a 404 if it does not exist.
+func (h *ErrorPageHandler) find(c *gin.Context) (*models.ErrorPage, bool) {
+	page, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrErrorPageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "error page not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return page, true
+}
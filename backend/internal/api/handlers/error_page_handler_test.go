@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupErrorPageTestRouter registers the error page routes over an in-memory
+// database. Changes are pushed to the Caddy admin API at caddyURL unless it is empty.
+func setupErrorPageTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ErrorPage{}, &models.ProxyHost{}, &models.Location{}))
+
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	}
+	r := gin.New()
+	NewErrorPageHandler(services.NewErrorPageService(db), manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db
+}
+
+func postErrorPage(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/error-pages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestErrorPageHandler_OnePagePerStatusAndScope(t *testing.T) {
+	router, db := setupErrorPageTestRouter(t, "")
+	app := models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}
+	require.NoError(t, db.Create(&app).Error)
+
+	resp := postErrorPage(router, `{"status_code":502,"body":"down"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	resp = postErrorPage(router, `{"status_code":502,"body":"again"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "a global error page for 502 already exists")
+
+	// A host may override the global page once
+	hostPage := fmt.Sprintf(`{"status_code":502,"proxy_host_id":%d,"body":"app is down"}`, app.ID)
+	resp = postErrorPage(router, hostPage)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	resp = postErrorPage(router, hostPage)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), fmt.Sprintf("proxy host %d already has an error page for 502", app.ID))
+
+	resp = postErrorPage(router, `{"status_code":503,"proxy_host_id":99,"body":"x"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "proxy host 99 not found")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/error-pages", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var list []models.ErrorPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	assert.Nil(t, list[0].ProxyHostID, "global pages come first")
+}
+
+func TestErrorPageHandler_UpdateChecksBody(t *testing.T) {
+	router, _ := setupErrorPageTestRouter(t, "")
+	resp := postErrorPage(router, `{"status_code":502,"body":"down"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var page models.ErrorPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"body":"{{oops}}"}`, "unknown placeholder {{oops}}"},
+		{`{"body":""}`, "body is required"},
+		{`{"status_code":418}`, "status_code must be one of"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/error-pages/"+page.UUID, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, tt.body)
+		assert.Contains(t, resp.Body.String(), tt.want)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/error-pages/"+page.UUID, strings.NewReader(`{"body":"Back soon ({{request_id}})"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.ErrorPage
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.Equal(t, 502, updated.StatusCode, "fields left out of the body are kept")
+}
+
+func TestErrorPageHandler_AppliesPlaceholders(t *testing.T) {
+	loads := make(chan string, 1)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" && r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			loads <- string(body)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	router, db := setupErrorPageTestRouter(t, caddyServer.URL)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+
+	resp := postErrorPage(router, `{"status_code":502,"body":"<h1>{{host}} is unavailable</h1>"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	loaded := <-loads
+	assert.Contains(t, loaded, `"errors":{"routes":[`)
+	assert.Contains(t, loaded, `{http.request.host} is unavailable`)
+}
@@ -25,6 +25,8 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	db.AutoMigrate(
 		&models.ProxyHost{},
 		&models.Location{},
+		&models.RedirectionHost{},
+		&models.StaticSite{},
 		&models.RemoteServer{},
 		&models.ImportSession{},
 		&models.Notification{},
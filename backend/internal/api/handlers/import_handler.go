@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -25,6 +26,7 @@ import (
 type ImportHandler struct {
 	db              *gorm.DB
 	proxyHostSvc    *services.ProxyHostService
+	redirectSvc     *services.RedirectionHostService
 	importerservice *caddy.Importer
 	importDir       string
 	mountPath       string
@@ -35,6 +37,7 @@ func NewImportHandler(db *gorm.DB, caddyBinary, importDir, mountPath string) *Im
 	return &ImportHandler{
 		db:              db,
 		proxyHostSvc:    services.NewProxyHostService(db),
+		redirectSvc:     services.NewRedirectionHostService(db),
 		importerservice: caddy.NewImporter(caddyBinary),
 		importDir:       importDir,
 		mountPath:       mountPath,
@@ -52,6 +55,14 @@ func (h *ImportHandler) RegisterRoutes(router *gin.RouterGroup) {
 	router.DELETE("/import/cancel", h.Cancel)
 }
 
+// RegisterAdminRoutes registers the imports of whole configurations from other
+// proxies. They stage access lists with their users, certificates with their
+// private keys and redirection hosts, so the router must require an admin login.
+func (h *ImportHandler) RegisterAdminRoutes(router *gin.RouterGroup) {
+	router.POST("/import/npm", h.UploadNPM)
+	router.POST("/import/config", h.UploadConfig)
+}
+
 // GetStatus returns current import session status.
 func (h *ImportHandler) GetStatus(c *gin.Context) {
 	var session models.ImportSession
@@ -131,7 +142,7 @@ func (h *ImportHandler) GetPreview(c *gin.Context) {
 
 			// Read original Caddyfile content if available
 			var caddyfileContent string
-			if session.SourceFile != "" {
+			if session.SourceFile != "" && session.Source != "npm" {
 				if content, err := os.ReadFile(session.SourceFile); err == nil {
 					caddyfileContent = string(content)
 				} else {
@@ -148,9 +159,10 @@ func (h *ImportHandler) GetPreview(c *gin.Context) {
 					"state":       session.Status,
 					"created_at":  session.CreatedAt,
 					"updated_at":  session.UpdatedAt,
+					"source":      session.Source,
 					"source_file": session.SourceFile,
 				},
-				"preview":           result,
+				"preview":           result.Redacted(),
 				"caddyfile_content": caddyfileContent,
 			})
 			return
@@ -195,35 +207,7 @@ func (h *ImportHandler) GetPreview(c *gin.Context) {
 			}
 
 			// Check for conflicts with existing hosts and build conflict details
-			existingHosts, _ := h.proxyHostSvc.List()
-			existingDomainsMap := make(map[string]models.ProxyHost)
-			for _, eh := range existingHosts {
-				existingDomainsMap[eh.DomainNames] = eh
-			}
-
-			conflictDetails := make(map[string]gin.H)
-			for _, ph := range transient.Hosts {
-				if existing, found := existingDomainsMap[ph.DomainNames]; found {
-					transient.Conflicts = append(transient.Conflicts, ph.DomainNames)
-					conflictDetails[ph.DomainNames] = gin.H{
-						"existing": gin.H{
-							"forward_scheme": existing.ForwardScheme,
-							"forward_host":   existing.ForwardHost,
-							"forward_port":   existing.ForwardPort,
-							"ssl_forced":     existing.SSLForced,
-							"websocket":      existing.WebsocketSupport,
-							"enabled":        existing.Enabled,
-						},
-						"imported": gin.H{
-							"forward_scheme": ph.ForwardScheme,
-							"forward_host":   ph.ForwardHost,
-							"forward_port":   ph.ForwardPort,
-							"ssl_forced":     ph.SSLForced,
-							"websocket":      ph.WebsocketSupport,
-						},
-					}
-				}
-			}
+			conflictDetails := h.detectConflicts(transient)
 
 			c.JSON(http.StatusOK, gin.H{
 				"session":           gin.H{"id": sid, "state": "transient", "source_file": h.mountPath},
@@ -320,14 +304,37 @@ func (h *ImportHandler) Upload(c *gin.Context) {
 	}
 
 	// Check for conflicts with existing hosts and build conflict details
+	conflictDetails := h.detectConflicts(result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session":          gin.H{"id": sid, "state": "transient", "source_file": tempPath},
+		"conflict_details": conflictDetails,
+		"preview":          result,
+	})
+}
+
+// detectConflicts records imported hosts whose domains already exist in result.Conflicts
+// and returns both sides of each conflict for review.
+func (h *ImportHandler) detectConflicts(result *caddy.ImportResult) map[string]gin.H {
 	existingHosts, _ := h.proxyHostSvc.List()
 	existingDomainsMap := make(map[string]models.ProxyHost)
 	for _, eh := range existingHosts {
 		existingDomainsMap[eh.DomainNames] = eh
 	}
+	existingRedirects, _ := h.redirectSvc.List()
+	existingRedirectsMap := make(map[string]bool)
+	for _, er := range existingRedirects {
+		existingRedirectsMap[er.DomainNames] = true
+	}
 
 	conflictDetails := make(map[string]gin.H)
 	for _, ph := range result.Hosts {
+		// The review table compares proxy settings only, so redirection host
+		// conflicts are listed without details
+		if existingRedirectsMap[ph.DomainNames] {
+			result.Conflicts = append(result.Conflicts, ph.DomainNames)
+			continue
+		}
 		if existing, found := existingDomainsMap[ph.DomainNames]; found {
 			result.Conflicts = append(result.Conflicts, ph.DomainNames)
 			conflictDetails[ph.DomainNames] = gin.H{
@@ -349,9 +356,163 @@ func (h *ImportHandler) Upload(c *gin.Context) {
 			}
 		}
 	}
+	return conflictDetails
+}
+
+// UploadNPM imports an Nginx Proxy Manager database (data/database.sqlite or a
+// MySQL/SQLite dump, form field "database") with optional data/nginx/custom/*.conf
+// snippets (form field "snippets"). The result is stored as a pending session and
+// goes through the same preview, conflict and commit flow as a Caddyfile.
+func (h *ImportHandler) UploadNPM(c *gin.Context) {
+	dbFile, err := c.FormFile("database")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "database file is required"})
+		return
+	}
+
+	sid := uuid.NewString()
+	uploadsDir, err := safeJoin(h.importDir, "uploads")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid import directory"})
+		return
+	}
+	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create uploads directory"})
+		return
+	}
+	dbPath, err := safeJoin(uploadsDir, fmt.Sprintf("%s.npm", sid))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid temp path"})
+		return
+	}
+	if err := c.SaveUploadedFile(dbFile, dbPath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write upload"})
+		return
+	}
+
+	snippets := make(map[string]string)
+	if form, err := c.MultipartForm(); err == nil {
+		for _, f := range form.File["snippets"] {
+			src, err := f.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read snippet %s", filepath.Base(f.Filename))})
+				return
+			}
+			content, err := io.ReadAll(io.LimitReader(src, 1024*1024))
+			_ = src.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read snippet %s", filepath.Base(f.Filename))})
+				return
+			}
+			snippets[filepath.Base(f.Filename)] = string(content)
+		}
+	}
+
+	result, err := caddy.ImportNPM(caddy.NPMSource{Database: dbPath, Snippets: snippets})
+	if err != nil {
+		middleware.GetRequestLogger(c).WithError(err).WithField("filename", util.SanitizeForLog(filepath.Base(dbFile.Filename))).Error("Import NPM: import failed")
+		_ = os.Remove(dbPath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("import failed: %v", err)})
+		return
+	}
+	if len(result.Hosts) == 0 {
+		_ = os.Remove(dbPath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no hosts found in Nginx Proxy Manager database"})
+		return
+	}
+
+	conflictDetails := h.detectConflicts(result)
+	session := models.ImportSession{
+		UUID:           sid,
+		Source:         "npm",
+		SourceFile:     dbPath,
+		Status:         "pending",
+		ParsedData:     string(mustMarshal(result)),
+		ConflictReport: string(mustMarshal(result.Conflicts)),
+	}
+	if err := h.db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save import session"})
+		return
+	}
+	middleware.GetRequestLogger(c).WithField("hosts", len(result.Hosts)).WithField("conflicts", len(result.Conflicts)).Info("Import NPM: session created")
 
 	c.JSON(http.StatusOK, gin.H{
-		"session":          gin.H{"id": sid, "state": "transient", "source_file": tempPath},
+		"session":          gin.H{"id": sid, "state": session.Status, "source": session.Source, "source_file": dbPath},
+		"conflict_details": conflictDetails,
+		"preview":          result.Redacted(),
+	})
+}
+
+// UploadConfig imports a Traefik file-provider config (YAML/TOML) or nginx server
+// blocks into a pending session for the regular preview, conflict and commit flow.
+func (h *ImportHandler) UploadConfig(c *gin.Context) {
+	var req struct {
+		Format   string `json:"format" binding:"required,oneof=traefik nginx"`
+		Content  string `json:"content" binding:"required"`
+		Filename string `json:"filename"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result *caddy.ImportResult
+	var err error
+	ext := "conf"
+	switch req.Format {
+	case "traefik":
+		ext = caddy.TraefikFormat(req.Filename, []byte(req.Content))
+		result, err = caddy.ImportTraefik([]byte(req.Content), ext)
+	case "nginx":
+		result, err = caddy.ImportNginx(req.Content)
+	}
+	if err != nil {
+		middleware.GetRequestLogger(c).WithError(err).WithField("format", req.Format).Error("Import Config: import failed")
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("import failed: %v", err)})
+		return
+	}
+	if len(result.Hosts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("no hosts found in %s config", req.Format), "warnings": result.Warnings})
+		return
+	}
+
+	// Keep the upload so the preview can show the original config
+	sid := uuid.NewString()
+	uploadsDir, err := safeJoin(h.importDir, "uploads")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid import directory"})
+		return
+	}
+	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create uploads directory"})
+		return
+	}
+	sourcePath, err := safeJoin(uploadsDir, fmt.Sprintf("%s.%s", sid, ext))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid temp path"})
+		return
+	}
+	if err := os.WriteFile(sourcePath, []byte(req.Content), 0644); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write upload"})
+		return
+	}
+
+	conflictDetails := h.detectConflicts(result)
+	session := models.ImportSession{
+		UUID:           sid,
+		Source:         req.Format,
+		SourceFile:     sourcePath,
+		Status:         "pending",
+		ParsedData:     string(mustMarshal(result)),
+		ConflictReport: string(mustMarshal(result.Conflicts)),
+	}
+	if err := h.db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save import session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session":          gin.H{"id": sid, "state": session.Status, "source": session.Source, "source_file": sourcePath},
 		"conflict_details": conflictDetails,
 		"preview":          result,
 	})
@@ -580,7 +741,7 @@ func (h *ImportHandler) Commit(c *gin.Context) {
 		return
 	}
 	var result *caddy.ImportResult
-	if err := h.db.Where("uuid = ? AND status = ?", sid, "reviewing").First(&session).Error; err == nil {
+	if err := h.db.Where("uuid = ? AND status IN ?", sid, []string{"pending", "reviewing"}).First(&session).Error; err == nil {
 		// DB session found
 		if err := json.Unmarshal([]byte(session.ParsedData), &result); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse import data"})
@@ -664,8 +825,16 @@ func (h *ImportHandler) Commit(c *gin.Context) {
 			if existing, found := existingMap[host.DomainNames]; found {
 				host.ID = existing.ID
 				host.UUID = existing.UUID
-				host.CertificateID = existing.CertificateID // Preserve certificate association
+				if host.Certificate == nil {
+					host.CertificateID = existing.CertificateID // Preserve certificate association
+				}
 				host.CreatedAt = existing.CreatedAt
+				// Imported locations replace the existing ones
+				if len(host.Locations) > 0 {
+					if err := h.db.Where("proxy_host_id = ?", existing.ID).Delete(&models.Location{}).Error; err != nil {
+						middleware.GetRequestLogger(c).WithError(err).Warn("Import Commit: failed to remove existing locations")
+					}
+				}
 
 				if err := h.proxyHostSvc.Update(&host); err != nil {
 					errMsg := fmt.Sprintf("%s: %s", host.DomainNames, err.Error())
@@ -692,6 +861,49 @@ func (h *ImportHandler) Commit(c *gin.Context) {
 		}
 	}
 
+	// Redirect-only hosts become redirection hosts, resolved the same way
+	existingRedirects, _ := h.redirectSvc.List()
+	existingRedirectMap := make(map[string]*models.RedirectionHost)
+	for i := range existingRedirects {
+		existingRedirectMap[existingRedirects[i].DomainNames] = &existingRedirects[i]
+	}
+
+	for _, redirect := range caddy.ConvertToRedirectionHosts(result.Hosts) {
+		action := req.Resolutions[redirect.DomainNames]
+
+		if customName, ok := req.Names[redirect.DomainNames]; ok && customName != "" {
+			redirect.Name = customName
+		}
+
+		if action == "skip" || action == "keep" {
+			skipped++
+			continue
+		}
+
+		if action == "rename" {
+			redirect.DomainNames += "-imported"
+		}
+
+		var err error
+		if existing, found := existingRedirectMap[redirect.DomainNames]; found && action == "overwrite" {
+			redirect.ID = existing.ID
+			redirect.UUID = existing.UUID
+			redirect.CreatedAt = existing.CreatedAt
+			if err = h.redirectSvc.Update(&redirect); err == nil {
+				updated++
+			}
+		} else if err = h.redirectSvc.Create(&redirect); err == nil {
+			created++
+		}
+		if err != nil {
+			errMsg := fmt.Sprintf("%s: %s", redirect.DomainNames, err.Error())
+			errors = append(errors, errMsg)
+			middleware.GetRequestLogger(c).WithField("host", util.SanitizeForLog(redirect.DomainNames)).WithField("error", util.SanitizeForLog(errMsg)).Error("Import Commit Error (redirection host)")
+		} else {
+			middleware.GetRequestLogger(c).WithField("host", util.SanitizeForLog(redirect.DomainNames)).Info("Import Commit Success: Saved redirection host")
+		}
+	}
+
 	// Persist an import session record now that user confirmed
 	now := time.Now()
 	session.Status = "committed"
@@ -3,6 +3,7 @@ package handlers_test
 import (
 	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
@@ -26,7 +28,7 @@ func setupImportTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		panic("failed to connect to test database")
 	}
-	db.AutoMigrate(&models.ImportSession{}, &models.ProxyHost{}, &models.Location{})
+	db.AutoMigrate(&models.ImportSession{}, &models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}, &models.StaticSite{})
 	return db
 }
 
@@ -191,6 +193,208 @@ func TestImportHandler_Commit(t *testing.T) {
 	assert.Equal(t, "committed", updatedSession.Status)
 }
 
+func TestImportHandler_Commit_LocationsAndAccessList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupImportTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.AccessList{}))
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", "")
+	router := gin.New()
+	router.POST("/import/commit", handler.Commit)
+
+	session := models.ImportSession{
+		UUID:   "test-uuid",
+		Status: "reviewing",
+		ParsedData: `{"hosts": [{"domain_names": "example.com", "forward_host": "app", "forward_port": 8080,
+			"locations": [{"path": "/api", "forward_scheme": "http", "forward_host": "api", "forward_port": 9000}],
+			"access_list": {"type": "whitelist", "cidrs": ["10.0.0.0/8"]}}]}`,
+	}
+	db.Create(&session)
+
+	body, _ := json.Marshal(map[string]interface{}{"session_uuid": "test-uuid"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import/commit", bytes.NewBuffer(body))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var host models.ProxyHost
+	require.NoError(t, db.Preload("Locations").Preload("AccessList").Where("domain_names = ?", "example.com").First(&host).Error)
+	require.Len(t, host.Locations, 1)
+	assert.Equal(t, "/api", host.Locations[0].Path)
+	require.NotNil(t, host.AccessList)
+	assert.Equal(t, "whitelist", host.AccessList.Type)
+	assert.Contains(t, host.AccessList.IPRules, "10.0.0.0/8")
+}
+
+func TestImportHandler_UploadNPM(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupImportTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.AccessList{}, &models.SSLCertificate{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "existing", DomainNames: "b.example.com", ForwardHost: "old", ForwardPort: 80}).Error)
+	handler := handlers.NewImportHandler(db, "echo", t.TempDir(), "")
+	router := gin.New()
+	router.POST("/import/npm", handler.UploadNPM)
+	router.GET("/import/preview", handler.GetPreview)
+	router.POST("/import/commit", handler.Commit)
+
+	dump := `CREATE TABLE proxy_host (id integer, is_deleted integer, domain_names json, forward_scheme varchar(255), forward_host varchar(255), forward_port integer, access_list_id integer, certificate_id integer);
+INSERT INTO proxy_host VALUES(1,0,'["a.example.com"]','http','a',8080,1,1);
+INSERT INTO proxy_host VALUES(2,0,'["b.example.com"]','http','b',8080,1,1);
+CREATE TABLE access_list (id integer, is_deleted integer, name varchar(255));
+INSERT INTO access_list VALUES(1,0,'Office');
+CREATE TABLE access_list_client (id integer, access_list_id integer, address varchar(255), directive varchar(255));
+INSERT INTO access_list_client VALUES(1,1,'10.0.0.0/8','allow');
+CREATE TABLE certificate (id integer, is_deleted integer, provider varchar(255), nice_name varchar(255), meta json);
+INSERT INTO certificate VALUES(1,0,'other','Corp','{"certificate":"CERT PEM","certificate_key":"KEY PEM"}');
+`
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("database", "npm.sql")
+	require.NoError(t, err)
+	_, _ = part.Write([]byte(dump))
+	part, err = mw.CreateFormFile("snippets", "http_top.conf")
+	require.NoError(t, err)
+	_, _ = part.Write([]byte("map $a $b {}"))
+	require.NoError(t, mw.Close())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import/npm", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "KEY PEM")
+
+	var resp struct {
+		Session struct {
+			ID    string `json:"id"`
+			State string `json:"state"`
+		} `json:"session"`
+		Preview struct {
+			Conflicts []string `json:"conflicts"`
+			Warnings  []string `json:"warnings"`
+		} `json:"preview"`
+		ConflictDetails map[string]interface{} `json:"conflict_details"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "pending", resp.Session.State)
+	assert.Equal(t, []string{"b.example.com"}, resp.Preview.Conflicts)
+	assert.Contains(t, resp.ConflictDetails, "b.example.com")
+	assert.Equal(t, []string{"custom nginx snippet http_top.conf is not converted"}, resp.Preview.Warnings)
+
+	// The session goes through the regular preview
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/import/preview", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"source":"npm"`)
+	assert.Contains(t, w.Body.String(), `"caddyfile_content":""`)
+	assert.NotContains(t, w.Body.String(), "KEY PEM")
+
+	commit, _ := json.Marshal(map[string]interface{}{
+		"session_uuid": resp.Session.ID,
+		"resolutions":  map[string]string{"b.example.com": "overwrite"},
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/import/commit", bytes.NewBuffer(commit))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"created":1`)
+	assert.Contains(t, w.Body.String(), `"updated":1`)
+
+	var hosts []models.ProxyHost
+	require.NoError(t, db.Preload("AccessList").Preload("Certificate").Order("domain_names").Find(&hosts).Error)
+	require.Len(t, hosts, 2)
+	assert.Equal(t, "b", hosts[1].ForwardHost)
+	for _, h := range hosts {
+		require.NotNil(t, h.AccessList, h.DomainNames)
+		assert.Equal(t, "Office", h.AccessList.Name)
+		require.NotNil(t, h.Certificate, h.DomainNames)
+		assert.Equal(t, "KEY PEM", h.Certificate.PrivateKey)
+	}
+	assert.Equal(t, *hosts[0].AccessListID, *hosts[1].AccessListID, "hosts share the imported access list")
+	assert.Equal(t, *hosts[0].CertificateID, *hosts[1].CertificateID, "hosts share the imported certificate")
+}
+
+func TestImportHandler_UploadConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupImportTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.AccessList{}))
+	handler := handlers.NewImportHandler(db, "echo", t.TempDir(), "")
+	router := gin.New()
+	router.POST("/import/config", handler.UploadConfig)
+	router.GET("/import/preview", handler.GetPreview)
+	router.POST("/import/commit", handler.Commit)
+
+	nginx := `server {
+    server_name app.example.com;
+    location / { proxy_pass http://app:3000; }
+    location /api { proxy_pass http://api:9000; }
+    gzip on;
+}`
+	payload, _ := json.Marshal(map[string]string{"format": "nginx", "content": nginx, "filename": "app.conf"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import/config", bytes.NewBuffer(payload))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Session struct {
+			ID     string `json:"id"`
+			State  string `json:"state"`
+			Source string `json:"source"`
+		} `json:"session"`
+		Preview struct {
+			Hosts []struct {
+				DomainNames string `json:"domain_names"`
+				Report      []struct {
+					Directive string `json:"directive"`
+					Status    string `json:"status"`
+				} `json:"report"`
+			} `json:"hosts"`
+		} `json:"preview"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "pending", resp.Session.State)
+	assert.Equal(t, "nginx", resp.Session.Source)
+	require.Len(t, resp.Preview.Hosts, 1)
+	assert.Equal(t, "gzip", resp.Preview.Hosts[0].Report[len(resp.Preview.Hosts[0].Report)-1].Directive)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/import/preview", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"source":"nginx"`)
+	assert.Contains(t, w.Body.String(), "proxy_pass http://app:3000")
+
+	commit, _ := json.Marshal(map[string]interface{}{"session_uuid": resp.Session.ID})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/import/commit", bytes.NewBuffer(commit))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var host models.ProxyHost
+	require.NoError(t, db.Preload("Locations").First(&host).Error)
+	assert.Equal(t, "app", host.ForwardHost)
+	require.Len(t, host.Locations, 1)
+	assert.Equal(t, "/api", host.Locations[0].Path)
+
+	t.Run("rejects configs without hosts", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{"format": "traefik", "content": "tcp:\n  routers:\n    db: {rule: \"HostSNI(`*`)\"}\n"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/import/config", bytes.NewBuffer(payload))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "TCP router")
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]string{"format": "haproxy", "content": "frontend a"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/import/config", bytes.NewBuffer(payload))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
 func TestImportHandler_Upload(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupImportTestDB(t)
@@ -897,3 +1101,53 @@ func TestImportHandler_UploadMulti(t *testing.T) {
 		assert.Contains(t, resp["error"], "empty")
 	})
 }
+
+func TestImportHandler_Commit_RedirectionHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupImportTestDB(t)
+	handler := handlers.NewImportHandler(db, "echo", "/tmp", "")
+	router := gin.New()
+	router.POST("/import/commit", handler.Commit)
+
+	existing := models.RedirectionHost{UUID: "r1", DomainNames: "old.example.com", TargetURL: "https://stale.example.com", ForwardScheme: "auto", ForwardHTTPCode: 302, Enabled: true}
+	require.NoError(t, db.Create(&existing).Error)
+
+	session := models.ImportSession{
+		UUID:   "redirect-uuid",
+		Status: "reviewing",
+		ParsedData: `{"hosts": [
+			{"domain_names": "old.example.com", "redirect": {"target_url": "https://new.example.com", "forward_http_code": 301, "preserve_path": true}},
+			{"domain_names": "legacy.example.com", "ssl_forced": true, "redirect": {"forward_scheme": "auto", "forward_domain_name": "new.example.com", "forward_http_code": 308}}
+		]}`,
+	}
+	require.NoError(t, db.Create(&session).Error)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"session_uuid": "redirect-uuid",
+		"resolutions":  map[string]string{"old.example.com": "overwrite"},
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/import/commit", bytes.NewBuffer(body))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(1), resp["created"])
+	assert.Equal(t, float64(1), resp["updated"])
+
+	var redirects []models.RedirectionHost
+	require.NoError(t, db.Order("id").Find(&redirects).Error)
+	require.Len(t, redirects, 2)
+	assert.Equal(t, "r1", redirects[0].UUID)
+	assert.Equal(t, "https://new.example.com", redirects[0].TargetURL)
+	assert.Equal(t, 301, redirects[0].ForwardHTTPCode)
+	assert.True(t, redirects[0].PreservePath)
+	assert.Equal(t, "legacy.example.com", redirects[1].DomainNames)
+	assert.Equal(t, "new.example.com", redirects[1].ForwardDomainName)
+	assert.True(t, redirects[1].SSLForced)
+
+	var proxies int64
+	db.Model(&models.ProxyHost{}).Count(&proxies)
+	assert.Zero(t, proxies, "redirect-only hosts are not proxy hosts")
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// MaintenanceWindowHandler handles CRUD operations for maintenance windows.
+type MaintenanceWindowHandler struct {
+	service *services.MaintenanceService
+}
+
+// NewMaintenanceWindowHandler creates a new maintenance window handler. The
+// service applies the config, so that its scheduler knows what Caddy runs.
+func NewMaintenanceWindowHandler(service *services.MaintenanceService) *MaintenanceWindowHandler {
+	return &MaintenanceWindowHandler{service: service}
+}
+
+// RegisterRoutes registers maintenance window routes.
+func (h *MaintenanceWindowHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/maintenance-windows", h.List)
+	router.POST("/maintenance-windows", h.Create)
+	router.GET("/maintenance-windows/:uuid", h.Get)
+	router.PUT("/maintenance-windows/:uuid", h.Update)
+	router.DELETE("/maintenance-windows/:uuid", h.Delete)
+}
+
+// List returns all maintenance windows.
+func (h *MaintenanceWindowHandler) List(c *gin.Context) {
+	windows, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list maintenance windows"})
+		return
+	}
+	c.JSON(http.StatusOK, windows)
+}
+
+// Create stores a new maintenance window and applies the config.
+func (h *MaintenanceWindowHandler) Create(c *gin.Context) {
+	var window models.MaintenanceWindow
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Create(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !apply(c, h.service) {
+		// Rollback: a window Caddy rejects must not stay behind
+		if err := h.service.Delete(window.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("maintenance_window", window.UUID).WithError(err).Error("Critical: Failed to rollback maintenance window")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, window)
+}
+
+// Get returns a maintenance window by UUID.
+func (h *MaintenanceWindowHandler) Get(c *gin.Context) {
+	window, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, window)
+}
+
+// Update merges the body into a maintenance window, keeping its ID and UUID,
+// and checks its schedule and allowed IPs again. The config is applied through
+// the service, so an edit that starts or ends the window takes effect right
+// away. The response's active field tells whether the window is on now.
+func (h *MaintenanceWindowHandler) Update(c *gin.Context) {
+	window, ok := h.find(c)
+	if !ok {
+		return
+	}
+	id, uuid, createdAt := window.ID, window.UUID, window.CreatedAt
+	if err := c.ShouldBindJSON(window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window.ID, window.UUID, window.CreatedAt = id, uuid, createdAt
+
+	if err := h.service.Update(window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.service) {
+		return
+	}
+	c.JSON(http.StatusOK, window)
+}
+
+// Delete removes a maintenance window and applies the config.
+func (h *MaintenanceWindowHandler) Delete(c *gin.Context) {
+	window, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(window.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete maintenance window"})
+		return
+	}
+	if !apply(c, h.service) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "maintenance window deleted"})
+}
+
+// find loads the maintenance window named in the URL, writing a 404 if it does not exist.
+func (h *MaintenanceWindowHandler) find(c *gin.Context) (*models.MaintenanceWindow, bool) {
+	window, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrMaintenanceWindowNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "maintenance window not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return window, true
+}
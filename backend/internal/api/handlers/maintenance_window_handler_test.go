@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// recordingApplier stands in for the Caddy manager behind the maintenance service.
+type recordingApplier struct {
+	calls int
+	err   error
+}
+
+func (a *recordingApplier) ApplyConfig(context.Context) error {
+	a.calls++
+	return a.err
+}
+
+// setupMaintenanceWindowTestRouter registers the maintenance window routes over
+// an in-memory database holding proxy host 1.
+func setupMaintenanceWindowTestRouter(t *testing.T, applier services.ConfigApplier) (*gin.Engine, *gorm.DB, *services.MaintenanceService) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.MaintenanceWindow{}, &models.ProxyHost{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}).Error)
+
+	service := services.NewMaintenanceService(db, applier)
+	r := gin.New()
+	NewMaintenanceWindowHandler(service).RegisterRoutes(r.Group("/api/v1"))
+	return r, db, service
+}
+
+func sendMaintenanceWindow(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestMaintenanceWindowHandler_OneOffWindows(t *testing.T) {
+	router, _, _ := setupMaintenanceWindowTestRouter(t, nil)
+
+	resp := sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", `{"proxy_host_id":1,"name":"Upgrade"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var now models.MaintenanceWindow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &now))
+	assert.NotNil(t, now.StartsAt)
+	assert.True(t, now.Active, "a window without starts_at starts right away")
+
+	tomorrow := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	resp = sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", `{"proxy_host_id":1,"starts_at":"`+tomorrow+`"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var later models.MaintenanceWindow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &later))
+	assert.False(t, later.Active)
+
+	// Postponing the window takes the host out of maintenance
+	resp = sendMaintenanceWindow(router, http.MethodPut, "/api/v1/maintenance-windows/"+now.UUID, `{"starts_at":"`+tomorrow+`"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var postponed models.MaintenanceWindow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &postponed))
+	assert.Equal(t, "Upgrade", postponed.Name, "fields left out of the body are kept")
+	assert.False(t, postponed.Active)
+}
+
+func TestMaintenanceWindowHandler_Validation(t *testing.T) {
+	router, _, _ := setupMaintenanceWindowTestRouter(t, nil)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing host", `{"proxy_host_id":99}`, "proxy host 99 not found"},
+		{"bad schedule", `{"proxy_host_id":1,"schedule":"sometimes","duration_minutes":30}`, "invalid schedule"},
+		{"schedule without duration", `{"proxy_host_id":1,"schedule":"0 2 * * 0"}`, "duration_minutes must be between 1 and"},
+		{"duration without schedule", `{"proxy_host_id":1,"duration_minutes":30}`, "duration_minutes needs a schedule"},
+		{"ends before start", `{"proxy_host_id":1,"starts_at":"2026-01-02T00:00:00Z","ends_at":"2026-01-01T00:00:00Z"}`, "ends_at must be after starts_at"},
+		{"allow_ips", `{"proxy_host_id":1,"allow_ips":"office"}`, `allow_ips: \"office\" is not an IP address or CIDR`},
+		{"placeholder", `{"proxy_host_id":1,"body":"{{oops}}"}`, "unknown placeholder {{oops}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", tt.body)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.want)
+		})
+	}
+
+	resp := sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", `{"proxy_host_id":1,"schedule":"0 2 * * 0","duration_minutes":60}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var weekly models.MaintenanceWindow
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &weekly))
+	assert.Nil(t, weekly.StartsAt, "scheduled windows do not start right away")
+}
+
+func TestMaintenanceWindowHandler_AppliesThroughService(t *testing.T) {
+	applier := &recordingApplier{}
+	router, _, service := setupMaintenanceWindowTestRouter(t, applier)
+
+	resp := sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", `{"proxy_host_id":1}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	assert.Equal(t, 1, applier.calls)
+
+	// The scheduler knows the window is already applied
+	require.NoError(t, service.Sync(context.Background()))
+	assert.Equal(t, 1, applier.calls)
+}
+
+func TestMaintenanceWindowHandler_CreateRolledBackWhenApplyFails(t *testing.T) {
+	applier := &recordingApplier{err: errors.New("caddy unavailable")}
+	router, db, _ := setupMaintenanceWindowTestRouter(t, applier)
+
+	resp := sendMaintenanceWindow(router, http.MethodPost, "/api/v1/maintenance-windows", `{"proxy_host_id":1}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Contains(t, resp.Body.String(), "caddy unavailable")
+
+	var count int64
+	db.Model(&models.MaintenanceWindow{}).Count(&count)
+	assert.Zero(t, count)
+}
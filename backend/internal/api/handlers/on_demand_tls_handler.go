@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/services"
+	"github.com/Wikid82/charon/backend/internal/util"
+)
+
+// OnDemandTLSHandler serves the permission endpoint Caddy calls before on-demand issuance.
+type OnDemandTLSHandler struct {
+	service *services.OnDemandTLSService
+	// secret is part of the ask URL given to Caddy
+	secret string
+}
+
+// NewOnDemandTLSHandler creates a new on-demand TLS handler that only answers
+// requests carrying secret.
+func NewOnDemandTLSHandler(service *services.OnDemandTLSService, secret string) *OnDemandTLSHandler {
+	return &OnDemandTLSHandler{service: service, secret: secret}
+}
+
+// Ask answers Caddy's permission request (GET ?secret=&domain=). Caddy treats
+// any 2xx as approval and anything else as denial. Only local callers that
+// know the secret are accepted so that outsiders can't probe the allow list or
+// spend the hourly budget. The loopback check alone isn't enough: when the
+// Charon UI is itself proxied through Caddy, outside requests arrive from
+// 127.0.0.1.
+func (h *OnDemandTLSHandler) Ask(c *gin.Context) {
+	// RemoteAddr, not ClientIP: forwarded headers must not grant access
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission endpoint is only available to the local Caddy instance"})
+		return
+	}
+	if h.secret == "" || subtle.ConstantTimeCompare([]byte(c.Query("secret")), []byte(h.secret)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission endpoint is only available to the local Caddy instance"})
+		return
+	}
+
+	decision := h.service.Check(c.Query("domain"))
+	if !decision.Allowed {
+		logger.Log().WithField("domain", util.SanitizeForLog(decision.Domain)).WithField("reason", decision.Reason).Info("on-demand TLS issuance denied")
+		c.JSON(http.StatusForbidden, decision)
+		return
+	}
+	logger.Log().WithField("domain", util.SanitizeForLog(decision.Domain)).Info("on-demand TLS issuance approved")
+	c.JSON(http.StatusOK, decision)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+func TestOnDemandTLSHandler_Ask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}, &models.ProxyHost{}))
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.OnDemandTLSEnabledSettingKey, Value: "true"}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: caddy.OnDemandTLSDomainsSettingKey, Value: "*.customers.example.com"}).Error)
+
+	h := NewOnDemandTLSHandler(services.NewOnDemandTLSService(db), "s3cret")
+	r := gin.New()
+	r.GET("/api/v1/tls/ask", h.Ask)
+
+	ask := func(domain, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tls/ask?secret=s3cret&domain="+domain, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, ask("acme.customers.example.com", "127.0.0.1:50000").Code)
+	assert.Equal(t, http.StatusOK, ask("acme.customers.example.com", "[::1]:50000").Code)
+
+	w := ask("other.example.com", "127.0.0.1:50000")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "does not match")
+
+	// Remote callers are refused even for allowed names
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tls/ask?secret=s3cret&domain=acme.customers.example.com", nil)
+	req.RemoteAddr = "203.0.113.7:40000"
+	req.Header.Set("X-Forwarded-For", "127.0.0.1")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "local Caddy instance")
+
+	// Loopback callers without the secret are refused too: a Charon UI proxied
+	// through Caddy sees outside requests coming from 127.0.0.1
+	for _, query := range []string{"", "secret=wrong&"} {
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/tls/ask?"+query+"domain=acme.customers.example.com", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, query)
+	}
+}
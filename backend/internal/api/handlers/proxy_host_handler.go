@@ -23,6 +23,14 @@ type ProxyHostHandler struct {
 	caddyManager        *caddy.Manager
 	notificationService *services.NotificationService
 	uptimeService       *services.UptimeService
+	issuanceService     *services.CertificateIssuanceService
+	validationOpts      caddy.ValidationOptions
+}
+
+// proxyHostResponse is a saved proxy host plus the validation warnings it still has.
+type proxyHostResponse struct {
+	*models.ProxyHost
+	Validation []caddy.ValidationIssue `json:"validation,omitempty"`
 }
 
 // NewProxyHostHandler creates a new proxy host handler.
@@ -35,10 +43,58 @@ func NewProxyHostHandler(db *gorm.DB, caddyManager *caddy.Manager, ns *services.
 	}
 }
 
+// SetIssuanceService enables ACME pre-flight checks on create/update when ?preflight=true is passed.
+func (h *ProxyHostHandler) SetIssuanceService(svc *services.CertificateIssuanceService) {
+	h.issuanceService = svc
+}
+
+// SetValidationOptions sets the ports Charon and Caddy listen on and the Caddy
+// modules for semantic validation.
+func (h *ProxyHostHandler) SetValidationOptions(opts caddy.ValidationOptions) {
+	h.validationOpts = opts
+}
+
+// validate runs the semantic validator with host as if saved. It writes a 422
+// response and returns false if saving the host adds errors; otherwise it
+// returns the issues that remain for the host.
+func (h *ProxyHostHandler) validate(c *gin.Context, host *models.ProxyHost) ([]caddy.ValidationIssue, bool) {
+	before, err := h.service.ValidateAll(nil, h.validationOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	report, err := h.service.ValidateAll(host, h.validationOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	issues := report.ForHost(host.UUID).Issues
+	if len(report.HostErrors(host.UUID, before)) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "proxy host configuration is invalid", "validation": issues})
+		return nil, false
+	}
+	return issues, true
+}
+
+// runPreflight performs ACME pre-flight checks for the given domains when requested.
+// It writes a 422 response and returns false if any check fails.
+func (h *ProxyHostHandler) runPreflight(c *gin.Context, domainNames string) bool {
+	if h.issuanceService == nil || c.Query("preflight") != "true" {
+		return true
+	}
+	results, ok := h.issuanceService.PreflightDomains(c.Request.Context(), domainNames)
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "ACME pre-flight check failed", "preflight": results})
+		return false
+	}
+	return true
+}
+
 // RegisterRoutes registers proxy host routes.
 func (h *ProxyHostHandler) RegisterRoutes(router *gin.RouterGroup) {
 	router.GET("/proxy-hosts", h.List)
 	router.POST("/proxy-hosts", h.Create)
+	router.GET("/proxy-hosts/validate", h.ValidateAll)
 	router.GET("/proxy-hosts/:uuid", h.Get)
 	router.PUT("/proxy-hosts/:uuid", h.Update)
 	router.DELETE("/proxy-hosts/:uuid", h.Delete)
@@ -81,6 +137,10 @@ func (h *ProxyHostHandler) Create(c *gin.Context) {
 		}
 	}
 
+	if !h.runPreflight(c, host.DomainNames) {
+		return
+	}
+
 	host.UUID = uuid.NewString()
 
 	// Assign UUIDs to locations
@@ -88,6 +148,11 @@ func (h *ProxyHostHandler) Create(c *gin.Context) {
 		host.Locations[i].UUID = uuid.NewString()
 	}
 
+	issues, ok := h.validate(c, &host)
+	if !ok {
+		return
+	}
+
 	if err := h.service.Create(&host); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -120,7 +185,17 @@ func (h *ProxyHostHandler) Create(c *gin.Context) {
 		)
 	}
 
-	c.JSON(http.StatusCreated, host)
+	c.JSON(http.StatusCreated, proxyHostResponse{ProxyHost: &host, Validation: issues})
+}
+
+// ValidateAll runs the semantic validator over every proxy host and returns all issues.
+func (h *ProxyHostHandler) ValidateAll(c *gin.Context) {
+	report, err := h.service.ValidateAll(nil, h.validationOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
 }
 
 // Get retrieves a proxy host by UUID.
@@ -202,6 +277,54 @@ func (h *ProxyHostHandler) Update(c *gin.Context) {
 	if v, ok := payload["enabled"].(bool); ok {
 		host.Enabled = v
 	}
+	if v, ok := payload["tls_min_version"].(string); ok {
+		host.TLSMinVersion = v
+	}
+	if v, ok := payload["tls_max_version"].(string); ok {
+		host.TLSMaxVersion = v
+	}
+	if v, ok := payload["tls_cipher_suites"].(string); ok {
+		host.TLSCipherSuites = v
+	}
+	if v, ok := payload["tls_alpn"].(string); ok {
+		host.TLSALPN = v
+	}
+	if v, ok := payload["client_auth_mode"].(string); ok {
+		host.ClientAuthMode = v
+	}
+	if v, ok := payload["compression"].(string); ok {
+		host.Compression = v
+	}
+	if v, ok := payload["compression_types"].(string); ok {
+		host.CompressionTypes = v
+	}
+	if v, ok := payload["upstream_connect_timeout"].(float64); ok {
+		host.UpstreamConnectTimeout = int(v)
+	}
+	if v, ok := payload["upstream_read_timeout"].(float64); ok {
+		host.UpstreamReadTimeout = int(v)
+	}
+	if v, ok := payload["upstream_write_timeout"].(float64); ok {
+		host.UpstreamWriteTimeout = int(v)
+	}
+	if v, ok := payload["upstream_keepalive"].(float64); ok {
+		host.UpstreamKeepalive = int(v)
+	}
+	if v, ok := payload["upstream_protocol"].(string); ok {
+		host.UpstreamProtocol = v
+	}
+	if v, ok := payload["upstream_proxy_protocol"].(string); ok {
+		host.UpstreamProxyProtocol = v
+	}
+	if v, ok := payload["max_body_size"].(float64); ok {
+		host.MaxBodySize = int(v)
+	}
+	if v, ok := payload["buffering"].(bool); ok {
+		host.Buffering = v
+	}
+	if v, ok := payload["cache_rules"].(string); ok {
+		host.CacheRules = v
+	}
 
 	// Nullable foreign keys
 	if v, ok := payload["certificate_id"]; ok {
@@ -243,6 +366,28 @@ func (h *ProxyHostHandler) Update(c *gin.Context) {
 		}
 	}
 
+	if v, ok := payload["client_ca_id"]; ok {
+		// Drop any loaded association so Save doesn't write the old ID back
+		host.ClientCA = nil
+		if v == nil {
+			host.ClientCAID = nil
+		} else {
+			switch t := v.(type) {
+			case float64:
+				id := uint(t)
+				host.ClientCAID = &id
+			case int:
+				id := uint(t)
+				host.ClientCAID = &id
+			case string:
+				if n, err := strconv.ParseUint(t, 10, 32); err == nil {
+					id := uint(n)
+					host.ClientCAID = &id
+				}
+			}
+		}
+	}
+
 	// Locations: replace only if provided
 	if v, ok := payload["locations"].([]interface{}); ok {
 		// Rebind to []models.Location
@@ -285,6 +430,15 @@ func (h *ProxyHostHandler) Update(c *gin.Context) {
 		}
 	}
 
+	if _, ok := payload["domain_names"]; ok && !h.runPreflight(c, host.DomainNames) {
+		return
+	}
+
+	issues, ok := h.validate(c, host)
+	if !ok {
+		return
+	}
+
 	if err := h.service.Update(host); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -297,7 +451,7 @@ func (h *ProxyHostHandler) Update(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, host)
+	c.JSON(http.StatusOK, proxyHostResponse{ProxyHost: host, Validation: issues})
 }
 
 // Delete removes a proxy host.
@@ -355,7 +509,7 @@ func (h *ProxyHostHandler) Delete(c *gin.Context) {
 func (h *ProxyHostHandler) TestConnection(c *gin.Context) {
 	var req struct {
 		ForwardHost string `json:"forward_host" binding:"required"`
-		ForwardPort int    `json:"forward_port" binding:"required"`
+		ForwardPort int    `json:"forward_port"` // Not used for unix//path sockets
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
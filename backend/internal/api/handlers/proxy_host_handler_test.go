@@ -16,6 +16,7 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
 	"github.com/Wikid82/charon/backend/internal/caddy"
 	"github.com/Wikid82/charon/backend/internal/config"
 	"github.com/Wikid82/charon/backend/internal/models"
@@ -31,6 +32,12 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
 	require.NoError(t, db.AutoMigrate(
 		&models.ProxyHost{},
 		&models.Location{},
+		&models.UpstreamGroup{},
+		&models.RoutingRule{},
+		&models.RedirectionHost{},
+		&models.StaticSite{},
+		&models.ErrorPage{},
+		&models.MaintenanceWindow{},
 		&models.Notification{},
 		&models.NotificationProvider{},
 	))
@@ -109,7 +116,7 @@ func TestProxyHostDelete_WithUptimeCleanup(t *testing.T) {
 	dsn := "file:test-delete-uptime?mode=memory&cache=shared"
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UptimeMonitor{}, &models.UptimeHeartbeat{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.RedirectionHost{}, &models.StaticSite{}, &models.ErrorPage{}, &models.MaintenanceWindow{}, &models.UptimeMonitor{}, &models.UptimeHeartbeat{}))
 
 	ns := services.NewNotificationService(db)
 	us := services.NewUptimeService(db, ns)
@@ -145,6 +152,19 @@ func TestProxyHostDelete_WithUptimeCleanup(t *testing.T) {
 	require.Equal(t, int64(0), count)
 }
 
+func TestProxyHostCreate_DomainUsedByRedirect(t *testing.T) {
+	router, db := setupTestRouter(t)
+	require.NoError(t, db.Create(&models.RedirectionHost{UUID: "r", Name: "Old media", DomainNames: "media.example.com"}).Error)
+
+	body := `{"name":"Media","domain_names":"media.example.com","forward_scheme":"http","forward_host":"media","forward_port":32400,"enabled":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/proxy-hosts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+	require.Contains(t, resp.Body.String(), "domain media.example.com is already used by redirection host Old media")
+}
+
 func TestProxyHostErrors(t *testing.T) {
 	// Mock Caddy Admin API that fails
 	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -156,7 +176,7 @@ func TestProxyHostErrors(t *testing.T) {
 	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.CaddyConfig{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.RedirectionHost{}, &models.StaticSite{}, &models.ErrorPage{}, &models.MaintenanceWindow{}, &models.Setting{}, &models.CaddyConfig{}))
 
 	// Setup Caddy Manager
 	tmpDir := t.TempDir()
@@ -429,7 +449,7 @@ func TestProxyHostWithCaddyIntegration(t *testing.T) {
 	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.CaddyConfig{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.RedirectionHost{}, &models.StaticSite{}, &models.ErrorPage{}, &models.MaintenanceWindow{}, &models.Setting{}, &models.CaddyConfig{}))
 
 	// Setup Caddy Manager
 	tmpDir := t.TempDir()
@@ -910,3 +930,106 @@ func TestProxyHostCreate_WithCertificateAndLocations(t *testing.T) {
 	require.NotEmpty(t, created.Locations[0].UUID)
 	require.NotEmpty(t, created.AdvancedConfig)
 }
+
+func TestProxyHostSemanticValidation(t *testing.T) {
+	router, db := setupTestRouter(t)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "existing", Name: "Existing", DomainNames: "a.example.com, b.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/proxy-hosts", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Overlapping domain list: the new host would silently take b.example.com from the existing one
+	resp := post(`{"domain_names":"b.example.com, c.example.com","forward_host":"app","forward_port":80,"enabled":true}`)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	var rejected struct {
+		Validation []caddy.ValidationIssue `json:"validation"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rejected))
+	require.Len(t, rejected.Validation, 1)
+	require.Equal(t, caddy.IssueDuplicateDomain, rejected.Validation[0].Code)
+	require.Equal(t, "existing", rejected.Validation[0].HostUUID)
+
+	// Warnings are returned with the saved host
+	resp = post(`{"domain_names":"c.example.com","forward_host":"app","forward_port":80,"enabled":true,"advanced_config":"{\"handler\":\"forward_auth\"}"}`)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var created struct {
+		models.ProxyHost
+		Validation []caddy.ValidationIssue `json:"validation"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	require.Equal(t, "c.example.com", created.DomainNames)
+	require.Len(t, created.Validation, 1)
+	require.Equal(t, caddy.IssueUnknownHandler, created.Validation[0].Code)
+
+	// Updates that break a location are rejected and not saved
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/proxy-hosts/existing", strings.NewReader(`{"locations":[{"path":"/api","forward_host":"api","forward_port":80},{"path":"/api/v2","forward_host":"api","forward_port":80}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	require.Contains(t, resp.Body.String(), caddy.IssueLocationShadowed)
+	var count int64
+	require.NoError(t, db.Model(&models.Location{}).Count(&count).Error)
+	require.Zero(t, count)
+
+	// Validate all
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "ghost", DomainNames: "a.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/proxy-hosts/validate", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var report caddy.ValidationReport
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+	require.False(t, report.Valid)
+	require.Equal(t, 1, report.Errors)
+	require.Equal(t, 1, report.Warnings)
+	require.Equal(t, []string{"ghost"}, report.Issues[0].Related)
+
+	// Hosts that already share a domain can still be edited
+	for _, uuid := range []string{"existing", "ghost"} {
+		req = httptest.NewRequest(http.MethodPut, "/api/v1/proxy-hosts/"+uuid, strings.NewReader(`{"forward_port":8080}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code, uuid+": "+resp.Body.String())
+	}
+}
+
+func TestProxyHostUpdate_RecordsChangeActor(t *testing.T) {
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.RedirectionHost{}, &models.StaticSite{}, &models.ErrorPage{}, &models.MaintenanceWindow{}, &models.Setting{}, &models.CaddyConfig{}, &models.ConfigSnapshot{}, &models.SSLCertificate{}, &models.AccessList{}, &models.ClientCA{}, &models.Stream{}))
+
+	manager := caddy.NewManager(caddy.NewClient(caddyServer.URL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	h := NewProxyHostHandler(db, manager, services.NewNotificationService(db), nil)
+	r := gin.New()
+	// Stands in for an auth middleware that sets the user
+	api := r.Group("/api/v1", func(c *gin.Context) { c.Set("userID", uint(7)); c.Next() }, middleware.ConfigChangeInfo())
+	h.RegisterRoutes(api)
+
+	host := models.ProxyHost{UUID: uuid.NewString(), DomainNames: "app.local", ForwardScheme: "http", ForwardHost: "localhost", ForwardPort: 8080, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+
+	body := `{"domain_names":"app.local","forward_scheme":"http","forward_host":"localhost","forward_port":9090,"enabled":true}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/proxy-hosts/"+host.UUID, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var snap models.ConfigSnapshot
+	require.NoError(t, db.Order("id desc").First(&snap).Error)
+	require.Equal(t, "7", snap.Actor)
+	require.Equal(t, "PUT /api/v1/proxy-hosts/"+host.UUID, snap.Reason)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// RedirectionHostHandler handles CRUD operations for redirection hosts.
+type RedirectionHostHandler struct {
+	service      *services.RedirectionHostService
+	caddyManager *caddy.Manager
+}
+
+// NewRedirectionHostHandler creates a new redirection host handler.
+func NewRedirectionHostHandler(service *services.RedirectionHostService, caddyManager *caddy.Manager) *RedirectionHostHandler {
+	return &RedirectionHostHandler{service: service, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers redirection host routes.
+func (h *RedirectionHostHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/redirection-hosts", h.List)
+	router.POST("/redirection-hosts", h.Create)
+	router.GET("/redirection-hosts/:uuid", h.Get)
+	router.PUT("/redirection-hosts/:uuid", h.Update)
+	router.DELETE("/redirection-hosts/:uuid", h.Delete)
+}
+
+// List returns all redirection hosts.
+func (h *RedirectionHostHandler) List(c *gin.Context) {
+	hosts, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list redirection hosts"})
+		return
+	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+// Create stores a new redirection host and applies the config.
+func (h *RedirectionHostHandler) Create(c *gin.Context) {
+	var host models.RedirectionHost
+	if err := c.ShouldBindJSON(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Create(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !apply(c, h.caddyManager) {
+		// Rollback: a host Caddy rejects must not stay behind
+		if err := h.service.Delete(host.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("redirection_host", host.UUID).WithError(err).Error("Critical: Failed to rollback redirection host")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, host)
+}
+
+// Get returns a redirection host by UUID.
+func (h *RedirectionHostHandler) Get(c *gin.Context) {
+	host, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// Update merges the body into a redirection host, keeping its ID and UUID. The
+// status code and target are checked again, and its domains may not be used by
+// a proxy host, a static site or another redirection host.
+func (h *RedirectionHostHandler) Update(c *gin.Context) {
+	host, ok := h.find(c)
+	if !ok {
+		return
+	}
+	id, uuid, createdAt := host.ID, host.UUID, host.CreatedAt
+	if err := c.ShouldBindJSON(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host.ID, host.UUID, host.CreatedAt = id, uuid, createdAt
+
+	if err := h.service.Update(host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// Delete removes a redirection host and applies the config.
+func (h *RedirectionHostHandler) Delete(c *gin.Context) {
+	host, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(host.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete redirection host"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "redirection host deleted"})
+}
+
+// find loads the redirection host named in the URL, writing a 404 if it does not exist.
+func (h *RedirectionHostHandler) find(c *gin.Context) (*models.RedirectionHost, bool) {
+	host, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrRedirectionHostNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "redirection host not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return host, true
+}
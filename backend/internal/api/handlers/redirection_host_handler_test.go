@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupRedirectionHostTestRouter registers the redirection host routes over an
+// in-memory database. Changes are pushed to the Caddy admin API at caddyURL
+// unless it is empty.
+func setupRedirectionHostTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	// Domains are checked against the proxy hosts and static sites as well
+	require.NoError(t, db.AutoMigrate(&models.RedirectionHost{}, &models.ProxyHost{}, &models.Location{}, &models.StaticSite{}))
+
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	}
+	r := gin.New()
+	NewRedirectionHostHandler(services.NewRedirectionHostService(db), manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db
+}
+
+func sendRedirectionHost(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func createRedirectionHost(t *testing.T, router *gin.Engine, body string) models.RedirectionHost {
+	t.Helper()
+	resp := sendRedirectionHost(router, http.MethodPost, "/api/v1/redirection-hosts", body)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var host models.RedirectionHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &host))
+	return host
+}
+
+func TestRedirectionHostHandler_Defaults(t *testing.T) {
+	router, _ := setupRedirectionHostTestRouter(t, "")
+
+	host := createRedirectionHost(t, router, `{"name":"Old domain","domain_names":"Old.Example.com","forward_domain_name":"example.com"}`)
+	assert.NotEmpty(t, host.UUID)
+	assert.Equal(t, 301, host.ForwardHTTPCode)
+	assert.Equal(t, "auto", host.ForwardScheme)
+}
+
+func TestRedirectionHostHandler_Validation(t *testing.T) {
+	router, _ := setupRedirectionHostTestRouter(t, "")
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no domains", `{"forward_domain_name":"example.com"}`, "domain_names is required"},
+		{"status code", `{"domain_names":"old.example.com","forward_domain_name":"example.com","forward_http_code":303}`, "forward_http_code must be 301, 302, 307 or 308"},
+		{"no target", `{"domain_names":"old.example.com"}`, "target_url or forward_domain_name is required"},
+		{"relative target", `{"domain_names":"old.example.com","target_url":"/new"}`, "target_url must be an absolute http or https URL"},
+		{"target query with path", `{"domain_names":"old.example.com","target_url":"https://example.com/?a=b","preserve_path":true}`, "target_url cannot have a query or fragment when the path is preserved"},
+		{"domain with scheme", `{"domain_names":"old.example.com","forward_domain_name":"https://example.com"}`, "forward_domain_name must be a domain"},
+		{"scheme", `{"domain_names":"old.example.com","forward_domain_name":"example.com","forward_scheme":"ftp"}`, "forward_scheme must be auto, http or https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendRedirectionHost(router, http.MethodPost, "/api/v1/redirection-hosts", tt.body)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestRedirectionHostHandler_DomainInUse(t *testing.T) {
+	router, db := setupRedirectionHostTestRouter(t, "")
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}).Error)
+	require.NoError(t, db.Create(&models.StaticSite{UUID: "docs", Name: "Docs", DomainNames: "docs.example.com"}).Error)
+	old := createRedirectionHost(t, router, `{"domain_names":"old.example.com","forward_domain_name":"example.com"}`)
+
+	resp := sendRedirectionHost(router, http.MethodPost, "/api/v1/redirection-hosts", `{"domain_names":"app.example.com","forward_domain_name":"example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "domain app.example.com is already used by proxy host app.example.com")
+
+	resp = sendRedirectionHost(router, http.MethodPut, "/api/v1/redirection-hosts/"+old.UUID, `{"domain_names":"old.example.com,docs.example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "domain docs.example.com is already used by static site Docs")
+
+	resp = sendRedirectionHost(router, http.MethodPost, "/api/v1/redirection-hosts", `{"domain_names":"old.example.com","forward_domain_name":"example.org"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "is already used by redirection host")
+
+	// A redirection host keeps its own domains on update
+	resp = sendRedirectionHost(router, http.MethodPut, "/api/v1/redirection-hosts/"+old.UUID, `{"forward_http_code":308,"uuid":"changed"}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.RedirectionHost
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.Equal(t, old.UUID, updated.UUID)
+	assert.Equal(t, 308, updated.ForwardHTTPCode)
+	assert.Equal(t, "example.com", updated.ForwardDomainName, "fields left out of the body are kept")
+}
+
+func TestRedirectionHostHandler_AppliesRedirect(t *testing.T) {
+	changes := make(chan string, 1)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			changes <- r.Method + " " + r.URL.Path + " " + string(body)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	router, db := setupRedirectionHostTestRouter(t, caddyServer.URL)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardScheme: "http", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+	host := createRedirectionHost(t, router, `{"domain_names":"old.example.com","forward_scheme":"https","forward_domain_name":"example.com","preserve_path":true}`)
+	assert.Contains(t, <-changes, `"Location":["https://example.com{http.request.uri}"]`)
+
+	// Removing the host only removes its route while other hosts are served
+	resp := sendRedirectionHost(router, http.MethodDelete, "/api/v1/redirection-hosts/"+host.UUID, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "DELETE /id/"+caddy.RedirectRouteID(host.UUID)+" ", <-changes)
+}
+
+func TestRedirectionHostHandler_CreateRolledBackWhenCaddyRejects(t *testing.T) {
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		http.Error(w, `{"error":"rejected"}`, http.StatusBadRequest)
+	}))
+	defer caddyServer.Close()
+
+	router, db := setupRedirectionHostTestRouter(t, caddyServer.URL)
+	resp := sendRedirectionHost(router, http.MethodPost, "/api/v1/redirection-hosts", `{"domain_names":"old.example.com","forward_domain_name":"example.com"}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Failed to apply configuration")
+
+	var count int64
+	db.Model(&models.RedirectionHost{}).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// RoutingRuleHandler handles CRUD operations for the routing rules of proxy hosts.
+type RoutingRuleHandler struct {
+	service      *services.RoutingRuleService
+	hosts        *services.ProxyHostService
+	caddyManager *caddy.Manager
+}
+
+// NewRoutingRuleHandler creates a new routing rule handler.
+func NewRoutingRuleHandler(service *services.RoutingRuleService, hosts *services.ProxyHostService, caddyManager *caddy.Manager) *RoutingRuleHandler {
+	return &RoutingRuleHandler{service: service, hosts: hosts, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers routing rule routes.
+func (h *RoutingRuleHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/proxy-hosts/:uuid/routing-rules", h.List)
+	router.POST("/proxy-hosts/:uuid/routing-rules", h.Create)
+	router.GET("/proxy-hosts/:uuid/routing-rules/:rule_uuid", h.Get)
+	router.PUT("/proxy-hosts/:uuid/routing-rules/:rule_uuid", h.Update)
+	router.DELETE("/proxy-hosts/:uuid/routing-rules/:rule_uuid", h.Delete)
+}
+
+// List returns the routing rules of a host in the order they are matched.
+func (h *RoutingRuleHandler) List(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	rules, err := h.service.List(host.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list routing rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// Create adds a routing rule to a host and applies the config.
+func (h *RoutingRuleHandler) Create(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	var rule models.RoutingRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ProxyHostID = host.ID
+
+	if err := h.service.Create(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		// Rollback: a rule Caddy rejects must not stay behind
+		if err := h.service.Delete(rule.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("routing_rule", rule.UUID).WithError(err).Error("Critical: Failed to rollback routing rule")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// Get returns a routing rule by UUID.
+func (h *RoutingRuleHandler) Get(c *gin.Context) {
+	rule, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// Update merges the body into a routing rule, keeping its ID, UUID and host.
+// The rule must keep at least one matcher, and the fields of its action are
+// checked again: upstreams for proxy, a status code for respond and a target
+// for redirect.
+func (h *RoutingRuleHandler) Update(c *gin.Context) {
+	rule, ok := h.find(c)
+	if !ok {
+		return
+	}
+	id, uuid, hostID, createdAt := rule.ID, rule.UUID, rule.ProxyHostID, rule.CreatedAt
+	if err := c.ShouldBindJSON(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID, rule.UUID, rule.ProxyHostID, rule.CreatedAt = id, uuid, hostID, createdAt
+
+	if err := h.service.Update(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// Delete removes a routing rule and applies the config.
+func (h *RoutingRuleHandler) Delete(c *gin.Context) {
+	rule, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(rule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete routing rule"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "routing rule deleted"})
+}
+
+// findHost loads the proxy host named in the URL, writing a 404 if it does not exist.
+func (h *RoutingRuleHandler) findHost(c *gin.Context) (*models.ProxyHost, bool) {
+	host, err := h.hosts.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "proxy host not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return host, true
+}
+
+// find loads the routing rule named in the URL, writing a 404 if it does not exist.
+func (h *RoutingRuleHandler) find(c *gin.Context) (*models.RoutingRule, bool) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return nil, false
+	}
+	rule, err := h.service.GetByUUID(host.ID, c.Param("rule_uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrRoutingRuleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "routing rule not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return rule, true
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupRoutingRuleTestRouter registers the routing rule routes over an
+// in-memory database holding proxy host "app". Changes are pushed to the Caddy
+// admin API at caddyURL unless it is empty.
+func setupRoutingRuleTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.RoutingRule{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardScheme: "http", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	}
+	r := gin.New()
+	NewRoutingRuleHandler(services.NewRoutingRuleService(db), services.NewProxyHostService(db), manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db
+}
+
+func sendRoutingRule(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func createRoutingRule(t *testing.T, router *gin.Engine, body string) models.RoutingRule {
+	t.Helper()
+	resp := sendRoutingRule(router, http.MethodPost, "/api/v1/proxy-hosts/app/routing-rules", body)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var rule models.RoutingRule
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rule))
+	return rule
+}
+
+func TestRoutingRuleHandler_ActionDefaults(t *testing.T) {
+	router, _ := setupRoutingRuleTestRouter(t, "")
+
+	proxy := createRoutingRule(t, router, `{"paths":"/webhook","upstreams":"ci:8080"}`)
+	assert.Equal(t, caddy.RuleActionProxy, proxy.Action)
+	respond := createRoutingRule(t, router, `{"paths":"/healthz","action":"respond"}`)
+	assert.Equal(t, http.StatusOK, respond.StatusCode)
+	redirect := createRoutingRule(t, router, `{"paths":"/old/*","action":"redirect","target_url":" /new "}`)
+	assert.Equal(t, http.StatusFound, redirect.StatusCode)
+	assert.Equal(t, "/new", redirect.TargetURL)
+}
+
+func TestRoutingRuleHandler_Validation(t *testing.T) {
+	router, _ := setupRoutingRuleTestRouter(t, "")
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no matcher", `{"upstreams":"a:1"}`, "at least one matcher is required"},
+		{"relative path", `{"paths":"webhook","upstreams":"a:1"}`, `path \"webhook\" must start with / or *`},
+		{"unknown method", `{"methods":"FETCH","upstreams":"a:1"}`, `unknown method \"FETCH\"`},
+		{"remote ip", `{"remote_ips":"office","upstreams":"a:1"}`, `remote IP \"office\" must be an IP address or CIDR`},
+		{"proxy without upstreams", `{"paths":"/x"}`, "upstreams is required for action proxy"},
+		{"respond status", `{"paths":"/x","action":"respond","status_code":700}`, "status_code must be between 200 and 599"},
+		{"redirect status", `{"paths":"/x","action":"redirect","status_code":200,"target_url":"/y"}`, "status_code must be 301, 302, 307 or 308"},
+		{"redirect target", `{"paths":"/x","action":"redirect","target_url":"ftp://files"}`, "target_url must be a path or an http or https URL"},
+		{"action", `{"paths":"/x","action":"drop"}`, "action must be proxy, respond or redirect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendRoutingRule(router, http.MethodPost, "/api/v1/proxy-hosts/app/routing-rules", tt.body)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.want)
+		})
+	}
+}
+
+func TestRoutingRuleHandler_UpdateChangesAction(t *testing.T) {
+	router, db := setupRoutingRuleTestRouter(t, "")
+	other := models.ProxyHost{UUID: "other", DomainNames: "other.example.com", ForwardHost: "other", ForwardPort: 80}
+	require.NoError(t, db.Create(&other).Error)
+	rule := createRoutingRule(t, router, `{"name":"webhooks","paths":"/webhook","upstreams":"ci:8080"}`)
+
+	resp := sendRoutingRule(router, http.MethodPut, "/api/v1/proxy-hosts/app/routing-rules/"+rule.UUID, `{"paths":""}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "at least one matcher is required")
+
+	resp = sendRoutingRule(router, http.MethodPut, "/api/v1/proxy-hosts/app/routing-rules/"+rule.UUID, fmt.Sprintf(`{"action":"respond","status_code":403,"uuid":"changed","proxy_host_id":%d}`, other.ID))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.RoutingRule
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.Equal(t, rule.UUID, updated.UUID)
+	assert.Equal(t, rule.ProxyHostID, updated.ProxyHostID)
+	assert.Equal(t, "/webhook", updated.Paths, "fields left out of the body are kept")
+	assert.Equal(t, 403, updated.StatusCode)
+
+	// Rules are only found under their own host
+	resp = sendRoutingRule(router, http.MethodGet, "/api/v1/proxy-hosts/other/routing-rules/"+rule.UUID, "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestRoutingRuleHandler_ListsInMatchOrder(t *testing.T) {
+	router, _ := setupRoutingRuleTestRouter(t, "")
+	createRoutingRule(t, router, `{"name":"fallback","paths":"/*","upstreams":"app:80","priority":10}`)
+	createRoutingRule(t, router, `{"name":"api","paths":"/api/*","upstreams":"api:80","priority":1}`)
+
+	resp := sendRoutingRule(router, http.MethodGet, "/api/v1/proxy-hosts/app/routing-rules", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var rules []models.RoutingRule
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &rules))
+	require.Len(t, rules, 2)
+	assert.Equal(t, "api", rules[0].Name)
+	assert.Equal(t, "fallback", rules[1].Name)
+}
+
+func TestRoutingRuleHandler_AppliesMatchers(t *testing.T) {
+	changes := make(chan string, 1)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			changes <- r.Method + " " + r.URL.Path + " " + string(body)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	router, _ := setupRoutingRuleTestRouter(t, caddyServer.URL)
+	rule := createRoutingRule(t, router, `{"paths":"/webhook","remote_ips":"140.82.112.0/20","upstreams":"ci:8080"}`)
+	assert.Contains(t, <-changes, `"remote_ip":{"ranges":["140.82.112.0/20"]}`)
+
+	// Removing the rule only removes its route
+	resp := sendRoutingRule(router, http.MethodDelete, "/api/v1/proxy-hosts/app/routing-rules/"+rule.UUID, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "DELETE /id/"+caddy.RoutingRuleRouteID("app", rule.UUID)+" ", <-changes)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"strconv"
@@ -141,28 +142,47 @@ func (h *SecurityHandler) GetStatus(c *gin.Context) {
 		}
 	}
 
+	var modules caddy.ModuleSet
+	if h.caddyManager != nil {
+		modules = h.caddyManager.Modules()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"cerberus": gin.H{"enabled": enabled},
-		"crowdsec": gin.H{
+		"crowdsec": withModuleStatus(gin.H{
 			"mode":    mode,
 			"api_url": apiURL,
 			"enabled": crowdsecEnabled,
-		},
-		"waf": gin.H{
+		}, modules, caddy.FeatureCrowdSec),
+		"waf": withModuleStatus(gin.H{
 			"mode":    wafMode,
 			"enabled": wafEnabled,
-		},
-		"rate_limit": gin.H{
+		}, modules, caddy.FeatureWAF),
+		"rate_limit": withModuleStatus(gin.H{
 			"mode":    rateLimitMode,
 			"enabled": rateLimitEnabled,
-		},
+		}, modules, caddy.FeatureRateLimit),
 		"acl": gin.H{
 			"mode":    h.cfg.ACLMode,
 			"enabled": aclEffective,
+			"geo":     withModuleStatus(gin.H{}, modules, caddy.FeatureGeoIP),
 		},
 	})
 }
 
+// withModuleStatus marks a feature available or not depending on whether the
+// Caddy binary has its module. An unavailable feature is never enabled, since
+// its handlers are left out of the generated config.
+func withModuleStatus(status gin.H, modules caddy.ModuleSet, feature string) gin.H {
+	missing := modules.MissingModule(feature)
+	status["available"] = missing == ""
+	if missing != "" {
+		status["enabled"] = false
+		status["unavailable_reason"] = fmt.Sprintf("the Caddy binary does not include the %s module", missing)
+	}
+	return status
+}
+
 // GetConfig returns the site security configuration from DB or default
 func (h *SecurityHandler) GetConfig(c *gin.Context) {
 	cfg, err := h.svc.Get()
@@ -16,6 +16,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/Wikid82/charon/backend/internal/caddy"
 	"github.com/Wikid82/charon/backend/internal/config"
 	"github.com/Wikid82/charon/backend/internal/models"
 )
@@ -266,6 +267,42 @@ func TestSecurityHandler_GetStatus_SettingsOverride(t *testing.T) {
 	assert.True(t, resp["acl"]["enabled"].(bool), "acl should be enabled via settings")
 }
 
+func TestSecurityHandler_GetStatus_MissingCaddyModules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := setupAuditTestDB(t)
+
+	cfg := config.SecurityConfig{CerberusEnabled: true, WAFMode: "block", RateLimitMode: "enabled", CrowdSecMode: "local", ACLMode: "enabled"}
+	manager := caddy.NewManager(nil, db, t.TempDir(), "", false, cfg)
+	manager.SetModules(caddy.ModuleSet{caddy.ModuleCrowdSec: true, "http.handlers.reverse_proxy": true})
+	h := NewSecurityHandler(cfg, db, manager)
+
+	router := gin.New()
+	router.GET("/api/v1/security/status", h.GetStatus)
+	req := httptest.NewRequest("GET", "/api/v1/security/status", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Equal(t, true, resp["crowdsec"]["available"])
+	assert.Equal(t, true, resp["crowdsec"]["enabled"])
+	assert.NotContains(t, resp["crowdsec"], "unavailable_reason")
+
+	assert.Equal(t, false, resp["waf"]["available"])
+	assert.Equal(t, false, resp["waf"]["enabled"])
+	assert.Equal(t, "block", resp["waf"]["mode"])
+	assert.Equal(t, "the Caddy binary does not include the http.handlers.waf module", resp["waf"]["unavailable_reason"])
+	assert.Equal(t, false, resp["rate_limit"]["available"])
+	assert.Equal(t, false, resp["rate_limit"]["enabled"])
+
+	assert.Equal(t, true, resp["acl"]["enabled"])
+	geo := resp["acl"]["geo"].(map[string]interface{})
+	assert.Equal(t, false, geo["available"])
+	assert.Contains(t, geo["unavailable_reason"], caddy.ModuleGeoIP2)
+}
+
 func TestSecurityHandler_GetStatus_DisabledViaSettings(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := setupAuditTestDB(t)
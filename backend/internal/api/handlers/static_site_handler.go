@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// maxSiteUploadSize caps a site archive upload.
+const maxSiteUploadSize = 512 << 20
+
+// StaticSiteHandler handles CRUD operations, deploys and rollbacks for static sites.
+type StaticSiteHandler struct {
+	service      *services.StaticSiteService
+	caddyManager *caddy.Manager
+}
+
+// NewStaticSiteHandler creates a new static site handler.
+func NewStaticSiteHandler(service *services.StaticSiteService, caddyManager *caddy.Manager) *StaticSiteHandler {
+	return &StaticSiteHandler{service: service, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers static site routes.
+func (h *StaticSiteHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/static-sites", h.List)
+	router.POST("/static-sites", h.Create)
+	router.GET("/static-sites/:uuid", h.Get)
+	router.PUT("/static-sites/:uuid", h.Update)
+	router.DELETE("/static-sites/:uuid", h.Delete)
+	router.POST("/static-sites/:uuid/deploy", h.Deploy)
+	router.POST("/static-sites/:uuid/rollback", h.Rollback)
+}
+
+// List returns all static sites.
+func (h *StaticSiteHandler) List(c *gin.Context) {
+	sites, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list static sites"})
+		return
+	}
+	c.JSON(http.StatusOK, sites)
+}
+
+// Create stores a new static site and applies the config.
+func (h *StaticSiteHandler) Create(c *gin.Context) {
+	var site models.StaticSite
+	if err := c.ShouldBindJSON(&site); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Create(&site); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !apply(c, h.caddyManager) {
+		// Rollback: a static site Caddy rejects must not stay behind
+		if err := h.service.Delete(&site); err != nil {
+			middleware.GetRequestLogger(c).WithField("static_site", site.UUID).WithError(err).Error("Critical: Failed to rollback static site")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, site)
+}
+
+// Get returns a static site by UUID.
+func (h *StaticSiteHandler) Get(c *gin.Context) {
+	site, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, site)
+}
+
+// Update merges the body into a static site's settings, keeping its ID and UUID,
+// and checks that its domains are not used by another host. The releases are
+// kept as they are; they only change through Deploy and Rollback.
+func (h *StaticSiteHandler) Update(c *gin.Context) {
+	site, ok := h.find(c)
+	if !ok {
+		return
+	}
+	saved := *site
+	if err := c.ShouldBindJSON(site); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Releases only change through deploy and rollback
+	site.ID, site.UUID, site.CreatedAt = saved.ID, saved.UUID, saved.CreatedAt
+	site.CurrentRelease, site.PreviousRelease, site.DeployedAt = saved.CurrentRelease, saved.PreviousRelease, saved.DeployedAt
+
+	if err := h.service.Update(site); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, site)
+}
+
+// Delete removes a static site and applies the config.
+func (h *StaticSiteHandler) Delete(c *gin.Context) {
+	site, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(site); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete static site"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "static site deleted"})
+}
+
+// Deploy extracts the uploaded archive (form field "file") into a new release
+// and switches the site to it.
+func (h *StaticSiteHandler) Deploy(c *gin.Context) {
+	site, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSiteUploadSize)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive upload (form field \"file\") is required"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	if err := h.service.Deploy(site, src, file.Filename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.GetRequestLogger(c).WithField("static_site", site.UUID).WithField("release", site.CurrentRelease).Info("Deployed static site")
+	// The first release adds the site's route
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, site)
+}
+
+// Rollback switches the site back to its previous release.
+func (h *StaticSiteHandler) Rollback(c *gin.Context) {
+	site, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Rollback(site); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrNoPreviousRelease) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, site)
+}
+
+// find loads the static site named in the URL, writing a 404 if it does not exist.
+func (h *StaticSiteHandler) find(c *gin.Context) (*models.StaticSite, bool) {
+	site, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrStaticSiteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "static site not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return site, true
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupStaticSiteTestRouter registers the static site routes over an in-memory
+// database and returns the directory releases are unpacked to. Changes are
+// pushed to the Caddy admin API at caddyURL unless it is empty.
+func setupStaticSiteTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB, string) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	// Domains are checked against the proxy and redirection hosts as well
+	require.NoError(t, db.AutoMigrate(&models.StaticSite{}, &models.ProxyHost{}, &models.Location{}, &models.RedirectionHost{}))
+
+	sitesDir := t.TempDir()
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+		manager.SetSitesDir(sitesDir)
+	}
+	r := gin.New()
+	NewStaticSiteHandler(services.NewStaticSiteService(db, sitesDir), manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db, sitesDir
+}
+
+func createStaticSite(t *testing.T, router *gin.Engine, body string) models.StaticSite {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/static-sites", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var site models.StaticSite
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &site))
+	return site
+}
+
+// deploySite uploads a zip archive holding the given files.
+func deploySite(t *testing.T, router *gin.Engine, uuid, filename string, files map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, body := range files {
+		file, err := zw.Create(name)
+		require.NoError(t, err)
+		_, _ = file.Write([]byte(body))
+	}
+	require.NoError(t, zw.Close())
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, _ = part.Write(archive.Bytes())
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/static-sites/"+uuid+"/deploy", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func readCurrentIndex(t *testing.T, sitesDir, uuid string) string {
+	t.Helper()
+	index, err := os.ReadFile(filepath.Join(sitesDir, uuid, "current", "index.html"))
+	require.NoError(t, err)
+	return string(index)
+}
+
+func TestStaticSiteHandler_DeployAndRollback(t *testing.T) {
+	router, _, sitesDir := setupStaticSiteTestRouter(t, "")
+	site := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com","spa_fallback":true}`)
+	assert.Equal(t, "index.html", site.IndexFiles)
+
+	// A single top-level directory is deployed from inside
+	resp := deploySite(t, router, site.UUID, "site.zip", map[string]string{"dist/index.html": "v1"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, "v1", readCurrentIndex(t, sitesDir, site.UUID))
+
+	resp = deploySite(t, router, site.UUID, "site.zip", map[string]string{"index.html": "v2"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var deployed models.StaticSite
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &deployed))
+	assert.NotEmpty(t, deployed.PreviousRelease)
+	assert.NotNil(t, deployed.DeployedAt)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/static-sites/"+site.UUID+"/rollback", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, "v1", readCurrentIndex(t, sitesDir, site.UUID))
+
+	// Rolling back again returns to the release it came from
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/static-sites/"+site.UUID+"/rollback", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, "v2", readCurrentIndex(t, sitesDir, site.UUID))
+
+	// Only the current and previous release are kept
+	resp = deploySite(t, router, site.UUID, "site.zip", map[string]string{"index.html": "v3"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	releases, err := os.ReadDir(filepath.Join(sitesDir, site.UUID, "releases"))
+	require.NoError(t, err)
+	assert.Len(t, releases, 2)
+}
+
+func TestStaticSiteHandler_DeployRejects(t *testing.T) {
+	router, _, _ := setupStaticSiteTestRouter(t, "")
+	site := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/static-sites/"+site.UUID+"/rollback", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "no previous release to roll back to")
+
+	resp = deploySite(t, router, site.UUID, "site.exe", map[string]string{"index.html": "v1"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "archive must be a .zip, .tar.gz, .tgz or .tar file")
+
+	resp = deploySite(t, router, site.UUID, "site.zip", map[string]string{"../escape.html": "x"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "illegal path")
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/static-sites/"+site.UUID+"/deploy", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `form field \"file\"`)
+}
+
+func TestStaticSiteHandler_UpdateKeepsReleases(t *testing.T) {
+	router, _, sitesDir := setupStaticSiteTestRouter(t, "")
+	site := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com"}`)
+	resp := deploySite(t, router, site.UUID, "site.zip", map[string]string{"index.html": "v1"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var deployed models.StaticSite
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &deployed))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/static-sites/"+site.UUID, strings.NewReader(`{"browse":true,"current_release":"../../etc","previous_release":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var updated models.StaticSite
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.True(t, updated.Browse)
+	assert.Equal(t, deployed.CurrentRelease, updated.CurrentRelease, "releases only change through deploy and rollback")
+	assert.Empty(t, updated.PreviousRelease)
+	assert.Equal(t, "v1", readCurrentIndex(t, sitesDir, site.UUID))
+}
+
+func TestStaticSiteHandler_DomainInUse(t *testing.T) {
+	router, db, _ := setupStaticSiteTestRouter(t, "")
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", Name: "App", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}).Error)
+	docs := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/static-sites", strings.NewReader(`{"name":"App","domain_names":"app.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "domain app.example.com is already used by proxy host")
+
+	// A site keeps its own domains on update
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/static-sites/"+docs.UUID, strings.NewReader(`{"name":"Documentation"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func TestStaticSiteHandler_DeleteRemovesReleases(t *testing.T) {
+	router, _, sitesDir := setupStaticSiteTestRouter(t, "")
+	site := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com"}`)
+	resp := deploySite(t, router, site.UUID, "site.zip", map[string]string{"index.html": "v1"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/static-sites/"+site.UUID, nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	_, err := os.Stat(filepath.Join(sitesDir, site.UUID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStaticSiteHandler_ServedOnceDeployed(t *testing.T) {
+	loads := make(chan string, 2)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" && r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			loads <- string(body)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	router, _, _ := setupStaticSiteTestRouter(t, caddyServer.URL)
+	site := createStaticSite(t, router, `{"name":"Docs","domain_names":"docs.example.com","enabled":true}`)
+	assert.NotContains(t, <-loads, "docs.example.com", "a site without a release is not served")
+
+	resp := deploySite(t, router, site.UUID, "site.zip", map[string]string{"index.html": "v1"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, <-loads, "docs.example.com")
+}
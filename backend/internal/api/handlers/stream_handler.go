@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// StreamHandler handles CRUD operations for streams.
+type StreamHandler struct {
+	service      *services.StreamService
+	caddyManager *caddy.Manager
+}
+
+// NewStreamHandler creates a new stream handler.
+func NewStreamHandler(service *services.StreamService, caddyManager *caddy.Manager) *StreamHandler {
+	return &StreamHandler{service: service, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers stream routes.
+func (h *StreamHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/streams", h.List)
+	router.POST("/streams", h.Create)
+	router.GET("/streams/:uuid", h.Get)
+	router.PUT("/streams/:uuid", h.Update)
+	router.DELETE("/streams/:uuid", h.Delete)
+}
+
+// List returns all streams.
+func (h *StreamHandler) List(c *gin.Context) {
+	streams, err := h.service.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list streams"})
+		return
+	}
+	c.JSON(http.StatusOK, streams)
+}
+
+// Create stores a new stream and applies the config.
+func (h *StreamHandler) Create(c *gin.Context) {
+	var stream models.Stream
+	if err := c.ShouldBindJSON(&stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.Create(&stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !apply(c, h.caddyManager) {
+		// Rollback: a stream Caddy rejects must not stay behind
+		if err := h.service.Delete(stream.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("stream", stream.UUID).WithError(err).Error("Critical: Failed to rollback stream")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, stream)
+}
+
+// Get returns a stream by UUID.
+func (h *StreamHandler) Get(c *gin.Context) {
+	stream, ok := h.find(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, stream)
+}
+
+// Update merges the body into a stream, keeping its ID and UUID. The listen
+// port is checked again against the other streams and the ports Charon and
+// Caddy listen on, except for a disabled stream, which does not listen.
+func (h *StreamHandler) Update(c *gin.Context) {
+	stream, ok := h.find(c)
+	if !ok {
+		return
+	}
+	id, uuid, createdAt := stream.ID, stream.UUID, stream.CreatedAt
+	if err := c.ShouldBindJSON(stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stream.ID, stream.UUID, stream.CreatedAt = id, uuid, createdAt
+
+	if err := h.service.Update(stream); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, stream)
+}
+
+// Delete removes a stream and applies the config.
+func (h *StreamHandler) Delete(c *gin.Context) {
+	stream, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(stream.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete stream"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "stream deleted"})
+}
+
+// find loads the stream named in the URL, writing a 404 if it does not exist.
+func (h *StreamHandler) find(c *gin.Context) (*models.Stream, bool) {
+	stream, err := h.service.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrStreamNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "stream not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return stream, true
+}
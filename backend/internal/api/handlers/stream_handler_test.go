@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupStreamTestRouter registers the stream routes over an in-memory database.
+// Changes are pushed to the Caddy admin API at caddyURL unless it is empty.
+func setupStreamTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Stream{}, &models.UptimeMonitor{}, &models.UptimeHeartbeat{}))
+
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		// Config generation always reads the proxy hosts
+		require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}))
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	}
+	service := services.NewStreamService(db, caddy.ValidationOptions{CharonPort: 8080, AdminPort: 2019})
+	r := gin.New()
+	NewStreamHandler(service, manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db
+}
+
+func createStream(t *testing.T, router *gin.Engine, body string) models.Stream {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var stream models.Stream
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &stream))
+	return stream
+}
+
+func TestStreamHandler_CreateDefaults(t *testing.T) {
+	router, _ := setupStreamTestRouter(t, "")
+
+	stream := createStream(t, router, `{"name":"Postgres","listen_port":5432,"upstreams":"db:5432","enabled":false}`)
+	assert.NotEmpty(t, stream.UUID)
+	assert.Equal(t, "tcp", stream.Protocol)
+	assert.True(t, stream.Enabled, "new streams are stored enabled")
+}
+
+func TestStreamHandler_PortConflicts(t *testing.T) {
+	router, _ := setupStreamTestRouter(t, "")
+	createStream(t, router, `{"name":"Postgres","listen_port":5432,"upstreams":"db:5432"}`)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"same port", `{"listen_port":5432,"upstreams":"other:5432"}`, "port 5432/tcp is already used by stream Postgres"},
+		{"charon port", `{"listen_port":8080,"upstreams":"app:80"}`, "port 8080/tcp is used by Charon"},
+		{"admin port", `{"listen_port":2019,"upstreams":"app:80"}`, "port 2019/tcp is used by the Caddy admin API"},
+		{"http/3", `{"listen_port":443,"protocol":"udp","upstreams":"quic:443"}`, "port 443/udp is used by Caddy for HTTP/3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.want)
+		})
+	}
+
+	// The same port over UDP does not conflict
+	createStream(t, router, `{"name":"Postgres UDP","listen_port":5432,"protocol":"udp","upstreams":"db:5432"}`)
+}
+
+func TestStreamHandler_UpdateDisabledSkipsConflicts(t *testing.T) {
+	router, _ := setupStreamTestRouter(t, "")
+	createStream(t, router, `{"name":"Postgres","listen_port":5432,"upstreams":"db:5432"}`)
+	other := createStream(t, router, `{"name":"Replica","listen_port":5433,"upstreams":"replica:5432"}`)
+
+	// Moving an enabled stream onto a used port is rejected
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/streams/"+other.UUID, strings.NewReader(`{"listen_port":5432}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// A disabled stream does not listen, so it may share the port
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/streams/"+other.UUID, strings.NewReader(`{"listen_port":5432,"enabled":false,"uuid":"changed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var updated models.Stream
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.Equal(t, other.UUID, updated.UUID)
+	assert.Equal(t, "replica:5432", updated.Upstreams, "fields left out of the body are kept")
+	assert.False(t, updated.Enabled)
+}
+
+func TestStreamHandler_UpdateValidatesTLS(t *testing.T) {
+	router, _ := setupStreamTestRouter(t, "")
+	stream := createStream(t, router, `{"name":"DNS","listen_port":53,"protocol":"udp","upstreams":"dns:53"}`)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/streams/"+stream.UUID, strings.NewReader(`{"tls_mode":"sni","server_names":"dns.example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "tls_mode sni is only available for tcp")
+}
+
+func TestStreamHandler_DeleteRemovesUptimeMonitor(t *testing.T) {
+	router, db := setupStreamTestRouter(t, "")
+	stream := createStream(t, router, `{"name":"Postgres","listen_port":5432,"upstreams":"db:5432"}`)
+
+	monitor := models.UptimeMonitor{ID: "postgres", StreamID: &stream.ID, Name: "Postgres", Type: "tcp", URL: "db:5432"}
+	require.NoError(t, db.Create(&monitor).Error)
+	require.NoError(t, db.Create(&models.UptimeHeartbeat{MonitorID: monitor.ID, Status: "up"}).Error)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/streams/"+stream.UUID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var monitors, heartbeats int64
+	db.Model(&models.UptimeMonitor{}).Count(&monitors)
+	db.Model(&models.UptimeHeartbeat{}).Count(&heartbeats)
+	assert.Zero(t, monitors)
+	assert.Zero(t, heartbeats)
+}
+
+func TestStreamHandler_CreateRolledBackWhenCaddyRejects(t *testing.T) {
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		http.Error(w, `{"error":"layer4 app not installed"}`, http.StatusBadRequest)
+	}))
+	defer caddyServer.Close()
+
+	router, db := setupStreamTestRouter(t, caddyServer.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/streams", strings.NewReader(`{"listen_port":5432,"upstreams":"db:5432"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Failed to apply configuration")
+
+	var count int64
+	db.Model(&models.Stream{}).Count(&count)
+	assert.Zero(t, count)
+}
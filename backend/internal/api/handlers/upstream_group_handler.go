@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/api/middleware"
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// UpstreamGroupHandler manages the upstream groups of proxy hosts and how
+// their traffic is split.
+type UpstreamGroupHandler struct {
+	service      *services.UpstreamGroupService
+	hosts        *services.ProxyHostService
+	caddyManager *caddy.Manager
+}
+
+// NewUpstreamGroupHandler creates a new upstream group handler.
+func NewUpstreamGroupHandler(service *services.UpstreamGroupService, hosts *services.ProxyHostService, caddyManager *caddy.Manager) *UpstreamGroupHandler {
+	return &UpstreamGroupHandler{service: service, hosts: hosts, caddyManager: caddyManager}
+}
+
+// RegisterRoutes registers upstream group and traffic split routes.
+func (h *UpstreamGroupHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/proxy-hosts/:uuid/upstream-groups", h.List)
+	router.POST("/proxy-hosts/:uuid/upstream-groups", h.Create)
+	router.PUT("/proxy-hosts/:uuid/upstream-groups/:group_uuid", h.Update)
+	router.DELETE("/proxy-hosts/:uuid/upstream-groups/:group_uuid", h.Delete)
+	router.GET("/proxy-hosts/:uuid/traffic-split", h.GetSplit)
+	router.PUT("/proxy-hosts/:uuid/traffic-split", h.SetSplit)
+	router.POST("/proxy-hosts/:uuid/traffic-split/rollback", h.Rollback)
+}
+
+// List returns the upstream groups of a host.
+func (h *UpstreamGroupHandler) List(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	groups, err := h.service.List(host.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list upstream groups"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// Create adds an upstream group to a host and applies the config.
+func (h *UpstreamGroupHandler) Create(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	var group models.UpstreamGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group.ProxyHostID = host.ID
+
+	if err := h.service.Create(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		// Rollback: a group Caddy rejects must not stay behind
+		if err := h.service.Delete(group.ID); err != nil {
+			middleware.GetRequestLogger(c).WithField("upstream_group", group.UUID).WithError(err).Error("Critical: Failed to rollback upstream group")
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// Update merges the body into an upstream group, keeping its ID, UUID and host.
+// The name must stay unique within the host, and the weights of all the host's
+// groups must still add up to at most 100%.
+func (h *UpstreamGroupHandler) Update(c *gin.Context) {
+	group, ok := h.findGroup(c)
+	if !ok {
+		return
+	}
+	id, uuid, hostID, createdAt := group.ID, group.UUID, group.ProxyHostID, group.CreatedAt
+	if err := c.ShouldBindJSON(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group.ID, group.UUID, group.ProxyHostID, group.CreatedAt = id, uuid, hostID, createdAt
+
+	if err := h.service.Update(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// Delete removes an upstream group and applies the config.
+func (h *UpstreamGroupHandler) Delete(c *gin.Context) {
+	group, ok := h.findGroup(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(group.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete upstream group"})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "upstream group deleted"})
+}
+
+// GetSplit returns how a host's traffic is split.
+func (h *UpstreamGroupHandler) GetSplit(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	split, err := h.service.Split(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, split)
+}
+
+// SetSplit shifts the weights of a host's upstream groups in one step. If
+// Caddy rejects the result, the previous weights are restored.
+func (h *UpstreamGroupHandler) SetSplit(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Weights        map[string]int `json:"weights"`
+		StickySessions *bool          `json:"sticky_sessions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	previous, err := h.service.Split(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	split, err := h.service.SetWeights(host, req.Weights, req.StickySessions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		h.restore(c, host, previous)
+		return
+	}
+	c.JSON(http.StatusOK, split)
+}
+
+// Rollback sends all of a host's traffic back to its own upstream.
+func (h *UpstreamGroupHandler) Rollback(c *gin.Context) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return
+	}
+	previous, err := h.service.Split(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	split, err := h.service.Rollback(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !apply(c, h.caddyManager) {
+		h.restore(c, host, previous)
+		return
+	}
+	c.JSON(http.StatusOK, split)
+}
+
+// restore puts back the weights of a split after Caddy rejected a change.
+func (h *UpstreamGroupHandler) restore(c *gin.Context, host *models.ProxyHost, previous *services.TrafficSplit) {
+	if _, err := h.service.SetWeights(host, previous.Weights(), &previous.StickySessions); err != nil {
+		middleware.GetRequestLogger(c).WithField("host", host.UUID).WithError(err).Error("Critical: Failed to restore traffic split")
+	}
+}
+
+// findHost loads the proxy host named in the URL, writing a 404 if it does not exist.
+func (h *UpstreamGroupHandler) findHost(c *gin.Context) (*models.ProxyHost, bool) {
+	host, err := h.hosts.GetByUUID(c.Param("uuid"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "proxy host not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return host, true
+}
+
+// findGroup loads the upstream group named in the URL, writing a 404 if it does not exist.
+func (h *UpstreamGroupHandler) findGroup(c *gin.Context) (*models.UpstreamGroup, bool) {
+	host, ok := h.findHost(c)
+	if !ok {
+		return nil, false
+	}
+	group, err := h.service.GetByUUID(host.ID, c.Param("group_uuid"))
+	if err != nil {
+		if errors.Is(err, services.ErrUpstreamGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "upstream group not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	return group, true
+}
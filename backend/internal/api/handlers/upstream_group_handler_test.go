@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/services"
+)
+
+// setupUpstreamGroupTestRouter registers the upstream group routes over an
+// in-memory database holding proxy host "app". Changes are pushed to the Caddy
+// admin API at caddyURL unless it is empty.
+func setupUpstreamGroupTestRouter(t *testing.T, caddyURL string) (*gin.Engine, *gorm.DB, models.ProxyHost) {
+	t.Helper()
+
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}))
+	host := models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardScheme: "http", ForwardHost: "app", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+
+	var manager *caddy.Manager
+	if caddyURL != "" {
+		manager = caddy.NewManager(caddy.NewClient(caddyURL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	}
+	r := gin.New()
+	NewUpstreamGroupHandler(services.NewUpstreamGroupService(db), services.NewProxyHostService(db), manager).RegisterRoutes(r.Group("/api/v1"))
+	return r, db, host
+}
+
+func sendUpstreamGroup(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func createUpstreamGroup(t *testing.T, router *gin.Engine, body string) models.UpstreamGroup {
+	t.Helper()
+	resp := sendUpstreamGroup(router, http.MethodPost, "/api/v1/proxy-hosts/app/upstream-groups", body)
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var group models.UpstreamGroup
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &group))
+	return group
+}
+
+func TestUpstreamGroupHandler_SplitWeights(t *testing.T) {
+	router, _, _ := setupUpstreamGroupTestRouter(t, "")
+	canary := createUpstreamGroup(t, router, `{"name":"canary","upstreams":"app-v2:80"}`)
+	assert.Equal(t, 0, canary.Weight, "new groups take no traffic")
+	createUpstreamGroup(t, router, `{"name":"blue","upstreams":"app-blue:80","weight":50}`)
+
+	resp := sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/traffic-split", `{"weights":{"canary":30},"sticky_sessions":true}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var split services.TrafficSplit
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &split))
+	assert.Equal(t, 20, split.PrimaryWeight, "the host's own upstream gets the rest")
+	assert.True(t, split.StickySessions)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"over 100", `{"weights":{"canary":60}}`, "upstream group weights add up to 110%; at most 100% is allowed"},
+		{"unknown group", `{"weights":{"green":10}}`, `host has no upstream group \"green\"`},
+		{"out of range", `{"weights":{"canary":-1}}`, "weight must be between 0 and 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/traffic-split", tt.body)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.want)
+		})
+	}
+
+	// Raising a weight through the group itself is held to the same total
+	resp = sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/upstream-groups/"+canary.UUID, `{"weight":60}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "add up to 110%")
+
+	resp = sendUpstreamGroup(router, http.MethodPost, "/api/v1/proxy-hosts/app/traffic-split/rollback", "")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &split))
+	assert.Equal(t, 100, split.PrimaryWeight)
+	for _, g := range split.Groups {
+		assert.Equal(t, 0, g.Weight, g.Name)
+	}
+	assert.True(t, split.StickySessions, "a rollback keeps sticky sessions")
+}
+
+func TestUpstreamGroupHandler_UpdateKeepsHost(t *testing.T) {
+	router, db, host := setupUpstreamGroupTestRouter(t, "")
+	other := models.ProxyHost{UUID: "other", DomainNames: "other.example.com", ForwardHost: "other", ForwardPort: 80}
+	require.NoError(t, db.Create(&other).Error)
+	canary := createUpstreamGroup(t, router, `{"name":"canary","upstreams":"app-v2:80"}`)
+	createUpstreamGroup(t, router, `{"name":"blue","upstreams":"app-blue:80"}`)
+
+	resp := sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/upstream-groups/"+canary.UUID, `{"name":"Blue"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "is defined twice")
+
+	resp = sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/upstream-groups/"+canary.UUID, fmt.Sprintf(`{"upstreams":"app-v2:80,app-v2b:80","uuid":"changed","proxy_host_id":%d}`, other.ID))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var updated models.UpstreamGroup
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &updated))
+	assert.Equal(t, canary.UUID, updated.UUID)
+	assert.Equal(t, host.ID, updated.ProxyHostID)
+	assert.Equal(t, "canary", updated.Name, "fields left out of the body are kept")
+
+	// Groups are only found under their own host
+	resp = sendUpstreamGroup(router, http.MethodDelete, "/api/v1/proxy-hosts/other/upstream-groups/"+canary.UUID, "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestUpstreamGroupHandler_AppliesWeights(t *testing.T) {
+	loads := make(chan string, 1)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" && r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			loads <- string(body)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	router, db, host := setupUpstreamGroupTestRouter(t, caddyServer.URL)
+	require.NoError(t, db.Create(&models.UpstreamGroup{UUID: "canary", ProxyHostID: host.ID, Name: "canary", Upstreams: "app-v2:80"}).Error)
+
+	resp := sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/traffic-split", `{"weights":{"canary":10}}`)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, <-loads, `"weights":[9,1]`)
+}
+
+func TestUpstreamGroupHandler_SplitRestoredWhenCaddyRejects(t *testing.T) {
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		http.Error(w, `{"error":"rejected"}`, http.StatusBadRequest)
+	}))
+	defer caddyServer.Close()
+
+	router, db, host := setupUpstreamGroupTestRouter(t, caddyServer.URL)
+	canary := models.UpstreamGroup{UUID: "canary", ProxyHostID: host.ID, Name: "canary", Upstreams: "app-v2:80", Weight: 10}
+	require.NoError(t, db.Create(&canary).Error)
+
+	resp := sendUpstreamGroup(router, http.MethodPut, "/api/v1/proxy-hosts/app/traffic-split", `{"weights":{"canary":50},"sticky_sessions":true}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+
+	var stored models.UpstreamGroup
+	require.NoError(t, db.First(&stored, canary.ID).Error)
+	assert.Equal(t, 10, stored.Weight)
+	var storedHost models.ProxyHost
+	require.NoError(t, db.First(&storedHost, host.ID).Error)
+	assert.False(t, storedHost.StickySessions)
+
+	resp = sendUpstreamGroup(router, http.MethodPost, "/api/v1/proxy-hosts/app/traffic-split/rollback", "")
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	require.NoError(t, db.First(&stored, canary.ID).Error)
+	assert.Equal(t, 10, stored.Weight, "a rollback Caddy rejects is undone as well")
+}
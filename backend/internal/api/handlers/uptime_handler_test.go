@@ -21,7 +21,7 @@ import (
 func setupUptimeHandlerTest(t *testing.T) (*gin.Engine, *gorm.DB) {
 	t.Helper()
 	db := handlers.OpenTestDB(t)
-	require.NoError(t, db.AutoMigrate(&models.UptimeMonitor{}, &models.UptimeHeartbeat{}, &models.UptimeHost{}, &models.RemoteServer{}, &models.NotificationProvider{}, &models.Notification{}, &models.ProxyHost{}))
+	require.NoError(t, db.AutoMigrate(&models.UptimeMonitor{}, &models.UptimeHeartbeat{}, &models.UptimeHost{}, &models.RemoteServer{}, &models.NotificationProvider{}, &models.Notification{}, &models.ProxyHost{}, &models.Stream{}))
 
 	ns := services.NewNotificationService(db)
 	service := services.NewUptimeService(db, ns)
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+)
+
+// ConfigChangeInfo tags the request context with the acting user and request line,
+// so Caddy config snapshots record who made a change and through which endpoint.
+// It must run after AuthMiddleware.
+func ConfigChangeInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := caddy.ChangeInfo{Reason: c.Request.Method + " " + c.Request.URL.Path}
+		if userID, ok := c.Get("userID"); ok {
+			info.Actor = fmt.Sprintf("%v", userID)
+		}
+		c.Request = c.Request.WithContext(caddy.WithChangeInfo(c.Request.Context(), info))
+		c.Next()
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,9 +35,20 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	if err := db.AutoMigrate(
 		&models.ProxyHost{},
 		&models.Location{},
+		&models.UpstreamGroup{},
+		&models.RoutingRule{},
 		&models.CaddyConfig{},
 		&models.RemoteServer{},
 		&models.SSLCertificate{},
+		&models.CertificateExpiryState{},
+		&models.CertificateIssuance{},
+		&models.ClientCA{},
+		&models.RedirectionHost{},
+		&models.StaticSite{},
+		&models.Stream{},
+		&models.ErrorPage{},
+		&models.MaintenanceWindow{},
+		&models.ConfigSnapshot{},
 		&models.AccessList{},
 		&models.User{},
 		&models.Setting{},
@@ -117,6 +129,15 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	api.GET("/auth/verify", authHandler.Verify)
 	api.GET("/auth/status", authHandler.VerifyStatus)
 
+	// On-demand TLS permission endpoint for Caddy (public, restricted to loopback
+	// callers that send the per-install secret from the ask URL)
+	onDemandAskSecret, err := caddy.LoadOnDemandAskSecret(cfg.CaddyConfigDir)
+	if err != nil {
+		logger.Log().WithError(err).Warn("On-demand TLS is unavailable: no permission endpoint secret")
+	}
+	onDemandTLSHandler := handlers.NewOnDemandTLSHandler(services.NewOnDemandTLSService(db), onDemandAskSecret)
+	api.GET("/tls/ask", onDemandTLSHandler.Ask)
+
 	// User handler (public endpoints)
 	userHandler := handlers.NewUserHandler(db)
 	api.GET("/setup", userHandler.GetSetupStatus)
@@ -124,11 +145,15 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	api.GET("/invite/validate", userHandler.ValidateInvite)
 	api.POST("/invite/accept", userHandler.AcceptInvite)
 
+	// Static site releases live next to the database
+	sitesDir := filepath.Join(filepath.Dir(cfg.DatabasePath), "sites")
+
 	// Uptime Service - define early so it can be used during route registration
 	uptimeService := services.NewUptimeService(db, notificationService)
 
 	protected := api.Group("/")
 	protected.Use(authMiddleware)
+	protected.Use(middleware.ConfigChangeInfo())
 	{
 		protected.POST("/auth/logout", authHandler.Logout)
 		protected.GET("/auth/me", authHandler.Me)
@@ -262,6 +287,38 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 		// Caddy Manager
 		caddyClient := caddy.NewClient(cfg.CaddyAdminAPI)
 		caddyManager = caddy.NewManager(caddyClient, db, cfg.CaddyConfigDir, cfg.FrontendDir, cfg.ACMEStaging, cfg.Security)
+		// Caddy runs alongside Charon and asks this endpoint before on-demand issuance
+		if onDemandAskSecret != "" {
+			caddyManager.SetOnDemandAskURL(fmt.Sprintf("http://127.0.0.1:%s/api/v1/tls/ask?secret=%s", cfg.HTTPPort, onDemandAskSecret))
+		}
+		caddyManager.SetSitesDir(sitesDir)
+		// Features whose plugin the Caddy binary lacks are kept out of the config
+		if modules, err := caddy.DetectModules(&caddy.DefaultExecutor{}, cfg.CaddyBinary); err != nil {
+			logger.Log().WithError(err).Warn("Could not detect Caddy modules; assuming all features are available")
+		} else {
+			caddyManager.SetModules(modules)
+		}
+
+		// Dry-run preview of the generated config (contains certificate keys, admin only)
+		caddyConfigHandler := handlers.NewCaddyConfigHandler(caddyManager)
+		if cfg.CaddySupervise {
+			// Charon runs Caddy itself, restarts it and loads the config into it
+			supervisor, err := caddy.NewSupervisor(caddyManager, cfg.CaddyBinary, cfg.CaddyConfigDir)
+			if err != nil {
+				return fmt.Errorf("caddy supervisor: %w", err)
+			}
+			caddyConfigHandler.SetSupervisor(supervisor)
+			go supervisor.Run(context.Background())
+		}
+		protected.GET("/caddy/process", middleware.RequireRole("admin"), caddyConfigHandler.Process)
+		protected.POST("/caddy/preview", middleware.RequireRole("admin"), caddyConfigHandler.Preview)
+		protected.GET("/caddy/export/caddyfile", middleware.RequireRole("admin"), caddyConfigHandler.ExportCaddyfile)
+		snapshots := protected.Group("/caddy/snapshots", middleware.RequireRole("admin"))
+		snapshots.GET("", caddyConfigHandler.ListSnapshots)
+		snapshots.POST("", caddyConfigHandler.CreateSnapshot)
+		snapshots.GET("/:id", caddyConfigHandler.GetSnapshot)
+		snapshots.GET("/:id/diff", caddyConfigHandler.DiffSnapshot)
+		snapshots.POST("/:id/rollback", caddyConfigHandler.RollbackSnapshot)
 
 		// Security Status
 		securityHandler := handlers.NewSecurityHandler(cfg.Security, db, caddyManager)
@@ -288,9 +345,59 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 
 	// Caddy Manager already created above
 
+	// ACME issuance tracking (from Caddy's TLS log) and pre-flight checks
+	caddyLogDir := filepath.Join(filepath.Dir(cfg.DatabasePath), "logs")
+	issuanceService := services.NewCertificateIssuanceService(db, caddyLogDir)
+	issuanceService.Start(30 * time.Second)
+	issuanceHandler := handlers.NewCertificateIssuanceHandler(issuanceService)
+	issuanceHandler.RegisterRoutes(protected)
+	protected.POST("/certificates/preflight", middleware.RequireRole("admin"), issuanceHandler.Preflight)
+
+	// Client CAs for mutual TLS on proxy hosts
+	clientCAHandler := handlers.NewClientCAHandler(services.NewClientCAService(db))
+	clientCAHandler.RegisterRoutes(protected.Group("/", middleware.RequireRole("admin")))
+
 	proxyHostHandler := handlers.NewProxyHostHandler(db, caddyManager, notificationService, uptimeService)
+	proxyHostHandler.SetIssuanceService(issuanceService)
+	validationOpts := caddy.NewValidationOptions(cfg.HTTPPort, cfg.CaddyAdminAPI)
+	if caddyManager != nil {
+		validationOpts.Modules = caddyManager.Modules()
+	}
+	proxyHostHandler.SetValidationOptions(validationOpts)
 	proxyHostHandler.RegisterRoutes(api)
 
+	// Imports from other proxies; admin only, as they carry credentials and private keys
+	adminImportHandler := handlers.NewImportHandler(db, cfg.CaddyBinary, cfg.ImportDir, cfg.ImportCaddyfile)
+	adminImportHandler.RegisterAdminRoutes(protected.Group("/", middleware.RequireRole("admin")))
+
+	// Weighted upstream groups for canary and blue/green rollouts
+	upstreamGroupHandler := handlers.NewUpstreamGroupHandler(services.NewUpstreamGroupService(db), services.NewProxyHostService(db), caddyManager)
+	upstreamGroupHandler.RegisterRoutes(protected)
+
+	// Routing rules on headers, method, query and client IP
+	routingRuleHandler := handlers.NewRoutingRuleHandler(services.NewRoutingRuleService(db), services.NewProxyHostService(db), caddyManager)
+	routingRuleHandler.RegisterRoutes(protected)
+
+	redirectionHostHandler := handlers.NewRedirectionHostHandler(services.NewRedirectionHostService(db), caddyManager)
+	redirectionHostHandler.RegisterRoutes(protected)
+
+	// Static sites, deployed from uploaded archives into data/sites
+	staticSiteHandler := handlers.NewStaticSiteHandler(services.NewStaticSiteService(db, sitesDir), caddyManager)
+	staticSiteHandler.RegisterRoutes(protected)
+
+	// TCP/UDP streams, served by Caddy's layer4 app
+	streamHandler := handlers.NewStreamHandler(services.NewStreamService(db, caddy.NewValidationOptions(cfg.HTTPPort, cfg.CaddyAdminAPI)), caddyManager)
+	streamHandler.RegisterRoutes(protected)
+
+	errorPageHandler := handlers.NewErrorPageHandler(services.NewErrorPageService(db), caddyManager)
+	errorPageHandler.RegisterRoutes(protected)
+
+	// Maintenance windows; the scheduler applies the config when one starts or ends
+	maintenanceService := services.NewMaintenanceService(db, caddyManager)
+	maintenanceService.Start()
+	maintenanceWindowHandler := handlers.NewMaintenanceWindowHandler(maintenanceService)
+	maintenanceWindowHandler.RegisterRoutes(protected)
+
 	remoteServerHandler := handlers.NewRemoteServerHandler(remoteServerService, notificationService)
 	remoteServerHandler.RegisterRoutes(api)
 
@@ -311,11 +418,20 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	logger.Log().WithField("caddy_data_dir", caddyDataDir).Info("Using Caddy data directory for certificates scan")
 	certService := services.NewCertificateService(caddyDataDir, db)
 	certHandler := handlers.NewCertificateHandler(certService, backupService, notificationService)
+	certHandler.SetAuditService(services.NewSecurityService(db))
 	api.GET("/certificates", certHandler.List)
 	api.POST("/certificates", certHandler.Upload)
 	api.DELETE("/certificates/:id", certHandler.Delete)
+	protected.GET("/certificates/:id/export", middleware.RequireRole("admin"), certHandler.Export)
 
-	// Initial Caddy Config Sync
+	// Certificate expiry watcher: hourly threshold notifications (thresholds in settings)
+	certExpiryService := services.NewCertificateExpiryService(db, notificationService, certService)
+	certExpiryService.Start()
+
+	// Initial Caddy Config Sync; a supervised Caddy gets its config from the supervisor
+	if cfg.CaddySupervise {
+		return nil
+	}
 	go func() {
 		// Wait for Caddy to be ready (max 30 seconds)
 		ctx := context.Background()
@@ -340,7 +456,7 @@ func Register(router *gin.Engine, db *gorm.DB, cfg config.Config) error {
 	Apply:
 		if ready {
 			// Apply config
-			if err := caddyManager.ApplyConfig(ctx); err != nil {
+			if err := caddyManager.ApplyConfig(caddy.WithChangeInfo(ctx, caddy.ChangeInfo{Reason: "startup"})); err != nil {
 				logger.Log().WithError(err).Error("Failed to apply initial Caddy config")
 			} else {
 				logger.Log().Info("Successfully applied initial Caddy config")
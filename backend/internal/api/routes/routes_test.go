@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/Wikid82/charon/backend/internal/config"
@@ -39,3 +41,19 @@ func TestRegister(t *testing.T) {
 	}
 	assert.True(t, foundHealth, "Health route should be registered")
 }
+
+func TestRegister_ProxyImportsRequireLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, Register(router, db, config.Config{JWTSecret: "test-secret"}))
+	RegisterImportHandler(router, db, "echo", t.TempDir(), "")
+
+	for _, path := range []string{"/api/v1/import/npm", "/api/v1/import/config"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+}
@@ -57,6 +57,47 @@ func (c *Client) Load(ctx context.Context, config *Config) error {
 	return nil
 }
 
+// Send marshals v and sends it to path with method. Caddy's /id/<id> and
+// /config/<path> endpoints accept PATCH (replace), PUT (insert) and POST (append).
+func (c *Client) Send(ctx context.Context, method, path string, v interface{}) error {
+	body, err := jsonMarshalClient(v)
+	if err != nil {
+		return fmt.Errorf("marshal body: %w", err)
+	}
+	return c.do(ctx, method, path, body)
+}
+
+// Delete removes the config value at path, e.g. /id/<id>.
+func (c *Client) Delete(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodDelete, path, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("caddy returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
 // GetConfig retrieves the current running configuration from Caddy.
 func (c *Client) GetConfig(ctx context.Context) (*Config, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/config/", nil)
@@ -201,3 +201,40 @@ func TestClient_Ping_TransportError(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "caddy unreachable")
 }
+
+func TestClient_SendAndDelete(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/id/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"unknown object ID"}`))
+			return
+		}
+		if r.Method != http.MethodDelete {
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			var route Route
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&route))
+			require.Equal(t, "charon-host-a", route.ID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	route := &Route{ID: HostRouteID("a"), Terminal: true}
+	require.NoError(t, client.Send(context.Background(), http.MethodPatch, "/id/charon-host-a", route))
+	require.NoError(t, client.Send(context.Background(), http.MethodPut, "/config/apps/http/servers/charon_server/routes/0", route))
+	require.NoError(t, client.Delete(context.Background(), "/id/charon-host-a"))
+
+	err := client.Delete(context.Background(), "/id/missing")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown object ID")
+
+	require.Equal(t, []string{
+		"PATCH /id/charon-host-a",
+		"PUT /config/apps/http/servers/charon_server/routes/0",
+		"DELETE /id/charon-host-a",
+		"DELETE /id/missing",
+	}, got)
+}
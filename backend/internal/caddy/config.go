@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/Wikid82/charon/backend/internal/logger"
@@ -11,6 +12,12 @@ import (
 	"github.com/Wikid82/charon/backend/internal/models"
 )
 
+// TLSLogFilename is the file (in the logs directory) that receives Caddy's certificate management events.
+const TLSLogFilename = "tls.log"
+
+// TLSLogLoggers are the Caddy logger namespaces written to TLSLogFilename.
+var TLSLogLoggers = []string{"tls.obtain", "tls.renew", "tls.issuance"}
+
 // GenerateConfig creates a Caddy JSON configuration from proxy hosts.
 // This is the core transformation layer from our database model to Caddy config.
 func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail string, frontendDir string, sslProvider string, acmeStaging bool, crowdsecEnabled bool, wafEnabled bool, rateLimitEnabled bool, aclEnabled bool, adminWhitelist string, rulesets []models.SecurityRuleSet, rulesetPaths map[string]string, decisions []models.SecurityDecision, secCfg *models.SecurityConfig) (*Config, error) {
@@ -21,6 +28,7 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 	// Dir -> .../data
 	logDir := filepath.Join(filepath.Dir(filepath.Dir(storageDir)), "logs")
 	logFile := filepath.Join(logDir, "access.log")
+	tlsLogFile := filepath.Join(logDir, TLSLogFilename)
 
 	config := &Config{
 		Logging: &LoggingConfig{
@@ -40,6 +48,22 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 					},
 					Include: []string{"http.log.access.access_log"},
 				},
+				// Certificate issuance/renewal events, parsed by Charon to report ACME status per domain
+				"tls": {
+					Level: "INFO",
+					Writer: &WriterConfig{
+						Output:       "file",
+						Filename:     tlsLogFile,
+						Roll:         true,
+						RollSize:     10,
+						RollKeep:     3,
+						RollKeepDays: 30,
+					},
+					Encoder: &EncoderConfig{
+						Format: "json",
+					},
+					Include: TLSLogLoggers,
+				},
 			},
 		},
 		Apps: Apps{
@@ -143,6 +167,10 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 	// Track processed domains to prevent duplicates (Ghost Host fix)
 	processedDomains := make(map[string]bool)
 
+	// Per-host TLS connection policies, matched by SNI
+	tlsPolicies := make([]*TLSConnectionPolicy, 0)
+	clientAuthUsed := false
+
 	// Sort hosts by UpdatedAt desc to prefer newer configs in case of duplicates
 	// Note: This assumes the input slice is already sorted or we don't care about order beyond duplicates
 	// The caller (ApplyConfig) fetches all hosts. We should probably sort them here or there.
@@ -196,6 +224,17 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 			continue
 		}
 
+		tlsPolicy, err := buildTLSConnectionPolicy(&host, uniqueDomains)
+		if err != nil {
+			return nil, fmt.Errorf("tls policy for host %s: %w", host.UUID, err)
+		}
+		if tlsPolicy != nil {
+			tlsPolicies = append(tlsPolicies, tlsPolicy)
+			if tlsPolicy.ClientAuthentication != nil {
+				clientAuthUsed = true
+			}
+		}
+
 		// Build handlers for this host
 		handlers := make([]Handler, 0)
 
@@ -278,6 +317,16 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 			}
 		}
 
+		// Compression, body size limit and Cache-Control rules
+		perfHandlers, err := buildPerformanceHandlers(&host)
+		if err != nil {
+			return nil, fmt.Errorf("performance settings for host %s: %w", host.UUID, err)
+		}
+		handlers = append(handlers, perfHandlers...)
+		if err := ValidateTrafficSplit(host.UpstreamGroups); err != nil {
+			return nil, fmt.Errorf("traffic split for host %s: %w", host.UUID, err)
+		}
+
 		// Add HSTS header if enabled
 		if host.HSTSEnabled {
 			hstsValue := "max-age=31536000"
@@ -294,29 +343,12 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 			handlers = append(handlers, BlockExploitsHandler())
 		}
 
-		// Handle custom locations first (more specific routes)
-		for _, loc := range host.Locations {
-			dial := fmt.Sprintf("%s:%d", loc.ForwardHost, loc.ForwardPort)
-			// For each location, we want the same security pre-handlers before proxy
-			locHandlers := append(append([]Handler{}, securityHandlers...), handlers...)
-			locHandlers = append(locHandlers, ReverseProxyHandler(dial, host.WebsocketSupport, host.Application))
-			locRoute := &Route{
-				Match: []Match{
-					{
-						Host: uniqueDomains,
-						Path: []string{loc.Path, loc.Path + "/*"},
-					},
-				},
-				Handle:   locHandlers,
-				Terminal: true,
-			}
-			routes = append(routes, locRoute)
-		}
+		// Custom locations only get the advanced config when they opt in, see below
+		locationHandlers := append([]Handler{}, handlers...)
 
-		// Main proxy handler
-		dial := fmt.Sprintf("%s:%d", host.ForwardHost, host.ForwardPort)
 		// Insert user advanced config (if present) as headers or handlers before the reverse proxy
-		// so user-specified headers/handlers are applied prior to proxying.
+		// so user-specified headers/handlers (e.g. authentication) are applied prior to proxying
+		// on the routing rules and the main route of the host.
 		if host.AdvancedConfig != "" {
 			var parsed interface{}
 			if err := json.Unmarshal([]byte(host.AdvancedConfig), &parsed); err != nil {
@@ -369,9 +401,63 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 				}
 			}
 		}
+
+		advancedHandlers := handlers[len(locationHandlers):]
+
+		// Routing rules come first: they narrow locations and the main route down
+		// by headers, method, query or client IP
+		for _, rule := range sortedRoutingRules(host.RoutingRules) {
+			if err := ValidateRoutingRule(&rule); err != nil {
+				logger.Log().WithField("host", host.UUID).WithField("rule", rule.UUID).WithError(err).Warn("Skipping invalid routing rule")
+				continue
+			}
+			match, _ := RoutingRuleMatch(&rule, uniqueDomains)
+			ruleHandlers := append(append([]Handler{}, securityHandlers...), handlers...)
+			ruleHandlers = append(ruleHandlers, routingRuleAction(&rule, func(dial string) Handler {
+				return applyUpstreamSettings(ReverseProxyHandler(dial, host.WebsocketSupport, host.Application), &host, host.UpstreamProtocol)
+			}))
+			ruleRoute := &Route{Match: []Match{match}, Handle: ruleHandlers, Terminal: true}
+			if host.UUID != "" && rule.UUID != "" {
+				ruleRoute.ID = RoutingRuleRouteID(host.UUID, rule.UUID)
+			}
+			routes = append(routes, ruleRoute)
+		}
+
+		// Then custom locations (more specific than the main route)
+		for i, loc := range host.Locations {
+			dial := UpstreamDial(loc.ForwardHost, loc.ForwardPort)
+			// For each location, we want the same security pre-handlers before proxy
+			locHandlers := append(append([]Handler{}, securityHandlers...), locationHandlers...)
+			if loc.InheritAdvanced {
+				locHandlers = append(locHandlers, nonTerminalHandlers(advancedHandlers)...)
+			}
+			locHandlers = append(locHandlers, applyUpstreamSettings(ReverseProxyHandler(dial, host.WebsocketSupport, host.Application), &host, LocationProtocol(&host, &loc)))
+			locRoute := &Route{
+				Match: []Match{
+					{
+						Host: uniqueDomains,
+						Path: []string{loc.Path, loc.Path + "/*"},
+					},
+				},
+				Handle:   locHandlers,
+				Terminal: true,
+			}
+			if host.UUID != "" {
+				locKey := loc.UUID
+				if locKey == "" {
+					locKey = strconv.Itoa(i)
+				}
+				locRoute.ID = LocationRouteID(host.UUID, locKey)
+			}
+			routes = append(routes, locRoute)
+		}
+
+		// Main proxy handler
+		dial := UpstreamDial(host.ForwardHost, host.ForwardPort)
 		// Build main handlers: security pre-handlers, other host-level handlers, then reverse proxy
 		mainHandlers := append(append([]Handler{}, securityHandlers...), handlers...)
-		mainHandlers = append(mainHandlers, ReverseProxyHandler(dial, host.WebsocketSupport, host.Application))
+		proxy := applyUpstreamSettings(ReverseProxyHandler(dial, host.WebsocketSupport, host.Application), &host, host.UpstreamProtocol)
+		mainHandlers = append(mainHandlers, applyTrafficSplit(proxy, dial, &host))
 
 		route := &Route{
 			Match: []Match{
@@ -380,6 +466,9 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 			Handle:   mainHandlers,
 			Terminal: true,
 		}
+		if host.UUID != "" {
+			route.ID = HostRouteID(host.UUID)
+		}
 
 		routes = append(routes, route)
 	}
@@ -409,9 +498,41 @@ func GenerateConfig(hosts []models.ProxyHost, storageDir string, acmeEmail strin
 		},
 	}
 
+	if len(tlsPolicies) > 0 {
+		// Caddy rejects handshakes that match no policy, so keep a default for every other name
+		tlsPolicies = append(tlsPolicies, &TLSConnectionPolicy{})
+		config.Apps.HTTP.Servers["charon_server"].TLSConnPolicies = tlsPolicies
+	}
+	if clientAuthUsed {
+		// Stop clients from reaching an mTLS host by sending a different SNI than the Host header
+		strict := true
+		config.Apps.HTTP.Servers["charon_server"].StrictSNIHost = &strict
+	}
+
 	return config, nil
 }
 
+// terminalHandlers end the handler chain: a location that inherited one would
+// never reach its own proxy.
+var terminalHandlers = map[string]bool{
+	"static_response": true,
+	"reverse_proxy":   true,
+	"file_server":     true,
+	"error":           true,
+	"copy_response":   true,
+}
+
+// nonTerminalHandlers returns the handlers a location can run before its proxy.
+func nonTerminalHandlers(handlers []Handler) []Handler {
+	var kept []Handler
+	for _, h := range handlers {
+		if name, _ := h["handler"].(string); !terminalHandlers[name] {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
 // normalizeHandlerHeaders ensures header values in handlers are arrays of strings
 // Caddy's JSON schema expects header values to be an array of strings (e.g. ["websocket"]) rather than a single string.
 func normalizeHandlerHeaders(h map[string]interface{}) {
@@ -493,6 +614,19 @@ func NormalizeAdvancedConfig(parsed interface{}) interface{} {
 	}
 }
 
+// localNetworkRanges are the private, loopback and link-local ranges allowed by
+// "local network only" access lists.
+var localNetworkRanges = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"fc00::/7",
+	"fe80::/10",
+	"::1/128",
+}
+
 // buildACLHandler creates access control handlers based on the AccessList configuration
 func buildACLHandler(acl *models.AccessList, adminWhitelist string) (Handler, error) {
 	// For geo-blocking, we use CEL (Common Expression Language) matcher with caddy-geoip2 placeholders
@@ -572,16 +706,7 @@ func buildACLHandler(acl *models.AccessList, adminWhitelist string) (Handler, er
 							"not": []map[string]interface{}{
 								{
 									"remote_ip": map[string]interface{}{
-										"ranges": []string{
-											"10.0.0.0/8",
-											"172.16.0.0/12",
-											"192.168.0.0/16",
-											"127.0.0.0/8",
-											"169.254.0.0/16",
-											"fc00::/7",
-											"fe80::/10",
-											"::1/128",
-										},
+										"ranges": localNetworkRanges,
 									},
 								},
 							},
@@ -0,0 +1,228 @@
+package caddy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrorPageStatusCodes are the status codes an error page can replace.
+var ErrorPageStatusCodes = []int{403, 404, 502, 503, 504}
+
+// maxErrorPageSize caps an error page body, which is embedded in every config load.
+const maxErrorPageSize = 64 * 1024
+
+// errorPagePlaceholders maps the placeholders of an error page to the Caddy
+// placeholders they are served as.
+var errorPagePlaceholders = map[string]string{
+	"host":        "{http.request.host}",
+	"status":      "{http.error.status_code}",
+	"status_text": "{http.error.status_text}",
+	"request_id":  "{http.error.id}", // logged by Caddy as err_id
+}
+
+var errorPagePlaceholderRe = regexp.MustCompile(`\{\{\s*([a-z_]*)\s*\}\}`)
+
+// ValidateErrorPage checks the status code, size and placeholders of a page.
+func ValidateErrorPage(page *models.ErrorPage) error {
+	supported := false
+	for _, code := range ErrorPageStatusCodes {
+		if page.StatusCode == code {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("status_code must be one of %v", ErrorPageStatusCodes)
+	}
+	if strings.TrimSpace(page.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	if len(page.Body) > maxErrorPageSize {
+		return fmt.Errorf("body must be at most %d bytes", maxErrorPageSize)
+	}
+	for _, m := range errorPagePlaceholderRe.FindAllStringSubmatch(page.Body, -1) {
+		if _, ok := errorPagePlaceholders[m[1]]; !ok {
+			return fmt.Errorf("unknown placeholder %s", m[0])
+		}
+	}
+	return nil
+}
+
+// ErrorPageBody turns the placeholders of a page body into Caddy placeholders.
+func ErrorPageBody(body string) string {
+	return errorPagePlaceholderRe.ReplaceAllStringFunc(body, func(s string) string {
+		name := errorPagePlaceholderRe.FindStringSubmatch(s)[1]
+		if p, ok := errorPagePlaceholders[name]; ok {
+			return p
+		}
+		return s
+	})
+}
+
+// errorPageRoute serves page for errors with its status code on domains, or on
+// any host when domains is empty.
+func errorPageRoute(page models.ErrorPage, domains []string) *Route {
+	return &Route{
+		Match: []Match{{
+			Host:       domains,
+			Expression: fmt.Sprintf("{http.error.status_code} == %d", page.StatusCode),
+		}},
+		Handle: []Handler{{
+			"handler":     "static_response",
+			"status_code": page.StatusCode,
+			"headers": map[string][]string{
+				"Content-Type":  {"text/html; charset=utf-8"},
+				"Cache-Control": {"no-store"},
+			},
+			"body": ErrorPageBody(page.Body),
+		}},
+		Terminal: true,
+	}
+}
+
+// AddErrorPages compiles the enabled error pages into the errors routes of the
+// Charon server, host pages before global ones. Access denials (403 responses
+// from access lists and security decisions) are raised as errors on hosts that
+// have a 403 page, and unknown hosts get the global 404 page if there is one.
+func AddErrorPages(config *Config, pages []models.ErrorPage, hosts []models.ProxyHost) {
+	if config == nil || config.Apps.HTTP == nil {
+		return
+	}
+	server := config.Apps.HTTP.Servers["charon_server"]
+	if server == nil {
+		return
+	}
+
+	global := make(map[int]models.ErrorPage)
+	byHost := make(map[uint][]models.ErrorPage)
+	for _, p := range pages {
+		if !p.Enabled {
+			continue
+		}
+		if p.ProxyHostID == nil {
+			global[p.StatusCode] = p
+		} else {
+			byHost[*p.ProxyHostID] = append(byHost[*p.ProxyHostID], p)
+		}
+	}
+
+	var routes []*Route
+	forbidden := make(map[string]bool) // domains whose 403s are raised as errors
+
+	// Newest host first, as in GenerateConfig, so a domain gets the pages of the host serving it
+	seen := make(map[string]bool)
+	for i := len(hosts) - 1; i >= 0; i-- {
+		host := hosts[i]
+		if !host.Enabled {
+			continue
+		}
+		var domains []string
+		for _, d := range SplitDomains(host.DomainNames) {
+			if !seen[d] {
+				seen[d] = true
+				domains = append(domains, d)
+			}
+		}
+		hostPages := byHost[host.ID]
+		if len(domains) == 0 || len(hostPages) == 0 {
+			continue
+		}
+		sort.Slice(hostPages, func(a, b int) bool { return hostPages[a].StatusCode < hostPages[b].StatusCode })
+		for _, p := range hostPages {
+			routes = append(routes, errorPageRoute(p, domains))
+			if p.StatusCode == 403 {
+				for _, d := range domains {
+					forbidden[d] = true
+				}
+			}
+		}
+	}
+	for _, code := range ErrorPageStatusCodes {
+		if p, ok := global[code]; ok {
+			routes = append(routes, errorPageRoute(p, nil))
+		}
+	}
+	if len(routes) == 0 {
+		return
+	}
+
+	_, globalForbidden := global[403]
+	_, globalNotFound := global[404]
+	for i, route := range server.Routes {
+		if len(route.Match) == 0 {
+			// The catch-all for unknown hosts
+			if globalNotFound && i == len(server.Routes)-1 {
+				route.Handle = []Handler{{"handler": "error", "status_code": 404}}
+			}
+			continue
+		}
+		if globalForbidden || routeMatchesAny(route, forbidden) {
+			for _, h := range route.Handle {
+				raiseAccessDenials(h)
+			}
+		}
+	}
+
+	server.Errors = &HTTPErrorConfig{Routes: routes}
+}
+
+// routeMatchesAny reports whether route matches one of domains.
+func routeMatchesAny(route *Route, domains map[string]bool) bool {
+	for _, m := range route.Match {
+		for _, h := range m.Host {
+			if domains[h] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// raiseAccessDenials turns 403 static responses in handler and its nested
+// routes into errors, so that the errors routes serve them.
+func raiseAccessDenials(handler map[string]interface{}) {
+	if handler["handler"] == "static_response" && fmt.Sprint(handler["status_code"]) == "403" {
+		message, _ := handler["body"].(string)
+		for k := range handler {
+			delete(handler, k)
+		}
+		handler["handler"] = "error"
+		handler["status_code"] = 403
+		if message != "" {
+			handler["error"] = message
+		}
+		return
+	}
+	var routes []map[string]interface{}
+	switch rs := handler["routes"].(type) {
+	case []map[string]interface{}:
+		routes = rs
+	case []interface{}:
+		for _, r := range rs {
+			if rm, ok := r.(map[string]interface{}); ok {
+				routes = append(routes, rm)
+			}
+		}
+	}
+	for _, r := range routes {
+		switch handle := r["handle"].(type) {
+		case []Handler:
+			for _, h := range handle {
+				raiseAccessDenials(h)
+			}
+		case []map[string]interface{}:
+			for _, h := range handle {
+				raiseAccessDenials(h)
+			}
+		case []interface{}:
+			for _, h := range handle {
+				if hm, ok := h.(map[string]interface{}); ok {
+					raiseAccessDenials(hm)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateErrorPage(t *testing.T) {
+	assert.NoError(t, ValidateErrorPage(&models.ErrorPage{StatusCode: 502, Body: "<p>{{host}} is down ({{ status }} {{status_text}}, {{request_id}})</p><style>p{color:red}</style>"}))
+	assert.ErrorContains(t, ValidateErrorPage(&models.ErrorPage{StatusCode: 500, Body: "x"}), "status_code must be one of")
+	assert.ErrorContains(t, ValidateErrorPage(&models.ErrorPage{StatusCode: 404, Body: "  "}), "body is required")
+	assert.ErrorContains(t, ValidateErrorPage(&models.ErrorPage{StatusCode: 404, Body: "{{path}}"}), "unknown placeholder {{path}}")
+}
+
+func TestErrorPageBody(t *testing.T) {
+	assert.Equal(t, "{http.request.host}: {http.error.status_code} {http.error.status_text} ({http.error.id})", ErrorPageBody("{{host}}: {{ status }} {{status_text}} ({{request_id}})"))
+}
+
+func TestAddErrorPages(t *testing.T) {
+	hostID := uint(1)
+	hosts := []models.ProxyHost{
+		{ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+		{ID: 2, UUID: "wiki", DomainNames: "wiki.example.com", ForwardHost: "wiki", ForwardPort: 80, Enabled: true},
+	}
+	decisions := []models.SecurityDecision{{Action: "block", IP: "203.0.113.7"}}
+	cfg, err := GenerateConfig(hosts, "/data/caddy/data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, decisions, nil)
+	require.NoError(t, err)
+
+	AddErrorPages(cfg, []models.ErrorPage{
+		{StatusCode: 502, Body: "global {{status}}", Enabled: true},
+		{StatusCode: 403, ProxyHostID: &hostID, Body: "no entry to {{host}}", Enabled: true},
+		{StatusCode: 502, ProxyHostID: &hostID, Body: "app is down", Enabled: true},
+		{StatusCode: 404, Body: "unused", Enabled: false},
+	}, hosts)
+
+	server := cfg.Apps.HTTP.Servers["charon_server"]
+	require.NotNil(t, server.Errors)
+	routes := server.Errors.Routes
+	require.Len(t, routes, 3)
+	out, err := json.Marshal(routes[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"match": [{"host": ["app.example.com"], "expression": "{http.error.status_code} == 403"}],
+		"handle": [{"handler": "static_response", "status_code": 403, "body": "no entry to {http.request.host}",
+			"headers": {"Content-Type": ["text/html; charset=utf-8"], "Cache-Control": ["no-store"]}}],
+		"terminal": true
+	}`, string(out))
+	assert.Equal(t, "{http.error.status_code} == 502", routes[1].Match[0].Expression)
+	assert.Equal(t, []string{"app.example.com"}, routes[1].Match[0].Host)
+	assert.Empty(t, routes[2].Match[0].Host, "global pages match any host")
+	assert.Equal(t, "global {http.error.status_code}", routes[2].Handle[0]["body"])
+
+	// Only the host with a 403 page raises its decision blocks as errors
+	app, _ := json.Marshal(server.Routes[1])
+	wiki, _ := json.Marshal(server.Routes[0])
+	assert.Equal(t, []string{"app.example.com"}, server.Routes[1].Match[0].Host)
+	assert.Contains(t, string(app), `{"error":"Access denied: Blocked by security decision","handler":"error","status_code":403}`)
+	assert.NotContains(t, string(app), `"static_response"`)
+	assert.Contains(t, string(wiki), `"static_response"`)
+
+	// Without a global 404 page the catch-all keeps serving unknown.html
+	assert.Equal(t, "rewrite", server.Routes[2].Handle[0]["handler"])
+}
+
+func TestAddErrorPages_GlobalNotFound(t *testing.T) {
+	cfg, err := GenerateConfig(nil, "/data/caddy/data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	AddErrorPages(cfg, []models.ErrorPage{{StatusCode: 404, Body: "nothing here", Enabled: true}}, nil)
+
+	server := cfg.Apps.HTTP.Servers["charon_server"]
+	require.Len(t, server.Routes, 1)
+	assert.Equal(t, []Handler{{"handler": "error", "status_code": 404}}, server.Routes[0].Handle)
+	require.Len(t, server.Errors.Routes, 1)
+
+	// No enabled pages leave the config alone
+	plain, err := GenerateConfig(nil, "/data/caddy/data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	AddErrorPages(plain, nil, nil)
+	assert.Nil(t, plain.Apps.HTTP.Servers["charon_server"].Errors)
+}
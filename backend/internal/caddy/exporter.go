@@ -0,0 +1,946 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+const letsEncryptStagingCA = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// CaddyfileOptions are the global settings rendered into an exported Caddyfile.
+type CaddyfileOptions struct {
+	ACMEEmail   string
+	SSLProvider string
+	ACMEStaging bool
+	// ACLEnabled mirrors the global ACL switch; access lists are only enforced when it is on
+	ACLEnabled     bool
+	AdminWhitelist string
+	// OnDemandAskURL enables on-demand TLS for wildcard hosts when set. It must not
+	// carry the permission endpoint's secret; the export leaves a placeholder for it
+	OnDemandAskURL string
+	// ProxyProtocolTrusted enables the PROXY protocol listener wrapper for these sources when set
+	ProxyProtocolTrusted []string
+	// Unexported lists enabled Charon features that have no Caddyfile equivalent
+	Unexported []string
+
+	// RedirectionHosts are exported as redir sites after the proxy hosts
+	RedirectionHosts []models.RedirectionHost
+	// Streams run on the layer4 app and are only listed as notes
+	Streams []models.Stream
+	// ErrorPages are exported as handle_errors blocks on the hosts they apply to
+	ErrorPages []models.ErrorPage
+	// MaintenanceWindows are switched on and off by Charon and only listed as notes
+	MaintenanceWindows []models.MaintenanceWindow
+	// StaticSites are exported as file_server sites rooted in SitesDir
+	StaticSites []models.StaticSite
+	SitesDir    string
+}
+
+// ExportCaddyfile renders enabled proxy hosts and the other sites in opts as a
+// Caddyfile that behaves like the generated JSON config. Disabled ones are
+// included commented out. Anything a
+// Caddyfile can't express is written as a "# NOTE:" comment.
+func ExportCaddyfile(hosts []models.ProxyHost, opts CaddyfileOptions) string {
+	sorted := append([]models.ProxyHost(nil), hosts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	// Like GenerateConfig, the newest enabled host owns a domain claimed twice
+	owner := make(map[string]uint)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if !sorted[i].Enabled {
+			continue
+		}
+		for _, d := range hostDomains(&sorted[i]) {
+			if _, ok := owner[d]; !ok {
+				owner[d] = sorted[i].ID
+			}
+		}
+	}
+
+	w := &caddyfileWriter{}
+	w.line("# Caddyfile exported from Charon.")
+	w.line("# Lines starting with \"# NOTE:\" mark settings a Caddyfile can't express; review them before use.")
+	w.blank()
+	writeGlobalOptions(w, sorted, opts)
+
+	for i := range sorted {
+		host := &sorted[i]
+		var domains []string
+		var duplicates []string
+		for _, d := range hostDomains(host) {
+			if host.Enabled && owner[d] != host.ID {
+				duplicates = append(duplicates, d)
+				continue
+			}
+			domains = append(domains, d)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+
+		site := &caddyfileWriter{}
+		if len(duplicates) > 0 {
+			site.line("# NOTE: %s served by a newer host and left out here", strings.Join(duplicates, ", "))
+		}
+		writeSite(site, host, domains, opts)
+
+		w.blank()
+		title := host.Name
+		if title == "" {
+			title = host.UUID
+		}
+		if host.Enabled {
+			w.line("# %s", title)
+			w.raw(site.String())
+		} else {
+			w.line("# %s (disabled)", title)
+			w.raw(site.commented())
+		}
+	}
+
+	routed := make(map[string]bool, len(owner))
+	for d := range owner {
+		routed[d] = true
+	}
+	writeRedirectionHosts(w, opts.RedirectionHosts, routed)
+	writeStaticSites(w, opts.StaticSites, opts.SitesDir, routed)
+	writeStreams(w, opts.Streams)
+	return w.String()
+}
+
+// writeRedirectionHosts exports redirection hosts as redir sites and adds their
+// domains to routed. Like AddRedirectionRoutes, domains a proxy host serves
+// stay with it and the newest redirect wins among redirects. Caddy's automatic
+// HTTPS already upgrades plain HTTP requests, so forcing SSL needs nothing extra.
+func writeRedirectionHosts(w *caddyfileWriter, redirects []models.RedirectionHost, routed map[string]bool) {
+	sorted := append([]models.RedirectionHost(nil), redirects...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	owner := make(map[string]uint)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if !sorted[i].Enabled {
+			continue
+		}
+		for _, d := range SplitDomains(sorted[i].DomainNames) {
+			if _, ok := owner[d]; !ok && !routed[d] {
+				owner[d] = sorted[i].ID
+			}
+		}
+	}
+	for d := range owner {
+		routed[d] = true
+	}
+
+	for i := range sorted {
+		r := &sorted[i]
+		var domains, skipped []string
+		for _, d := range SplitDomains(r.DomainNames) {
+			if r.Enabled && owner[d] != r.ID {
+				skipped = append(skipped, d)
+				continue
+			}
+			domains = append(domains, d)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+
+		site := &caddyfileWriter{}
+		if len(skipped) > 0 {
+			site.line("# NOTE: %s served by a proxy host or newer redirect and left out here", strings.Join(skipped, ", "))
+		}
+		site.open(strings.Join(domains, ", "))
+		if location, err := RedirectLocation(r); err != nil {
+			site.line("# NOTE: invalid redirect, not exported: %v", err)
+		} else {
+			code := r.ForwardHTTPCode
+			if !redirectCodes[code] {
+				code = 301
+			}
+			site.line("redir %s %d", quoteCaddyfileToken(location), code)
+		}
+		site.close()
+
+		w.blank()
+		title := r.Name
+		if title == "" {
+			title = r.UUID
+		}
+		if r.Enabled {
+			w.line("# %s (redirect)", title)
+			w.raw(site.String())
+		} else {
+			w.line("# %s (redirect, disabled)", title)
+			w.raw(site.commented())
+		}
+	}
+}
+
+// writeStaticSites exports deployed static sites as file_server sites. Like
+// AddStaticSites, routed domains keep their site and the newest static site
+// wins among static sites.
+func writeStaticSites(w *caddyfileWriter, sites []models.StaticSite, sitesDir string, routed map[string]bool) {
+	sorted := append([]models.StaticSite(nil), sites...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	owner := make(map[string]uint)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if !sorted[i].Enabled || sorted[i].CurrentRelease == "" {
+			continue
+		}
+		for _, d := range SplitDomains(sorted[i].DomainNames) {
+			if _, ok := owner[d]; !ok && !routed[d] {
+				owner[d] = sorted[i].ID
+			}
+		}
+	}
+
+	for i := range sorted {
+		site := &sorted[i]
+		title := site.Name
+		if title == "" {
+			title = site.UUID
+		}
+		if site.CurrentRelease == "" {
+			w.blank()
+			w.line("# %s (static site)", title)
+			w.line("# NOTE: not deployed yet and not exported")
+			continue
+		}
+
+		var domains, skipped []string
+		for _, d := range SplitDomains(site.DomainNames) {
+			if site.Enabled && owner[d] != site.ID {
+				skipped = append(skipped, d)
+				continue
+			}
+			domains = append(domains, d)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+
+		b := &caddyfileWriter{}
+		if len(skipped) > 0 {
+			b.line("# NOTE: %s served by a proxy host, redirect or newer static site and left out here", strings.Join(skipped, ", "))
+		}
+		b.open(strings.Join(domains, ", "))
+		if err := ValidateStaticSite(site); err != nil {
+			b.line("# NOTE: invalid static site, not exported: %v", err)
+		} else {
+			writeFileServer(b, site, StaticSiteRoot(sitesDir, site.UUID))
+		}
+		b.close()
+
+		w.blank()
+		if site.Enabled {
+			w.line("# %s (static site)", title)
+			w.raw(b.String())
+		} else {
+			w.line("# %s (static site, disabled)", title)
+			w.raw(b.commented())
+		}
+	}
+}
+
+// writeFileServer writes the handling of StaticSiteHandlers.
+func writeFileServer(w *caddyfileWriter, site *models.StaticSite, root string) {
+	indexNames := splitList(site.IndexFiles)
+	if len(indexNames) == 0 {
+		indexNames = []string{"index.html"}
+	}
+	w.line("root * %s", quoteCaddyfileToken(root))
+	if site.SPAFallback {
+		w.line("try_files {path} {path}/ /%s", quoteCaddyfileToken(indexNames[0]))
+	}
+	w.open("file_server")
+	w.line("index %s", strings.Join(indexNames, " "))
+	if site.Browse {
+		w.line("browse")
+	}
+	if site.Precompressed {
+		w.line("precompressed br zstd gzip")
+	}
+	w.close()
+}
+
+// writeStreams lists streams as notes. They run on Caddy's layer4 app, which a
+// Caddyfile can only configure through the caddy-l4 plugin's own syntax.
+func writeStreams(w *caddyfileWriter, streams []models.Stream) {
+	if len(streams) == 0 {
+		return
+	}
+	sorted := append([]models.Stream(nil), streams...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	w.blank()
+	w.line("# NOTE: these streams run on the layer4 app and are not exported:")
+	for _, s := range sorted {
+		title := s.Name
+		if title == "" {
+			title = s.UUID
+		}
+		protocol := s.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		state := ""
+		if !s.Enabled {
+			state = ", disabled"
+		}
+		w.line("#   %s: %s/%d -> %s%s", title, protocol, s.ListenPort, s.Upstreams, state)
+	}
+}
+
+func writeGlobalOptions(w *caddyfileWriter, hosts []models.ProxyHost, opts CaddyfileOptions) {
+	g := &caddyfileWriter{}
+	if opts.ACMEEmail != "" {
+		g.line("email %s", opts.ACMEEmail)
+		switch opts.SSLProvider {
+		case "letsencrypt":
+			g.line("cert_issuer acme")
+		case "zerossl":
+			g.line("# NOTE: ZeroSSL may require an API key: cert_issuer zerossl <api_key>")
+			g.line("cert_issuer zerossl")
+		}
+		if opts.ACMEStaging && opts.SSLProvider != "zerossl" {
+			g.line("acme_ca %s", letsEncryptStagingCA)
+		}
+	}
+	if opts.OnDemandAskURL != "" {
+		g.open("on_demand_tls")
+		g.line("# NOTE: Charon's permission endpoint needs its secret: set %s to the contents of", OnDemandAskSecretEnv)
+		g.line("# %s in Charon's Caddy config directory, or ask an endpoint of your own", onDemandAskSecretFile)
+		g.line("ask %s?secret={$%s}", opts.OnDemandAskURL, OnDemandAskSecretEnv)
+		g.close()
+	}
+	strictSNI := false
+	for i := range hosts {
+		if hosts[i].Enabled && hosts[i].ClientAuthMode != "" {
+			strictSNI = true
+			break
+		}
+	}
+	if strictSNI || len(opts.ProxyProtocolTrusted) > 0 {
+		g.open("servers")
+		if len(opts.ProxyProtocolTrusted) > 0 {
+			// Like ConfigureProxyProtocol: the PROXY header is read before TLS
+			g.open("listener_wrappers")
+			g.open("proxy_protocol")
+			g.line("timeout %s", proxyProtocolTimeout)
+			g.line("allow %s", strings.Join(opts.ProxyProtocolTrusted, " "))
+			g.close()
+			g.line("tls")
+			g.close()
+		}
+		if strictSNI {
+			// Matches GenerateConfig: client certificates are checked per SNI, so the Host header must agree
+			g.line("strict_sni_host on")
+		}
+		g.close()
+	}
+	if len(opts.Unexported) > 0 {
+		g.line("# NOTE: %s enabled in Charon; these are not exported", strings.Join(opts.Unexported, ", "))
+	}
+
+	if g.String() == "" {
+		return
+	}
+	w.open("")
+	w.raw(g.String())
+	w.close()
+}
+
+func writeSite(w *caddyfileWriter, host *models.ProxyHost, domains []string, opts CaddyfileOptions) {
+	w.open(strings.Join(domains, ", "))
+	writeTLS(w, host, domains, opts)
+	writeMaintenanceNotes(w, host.ID, opts.MaintenanceWindows)
+
+	rules := sortedRoutingRules(host.RoutingRules)
+	if len(host.Locations) == 0 && len(rules) == 0 {
+		writeProxy(w, host, UpstreamDial(host.ForwardHost, host.ForwardPort), host.UpstreamProtocol, host.UpstreamGroups, opts)
+		writeErrorPages(w, hostErrorPages(host.ID, opts.ErrorPages))
+		w.close()
+		return
+	}
+
+	// Routing rules, then locations, take precedence over the host's main upstream, as in the generated routes
+	for i := range rules {
+		writeRoutingRule(w, host, &rules[i], fmt.Sprintf("@rule%d", i+1), opts)
+	}
+	for i, loc := range host.Locations {
+		matcher := fmt.Sprintf("@location%d", i+1)
+		w.line("%s path %s %s", matcher, quoteCaddyfileToken(loc.Path), quoteCaddyfileToken(loc.Path+"/*"))
+		w.open("handle " + matcher)
+		writeProxy(w, host, UpstreamDial(loc.ForwardHost, loc.ForwardPort), LocationProtocol(host, &loc), nil, opts)
+		w.close()
+	}
+	w.open("handle")
+	writeProxy(w, host, UpstreamDial(host.ForwardHost, host.ForwardPort), host.UpstreamProtocol, host.UpstreamGroups, opts)
+	w.close()
+	writeErrorPages(w, hostErrorPages(host.ID, opts.ErrorPages))
+	w.close()
+}
+
+// writeMaintenanceNotes lists a host's enabled maintenance windows as notes.
+// Charon applies a new config when a window starts or ends; a static
+// Caddyfile has no such schedule.
+func writeMaintenanceNotes(w *caddyfileWriter, hostID uint, windows []models.MaintenanceWindow) {
+	for _, mw := range windows {
+		if !mw.Enabled || mw.ProxyHostID != hostID {
+			continue
+		}
+		title := mw.Name
+		if title == "" {
+			title = mw.UUID
+		}
+		var when string
+		switch {
+		case mw.Schedule != "":
+			when = fmt.Sprintf("%q for %d minutes", mw.Schedule, mw.DurationMinutes)
+		case mw.EndsAt != nil:
+			when = "until " + mw.EndsAt.UTC().Format(time.RFC3339)
+		default:
+			when = "until disabled"
+		}
+		w.line("# NOTE: maintenance window %q (%s) is scheduled by Charon and not exported", title, when)
+	}
+}
+
+// hostErrorPages returns the enabled error pages that apply to a host, its own
+// pages replacing global ones for the same status code, sorted by status code.
+func hostErrorPages(hostID uint, pages []models.ErrorPage) []models.ErrorPage {
+	global := make(map[int]models.ErrorPage)
+	own := make(map[int]models.ErrorPage)
+	for _, p := range pages {
+		if !p.Enabled {
+			continue
+		}
+		if p.ProxyHostID == nil {
+			global[p.StatusCode] = p
+		} else if *p.ProxyHostID == hostID {
+			own[p.StatusCode] = p
+		}
+	}
+	var result []models.ErrorPage
+	for _, code := range ErrorPageStatusCodes {
+		if p, ok := own[code]; ok {
+			result = append(result, p)
+		} else if p, ok := global[code]; ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// writeErrorPages writes error pages as handle_errors blocks, like errorPageRoute.
+func writeErrorPages(w *caddyfileWriter, pages []models.ErrorPage) {
+	for _, p := range pages {
+		w.open(fmt.Sprintf("handle_errors %d", p.StatusCode))
+		w.line("header Content-Type \"text/html; charset=utf-8\"")
+		w.line("header Cache-Control no-store")
+		w.line("respond %s %d", quoteCaddyfileToken(ErrorPageBody(p.Body)), p.StatusCode)
+		w.close()
+	}
+}
+
+// raisesDenials reports whether a host's 403 responses are raised as errors
+// so that its 403 error page serves them, like raiseAccessDenials.
+func raisesDenials(hostID uint, pages []models.ErrorPage) bool {
+	for _, p := range hostErrorPages(hostID, pages) {
+		if p.StatusCode == 403 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDenial writes a 403 response, or raises it as an error when raise is set.
+func writeDenial(w *caddyfileWriter, matcher, message string, raise bool) {
+	directive := "respond"
+	if raise {
+		directive = "error"
+	}
+	if matcher != "" {
+		directive += " " + matcher
+	}
+	if message == "" {
+		w.line("%s 403", directive)
+		return
+	}
+	w.line("%s %s 403", directive, quoteCaddyfileToken(message))
+}
+
+// writeRoutingRule writes a routing rule as a named matcher and a handle block, like GenerateConfig.
+func writeRoutingRule(w *caddyfileWriter, host *models.ProxyHost, rule *models.RoutingRule, matcher string, opts CaddyfileOptions) {
+	if err := ValidateRoutingRule(rule); err != nil {
+		w.line("# NOTE: routing rule %q is invalid and not exported: %v", rule.Name, err)
+		return
+	}
+	match, _ := RoutingRuleMatch(rule, nil)
+	w.open(matcher)
+	if len(match.Path) > 0 {
+		w.line("path %s", strings.Join(match.Path, " "))
+	}
+	if len(match.Method) > 0 {
+		w.line("method %s", strings.Join(match.Method, " "))
+	}
+	for _, name := range sortedKeys(match.Header) {
+		if len(match.Header[name]) == 0 {
+			w.line("header %s *", name)
+		}
+		for _, v := range match.Header[name] {
+			w.line("header %s %s", name, quoteCaddyfileToken(v))
+		}
+	}
+	for _, key := range sortedKeys(match.Query) {
+		for _, v := range match.Query[key] {
+			w.line("query %s", quoteCaddyfileToken(key+"="+v))
+		}
+	}
+	if match.RemoteIP != nil {
+		w.line("remote_ip %s", strings.Join(match.RemoteIP.Ranges, " "))
+	}
+	w.close()
+
+	w.open("handle " + matcher)
+	switch rule.Action {
+	case RuleActionRespond:
+		if rule.StatusCode == 403 {
+			writeDenial(w, "", rule.Body, raisesDenials(host.ID, opts.ErrorPages))
+		} else if rule.Body != "" {
+			w.line("respond %s %d", quoteCaddyfileToken(rule.Body), rule.StatusCode)
+		} else {
+			w.line("respond %d", rule.StatusCode)
+		}
+	case RuleActionRedirect:
+		w.line("redir %s %d", quoteCaddyfileToken(rule.TargetURL), rule.StatusCode)
+	default:
+		writeProxy(w, host, strings.Join(splitList(rule.Upstreams), " "), host.UpstreamProtocol, nil, opts)
+	}
+	w.close()
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeTLS(w *caddyfileWriter, host *models.ProxyHost, domains []string, opts CaddyfileOptions) {
+	args := ""
+	if host.Certificate != nil && host.Certificate.Provider == "custom" {
+		w.line("# NOTE: uses the custom certificate %q stored in Charon; export it and adjust these paths", host.Certificate.Name)
+		args = fmt.Sprintf(" %s.crt %s.key", host.Certificate.UUID, host.Certificate.UUID)
+	}
+
+	b := &caddyfileWriter{}
+	if host.TLSMinVersion != "" || host.TLSMaxVersion != "" {
+		minVersion := host.TLSMinVersion
+		if minVersion == "" {
+			minVersion = tlsVersions[0]
+		}
+		b.line("protocols %s", strings.TrimSpace(minVersion+" "+host.TLSMaxVersion))
+	}
+	if suites := splitList(host.TLSCipherSuites); len(suites) > 0 {
+		b.line("ciphers %s", strings.Join(suites, " "))
+	}
+	if alpn := splitList(host.TLSALPN); len(alpn) > 0 {
+		b.line("alpn %s", strings.Join(alpn, " "))
+	}
+	if mode, ok := clientAuthModes[host.ClientAuthMode]; ok {
+		b.open("client_auth")
+		b.line("mode %s", mode)
+		certs := []string(nil)
+		if host.ClientCA != nil {
+			certs, _ = clientCACertsDER(host.ClientCA.Certificate)
+		}
+		if len(certs) == 0 {
+			b.line("# NOTE: the client CA is missing; Charon refuses to generate this host")
+		} else {
+			b.open("trust_pool inline")
+			for _, c := range certs {
+				b.line("trust_der %s", c)
+			}
+			b.close()
+		}
+		b.close()
+	}
+	if opts.OnDemandAskURL != "" {
+		for _, d := range domains {
+			if strings.HasPrefix(d, "*.") {
+				b.line("on_demand")
+				break
+			}
+		}
+	}
+
+	if b.String() == "" {
+		if args != "" {
+			w.line("tls%s", args)
+		}
+		return
+	}
+	w.open("tls" + args)
+	w.raw(b.String())
+	w.close()
+}
+
+// writeProxy writes the handling shared by a host's main upstream and its locations:
+// access list, response headers, advanced config and the reverse proxy. Traffic
+// is split over groups like applyTrafficSplit does.
+func writeProxy(w *caddyfileWriter, host *models.ProxyHost, dial, protocol string, groups []models.UpstreamGroup, opts CaddyfileOptions) {
+	if acl := host.AccessList; acl != nil && host.AccessListID != nil && acl.Enabled {
+		if opts.ACLEnabled {
+			writeACL(w, acl, splitList(opts.AdminWhitelist), raisesDenials(host.ID, opts.ErrorPages))
+		} else {
+			w.line("# NOTE: access list %q is not enforced because ACLs are disabled in Charon", acl.Name)
+		}
+	}
+
+	if host.HSTSEnabled {
+		value := "max-age=31536000"
+		if host.HSTSSubdomains {
+			value += "; includeSubDomains"
+		}
+		w.line("header Strict-Transport-Security %s", quoteCaddyfileToken(value))
+	}
+	if host.BlockExploits {
+		w.line("# NOTE: exploit blocking is enabled in Charon; its handler has no Caddyfile equivalent and is not exported")
+	}
+
+	writePerformance(w, host)
+
+	if strings.TrimSpace(host.AdvancedConfig) != "" {
+		w.line("# NOTE: advanced config (Caddy JSON handlers) is not exported:")
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(host.AdvancedConfig)); err != nil {
+			compact.Reset()
+			compact.WriteString(strings.Join(strings.Fields(host.AdvancedConfig), " "))
+		}
+		w.line("# %s", compact.String())
+	}
+
+	proxy := applyUpstreamSettings(ReverseProxyHandler(dial, host.WebsocketSupport, host.Application), host, protocol)
+	var set map[string][]string
+	if headers, ok := proxy["headers"].(map[string]interface{}); ok {
+		if req, ok := headers["request"].(map[string]interface{}); ok {
+			set, _ = req["set"].(map[string][]string)
+		}
+	}
+	dials, weights := trafficSplit(dial, groups)
+	if dials == nil {
+		dials = []string{dial}
+	}
+	w.open("reverse_proxy " + strings.Join(dials, " "))
+	if _, ok := proxy["flush_interval"]; ok {
+		w.line("flush_interval -1")
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range set[name] {
+			w.line("header_up %s %s", name, quoteCaddyfileToken(v))
+		}
+	}
+	if weights != nil {
+		policy := "weighted_round_robin"
+		for _, weight := range weights {
+			policy += fmt.Sprintf(" %d", weight)
+		}
+		if host.StickySessions {
+			w.open("lb_policy cookie " + StickyCookieName)
+			w.line("fallback %s", policy)
+			w.close()
+		} else {
+			w.line("lb_policy %s", policy)
+		}
+	}
+	writeTransport(w, host, proxy)
+	w.close()
+}
+
+// writePerformance writes compression, the request body limit and cache rules,
+// like buildPerformanceHandlers.
+func writePerformance(w *caddyfileWriter, host *models.ProxyHost) {
+	if encodings := splitList(host.Compression); len(encodings) > 0 {
+		if types := compressionTypeMatches(host.CompressionTypes); len(types) > 0 {
+			w.open("encode " + strings.Join(encodings, " "))
+			w.open("match")
+			w.line("header Content-Type %s", strings.Join(types, " "))
+			w.close()
+			w.close()
+		} else {
+			w.line("encode %s", strings.Join(encodings, " "))
+		}
+	}
+	if host.MaxBodySize > 0 {
+		w.open("request_body")
+		w.line("max_size %dMB", host.MaxBodySize)
+		w.close()
+	}
+	rules, err := ParseCacheRules(host.CacheRules)
+	if err != nil {
+		w.line("# NOTE: cache rules are invalid and not exported: %v", err)
+		return
+	}
+	for i, r := range rules {
+		matcher := fmt.Sprintf("@cache%d", i+1)
+		w.line("%s path %s", matcher, strings.Join(r.Paths, " "))
+		w.line("header %s >Cache-Control %s", matcher, quoteCaddyfileToken(r.Value))
+	}
+}
+
+// writeTransport writes the upstream timeouts, keep-alive, HTTP versions and
+// PROXY protocol of a reverse_proxy handler built by applyUpstreamSettings.
+func writeTransport(w *caddyfileWriter, host *models.ProxyHost, proxy Handler) {
+	transport, ok := proxy["transport"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	w.open("transport http")
+	if versions, ok := transport["versions"].([]string); ok {
+		w.line("versions %s", strings.Join(versions, " "))
+	}
+	if _, ok := transport["tls"]; ok {
+		w.line("tls")
+	}
+	if v, ok := transport["proxy_protocol"].(string); ok {
+		w.line("proxy_protocol %s", v)
+	}
+	for _, key := range []string{"dial_timeout", "read_timeout", "write_timeout"} {
+		if v, ok := transport[key].(string); ok {
+			w.line("%s %s", key, v)
+		}
+	}
+	if host.UpstreamKeepalive < 0 {
+		w.line("keepalive off")
+	} else if host.UpstreamKeepalive > 0 {
+		w.line("keepalive %s", time.Duration(host.UpstreamKeepalive)*time.Second)
+	}
+	w.close()
+}
+
+// writeACL writes an access list as a named matcher and a 403 response, like
+// buildACLHandler. With raise set the 403 is raised as an error instead.
+func writeACL(w *caddyfileWriter, acl *models.AccessList, adminWhitelist []string, raise bool) {
+	const matcher = "@acl_denied"
+
+	if strings.HasPrefix(acl.Type, "geo_") {
+		codes := make([]string, 0)
+		for _, c := range splitList(acl.CountryCodes) {
+			codes = append(codes, `"`+c+`"`)
+		}
+		expr := fmt.Sprintf("`{geoip2.country_code} in [%s]`", strings.Join(codes, ", "))
+		w.line("# NOTE: access list %q needs the caddy-geoip2 module", acl.Name)
+		if acl.Type == "geo_whitelist" {
+			w.line("%s not expression %s", matcher, expr)
+		} else {
+			w.line("%s expression %s", matcher, expr)
+		}
+		writeDenial(w, matcher, "Access denied: Geographic restriction", raise)
+		return
+	}
+
+	if acl.LocalNetworkOnly {
+		w.line("%s not remote_ip %s", matcher, strings.Join(localNetworkRanges, " "))
+		writeDenial(w, matcher, "Access denied: Not a local network IP", raise)
+		return
+	}
+
+	var rules []models.AccessListRule
+	if acl.IPRules != "" {
+		if err := json.Unmarshal([]byte(acl.IPRules), &rules); err != nil {
+			w.line("# NOTE: access list %q has invalid IP rules and is not exported", acl.Name)
+			return
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+	cidrs := make([]string, 0, len(rules))
+	for _, r := range rules {
+		cidrs = append(cidrs, r.CIDR)
+	}
+
+	switch acl.Type {
+	case "whitelist":
+		// Admin addresses always pass, as in the generated config
+		cidrs = append(cidrs, adminWhitelist...)
+		w.line("%s not remote_ip %s", matcher, strings.Join(cidrs, " "))
+		writeDenial(w, matcher, "Access denied: IP not in whitelist", raise)
+	case "blacklist":
+		if len(adminWhitelist) == 0 {
+			w.line("%s remote_ip %s", matcher, strings.Join(cidrs, " "))
+		} else {
+			w.open(matcher)
+			w.line("remote_ip %s", strings.Join(cidrs, " "))
+			w.line("not remote_ip %s", strings.Join(adminWhitelist, " "))
+			w.close()
+		}
+		writeDenial(w, matcher, "Access denied: IP blacklisted", raise)
+	}
+}
+
+// hostDomains returns a host's domains normalized like GenerateConfig does.
+func hostDomains(host *models.ProxyHost) []string {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, d := range strings.Split(host.DomainNames, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		domains = append(domains, d)
+	}
+	return domains
+}
+
+// quoteCaddyfileToken quotes s when it would otherwise be split or read as a comment.
+func quoteCaddyfileToken(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\"#") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// caddyfileWriter builds Caddyfile text with tab indentation.
+type caddyfileWriter struct {
+	b      strings.Builder
+	indent int
+}
+
+func (w *caddyfileWriter) line(format string, args ...interface{}) {
+	w.b.WriteString(strings.Repeat("\t", w.indent))
+	fmt.Fprintf(&w.b, format, args...)
+	w.b.WriteByte('\n')
+}
+
+func (w *caddyfileWriter) blank() {
+	w.b.WriteByte('\n')
+}
+
+// raw writes pre-rendered lines at the current indentation.
+func (w *caddyfileWriter) raw(text string) {
+	for _, l := range strings.SplitAfter(text, "\n") {
+		if l == "" {
+			continue
+		}
+		if l != "\n" {
+			w.b.WriteString(strings.Repeat("\t", w.indent))
+		}
+		w.b.WriteString(l)
+	}
+}
+
+func (w *caddyfileWriter) open(head string) {
+	if head == "" {
+		w.line("{")
+	} else {
+		w.line("%s {", head)
+	}
+	w.indent++
+}
+
+func (w *caddyfileWriter) close() {
+	w.indent--
+	w.line("}")
+}
+
+func (w *caddyfileWriter) String() string {
+	return w.b.String()
+}
+
+// commented returns the text with every line turned into a comment.
+func (w *caddyfileWriter) commented() string {
+	var out strings.Builder
+	for _, l := range strings.SplitAfter(w.String(), "\n") {
+		if l != "" {
+			out.WriteString("# " + l)
+		}
+	}
+	return out.String()
+}
+
+// ExportCaddyfile renders the current proxy hosts and settings as a Caddyfile.
+func (m *Manager) ExportCaddyfile(ctx context.Context) (string, error) {
+	hosts, err := m.loadHosts()
+	if err != nil {
+		return "", err
+	}
+
+	opts := CaddyfileOptions{
+		ACMEEmail:   m.setting("caddy.acme_email"),
+		SSLProvider: m.setting("caddy.ssl_provider"),
+		ACMEStaging: m.acmeStaging,
+	}
+	_, aclEnabled, wafEnabled, rateLimitEnabled, crowdsecEnabled := m.computeEffectiveFlags(ctx)
+	opts.ACLEnabled = aclEnabled
+	var secCfg models.SecurityConfig
+	if m.db.Where("name = ?", "default").First(&secCfg).Error == nil {
+		opts.AdminWhitelist = secCfg.AdminWhitelist
+	}
+	if m.onDemandAskURL != "" && strings.EqualFold(m.setting(OnDemandTLSEnabledSettingKey), "true") {
+		// The secret stays out of a file users copy and share
+		opts.OnDemandAskURL, _, _ = strings.Cut(m.onDemandAskURL, "?")
+	}
+	if strings.EqualFold(m.setting(ProxyProtocolEnabledSettingKey), "true") {
+		opts.ProxyProtocolTrusted, _ = ParseTrustedCIDRs(m.setting(ProxyProtocolTrustedSettingKey))
+	}
+	for name, enabled := range map[string]bool{"CrowdSec": crowdsecEnabled, "WAF": wafEnabled, "rate limiting": rateLimitEnabled} {
+		if enabled {
+			opts.Unexported = append(opts.Unexported, name)
+		}
+	}
+	sort.Strings(opts.Unexported)
+
+	if err := m.db.Order("id").Find(&opts.RedirectionHosts).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load redirection hosts for Caddyfile export")
+	}
+	if err := m.db.Order("id").Find(&opts.Streams).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load streams for Caddyfile export")
+	}
+	if err := m.db.Order("id").Find(&opts.ErrorPages).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load error pages for Caddyfile export")
+	}
+	if err := m.db.Order("id").Find(&opts.MaintenanceWindows).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load maintenance windows for Caddyfile export")
+	}
+	if m.sitesDir != "" {
+		// Like AddStaticSites, the root must not depend on Caddy's working directory
+		opts.SitesDir = m.sitesDir
+		if abs, err := filepath.Abs(m.sitesDir); err == nil {
+			opts.SitesDir = abs
+		}
+		if err := m.db.Order("id").Find(&opts.StaticSites).Error; err != nil {
+			logger.Log().WithError(err).Warn("failed to load static sites for Caddyfile export")
+		}
+	}
+
+	return ExportCaddyfile(hosts, opts), nil
+}
+
+// setting returns a setting value, or "" when it is not set.
+func (m *Manager) setting(key string) string {
+	var s models.Setting
+	if err := m.db.Where("key = ?", key).First(&s).Error; err != nil {
+		return ""
+	}
+	return s.Value
+}
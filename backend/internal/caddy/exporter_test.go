@@ -0,0 +1,208 @@
+package caddy
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestExportCaddyfile(t *testing.T) {
+	caPEM, caDER := testCAPEM(t)
+	aclID, caID, certID := uint(1), uint(2), uint(3)
+	hosts := []models.ProxyHost{
+		{
+			ID: 1, UUID: "app", Name: "App", DomainNames: "App.example.com, www.example.com", ForwardHost: "app", ForwardPort: 8080, Enabled: true,
+			HSTSEnabled: true, HSTSSubdomains: true, WebsocketSupport: true, BlockExploits: true,
+			AccessListID: &aclID, AccessList: &models.AccessList{Name: "office", Type: "whitelist", IPRules: `[{"cidr":"10.0.0.0/8"}]`, Enabled: true},
+			Locations: []models.Location{{Path: "/api", ForwardHost: "api", ForwardPort: 9000}},
+		},
+		{
+			ID: 2, UUID: "secure", DomainNames: "secure.example.com", ForwardHost: "s", ForwardPort: 443, Enabled: true,
+			TLSMinVersion: "tls1.2", TLSMaxVersion: "tls1.3", TLSALPN: "h2,http/1.1",
+			ClientAuthMode: ClientAuthRequire, ClientCAID: &caID, ClientCA: &models.ClientCA{Name: "corp", Certificate: caPEM},
+			CertificateID: &certID, Certificate: &models.SSLCertificate{UUID: "cert-uuid", Name: "corp cert", Provider: "custom"},
+			AdvancedConfig: `{"handler": "headers"}`,
+		},
+		{ID: 3, UUID: "old", Name: "Paused", DomainNames: "paused.example.com", ForwardHost: "p", ForwardPort: 80, Enabled: false},
+		{ID: 4, UUID: "dup", DomainNames: "www.example.com", ForwardHost: "d", ForwardPort: 80, Enabled: true},
+	}
+
+	out := ExportCaddyfile(hosts, CaddyfileOptions{
+		ACMEEmail: "ops@example.com", SSLProvider: "letsencrypt", ACMEStaging: true,
+		ACLEnabled: true, AdminWhitelist: "192.168.1.10",
+		Unexported: []string{"WAF"},
+	})
+
+	for _, want := range []string{
+		"\temail ops@example.com\n",
+		"\tcert_issuer acme\n",
+		"\tacme_ca " + letsEncryptStagingCA + "\n",
+		"\tservers {\n\t\tstrict_sni_host on\n\t}\n",
+		"# NOTE: WAF enabled in Charon; these are not exported",
+
+		// Newest host owns www.example.com; the domain is lowercased
+		"# App\n# NOTE: www.example.com served by a newer host and left out here\napp.example.com {\n",
+		"\t@location1 path /api /api/*\n\thandle @location1 {\n\t\t@acl_denied not remote_ip 10.0.0.0/8 192.168.1.10\n",
+		"\t\treverse_proxy api:9000 {\n",
+		"\thandle {\n",
+		"\t\theader Strict-Transport-Security \"max-age=31536000; includeSubDomains\"\n\t\t# NOTE: exploit blocking is enabled in Charon; its handler has no Caddyfile equivalent and is not exported\n",
+		"\t\trespond @acl_denied \"Access denied: IP not in whitelist\" 403\n",
+		"\t\t\theader_up Upgrade {http.request.header.Upgrade}\n",
+
+		"\ttls cert-uuid.crt cert-uuid.key {\n\t\tprotocols tls1.2 tls1.3\n\t\talpn h2 http/1.1\n",
+		"\t\tclient_auth {\n\t\t\tmode require_and_verify\n\t\t\ttrust_pool inline {\n\t\t\t\ttrust_der " + base64.StdEncoding.EncodeToString(caDER) + "\n",
+		"\t# NOTE: advanced config (Caddy JSON handlers) is not exported:\n\t# {\"handler\":\"headers\"}\n",
+
+		"# Paused (disabled)\n# paused.example.com {\n# \treverse_proxy p:80 {\n",
+		"# dup\nwww.example.com {\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func TestExportCaddyfile_AccessLists(t *testing.T) {
+	aclID := uint(1)
+	export := func(acl models.AccessList, opts CaddyfileOptions) string {
+		acl.Enabled = true
+		host := models.ProxyHost{ID: 1, UUID: "h", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, AccessListID: &aclID, AccessList: &acl}
+		return ExportCaddyfile([]models.ProxyHost{host}, opts)
+	}
+	on := CaddyfileOptions{ACLEnabled: true}
+
+	out := export(models.AccessList{Type: "blacklist", IPRules: `[{"cidr":"1.2.3.4"}]`}, CaddyfileOptions{ACLEnabled: true, AdminWhitelist: "10.0.0.1"})
+	assert.Contains(t, out, "\t@acl_denied {\n\t\tremote_ip 1.2.3.4\n\t\tnot remote_ip 10.0.0.1\n\t}\n\trespond @acl_denied \"Access denied: IP blacklisted\" 403\n")
+
+	out = export(models.AccessList{Type: "whitelist", LocalNetworkOnly: true}, on)
+	assert.Contains(t, out, "@acl_denied not remote_ip 10.0.0.0/8 172.16.0.0/12")
+
+	out = export(models.AccessList{Name: "eu", Type: "geo_whitelist", CountryCodes: "DE, FR"}, on)
+	assert.Contains(t, out, "# NOTE: access list \"eu\" needs the caddy-geoip2 module")
+	assert.Contains(t, out, "@acl_denied not expression `{geoip2.country_code} in [\"DE\", \"FR\"]`")
+
+	out = export(models.AccessList{Name: "office", Type: "whitelist", IPRules: `[{"cidr":"10.0.0.0/8"}]`}, CaddyfileOptions{})
+	assert.Contains(t, out, "# NOTE: access list \"office\" is not enforced because ACLs are disabled in Charon")
+	assert.NotContains(t, out, "respond")
+}
+
+func TestExportCaddyfile_OnDemand(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{ID: 1, UUID: "w", DomainNames: "*.example.com", ForwardHost: "w", ForwardPort: 80, Enabled: true},
+		{ID: 2, UUID: "p", DomainNames: "plain.example.com", ForwardHost: "p", ForwardPort: 80, Enabled: true},
+	}
+	out := ExportCaddyfile(hosts, CaddyfileOptions{OnDemandAskURL: "http://127.0.0.1:8080/api/v1/tls/ask"})
+	assert.Contains(t, out, "\t\task http://127.0.0.1:8080/api/v1/tls/ask?secret={$CHARON_ON_DEMAND_ASK_SECRET}\n\t}\n}\n")
+	assert.Contains(t, out, "\t\t# NOTE: Charon's permission endpoint needs its secret: set CHARON_ON_DEMAND_ASK_SECRET to the contents of\n")
+	assert.Contains(t, out, "*.example.com {\n\ttls {\n\t\ton_demand\n\t}\n")
+	assert.Contains(t, out, "plain.example.com {\n\treverse_proxy p:80 {\n")
+}
+
+func TestExportCaddyfile_RedirectionHosts(t *testing.T) {
+	hosts := []models.ProxyHost{{ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}}
+	out := ExportCaddyfile(hosts, CaddyfileOptions{RedirectionHosts: []models.RedirectionHost{
+		{ID: 1, UUID: "old", Name: "Old site", DomainNames: "old.example.com, app.example.com", ForwardScheme: "https", ForwardDomainName: "new.example.com", ForwardHTTPCode: 308, PreservePath: true, Enabled: true},
+		{ID: 2, UUID: "blog", DomainNames: "blog.example.com", TargetURL: "https://example.com/blog", Enabled: true},
+		{ID: 3, UUID: "off", DomainNames: "off.example.com", ForwardDomainName: "example.com", ForwardHTTPCode: 302, Enabled: false},
+	}})
+
+	assert.Contains(t, out, "# Old site (redirect)\n# NOTE: app.example.com served by a proxy host or newer redirect and left out here\nold.example.com {\n\tredir https://new.example.com{http.request.uri} 308\n}\n")
+	assert.Contains(t, out, "# blog (redirect)\nblog.example.com {\n\tredir https://example.com/blog 301\n}\n")
+	assert.Contains(t, out, "# off (redirect, disabled)\n# off.example.com {\n# \tredir {http.request.scheme}://example.com 302\n# }\n")
+}
+
+func TestExportCaddyfile_ErrorPages(t *testing.T) {
+	aclID, hostID, otherID := uint(1), uint(1), uint(2)
+	acl := &models.AccessList{ID: aclID, Type: "whitelist", IPRules: `[{"cidr":"10.0.0.0/8"}]`, Enabled: true}
+	hosts := []models.ProxyHost{
+		{ID: hostID, UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, AccessListID: &aclID, AccessList: acl},
+		{ID: otherID, UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true, AccessListID: &aclID, AccessList: acl},
+	}
+	out := ExportCaddyfile(hosts, CaddyfileOptions{ACLEnabled: true, ErrorPages: []models.ErrorPage{
+		{ID: 1, StatusCode: 404, Body: "<h1>{{host}} not found</h1>", Enabled: true},
+		{ID: 2, ProxyHostID: &hostID, StatusCode: 404, Body: "<h1>Gone</h1>", Enabled: true},
+		{ID: 3, ProxyHostID: &hostID, StatusCode: 403, Body: "<h1>Denied</h1>", Enabled: true},
+		{ID: 4, StatusCode: 502, Body: "down", Enabled: false},
+	}})
+
+	assert.Contains(t, out, "a.example.com {\n\t@acl_denied not remote_ip 10.0.0.0/8\n\terror @acl_denied \"Access denied: IP not in whitelist\" 403\n")
+	assert.Contains(t, out, "\thandle_errors 403 {\n\t\theader Content-Type \"text/html; charset=utf-8\"\n\t\theader Cache-Control no-store\n\t\trespond <h1>Denied</h1> 403\n\t}\n\thandle_errors 404 {\n\t\theader Content-Type \"text/html; charset=utf-8\"\n\t\theader Cache-Control no-store\n\t\trespond <h1>Gone</h1> 404\n\t}\n}\n")
+	// The other host only gets the global page and keeps its plain 403
+	assert.Contains(t, out, "\trespond @acl_denied \"Access denied: IP not in whitelist\" 403\n")
+	assert.Contains(t, out, "\t\trespond \"<h1>{http.request.host} not found</h1>\" 404\n\t}\n}\n")
+	assert.NotContains(t, out, "handle_errors 502")
+}
+
+func TestExportCaddyfile_MaintenanceWindows(t *testing.T) {
+	ends := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	hosts := []models.ProxyHost{{ID: 1, UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}}
+	out := ExportCaddyfile(hosts, CaddyfileOptions{MaintenanceWindows: []models.MaintenanceWindow{
+		{UUID: "nightly", ProxyHostID: 1, Schedule: "0 2 * * *", DurationMinutes: 30, Enabled: true},
+		{UUID: "upgrade", Name: "Upgrade", ProxyHostID: 1, EndsAt: &ends, Enabled: true},
+		{UUID: "off", ProxyHostID: 1, Enabled: false},
+		{UUID: "other", ProxyHostID: 2, Enabled: true},
+	}})
+
+	assert.Contains(t, out, "a.example.com {\n\t# NOTE: maintenance window \"nightly\" (\"0 2 * * *\" for 30 minutes) is scheduled by Charon and not exported\n"+
+		"\t# NOTE: maintenance window \"Upgrade\" (until 2026-01-02T03:00:00Z) is scheduled by Charon and not exported\n\treverse_proxy")
+	assert.NotContains(t, out, "\"off\"")
+	assert.NotContains(t, out, "\"other\"")
+}
+
+func TestExportCaddyfile_StaticSites(t *testing.T) {
+	hosts := []models.ProxyHost{{ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}}
+	out := ExportCaddyfile(hosts, CaddyfileOptions{
+		SitesDir:         "/data/sites",
+		RedirectionHosts: []models.RedirectionHost{{ID: 1, UUID: "r", DomainNames: "old.example.com", TargetURL: "https://example.com", Enabled: true}},
+		StaticSites: []models.StaticSite{
+			{ID: 1, UUID: "docs", Name: "Docs", DomainNames: "docs.example.com, app.example.com, old.example.com", IndexFiles: "index.html, index.htm", SPAFallback: true, Browse: true, Precompressed: true, CurrentRelease: "r1", Enabled: true},
+			{ID: 2, UUID: "plain", DomainNames: "plain.example.com", CurrentRelease: "r1", Enabled: false},
+			{ID: 3, UUID: "empty", DomainNames: "empty.example.com", Enabled: true},
+		},
+	})
+
+	assert.Contains(t, out, "# Docs (static site)\n# NOTE: app.example.com, old.example.com served by a proxy host, redirect or newer static site and left out here\n"+
+		"docs.example.com {\n\troot * /data/sites/docs/current\n\ttry_files {path} {path}/ /index.html\n\tfile_server {\n\t\tindex index.html index.htm\n\t\tbrowse\n\t\tprecompressed br zstd gzip\n\t}\n}\n")
+	assert.Contains(t, out, "# plain (static site, disabled)\n# plain.example.com {\n# \troot * /data/sites/plain/current\n# \tfile_server {\n# \t\tindex index.html\n")
+	assert.Contains(t, out, "# empty (static site)\n# NOTE: not deployed yet and not exported\n")
+}
+
+func TestExportCaddyfile_Streams(t *testing.T) {
+	out := ExportCaddyfile(nil, CaddyfileOptions{Streams: []models.Stream{
+		{ID: 2, UUID: "dns", Name: "DNS", ListenPort: 53, Protocol: "udp", Upstreams: "dns1:53,dns2:53", Enabled: false},
+		{ID: 1, UUID: "ssh", ListenPort: 2222, Upstreams: "git:22", Enabled: true},
+	}})
+
+	assert.Contains(t, out, "# NOTE: these streams run on the layer4 app and are not exported:\n#   ssh: tcp/2222 -> git:22\n#   DNS: udp/53 -> dns1:53,dns2:53, disabled\n")
+	assert.NotContains(t, ExportCaddyfile(nil, CaddyfileOptions{}), "layer4")
+}
+
+func TestManager_ExportCaddyfile(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	require.NoError(t, db.Create(&models.Setting{Key: "caddy.acme_email", Value: "ops@example.com"}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, db.Create(&models.RedirectionHost{UUID: "r", DomainNames: "old.example.com", ForwardDomainName: "a.example.com", Enabled: true}).Error)
+
+	out, err := manager.ExportCaddyfile(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, out, "\temail ops@example.com\n")
+	// block_exploits defaults to true
+	assert.Contains(t, out, "old.example.com {\n\tredir {http.request.scheme}://a.example.com 301\n}\n")
+	assert.Contains(t, out, "a.example.com {\n\t# NOTE: exploit blocking is enabled in Charon; its handler has no Caddyfile equivalent and is not exported\n\treverse_proxy a:80 {\n\t\tflush_interval -1\n\t}\n}\n")
+}
+
+func TestManager_ExportCaddyfile_LeavesOutAskSecret(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	manager.SetOnDemandAskURL("http://127.0.0.1:8080/api/v1/tls/ask?secret=s3cret")
+	require.NoError(t, db.Create(&models.Setting{Key: OnDemandTLSEnabledSettingKey, Value: "true"}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "w", DomainNames: "*.example.com", ForwardHost: "w", ForwardPort: 80, Enabled: true}).Error)
+
+	out, err := manager.ExportCaddyfile(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, out, "ask http://127.0.0.1:8080/api/v1/tls/ask?secret={$CHARON_ON_DEMAND_ASK_SECRET}\n")
+	assert.NotContains(t, out, "s3cret")
+}
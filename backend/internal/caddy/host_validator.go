@@ -0,0 +1,479 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Validation issue severities. Errors mean part of the config will not work as
+// configured; warnings point at settings that are likely mistakes.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Validation issue codes.
+const (
+	IssueDuplicateDomain    = "duplicate_domain"
+	IssueWildcardShadow     = "wildcard_shadow"
+	IssueLocationDuplicate  = "location_duplicate"
+	IssueLocationShadowed   = "location_shadowed"
+	IssueLocationPath       = "location_path"
+	IssueInvalidPlaceholder = "invalid_placeholder"
+	IssueUnknownHandler     = "unknown_handler"
+	IssueMissingHandler     = "missing_handler"
+	IssueInvalidAdvanced    = "invalid_advanced_config"
+	IssuePortConflict       = "port_conflict"
+	IssueReservedPort       = "reserved_port"
+)
+
+// ValidationIssue is one problem found in the proxy host configuration.
+type ValidationIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	// HostUUID and Host identify the host the issue belongs to; both are empty for global issues
+	HostUUID string `json:"host_uuid,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+	// Related lists the UUIDs of other hosts involved, e.g. the host that wins a duplicate domain
+	Related []string `json:"related,omitempty"`
+}
+
+// ValidationReport collects every issue found across the proxy hosts.
+type ValidationReport struct {
+	Valid    bool              `json:"valid"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Issues   []ValidationIssue `json:"issues"`
+}
+
+// ValidationOptions describes the ports Charon and Caddy themselves listen on
+// and the modules of the Caddy binary. Zero ports are not checked.
+type ValidationOptions struct {
+	CharonPort int
+	AdminPort  int
+	// Modules are checked against advanced_config handlers; nil when unknown
+	Modules ModuleSet
+}
+
+// NewValidationOptions builds options from Charon's HTTP port and the Caddy admin API URL.
+func NewValidationOptions(charonPort, adminAPI string) ValidationOptions {
+	var opts ValidationOptions
+	opts.CharonPort, _ = strconv.Atoi(charonPort)
+	if u, err := url.Parse(adminAPI); err == nil {
+		port := u.Port()
+		if port == "" && u.Scheme == "http" {
+			port = "80"
+		}
+		opts.AdminPort, _ = strconv.Atoi(port)
+	}
+	return opts
+}
+
+// serverListenPorts are the ports of the listen addresses GenerateConfig gives charon_server.
+var serverListenPorts = []int{80, 443}
+
+// ForHost returns the issues that concern the host with the given UUID,
+// including issues on other hosts that name it as related.
+func (r ValidationReport) ForHost(uuid string) ValidationReport {
+	var issues []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.HostUUID == uuid || containsString(issue.Related, uuid) {
+			issues = append(issues, issue)
+		}
+	}
+	return newValidationReport(issues)
+}
+
+// HostErrors returns the error-severity issues of the host with the given UUID
+// (its own, and those on other hosts that name it as related) that are not in
+// before, the report without the pending change. Errors that were already there,
+// such as a domain two existing hosts share, don't block edits of either host.
+func (r ValidationReport) HostErrors(uuid string, before ValidationReport) []ValidationIssue {
+	existing := make(map[string]int)
+	for _, issue := range before.ForHost(uuid).Issues {
+		if issue.Severity == SeverityError {
+			existing[issue.key()]++
+		}
+	}
+	var errs []ValidationIssue
+	for _, issue := range r.ForHost(uuid).Issues {
+		if issue.Severity != SeverityError {
+			continue
+		}
+		if key := issue.key(); existing[key] > 0 {
+			existing[key]--
+			continue
+		}
+		errs = append(errs, issue)
+	}
+	return errs
+}
+
+// key identifies an issue across two reports. Messages are left out: they name
+// hosts, which an edit may rename.
+func (i ValidationIssue) key() string {
+	return strings.Join([]string{i.Code, i.HostUUID, i.Field, strings.Join(i.Related, ",")}, "|")
+}
+
+func newValidationReport(issues []ValidationIssue) ValidationReport {
+	report := ValidationReport{Issues: issues}
+	if report.Issues == nil {
+		report.Issues = []ValidationIssue{}
+	}
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+	report.Valid = report.Errors == 0
+	return report
+}
+
+// ValidateHosts checks proxy hosts for problems that a valid Caddy config can
+// still contain: domains claimed twice, routes that can never match, broken
+// header placeholders, unknown handler modules and loops into Charon's own ports.
+// Hosts are expected in the order GenerateConfig receives them (oldest first).
+func ValidateHosts(hosts []models.ProxyHost, opts ValidationOptions) ValidationReport {
+	v := &hostValidator{opts: opts}
+
+	for _, port := range serverListenPorts {
+		if opts.CharonPort == port {
+			v.add(nil, SeverityError, IssueReservedPort, "", fmt.Sprintf("Charon listens on port %d, which Caddy needs for its HTTP server", port))
+		}
+	}
+
+	v.checkDomains(hosts)
+	for i := range hosts {
+		host := &hosts[i]
+		v.checkLocations(host)
+		v.checkForward(host, "forward_host", host.ForwardHost, host.ForwardPort)
+		for j, loc := range host.Locations {
+			v.checkForward(host, fmt.Sprintf("locations[%d].forward_host", j), loc.ForwardHost, loc.ForwardPort)
+		}
+		v.checkAdvancedConfig(host)
+	}
+	return newValidationReport(v.issues)
+}
+
+type hostValidator struct {
+	opts   ValidationOptions
+	issues []ValidationIssue
+}
+
+func (v *hostValidator) add(host *models.ProxyHost, severity, code, field, message string, related ...string) {
+	issue := ValidationIssue{Severity: severity, Code: code, Field: field, Message: message, Related: related}
+	if host != nil {
+		issue.HostUUID = host.UUID
+		issue.Host = hostLabel(host)
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// hostLabel names a host in messages: its name, or its domains when unnamed.
+func hostLabel(host *models.ProxyHost) string {
+	if host.Name != "" {
+		return host.Name
+	}
+	return host.DomainNames
+}
+
+// checkDomains reports domains used by several hosts and hosts shadowed by a
+// wildcard host whose route comes first. GenerateConfig emits routes newest
+// host first and gives each domain to the first host that claims it.
+func (v *hostValidator) checkDomains(hosts []models.ProxyHost) {
+	owner := make(map[string]*models.ProxyHost)
+	var wildcards []struct {
+		pattern string
+		host    *models.ProxyHost
+	}
+
+	for i := len(hosts) - 1; i >= 0; i-- {
+		host := &hosts[i]
+		if !host.Enabled {
+			continue
+		}
+		for _, d := range SplitDomains(host.DomainNames) {
+			if winner, ok := owner[d]; ok {
+				if winner != host {
+					v.add(host, SeverityError, IssueDuplicateDomain, "domain_names",
+						fmt.Sprintf("domain %s is also used by %s, which is newer and receives all of its traffic", d, hostLabel(winner)), winner.UUID)
+				}
+				continue
+			}
+			owner[d] = host
+			for _, w := range wildcards {
+				if w.host != host && wildcardMatches(w.pattern, d) {
+					v.add(host, SeverityError, IssueWildcardShadow, "domain_names",
+						fmt.Sprintf("domain %s is matched first by %s on %s, so this host never receives it", d, w.pattern, hostLabel(w.host)), w.host.UUID)
+					break
+				}
+			}
+			if strings.HasPrefix(d, "*.") {
+				wildcards = append(wildcards, struct {
+					pattern string
+					host    *models.ProxyHost
+				}{d, host})
+			}
+		}
+	}
+
+	for i := range hosts {
+		host := &hosts[i]
+		if host.Enabled {
+			continue
+		}
+		for _, d := range SplitDomains(host.DomainNames) {
+			if other, ok := owner[d]; ok {
+				v.add(host, SeverityWarning, IssueDuplicateDomain, "domain_names",
+					fmt.Sprintf("domain %s is also used by %s; they conflict once this host is enabled", d, hostLabel(other)), other.UUID)
+			}
+		}
+	}
+}
+
+// wildcardMatches reports whether a Caddy host pattern such as *.example.com
+// matches domain. The wildcard stands for exactly one label.
+func wildcardMatches(pattern, domain string) bool {
+	if !strings.HasPrefix(pattern, "*.") || !strings.HasSuffix(domain, pattern[1:]) {
+		return false
+	}
+	label := strings.TrimSuffix(domain, pattern[1:])
+	return label != "" && !strings.Contains(label, ".")
+}
+
+// checkLocations reports location paths that cannot match. Each location
+// matches its path and everything below it, in the order they are listed.
+func (v *hostValidator) checkLocations(host *models.ProxyHost) {
+	seen := make([]string, 0, len(host.Locations))
+	for i, loc := range host.Locations {
+		field := fmt.Sprintf("locations[%d].path", i)
+		if !strings.HasPrefix(loc.Path, "/") {
+			v.add(host, SeverityError, IssueLocationPath, field, fmt.Sprintf("location path %q must start with /", loc.Path))
+			continue
+		}
+		path := strings.TrimSuffix(loc.Path, "/")
+		for _, prev := range seen {
+			switch {
+			case prev == path:
+				v.add(host, SeverityError, IssueLocationDuplicate, field, fmt.Sprintf("location %s is defined more than once; only the first is used", loc.Path))
+			case strings.HasPrefix(path, prev+"/"):
+				v.add(host, SeverityError, IssueLocationShadowed, field, fmt.Sprintf("location %s is listed after %s, which already matches it", loc.Path, prev))
+			default:
+				continue
+			}
+			break
+		}
+		seen = append(seen, path)
+	}
+}
+
+// checkForward reports upstreams on this machine that point back at Caddy's
+// listeners or at the Caddy admin API.
+func (v *hostValidator) checkForward(host *models.ProxyHost, field, forwardHost string, port int) {
+	if !isLoopbackHost(forwardHost) {
+		return
+	}
+	for _, p := range serverListenPorts {
+		if port == p {
+			v.add(host, SeverityError, IssuePortConflict, field, fmt.Sprintf("%s:%d is Caddy's own listener; requests would loop back into Charon", forwardHost, port))
+			return
+		}
+	}
+	if v.opts.AdminPort != 0 && port == v.opts.AdminPort {
+		v.add(host, SeverityError, IssuePortConflict, field, fmt.Sprintf("%s:%d is the Caddy admin API, which must not be exposed", forwardHost, port))
+	}
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(strings.ToLower(host), "[]")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// knownHandlers are the HTTP handler modules in standard Caddy and the plugins
+// in Charon's Caddy build.
+var knownHandlers = map[string]bool{
+	"acme_server": true, "authentication": true, "copy_response": true, "copy_response_headers": true,
+	"encode": true, "error": true, "file_server": true, "headers": true, "intercept": true,
+	"invoke": true, "log_append": true, "map": true, "metrics": true, "push": true,
+	"request_body": true, "reverse_proxy": true, "rewrite": true, "static_response": true,
+	"subroute": true, "templates": true, "tracing": true, "vars": true,
+	// Plugins
+	"authenticator": true, "authorize": true, "crowdsec": true, "geoip2": true, "rate_limit": true, "waf": true,
+}
+
+// checkAdvancedConfig walks the handlers in advanced_config, including those
+// nested in subroutes, for unknown modules and broken header placeholders.
+func (v *hostValidator) checkAdvancedConfig(host *models.ProxyHost) {
+	if host.AdvancedConfig == "" {
+		return
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(host.AdvancedConfig), &parsed); err != nil {
+		v.add(host, SeverityError, IssueInvalidAdvanced, "advanced_config", "advanced_config is not valid JSON: "+err.Error())
+		return
+	}
+
+	var walk func(node interface{}, path string)
+	walk = func(node interface{}, path string) {
+		switch n := node.(type) {
+		case []interface{}:
+			for i, item := range n {
+				walk(item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case map[string]interface{}:
+			name, _ := n["handler"].(string)
+			switch {
+			case name == "":
+				v.add(host, SeverityWarning, IssueInvalidAdvanced, path, "object has no \"handler\" field and is ignored")
+			case !v.opts.Modules.Has("http.handlers." + name):
+				v.add(host, SeverityWarning, IssueMissingHandler, path, fmt.Sprintf("handler %q is not in the Caddy binary; the host's advanced_config is left out of the config", name))
+			case v.opts.Modules == nil && !knownHandlers[name]:
+				v.add(host, SeverityWarning, IssueUnknownHandler, path, fmt.Sprintf("handler %q is not a known Caddy module; Caddy will reject it unless it is built in", name))
+			case name == "headers":
+				v.checkHeaderPlaceholders(host, n, path)
+			}
+			if handle, ok := n["handle"].([]interface{}); ok {
+				walk(handle, path+".handle")
+			}
+			if routes, ok := n["routes"].([]interface{}); ok {
+				for i, r := range routes {
+					if route, ok := r.(map[string]interface{}); ok {
+						if handle, ok := route["handle"].([]interface{}); ok {
+							walk(handle, fmt.Sprintf("%s.routes[%d].handle", path, i))
+						}
+					}
+				}
+			}
+		}
+	}
+	walk(parsed, "advanced_config")
+}
+
+// checkHeaderPlaceholders validates the placeholders in a headers handler's values.
+func (v *hostValidator) checkHeaderPlaceholders(host *models.ProxyHost, handler map[string]interface{}, path string) {
+	var values []string
+	var collect func(node interface{})
+	collect = func(node interface{}) {
+		switch n := node.(type) {
+		case string:
+			values = append(values, n)
+		case []interface{}:
+			for _, item := range n {
+				collect(item)
+			}
+		case map[string]interface{}:
+			for _, item := range n {
+				collect(item)
+			}
+		}
+	}
+	for _, side := range []string{"request", "response"} {
+		if ops, ok := handler[side].(map[string]interface{}); ok {
+			for _, op := range []string{"set", "add", "replace"} {
+				collect(ops[op])
+			}
+		}
+	}
+	sort.Strings(values)
+
+	for _, value := range values {
+		if problem := placeholderProblem(value); problem != "" {
+			v.add(host, SeverityError, IssueInvalidPlaceholder, path, fmt.Sprintf("header value %q: %s", value, problem))
+		}
+	}
+}
+
+// placeholderNamespaces are the placeholder prefixes Caddy resolves in HTTP handlers.
+var placeholderNamespaces = []string{"http.", "env.", "file.", "system.", "time."}
+
+// caddyfileShorthands maps Caddyfile-only placeholders to their JSON config form.
+var caddyfileShorthands = map[string]string{
+	"host":              "http.request.host",
+	"hostport":          "http.request.hostport",
+	"method":            "http.request.method",
+	"path":              "http.request.uri.path",
+	"query":             "http.request.uri.query",
+	"remote":            "http.request.remote",
+	"remote_host":       "http.request.remote.host",
+	"remote_port":       "http.request.remote.port",
+	"client_ip":         "http.vars.client_ip",
+	"scheme":            "http.request.scheme",
+	"uri":               "http.request.uri",
+	"tls_version":       "http.request.tls.version",
+	"upstream_hostport": "http.reverse_proxy.upstream.hostport",
+}
+
+// caddyfileShorthandPrefixes maps Caddyfile-only placeholder prefixes to their JSON config form.
+var caddyfileShorthandPrefixes = map[string]string{
+	"header.": "http.request.header.",
+	"query.":  "http.request.uri.query.",
+	"cookie.": "http.request.cookie.",
+	"vars.":   "http.vars.",
+	"labels.": "http.request.host.labels.",
+	"re.":     "http.regexp.",
+}
+
+// placeholderProblem describes what is wrong with the placeholders in value, or returns "".
+func placeholderProblem(value string) string {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++ // escaped brace
+		case '}':
+			return "unexpected }"
+		case '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return "unterminated placeholder"
+			}
+			name := value[i+1 : i+end]
+			i += end
+			if name == "" || strings.ContainsAny(name, "{ ") {
+				return fmt.Sprintf("invalid placeholder {%s}", name)
+			}
+			if full, ok := caddyfileShorthands[name]; ok {
+				return fmt.Sprintf("{%s} only works in a Caddyfile; use {%s}", name, full)
+			}
+			for prefix, full := range caddyfileShorthandPrefixes {
+				if strings.HasPrefix(name, prefix) {
+					return fmt.Sprintf("{%s} only works in a Caddyfile; use {%s%s}", name, full, strings.TrimPrefix(name, prefix))
+				}
+			}
+			known := false
+			for _, ns := range placeholderNamespaces {
+				if strings.HasPrefix(name, ns) {
+					known = true
+					break
+				}
+			}
+			if !known {
+				return fmt.Sprintf("unknown placeholder {%s}", name)
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
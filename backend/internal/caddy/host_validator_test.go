@@ -0,0 +1,126 @@
+package caddy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateHosts_CollectsAllIssues(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "old", Name: "Old", DomainNames: "a.example.com, shared.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+		{UUID: "new", Name: "New", DomainNames: "Shared.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+		{UUID: "off", DomainNames: "a.example.com", ForwardHost: "app", ForwardPort: 80},
+		{
+			UUID: "paths", Name: "Paths", DomainNames: "paths.example.com", ForwardHost: "localhost", ForwardPort: 443, Enabled: true,
+			Locations: []models.Location{
+				{Path: "/api", ForwardHost: "api", ForwardPort: 80},
+				{Path: "/api/v1", ForwardHost: "api", ForwardPort: 80},
+				{Path: "/api/", ForwardHost: "api", ForwardPort: 80},
+				{Path: "admin", ForwardHost: "127.0.0.1", ForwardPort: 2019},
+				{Path: "/apiary", ForwardHost: "api", ForwardPort: 80},
+			},
+		},
+	}
+
+	report := ValidateHosts(hosts, ValidationOptions{CharonPort: 80, AdminPort: 2019})
+	assert.False(t, report.Valid)
+
+	type issue struct{ code, host, field string }
+	var got []issue
+	for _, i := range report.Issues {
+		got = append(got, issue{i.Code, i.HostUUID, i.Field})
+	}
+	assert.Equal(t, []issue{
+		{IssueReservedPort, "", ""},
+		{IssueDuplicateDomain, "old", "domain_names"},
+		{IssueDuplicateDomain, "off", "domain_names"},
+		{IssueLocationShadowed, "paths", "locations[1].path"},
+		{IssueLocationDuplicate, "paths", "locations[2].path"},
+		{IssueLocationPath, "paths", "locations[3].path"},
+		{IssuePortConflict, "paths", "forward_host"},
+		{IssuePortConflict, "paths", "locations[3].forward_host"},
+	}, got)
+	assert.Equal(t, 7, report.Errors)
+	assert.Equal(t, 1, report.Warnings)
+	assert.Equal(t, []string{"new"}, report.Issues[1].Related)
+	assert.Equal(t, "domain shared.example.com is also used by New, which is newer and receives all of its traffic", report.Issues[1].Message)
+
+	// The newer host is told about the duplicate it causes
+	assert.Len(t, report.HostErrors("new", ValidationReport{}), 1)
+	assert.Empty(t, report.HostErrors("off", ValidationReport{}))
+	// Errors that were there before the edit don't count against it
+	assert.Empty(t, report.HostErrors("new", report))
+	assert.Empty(t, report.HostErrors("old", report))
+	assert.Equal(t, 1, report.ForHost("off").Warnings)
+}
+
+func TestValidateHosts_WildcardShadow(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "app", DomainNames: "app.example.com, deep.app.example.com", Enabled: true},
+		{UUID: "wild", DomainNames: "*.example.com", Enabled: true},
+	}
+	report := ValidateHosts(hosts, ValidationOptions{})
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, IssueWildcardShadow, report.Issues[0].Code)
+	assert.Equal(t, "app", report.Issues[0].HostUUID)
+	assert.Equal(t, []string{"wild"}, report.Issues[0].Related)
+	assert.Contains(t, report.Issues[0].Message, "app.example.com is matched first by *.example.com")
+
+	// A newer specific host is routed before the wildcard
+	hosts[0], hosts[1] = hosts[1], hosts[0]
+	assert.True(t, ValidateHosts(hosts, ValidationOptions{}).Valid)
+}
+
+func TestValidateHosts_AdvancedConfig(t *testing.T) {
+	host := models.ProxyHost{UUID: "h", DomainNames: "h.example.com", Enabled: true, AdvancedConfig: `[
+		{"handler": "headers", "request": {"set": {"X-Real-IP": ["{remote_host}"], "X-Id": ["{http.request.uuid}"]}},
+		 "response": {"add": {"X-User": ["{header.X-User}"], "X-Env": ["{env.STAGE"]}}},
+		{"handler": "forward_auth"},
+		{"handler": "subroute", "routes": [{"handle": [{"handler": "headers", "response": {"set": {"X-A": ["{bogus.value}"]}}}]}]},
+		{"note": "not a handler"}
+	]`}
+
+	report := ValidateHosts([]models.ProxyHost{host}, ValidationOptions{})
+	var messages []string
+	for _, i := range report.Issues {
+		messages = append(messages, i.Field+": "+i.Message)
+	}
+	assert.Equal(t, []string{
+		`advanced_config[0]: header value "{env.STAGE": unterminated placeholder`,
+		`advanced_config[0]: header value "{header.X-User}": {header.X-User} only works in a Caddyfile; use {http.request.header.X-User}`,
+		`advanced_config[0]: header value "{remote_host}": {remote_host} only works in a Caddyfile; use {http.request.remote.host}`,
+		`advanced_config[1]: handler "forward_auth" is not a known Caddy module; Caddy will reject it unless it is built in`,
+		`advanced_config[2].routes[0].handle[0]: header value "{bogus.value}": unknown placeholder {bogus.value}`,
+		`advanced_config[3]: object has no "handler" field and is ignored`,
+	}, messages)
+
+	host.AdvancedConfig = "{"
+	report = ValidateHosts([]models.ProxyHost{host}, ValidationOptions{})
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, IssueInvalidAdvanced, report.Issues[0].Code)
+
+	// With the binary's modules known, they decide instead of the built-in list
+	host.AdvancedConfig = `[{"handler": "headers"}, {"handler": "forward_auth"}]`
+	report = ValidateHosts([]models.ProxyHost{host}, ValidationOptions{Modules: ParseModuleList([]byte(listModulesOutput))})
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, IssueMissingHandler, report.Issues[0].Code)
+	assert.Equal(t, SeverityWarning, report.Issues[0].Severity)
+	assert.Equal(t, "advanced_config[1]", report.Issues[0].Field)
+}
+
+func TestPlaceholderProblem(t *testing.T) {
+	assert.Empty(t, placeholderProblem("plain"))
+	assert.Empty(t, placeholderProblem(`\{literal\}`))
+	assert.Empty(t, placeholderProblem("{http.request.host}:{system.hostname}"))
+	assert.Equal(t, "unexpected }", placeholderProblem("a}"))
+	assert.Equal(t, "invalid placeholder {}", placeholderProblem("{}"))
+}
+
+func TestNewValidationOptions(t *testing.T) {
+	assert.Equal(t, ValidationOptions{CharonPort: 8080, AdminPort: 2019}, NewValidationOptions("8080", "http://localhost:2019"))
+	assert.Equal(t, ValidationOptions{AdminPort: 80}, NewValidationOptions("", "http://caddy"))
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -33,6 +32,17 @@ type CaddyConfig struct {
 // CaddyApps contains application-specific configurations.
 type CaddyApps struct {
 	HTTP *CaddyHTTP `json:"http,omitempty"`
+	TLS  *CaddyTLS  `json:"tls,omitempty"`
+}
+
+// CaddyTLS represents the TLS app; only automation policies are read (for `tls internal`).
+type CaddyTLS struct {
+	Automation *struct {
+		Policies []struct {
+			Subjects []string                 `json:"subjects,omitempty"`
+			Issuers  []map[string]interface{} `json:"issuers,omitempty"`
+		} `json:"policies,omitempty"`
+	} `json:"automation,omitempty"`
 }
 
 // CaddyHTTP represents the HTTP app configuration.
@@ -64,6 +74,36 @@ type CaddyHandler struct {
 	Upstreams interface{} `json:"upstreams,omitempty"`
 	Headers   interface{} `json:"headers,omitempty"`
 	Routes    interface{} `json:"routes,omitempty"` // For subroute handlers
+
+	// raw keeps every field of the decoded handler for the directive mapping
+	raw map[string]interface{}
+}
+
+// UnmarshalJSON decodes the known fields and keeps the full handler.
+func (h *CaddyHandler) UnmarshalJSON(data []byte) error {
+	type plain CaddyHandler
+	if err := json.Unmarshal(data, (*plain)(h)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &h.raw)
+}
+
+// fields returns the handler as a generic map.
+func (h *CaddyHandler) fields() map[string]interface{} {
+	if h.raw != nil {
+		return h.raw
+	}
+	m := map[string]interface{}{"handler": h.Handler}
+	if h.Upstreams != nil {
+		m["upstreams"] = h.Upstreams
+	}
+	if h.Headers != nil {
+		m["headers"] = h.Headers
+	}
+	if h.Routes != nil {
+		m["routes"] = h.Routes
+	}
+	return m
 }
 
 // ParsedHost represents a single host detected during Caddyfile import.
@@ -76,6 +116,50 @@ type ParsedHost struct {
 	WebsocketSupport bool     `json:"websocket_support"`
 	RawJSON          string   `json:"raw_json"` // Original Caddy JSON for this route
 	Warnings         []string `json:"warnings"` // Unsupported features
+
+	// Upstreams lists every upstream of the main reverse_proxy; Charon proxies to the first
+	Upstreams      []string           `json:"upstreams,omitempty"`
+	Locations      []ParsedLocation   `json:"locations,omitempty"`
+	HSTSEnabled    bool               `json:"hsts_enabled,omitempty"`
+	HSTSSubdomains bool               `json:"hsts_subdomains,omitempty"`
+	AccessList     *ParsedAccessList  `json:"access_list,omitempty"`
+	AdvancedConfig string             `json:"advanced_config,omitempty"` // Handlers kept as Caddy JSON
+	Certificate    *ParsedCertificate `json:"certificate,omitempty"`     // Custom certificate (Nginx Proxy Manager imports)
+	Redirect       *ParsedRedirect    `json:"redirect,omitempty"`        // Set for hosts that only redirect
+	Report         []DirectiveOutcome `json:"report"`                    // What happened to each directive
+}
+
+// ParsedRedirect is where a redirect-only host sends its requests. Such hosts
+// are committed as redirection hosts instead of proxy hosts.
+type ParsedRedirect struct {
+	TargetURL         string `json:"target_url,omitempty"`
+	ForwardScheme     string `json:"forward_scheme,omitempty"`
+	ForwardDomainName string `json:"forward_domain_name,omitempty"`
+	ForwardHTTPCode   int    `json:"forward_http_code"`
+	PreservePath      bool   `json:"preserve_path"`
+}
+
+// ParsedLocation is a path-matched upstream (handle/handle_path with reverse_proxy).
+type ParsedLocation struct {
+	Path          string `json:"path"`
+	ForwardScheme string `json:"forward_scheme"`
+	ForwardHost   string `json:"forward_host"`
+	ForwardPort   int    `json:"forward_port"`
+}
+
+// ParsedAccessList is an IP allow or block list built from remote_ip matchers
+// or an Nginx Proxy Manager access list.
+type ParsedAccessList struct {
+	Name  string   `json:"name,omitempty"` // Hosts sharing a named list share one access list
+	Type  string   `json:"type"`           // whitelist, blacklist
+	CIDRs []string `json:"cidrs"`
+}
+
+// ParsedCertificate is a custom certificate carried over with its key.
+type ParsedCertificate struct {
+	Name        string `json:"name"`
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key,omitempty"`
 }
 
 // ImportResult contains parsed hosts and detected conflicts.
@@ -83,6 +167,22 @@ type ImportResult struct {
 	Hosts     []ParsedHost `json:"hosts"`
 	Conflicts []string     `json:"conflicts"`
 	Errors    []string     `json:"errors"`
+	Warnings  []string     `json:"warnings,omitempty"` // Findings that apply to no single host
+}
+
+// Redacted returns a copy of the result without private keys, for previews.
+func (r ImportResult) Redacted() ImportResult {
+	hosts := make([]ParsedHost, len(r.Hosts))
+	for i, h := range r.Hosts {
+		if h.Certificate != nil {
+			cert := *h.Certificate
+			cert.PrivateKey = ""
+			h.Certificate = &cert
+		}
+		hosts[i] = h
+	}
+	r.Hosts = hosts
+	return r
 }
 
 // Importer handles Caddyfile parsing and conversion to Charon models.
@@ -183,6 +283,7 @@ func (i *Importer) ExtractHosts(caddyJSON []byte) (*ImportResult, error) {
 	}
 
 	seenDomains := make(map[string]bool)
+	internalTLS := internalTLSSubjects(config.Apps.TLS)
 
 	for serverName, server := range config.Apps.HTTP.Servers {
 		// Detect if this server uses SSL based on listen address or TLS policies
@@ -213,68 +314,17 @@ func (i *Importer) ExtractHosts(caddyJSON []byte) (*ImportResult, error) {
 						SSLForced:   strings.HasPrefix(domain, "https") || serverUsesSSL,
 					}
 
-					// Find reverse_proxy handler (may be nested in subroute)
-					handlers := i.extractHandlers(route.Handle)
-
-					for _, handler := range handlers {
-						if handler.Handler == "reverse_proxy" {
-							upstreams, _ := handler.Upstreams.([]interface{})
-							if len(upstreams) > 0 {
-								if upstream, ok := upstreams[0].(map[string]interface{}); ok {
-									dial, _ := upstream["dial"].(string)
-									if dial != "" {
-										hostStr, portStr, err := net.SplitHostPort(dial)
-										if err == nil && !forceSplitFallback {
-											host.ForwardHost = hostStr
-											if _, err := fmt.Sscanf(portStr, "%d", &host.ForwardPort); err != nil {
-												host.ForwardPort = 80
-											}
-										} else {
-											// Fallback: assume dial is just the host or has some other format
-											// Try to handle simple "host:port" manually if net.SplitHostPort failed for some reason
-											// or assume it's just a host
-											parts := strings.Split(dial, ":")
-											if len(parts) == 2 {
-												host.ForwardHost = parts[0]
-												if _, err := fmt.Sscanf(parts[1], "%d", &host.ForwardPort); err != nil {
-													host.ForwardPort = 80
-												}
-											} else {
-												host.ForwardHost = dial
-												host.ForwardPort = 80
-											}
-										}
-									}
-								}
-							}
-
-							// Check for websocket support
-							if headers, ok := handler.Headers.(map[string]interface{}); ok {
-								if upgrade, ok := headers["Upgrade"].([]interface{}); ok {
-									for _, v := range upgrade {
-										if v == "websocket" {
-											host.WebsocketSupport = true
-											break
-										}
-									}
-								}
-							}
-
-							// Default scheme
-							host.ForwardScheme = "http"
-							if host.SSLForced {
-								host.ForwardScheme = "https"
-							}
-						}
-
-						// Detect unsupported features
-						if handler.Handler == "rewrite" {
-							host.Warnings = append(host.Warnings, "Rewrite rules not supported - manual configuration required")
-						}
-						if handler.Handler == "file_server" {
-							host.Warnings = append(host.Warnings, "File server directives not supported")
-						}
+					// Map every directive of the site onto Charon concepts and report the outcome
+					imp := &hostImport{host: &host}
+					handles := make([]map[string]interface{}, 0, len(route.Handle))
+					for _, h := range route.Handle {
+						handles = append(handles, h.fields())
+					}
+					imp.walk(handles, nil)
+					if internalTLS[strings.ToLower(domain)] {
+						imp.report("tls", nil, ImportApproximated, "tls internal: Charon uses its ACME issuers for this domain instead of Caddy's internal CA")
 					}
+					imp.finish()
 
 					// Store raw JSON for this route
 					routeJSON, _ := json.Marshal(map[string]interface{}{
@@ -303,15 +353,40 @@ func (i *Importer) ImportFile(caddyfilePath string) (*ImportResult, error) {
 	return i.ExtractHosts(caddyJSON)
 }
 
-// ConvertToProxyHosts converts parsed hosts to ProxyHost models.
+// ConvertToProxyHosts converts parsed hosts to ProxyHost models. Locations, access
+// lists and certificates are attached as new associations and are created with the
+// host; hosts with the same access list or certificate share one record.
 func ConvertToProxyHosts(parsedHosts []ParsedHost) []models.ProxyHost {
 	hosts := make([]models.ProxyHost, 0, len(parsedHosts))
+	accessLists := make(map[string]*models.AccessList)
+	certificates := make(map[ParsedCertificate]*models.SSLCertificate)
 
 	for _, parsed := range parsedHosts {
 		if parsed.ForwardHost == "" || parsed.ForwardPort == 0 {
 			continue // Skip invalid entries
 		}
 
+		var acl *models.AccessList
+		if parsed.AccessList != nil {
+			key := parsed.AccessList.Type + "|" + strings.Join(parsed.AccessList.CIDRs, ",")
+			if parsed.AccessList.Name == "" {
+				key = parsed.DomainNames + "|" + key
+			} else {
+				key = parsed.AccessList.Name + "|" + key
+			}
+			if acl = accessLists[key]; acl == nil {
+				acl = convertAccessList(parsed.DomainNames, parsed.AccessList)
+				accessLists[key] = acl
+			}
+		}
+		var cert *models.SSLCertificate
+		if parsed.Certificate != nil {
+			if cert = certificates[*parsed.Certificate]; cert == nil {
+				cert = convertCertificate(parsed.Certificate)
+				certificates[*parsed.Certificate] = cert
+			}
+		}
+
 		hosts = append(hosts, models.ProxyHost{
 			Name:             parsed.DomainNames, // Can be customized by user during review
 			DomainNames:      parsed.DomainNames,
@@ -320,12 +395,41 @@ func ConvertToProxyHosts(parsedHosts []ParsedHost) []models.ProxyHost {
 			ForwardPort:      parsed.ForwardPort,
 			SSLForced:        parsed.SSLForced,
 			WebsocketSupport: parsed.WebsocketSupport,
+			HSTSEnabled:      parsed.HSTSEnabled,
+			HSTSSubdomains:   parsed.HSTSSubdomains,
+			AdvancedConfig:   parsed.AdvancedConfig,
+			Locations:        convertLocations(parsed.Locations, parsed.AdvancedConfig != ""),
+			AccessList:       acl,
+			Certificate:      cert,
 		})
 	}
 
 	return hosts
 }
 
+// ConvertToRedirectionHosts converts parsed redirect-only hosts to
+// RedirectionHost models; ConvertToProxyHosts takes the others.
+func ConvertToRedirectionHosts(parsedHosts []ParsedHost) []models.RedirectionHost {
+	var redirects []models.RedirectionHost
+	for _, parsed := range parsedHosts {
+		if parsed.Redirect == nil || parsed.ForwardHost != "" {
+			continue
+		}
+		redirects = append(redirects, models.RedirectionHost{
+			Name:              parsed.DomainNames, // Can be customized by user during review
+			DomainNames:       parsed.DomainNames,
+			TargetURL:         parsed.Redirect.TargetURL,
+			ForwardScheme:     parsed.Redirect.ForwardScheme,
+			ForwardDomainName: parsed.Redirect.ForwardDomainName,
+			ForwardHTTPCode:   parsed.Redirect.ForwardHTTPCode,
+			PreservePath:      parsed.Redirect.PreservePath,
+			SSLForced:         parsed.SSLForced,
+			Enabled:           true,
+		})
+	}
+	return redirects
+}
+
 // ValidateCaddyBinary checks if the Caddy binary is available.
 func (i *Importer) ValidateCaddyBinary() error {
 	_, err := i.executor.Execute(i.caddyBinaryPath, "version")
@@ -0,0 +1,594 @@
+package caddy
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Outcomes of a directive in the import report.
+const (
+	ImportImported     = "imported"
+	ImportApproximated = "approximated"
+	ImportDropped      = "dropped"
+)
+
+// DirectiveOutcome records how one Caddyfile directive was mapped during import.
+type DirectiveOutcome struct {
+	Directive string `json:"directive"`      // Caddyfile directive, e.g. reverse_proxy, handle_path, basicauth
+	Path      string `json:"path,omitempty"` // Path matcher the directive sits under
+	Status    string `json:"status"`         // imported, approximated, dropped
+	Detail    string `json:"detail,omitempty"`
+}
+
+// hostImport walks the handlers of one site and fills in the parsed host.
+type hostImport struct {
+	host     *ParsedHost
+	advanced []map[string]interface{}
+	// proxyWithoutUpstream is set when a reverse_proxy had no static upstream to import
+	proxyWithoutUpstream bool
+	// redirect is a site-level redir, imported as a redirection host unless the site also proxies
+	redirect *ParsedRedirect
+}
+
+func (imp *hostImport) report(directive string, paths []string, status, detail string) {
+	imp.host.Report = append(imp.host.Report, DirectiveOutcome{
+		Directive: directive,
+		Path:      strings.Join(paths, " "),
+		Status:    status,
+		Detail:    detail,
+	})
+	if status != ImportImported {
+		imp.host.Warnings = append(imp.host.Warnings, detail)
+	}
+}
+
+// walk maps handlers in order. paths are the path matchers of the enclosing
+// handle/handle_path block, nil at site level.
+func (imp *hostImport) walk(handlers []map[string]interface{}, paths []string) {
+	for _, h := range handlers {
+		name, _ := h["handler"].(string)
+		switch name {
+		case "subroute":
+			routes, _ := h["routes"].([]interface{})
+			for _, r := range routes {
+				if route, ok := r.(map[string]interface{}); ok {
+					imp.walkRoute(route, paths)
+				}
+			}
+		case "reverse_proxy":
+			imp.proxy(h, paths)
+		case "rewrite":
+			if prefix, ok := h["strip_path_prefix"].(string); ok && prefix != "" {
+				imp.report("handle_path", paths, ImportApproximated, fmt.Sprintf("handle_path strips %s before proxying; Charon locations forward the full path", prefix))
+				continue
+			}
+			imp.report("rewrite", paths, ImportDropped, "Rewrite rules not supported - manual configuration required")
+		case "static_response":
+			imp.staticResponse(h, paths)
+		case "authentication":
+			imp.keepAdvanced("basicauth", h, paths)
+		case "headers":
+			imp.headers(h, paths)
+		case "file_server":
+			imp.report("file_server", paths, ImportDropped, "File server directives not supported")
+		default:
+			imp.report(name, paths, ImportDropped, fmt.Sprintf("%s handler is not supported", name))
+		}
+	}
+}
+
+// walkRoute maps one route of a subroute. Routes matched by path become locations,
+// remote_ip routes that refuse requests become access lists; other matchers are dropped.
+func (imp *hostImport) walkRoute(route map[string]interface{}, paths []string) {
+	handlers := handlerMaps(route["handle"])
+	matchSets, _ := route["match"].([]interface{})
+	if len(matchSets) == 0 {
+		imp.walk(handlers, paths)
+		return
+	}
+
+	if acl := accessListFromRoute(matchSets, handlers); acl != nil {
+		switch {
+		case len(paths) > 0:
+			imp.report("remote_ip", paths, ImportDropped, "IP rules inside a path block are not supported")
+		case imp.host.AccessList != nil:
+			imp.report("remote_ip", paths, ImportDropped, "only one IP access list per host is supported")
+		default:
+			imp.host.AccessList = acl
+			imp.report("remote_ip", paths, ImportImported, fmt.Sprintf("as %s access list", acl.Type))
+		}
+		return
+	}
+
+	var matched []string
+	for _, set := range matchSets {
+		m, _ := set.(map[string]interface{})
+		var others []string
+		for k := range m {
+			if k != "path" {
+				others = append(others, k)
+			}
+		}
+		if len(others) > 0 {
+			sort.Strings(others)
+			imp.report("matcher", paths, ImportDropped, fmt.Sprintf("routes matched by %s are not supported and were skipped", strings.Join(others, ", ")))
+			return
+		}
+		list, _ := m["path"].([]interface{})
+		for _, p := range list {
+			if s, ok := p.(string); ok {
+				matched = append(matched, s)
+			}
+		}
+	}
+	imp.walk(handlers, matched)
+}
+
+func (imp *hostImport) proxy(h map[string]interface{}, paths []string) {
+	upstreams, _ := h["upstreams"].([]interface{})
+	var dials []string
+	for _, u := range upstreams {
+		if m, ok := u.(map[string]interface{}); ok {
+			if dial, _ := m["dial"].(string); dial != "" {
+				dials = append(dials, dial)
+			}
+		}
+	}
+	if len(dials) == 0 {
+		imp.proxyWithoutUpstream = true
+		return
+	}
+
+	scheme := "http"
+	if transport, ok := h["transport"].(map[string]interface{}); ok && transport["tls"] != nil {
+		scheme = "https"
+	}
+	forwardHost, forwardPort := parseDial(dials[0])
+
+	locPaths := locationPaths(paths)
+	if len(locPaths) == 0 {
+		imp.host.ForwardHost, imp.host.ForwardPort = forwardHost, forwardPort
+		imp.host.Upstreams = dials
+
+		// Check for websocket support
+		if headers, ok := h["headers"].(map[string]interface{}); ok {
+			if upgrade, ok := headers["Upgrade"].([]interface{}); ok {
+				for _, v := range upgrade {
+					if v == "websocket" {
+						imp.host.WebsocketSupport = true
+						break
+					}
+				}
+			}
+		}
+
+		// Default scheme
+		imp.host.ForwardScheme = "http"
+		if imp.host.SSLForced || scheme == "https" {
+			imp.host.ForwardScheme = "https"
+		}
+	} else {
+		for _, p := range locPaths {
+			imp.host.Locations = append(imp.host.Locations, ParsedLocation{Path: p, ForwardScheme: scheme, ForwardHost: forwardHost, ForwardPort: forwardPort})
+		}
+	}
+
+	switch {
+	case len(dials) > 1:
+		imp.report("reverse_proxy", paths, ImportApproximated, fmt.Sprintf("%d upstreams; Charon proxies to the first (%s)", len(dials), dials[0]))
+	case len(locPaths) > 0:
+		imp.report("reverse_proxy", paths, ImportImported, "as location "+strings.Join(locPaths, ", "))
+	default:
+		imp.report("reverse_proxy", paths, ImportImported, "")
+	}
+}
+
+func (imp *hostImport) staticResponse(h map[string]interface{}, paths []string) {
+	if abort, _ := h["abort"].(bool); abort {
+		imp.report("abort", paths, ImportDropped, "abort is not supported")
+		return
+	}
+	status := fmt.Sprint(h["status_code"])
+	if headers, ok := h["headers"].(map[string]interface{}); ok {
+		if loc, ok := headers["Location"].([]interface{}); ok && len(loc) > 0 && strings.HasPrefix(status, "3") {
+			if len(paths) > 0 {
+				imp.report("redir", paths, ImportDropped, fmt.Sprintf("redirect to %v (%s) inside a path block is not supported; create it manually", loc[0], status))
+				return
+			}
+			code, _ := strconv.Atoi(status)
+			redirect, err := parseRedirect(imp.host.DomainNames, fmt.Sprint(loc[0]), code)
+			if err != nil {
+				imp.report("redir", paths, ImportDropped, fmt.Sprintf("redirect to %v (%s) is not supported: %v", loc[0], status, err))
+				return
+			}
+			imp.redirect = redirect
+			return
+		}
+	}
+	imp.report("respond", paths, ImportDropped, "static responses are not supported")
+}
+
+// parseRedirect maps a redirect's Location and status code onto a redirection
+// host. Only {http.request.scheme} at the start and {http.request.uri} at the
+// end are understood, as Charon writes them.
+func parseRedirect(domains, location string, code int) (*ParsedRedirect, error) {
+	target, preserve := strings.CutSuffix(location, "{http.request.uri}")
+	r := models.RedirectionHost{DomainNames: domains, ForwardHTTPCode: code, PreservePath: preserve}
+	if domain, ok := strings.CutPrefix(target, "{http.request.scheme}://"); ok {
+		r.ForwardScheme, r.ForwardDomainName = "auto", domain
+	} else {
+		r.TargetURL = target
+	}
+	if strings.ContainsAny(r.TargetURL+r.ForwardDomainName, "{}") {
+		return nil, fmt.Errorf("placeholders other than {uri} are not supported")
+	}
+	if err := ValidateRedirectionHost(&r); err != nil {
+		return nil, err
+	}
+	return &ParsedRedirect{
+		TargetURL:         r.TargetURL,
+		ForwardScheme:     r.ForwardScheme,
+		ForwardDomainName: r.ForwardDomainName,
+		ForwardHTTPCode:   r.ForwardHTTPCode,
+		PreservePath:      r.PreservePath,
+	}, nil
+}
+
+// headers imports a Strict-Transport-Security header as HSTS and keeps other header
+// rules as advanced config.
+func (imp *hostImport) headers(h map[string]interface{}, paths []string) {
+	if len(paths) == 0 {
+		if resp, ok := h["response"].(map[string]interface{}); ok && len(h) == 2 && len(resp) == 1 {
+			if set, ok := resp["set"].(map[string]interface{}); ok && len(set) == 1 {
+				if values, ok := set["Strict-Transport-Security"].([]interface{}); ok && len(values) == 1 {
+					value := fmt.Sprint(values[0])
+					imp.host.HSTSEnabled = true
+					imp.host.HSTSSubdomains = strings.Contains(strings.ToLower(value), "includesubdomains")
+					if strings.Contains(value, "max-age=31536000") {
+						imp.report("header", paths, ImportImported, "as HSTS")
+					} else {
+						imp.report("header", paths, ImportApproximated, fmt.Sprintf("HSTS %q imported with Charon's max-age of one year", value))
+					}
+					return
+				}
+			}
+		}
+	}
+	imp.keepAdvanced("header", h, paths)
+}
+
+// keepAdvanced keeps a handler as advanced config, which Charon runs before the reverse proxy.
+func (imp *hostImport) keepAdvanced(directive string, h map[string]interface{}, paths []string) {
+	imp.advanced = append(imp.advanced, h)
+	if len(paths) > 0 {
+		imp.report(directive, paths, ImportApproximated, "kept as advanced config; it applies to every path of the host, locations and routing rules included")
+		return
+	}
+	imp.report(directive, paths, ImportImported, "as advanced config")
+}
+
+// promoteLocation proxies the whole host to the first location's upstream when
+// only paths were routed; a Charon host needs an upstream of its own.
+func (imp *hostImport) promoteLocation() {
+	if imp.host.ForwardHost != "" || len(imp.host.Locations) == 0 {
+		return
+	}
+	first := imp.host.Locations[0]
+	imp.host.ForwardScheme, imp.host.ForwardHost, imp.host.ForwardPort = first.ForwardScheme, first.ForwardHost, first.ForwardPort
+	imp.report("location", nil, ImportApproximated, fmt.Sprintf("nothing serves the whole host; other paths go to %s:%d too", first.ForwardHost, first.ForwardPort))
+}
+
+func (imp *hostImport) finish() {
+	if imp.proxyWithoutUpstream && imp.host.ForwardHost == "" && len(imp.host.Locations) == 0 {
+		imp.report("reverse_proxy", nil, ImportDropped, "reverse_proxy without a static upstream is not supported")
+	}
+	if imp.redirect != nil {
+		if imp.host.ForwardHost != "" || len(imp.host.Locations) > 0 {
+			imp.report("redir", nil, ImportDropped, "the site also proxies requests; only the proxy is imported")
+		} else {
+			imp.host.Redirect = imp.redirect
+			imp.report("redir", nil, ImportImported, "as redirection host")
+		}
+	}
+	if len(imp.advanced) > 0 {
+		var v interface{} = imp.advanced
+		if len(imp.advanced) == 1 {
+			v = imp.advanced[0]
+		}
+		if raw, err := json.Marshal(v); err == nil {
+			imp.host.AdvancedConfig = string(raw)
+		}
+	}
+}
+
+// ipRule is one nginx-style allow or deny rule; addr may be "all".
+type ipRule struct {
+	allow bool
+	addr  string
+}
+
+// ipAccessList maps allow/deny rules, evaluated in order, onto an access list.
+// denyByDefault adds the "deny all" Nginx Proxy Manager puts after the rules. The
+// status is empty when there are no rules.
+func ipAccessList(name string, rules []ipRule, denyByDefault bool) (*ParsedAccessList, string, string) {
+	if len(rules) == 0 {
+		return nil, "", ""
+	}
+	var allow, deny []string
+	allowAll, denyAll := false, denyByDefault
+	for _, r := range rules {
+		switch {
+		case r.allow && r.addr == "all":
+			allowAll = true
+		case r.allow:
+			allow = append(allow, r.addr)
+		case r.addr == "all":
+			denyAll = !allowAll
+		default:
+			deny = append(deny, r.addr)
+		}
+	}
+	if allowAll {
+		denyAll = false
+	}
+
+	switch {
+	case len(allow) > 0 && !denyAll:
+		return nil, ImportDropped, "allow rules without `deny all` have no effect"
+	case len(allow) > 0 && len(deny) > 0:
+		return &ParsedAccessList{Name: name, Type: "whitelist", CIDRs: allow}, ImportApproximated, fmt.Sprintf("deny rules next to allow rules are dropped (%s)", strings.Join(deny, ", "))
+	case len(allow) > 0:
+		return &ParsedAccessList{Name: name, Type: "whitelist", CIDRs: allow}, ImportImported, "as whitelist"
+	case len(deny) > 0 && denyAll:
+		return &ParsedAccessList{Name: name, Type: "blacklist", CIDRs: deny}, ImportApproximated, "every address not listed was denied too; Charon only blocks the listed ones"
+	case len(deny) > 0:
+		return &ParsedAccessList{Name: name, Type: "blacklist", CIDRs: deny}, ImportImported, "as blacklist"
+	case denyAll:
+		return nil, ImportDropped, "denies every address; not imported"
+	}
+	return nil, ImportImported, "allows every address"
+}
+
+// basicAuthHandler builds Caddy's basic auth handler for bcrypt-hashed accounts.
+func basicAuthHandler(accounts []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"handler": "authentication",
+		"providers": map[string]interface{}{
+			"http_basic": map[string]interface{}{
+				"accounts": accounts,
+				"hash":     map[string]interface{}{"algorithm": "bcrypt"},
+			},
+		},
+	}
+}
+
+// headerRules collects the header changes of one host into a single headers handler.
+type headerRules struct {
+	request, response map[string][]string
+	deleteRequest     []string
+	deleteResponse    []string
+}
+
+func (r *headerRules) set(response bool, name, value string) {
+	m := &r.request
+	if response {
+		m = &r.response
+	}
+	if *m == nil {
+		*m = make(map[string][]string)
+	}
+	(*m)[name] = []string{value}
+}
+
+func (r *headerRules) remove(response bool, name string) {
+	if response {
+		r.deleteResponse = append(r.deleteResponse, name)
+	} else {
+		r.deleteRequest = append(r.deleteRequest, name)
+	}
+}
+
+// handler returns the headers handler, or nil when no header changes were collected.
+func (r *headerRules) handler() map[string]interface{} {
+	ops := func(set map[string][]string, del []string) map[string]interface{} {
+		m := map[string]interface{}{}
+		if len(set) > 0 {
+			m["set"] = set
+		}
+		if len(del) > 0 {
+			m["delete"] = del
+		}
+		return m
+	}
+	h := map[string]interface{}{"handler": "headers"}
+	if req := ops(r.request, r.deleteRequest); len(req) > 0 {
+		h["request"] = req
+	}
+	if resp := ops(r.response, r.deleteResponse); len(resp) > 0 {
+		h["response"] = resp
+	}
+	if len(h) == 1 {
+		return nil
+	}
+	return h
+}
+
+// accessListFromRoute recognizes `@name [not] remote_ip ...` followed by `respond @name 403` or `abort @name`.
+func accessListFromRoute(matchSets []interface{}, handlers []map[string]interface{}) *ParsedAccessList {
+	if len(matchSets) != 1 || len(handlers) != 1 {
+		return nil
+	}
+	h := handlers[0]
+	if h["handler"] != "static_response" {
+		return nil
+	}
+	if abort, _ := h["abort"].(bool); !abort && fmt.Sprint(h["status_code"]) != "403" {
+		return nil
+	}
+
+	set, _ := matchSets[0].(map[string]interface{})
+	if len(set) != 1 {
+		return nil
+	}
+	if ranges := remoteIPRanges(set["remote_ip"]); len(ranges) > 0 {
+		return &ParsedAccessList{Type: "blacklist", CIDRs: ranges}
+	}
+	if not, ok := set["not"].([]interface{}); ok && len(not) == 1 {
+		if inner, ok := not[0].(map[string]interface{}); ok && len(inner) == 1 {
+			if ranges := remoteIPRanges(inner["remote_ip"]); len(ranges) > 0 {
+				return &ParsedAccessList{Type: "whitelist", CIDRs: ranges}
+			}
+		}
+	}
+	return nil
+}
+
+func remoteIPRanges(v interface{}) []string {
+	m, _ := v.(map[string]interface{})
+	list, _ := m["ranges"].([]interface{})
+	var out []string
+	for _, r := range list {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// locationPaths turns Caddy path matchers into Charon location paths: "/api/*" and
+// "/api" both become "/api". Root matchers map to the host itself and are left out.
+func locationPaths(paths []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		p = strings.TrimSuffix(strings.TrimSuffix(p, "*"), "/")
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+func handlerMaps(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	out := make([]map[string]interface{}, 0, len(list))
+	for _, h := range list {
+		if m, ok := h.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// parseDial splits an upstream dial address, defaulting the port to 80.
+func parseDial(dial string) (string, int) {
+	port := 80
+	hostStr, portStr, err := net.SplitHostPort(dial)
+	if err == nil && !forceSplitFallback {
+		if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil {
+			port = 80
+		}
+		return hostStr, port
+	}
+	// Fallback: handle a simple "host:port" manually, or assume it's just a host
+	parts := strings.Split(dial, ":")
+	if len(parts) == 2 {
+		if _, err := fmt.Sscanf(parts[1], "%d", &port); err != nil {
+			port = 80
+		}
+		return parts[0], port
+	}
+	return dial, 80
+}
+
+// internalTLSSubjects returns the lowercased subjects of automation policies using the internal issuer.
+func internalTLSSubjects(tlsApp *CaddyTLS) map[string]bool {
+	subjects := make(map[string]bool)
+	if tlsApp == nil || tlsApp.Automation == nil {
+		return subjects
+	}
+	for _, p := range tlsApp.Automation.Policies {
+		for _, issuer := range p.Issuers {
+			if issuer["module"] == "internal" {
+				for _, s := range p.Subjects {
+					subjects[strings.ToLower(s)] = true
+				}
+			}
+		}
+	}
+	return subjects
+}
+
+// convertLocations, convertAccessList and convertCertificate build the models for ConvertToProxyHosts.
+func convertLocations(parsed []ParsedLocation, inheritAdvanced bool) []models.Location {
+	if len(parsed) == 0 {
+		return nil
+	}
+	locations := make([]models.Location, 0, len(parsed))
+	for _, l := range parsed {
+		locations = append(locations, models.Location{
+			UUID:          uuid.NewString(),
+			Path:          l.Path,
+			ForwardScheme: l.ForwardScheme,
+			ForwardHost:   l.ForwardHost,
+			ForwardPort:   l.ForwardPort,
+			// The source applied the host's login and headers to every path
+			InheritAdvanced: inheritAdvanced,
+		})
+	}
+	return locations
+}
+
+func convertAccessList(domain string, parsed *ParsedAccessList) *models.AccessList {
+	if parsed == nil {
+		return nil
+	}
+	rules := make([]models.AccessListRule, 0, len(parsed.CIDRs))
+	for _, c := range parsed.CIDRs {
+		rules = append(rules, models.AccessListRule{CIDR: c, Description: "imported"})
+	}
+	raw, _ := json.Marshal(rules)
+	name := parsed.Name
+	if name == "" {
+		name = "Imported: " + domain
+	}
+	return &models.AccessList{
+		UUID:    uuid.NewString(),
+		Name:    name,
+		Type:    parsed.Type,
+		IPRules: string(raw),
+		Enabled: true,
+	}
+}
+
+func convertCertificate(parsed *ParsedCertificate) *models.SSLCertificate {
+	cert := &models.SSLCertificate{
+		UUID:        uuid.NewString(),
+		Name:        parsed.Name,
+		Provider:    "custom",
+		Certificate: parsed.Certificate,
+		PrivateKey:  parsed.PrivateKey,
+	}
+	if block, _ := pem.Decode([]byte(parsed.Certificate)); block != nil {
+		if x, err := x509.ParseCertificate(block.Bytes); err == nil {
+			cert.ExpiresAt = &x.NotAfter
+			cert.Domains = x.Subject.CommonName
+			if len(x.DNSNames) > 0 {
+				cert.Domains = strings.Join(x.DNSNames, ",")
+			}
+		}
+	}
+	return cert
+}
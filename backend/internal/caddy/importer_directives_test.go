@@ -0,0 +1,181 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// adaptedSites is `caddy adapt` output for:
+//
+//	app.example.com {
+//		tls internal
+//		header Strict-Transport-Security "max-age=31536000; includeSubDomains"
+//		header X-Frame-Options DENY
+//		@outside not remote_ip 10.0.0.0/8
+//		respond @outside 403
+//		basicauth /admin/* { bob $2a$14$hash }
+//		handle_path /api/* { reverse_proxy api:9000 }
+//		handle /static/* { file_server }
+//		@mobile header User-Agent *Mobile*
+//		handle @mobile { reverse_proxy mobile:80 }
+//		reverse_proxy app1:8080 app2:8080
+//	}
+//	old.example.com {
+//		redir https://new.example.com{uri} 301
+//	}
+const adaptedSites = `{
+  "apps": {
+    "http": {"servers": {"srv0": {"listen": [":443"], "routes": [
+      {"match": [{"host": ["app.example.com"]}], "handle": [{"handler": "subroute", "routes": [
+        {"handle": [{"handler": "headers", "response": {"set": {"Strict-Transport-Security": ["max-age=31536000; includeSubDomains"]}}}]},
+        {"handle": [{"handler": "headers", "response": {"set": {"X-Frame-Options": ["DENY"]}}}]},
+        {"match": [{"not": [{"remote_ip": {"ranges": ["10.0.0.0/8"]}}]}], "handle": [{"handler": "static_response", "status_code": 403}]},
+        {"match": [{"path": ["/admin/*"]}], "handle": [{"handler": "authentication", "providers": {"http_basic": {"accounts": [{"username": "bob", "password": "$2a$14$hash"}]}}}]},
+        {"group": "group2", "match": [{"path": ["/api/*"]}], "handle": [{"handler": "subroute", "routes": [
+          {"handle": [{"handler": "rewrite", "strip_path_prefix": "/api"}]},
+          {"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "api:9000"}]}]}
+        ]}]},
+        {"group": "group2", "match": [{"path": ["/static/*"]}], "handle": [{"handler": "subroute", "routes": [
+          {"handle": [{"handler": "file_server", "hide": ["./Caddyfile"]}]}
+        ]}]},
+        {"group": "group2", "match": [{"header": {"User-Agent": ["*Mobile*"]}}], "handle": [{"handler": "subroute", "routes": [
+          {"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "mobile:80"}]}]}
+        ]}]},
+        {"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "app1:8080"}, {"dial": "app2:8080"}]}]}
+      ]}], "terminal": true},
+      {"match": [{"host": ["old.example.com"]}], "handle": [{"handler": "subroute", "routes": [
+        {"handle": [{"handler": "static_response", "headers": {"Location": ["https://new.example.com{http.request.uri}"]}, "status_code": 301}]}
+      ]}], "terminal": true}
+    ]}}},
+    "tls": {"automation": {"policies": [{"subjects": ["app.example.com"], "issuers": [{"module": "internal"}]}]}}
+  }
+}`
+
+func TestImporter_ExtractHosts_Directives(t *testing.T) {
+	result, err := NewImporter("").ExtractHosts([]byte(adaptedSites))
+	require.NoError(t, err)
+	require.Len(t, result.Hosts, 2)
+
+	hosts := map[string]ParsedHost{}
+	for _, h := range result.Hosts {
+		hosts[h.DomainNames] = h
+	}
+
+	app := hosts["app.example.com"]
+	assert.Equal(t, "app1", app.ForwardHost)
+	assert.Equal(t, 8080, app.ForwardPort)
+	assert.Equal(t, []string{"app1:8080", "app2:8080"}, app.Upstreams)
+	assert.True(t, app.HSTSEnabled)
+	assert.True(t, app.HSTSSubdomains)
+	assert.Equal(t, &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}, app.AccessList)
+	assert.Equal(t, []ParsedLocation{{Path: "/api", ForwardScheme: "http", ForwardHost: "api", ForwardPort: 9000}}, app.Locations)
+
+	var advanced []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(app.AdvancedConfig), &advanced))
+	require.Len(t, advanced, 2)
+	assert.Equal(t, "headers", advanced[0]["handler"])
+	assert.Equal(t, "authentication", advanced[1]["handler"])
+
+	type outcome struct{ directive, path, status string }
+	var got []outcome
+	for _, r := range app.Report {
+		got = append(got, outcome{r.Directive, r.Path, r.Status})
+	}
+	assert.Equal(t, []outcome{
+		{"header", "", ImportImported},
+		{"header", "", ImportImported},
+		{"remote_ip", "", ImportImported},
+		{"basicauth", "/admin/*", ImportApproximated},
+		{"handle_path", "/api/*", ImportApproximated},
+		{"reverse_proxy", "/api/*", ImportImported},
+		{"file_server", "/static/*", ImportDropped},
+		{"matcher", "", ImportDropped},
+		{"reverse_proxy", "", ImportApproximated},
+		{"tls", "", ImportApproximated},
+	}, got)
+	assert.Len(t, app.Warnings, 6, "every approximated or dropped directive is a warning")
+	assert.Equal(t, "kept as advanced config; it applies to every path of the host, locations and routing rules included", app.Report[3].Detail)
+
+	old := hosts["old.example.com"]
+	assert.Empty(t, old.ForwardHost)
+	require.Len(t, old.Report, 1)
+	assert.Equal(t, "redir", old.Report[0].Directive)
+	assert.Equal(t, ImportImported, old.Report[0].Status)
+	assert.Equal(t, &ParsedRedirect{TargetURL: "https://new.example.com", ForwardScheme: "auto", ForwardHTTPCode: 301, PreservePath: true}, old.Redirect)
+}
+
+func TestImporter_ExtractHosts_Redirects(t *testing.T) {
+	site := func(handle string) []byte {
+		return []byte(`{"apps": {"http": {"servers": {"srv0": {"routes": [
+			{"match": [{"host": ["old.example.com"]}], "handle": [{"handler": "subroute", "routes": [` + handle + `]}]}
+		]}}}}}`)
+	}
+	redir := `{"handle": [{"handler": "static_response", "status_code": 308, "headers": {"Location": ["{http.request.scheme}://new.example.com"]}}]}`
+
+	res, err := NewImporter("").ExtractHosts(site(redir))
+	require.NoError(t, err)
+	assert.Equal(t, &ParsedRedirect{ForwardScheme: "auto", ForwardDomainName: "new.example.com", ForwardHTTPCode: 308}, res.Hosts[0].Redirect)
+
+	// A site that also proxies keeps only the proxy
+	res, err = NewImporter("").ExtractHosts(site(redir + `, {"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "app:80"}]}]}`))
+	require.NoError(t, err)
+	assert.Nil(t, res.Hosts[0].Redirect)
+	assert.Equal(t, "app", res.Hosts[0].ForwardHost)
+
+	// Placeholders other than the request URI cannot be stored
+	res, err = NewImporter("").ExtractHosts(site(`{"handle": [{"handler": "static_response", "status_code": 301, "headers": {"Location": ["https://{http.request.host}.example.net"]}}]}`))
+	require.NoError(t, err)
+	assert.Nil(t, res.Hosts[0].Redirect)
+	assert.Equal(t, ImportDropped, res.Hosts[0].Report[0].Status)
+}
+
+func TestImporter_ExtractHosts_AccessListForms(t *testing.T) {
+	site := func(routes string) []byte {
+		return []byte(`{"apps": {"http": {"servers": {"srv0": {"routes": [
+			{"match": [{"host": ["a.example.com"]}], "handle": [{"handler": "subroute", "routes": [` + routes + `,
+				{"handle": [{"handler": "reverse_proxy", "upstreams": [{"dial": "a:80"}]}]}
+			]}]}
+		]}}}}}`)
+	}
+
+	res, err := NewImporter("").ExtractHosts(site(`{"match": [{"remote_ip": {"ranges": ["1.2.3.4"]}}], "handle": [{"handler": "static_response", "abort": true}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &ParsedAccessList{Type: "blacklist", CIDRs: []string{"1.2.3.4"}}, res.Hosts[0].AccessList)
+
+	// A remote_ip route that serves content is not an access list
+	res, err = NewImporter("").ExtractHosts(site(`{"match": [{"remote_ip": {"ranges": ["1.2.3.4"]}}], "handle": [{"handler": "static_response", "status_code": 200, "body": "hi"}]}`))
+	require.NoError(t, err)
+	assert.Nil(t, res.Hosts[0].AccessList)
+	assert.Equal(t, "matcher", res.Hosts[0].Report[0].Directive)
+	assert.Equal(t, ImportDropped, res.Hosts[0].Report[0].Status)
+}
+
+func TestConvertToProxyHosts_Directives(t *testing.T) {
+	result, err := NewImporter("").ExtractHosts([]byte(adaptedSites))
+	require.NoError(t, err)
+
+	hosts := ConvertToProxyHosts(result.Hosts)
+	require.Len(t, hosts, 1, "the redirect-only host has no upstream")
+	redirects := ConvertToRedirectionHosts(result.Hosts)
+	require.Len(t, redirects, 1)
+	assert.Equal(t, "old.example.com", redirects[0].DomainNames)
+	assert.Equal(t, "https://new.example.com", redirects[0].TargetURL)
+	host := hosts[0]
+	assert.True(t, host.HSTSEnabled)
+	assert.NotEmpty(t, host.AdvancedConfig)
+	require.Len(t, host.Locations, 1)
+	assert.Equal(t, "/api", host.Locations[0].Path)
+	assert.NotEmpty(t, host.Locations[0].UUID)
+	require.NotNil(t, host.AccessList)
+	assert.Equal(t, "whitelist", host.AccessList.Type)
+	assert.True(t, host.AccessList.Enabled)
+
+	var rules []models.AccessListRule
+	require.NoError(t, json.Unmarshal([]byte(host.AccessList.IPRules), &rules))
+	assert.Equal(t, "10.0.0.0/8", rules[0].CIDR)
+}
@@ -0,0 +1,465 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// nginxDirective is one directive of an nginx config. Block is nil for simple
+// directives ending in a semicolon.
+type nginxDirective struct {
+	Name  string
+	Args  []string
+	Block []*nginxDirective
+	Line  int
+}
+
+type nginxToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// nginxIgnored are directives Charon or Caddy's defaults already cover.
+var nginxIgnored = map[string]bool{
+	"listen": true, "server_name": true, "http2": true,
+	"ssl_certificate_key": true, "ssl_protocols": true, "ssl_ciphers": true, "ssl_prefer_server_ciphers": true,
+	"ssl_session_cache": true, "ssl_session_timeout": true, "ssl_session_tickets": true,
+	"ssl_stapling": true, "ssl_stapling_verify": true, "ssl_dhparam": true, "ssl_trusted_certificate": true,
+	"access_log": true, "error_log": true, "client_max_body_size": true,
+	"proxy_http_version": true, "proxy_redirect": true, "proxy_cache_bypass": true,
+}
+
+// nginxForwarded are proxy headers Charon already sends upstream.
+var nginxForwarded = map[string]bool{
+	"host": true, "x-real-ip": true, "x-forwarded-for": true, "x-forwarded-proto": true,
+	"x-forwarded-host": true, "x-forwarded-port": true,
+}
+
+// ImportNginx reads the server blocks of an nginx config (a site file or a full
+// nginx.conf) and maps them to proxy hosts.
+func ImportNginx(content string) (*ImportResult, error) {
+	directives, err := parseNginx(content)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &nginxImport{upstreams: map[string][]string{}, hosts: map[string]*nginxHost{}}
+	result := &ImportResult{
+		Hosts:     []ParsedHost{},
+		Conflicts: []string{},
+		Errors:    []string{},
+	}
+	var servers []*nginxDirective
+	var collect func(list []*nginxDirective)
+	collect = func(list []*nginxDirective) {
+		for _, d := range list {
+			switch d.Name {
+			case "http":
+				collect(d.Block)
+			case "server":
+				servers = append(servers, d)
+			case "upstream":
+				if len(d.Args) == 1 {
+					for _, s := range d.Block {
+						if s.Name == "server" && len(s.Args) > 0 {
+							n.upstreams[d.Args[0]] = append(n.upstreams[d.Args[0]], s.Args[0])
+						}
+					}
+				}
+			case "include":
+				result.Warnings = append(result.Warnings, fmt.Sprintf("include %s (line %d) is not followed; paste the included files", strings.Join(d.Args, " "), d.Line))
+			case "stream":
+				result.Warnings = append(result.Warnings, fmt.Sprintf("stream block at line %d is not imported", d.Line))
+			}
+		}
+	}
+	collect(directives)
+
+	for _, s := range servers {
+		result.Warnings = append(result.Warnings, n.server(s)...)
+	}
+	for _, key := range n.order {
+		result.Hosts = append(result.Hosts, n.hosts[key].finish())
+	}
+	return result, nil
+}
+
+// nginxImport merges server blocks with the same server_name (e.g. the port 80
+// redirect and the TLS server) into one host.
+type nginxImport struct {
+	upstreams map[string][]string
+	hosts     map[string]*nginxHost
+	order     []string
+}
+
+type nginxHost struct {
+	imp     *hostImport
+	headers headerRules
+}
+
+// server maps one server block and returns warnings for names it cannot import.
+func (n *nginxImport) server(s *nginxDirective) []string {
+	var domains, warnings []string
+	for _, d := range s.Block {
+		if d.Name != "server_name" {
+			continue
+		}
+		for _, name := range d.Args {
+			switch {
+			case name == "_" || name == "":
+			case strings.HasPrefix(name, "~"):
+				warnings = append(warnings, fmt.Sprintf("server_name %s (line %d): regular expressions are not supported", name, d.Line))
+			case strings.HasPrefix(name, "."):
+				domains = append(domains, name[1:], "*"+name)
+			default:
+				domains = append(domains, name)
+			}
+		}
+	}
+	if len(domains) == 0 {
+		return append(warnings, fmt.Sprintf("server block at line %d has no server_name and was skipped", s.Line))
+	}
+
+	key := strings.ToLower(strings.Join(domains, ", "))
+	h := n.hosts[key]
+	if h == nil {
+		h = &nginxHost{imp: &hostImport{host: &ParsedHost{DomainNames: strings.Join(domains, ", ")}}}
+		n.hosts[key] = h
+		n.order = append(n.order, key)
+	}
+
+	var rules []ipRule
+	for _, d := range s.Block {
+		switch d.Name {
+		case "location":
+			n.location(h, d)
+		case "proxy_pass":
+			n.proxyPass(h, d, nil)
+		case "proxy_set_header":
+			h.proxySetHeader(d, nil)
+		case "add_header":
+			h.addHeader(d, nil)
+		case "return":
+			h.ret(d, nil)
+		case "allow", "deny":
+			if len(d.Args) == 1 {
+				rules = append(rules, ipRule{allow: d.Name == "allow", addr: d.Args[0]})
+			}
+		case "ssl_certificate":
+			h.imp.report(d.Name, nil, ImportApproximated, "certificate files are not read; Charon obtains certificates for the domains itself")
+		case "auth_basic":
+			if len(d.Args) == 1 && d.Args[0] != "off" {
+				h.imp.report(d.Name, nil, ImportDropped, "auth_basic users live in an htpasswd file; add them as advanced config")
+			}
+		default:
+			h.unsupported(d, nil)
+		}
+	}
+
+	if acl, status, detail := ipAccessList("", rules, false); status != "" {
+		if acl != nil && h.imp.host.AccessList != nil {
+			h.imp.report("allow", nil, ImportDropped, "only one IP access list per host is supported")
+		} else {
+			h.imp.host.AccessList = acl
+			h.imp.report("allow", nil, status, detail)
+		}
+	}
+	return warnings
+}
+
+func (n *nginxImport) location(h *nginxHost, d *nginxDirective) {
+	if len(d.Args) == 0 {
+		return
+	}
+	modifier, path := "", d.Args[len(d.Args)-1]
+	if len(d.Args) == 2 {
+		modifier = d.Args[0]
+	}
+	paths := []string{path}
+	switch {
+	case modifier == "~" || modifier == "~*":
+		h.imp.report("location", paths, ImportDropped, "regular expression locations are not supported")
+		return
+	case strings.HasPrefix(path, "@"):
+		h.imp.report("location", paths, ImportDropped, "named locations are not supported")
+		return
+	case modifier == "=":
+		h.imp.report("location", paths, ImportApproximated, "exact match imported as a path prefix")
+	}
+
+	for _, inner := range d.Block {
+		switch inner.Name {
+		case "proxy_pass":
+			n.proxyPass(h, inner, paths)
+		case "proxy_set_header":
+			h.proxySetHeader(inner, paths)
+		case "add_header":
+			h.addHeader(inner, paths)
+		case "return":
+			h.ret(inner, paths)
+		case "allow", "deny":
+			h.imp.report(inner.Name, paths, ImportDropped, "IP rules inside a location are not supported")
+		case "location":
+			h.imp.report("location", append(paths, inner.Args...), ImportDropped, "nested locations are not supported")
+		default:
+			h.unsupported(inner, paths)
+		}
+	}
+}
+
+func (n *nginxImport) proxyPass(h *nginxHost, d *nginxDirective, paths []string) {
+	imp := h.imp
+	if len(d.Args) != 1 || strings.Contains(d.Args[0], "$") {
+		imp.report(d.Name, paths, ImportDropped, "proxy_pass with variables is not supported")
+		return
+	}
+	u, err := url.Parse(d.Args[0])
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("proxy_pass %s is not supported", d.Args[0]))
+		return
+	}
+
+	defaultPort := "80"
+	if u.Scheme == "https" {
+		defaultPort = "443"
+	}
+	var dials []string
+	if servers, ok := n.upstreams[u.Host]; ok {
+		for _, s := range servers {
+			if strings.HasPrefix(s, "unix:") {
+				continue
+			}
+			if _, _, err := net.SplitHostPort(s); err != nil {
+				s = net.JoinHostPort(s, defaultPort)
+			}
+			dials = append(dials, s)
+		}
+		if len(dials) == 0 {
+			imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("upstream %s has no TCP servers", u.Host))
+			return
+		}
+	} else {
+		port := u.Port()
+		if port == "" {
+			port = defaultPort
+		}
+		dials = []string{net.JoinHostPort(u.Hostname(), port)}
+	}
+	forwardHost, forwardPort := parseDial(dials[0])
+
+	locPaths := locationPaths(paths)
+	if len(locPaths) == 0 {
+		imp.host.ForwardScheme, imp.host.ForwardHost, imp.host.ForwardPort = u.Scheme, forwardHost, forwardPort
+		imp.host.Upstreams = dials
+	}
+	for _, p := range locPaths {
+		imp.host.Locations = append(imp.host.Locations, ParsedLocation{Path: p, ForwardScheme: u.Scheme, ForwardHost: forwardHost, ForwardPort: forwardPort})
+	}
+
+	switch {
+	case len(dials) > 1:
+		imp.report(d.Name, paths, ImportApproximated, fmt.Sprintf("upstream %s has %d servers; Charon proxies to the first (%s)", u.Host, len(dials), dials[0]))
+	case u.Path == "/" && len(locPaths) > 0:
+		imp.report(d.Name, paths, ImportApproximated, "proxy_pass with a trailing / strips the location prefix; Charon locations forward the full path")
+	case u.Path != "" && u.Path != "/":
+		imp.report(d.Name, paths, ImportApproximated, fmt.Sprintf("upstream path %s is dropped", u.Path))
+	default:
+		imp.report(d.Name, paths, ImportImported, "")
+	}
+}
+
+func (h *nginxHost) proxySetHeader(d *nginxDirective, paths []string) {
+	if len(d.Args) != 2 {
+		return
+	}
+	name, value := d.Args[0], d.Args[1]
+	switch lower := strings.ToLower(name); {
+	case lower == "upgrade" && value == "$http_upgrade":
+		h.imp.host.WebsocketSupport = true
+		h.imp.report(d.Name, paths, ImportImported, "as websocket support")
+	case lower == "connection" && (value == "$connection_upgrade" || strings.EqualFold(value, "upgrade")):
+	case nginxForwarded[lower]:
+	case strings.Contains(value, "$"):
+		h.imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("header %s uses nginx variables", name))
+	default:
+		h.headers.set(false, name, value)
+		h.reportHeader(d.Name, paths)
+	}
+}
+
+func (h *nginxHost) addHeader(d *nginxDirective, paths []string) {
+	if len(d.Args) < 2 {
+		return
+	}
+	name, value := d.Args[0], d.Args[1]
+	switch {
+	case strings.EqualFold(name, "Strict-Transport-Security") && len(paths) == 0:
+		h.imp.host.HSTSEnabled = true
+		h.imp.host.HSTSSubdomains = strings.Contains(strings.ToLower(value), "includesubdomains")
+		if strings.Contains(value, "max-age=31536000") {
+			h.imp.report(d.Name, paths, ImportImported, "as HSTS")
+		} else {
+			h.imp.report(d.Name, paths, ImportApproximated, fmt.Sprintf("HSTS %q imported with Charon's max-age of one year", value))
+		}
+	case strings.Contains(value, "$"):
+		h.imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("header %s uses nginx variables", name))
+	default:
+		h.headers.set(true, name, value)
+		h.reportHeader(d.Name, paths)
+	}
+}
+
+func (h *nginxHost) reportHeader(directive string, paths []string) {
+	if len(locationPaths(paths)) > 0 {
+		h.imp.report(directive, paths, ImportApproximated, "kept as advanced config; it applies to the whole host, not just this location")
+		return
+	}
+	h.imp.report(directive, paths, ImportImported, "as advanced config")
+}
+
+// ret imports the usual port 80 "return 301 https://$host$request_uri" as Force SSL.
+func (h *nginxHost) ret(d *nginxDirective, paths []string) {
+	if len(d.Args) == 2 && strings.HasPrefix(d.Args[0], "30") {
+		target := d.Args[1]
+		if len(locationPaths(paths)) == 0 && (strings.HasPrefix(target, "https://$host") || strings.HasPrefix(target, "https://$server_name") || strings.HasPrefix(target, "https://$http_host")) {
+			h.imp.host.SSLForced = true
+			h.imp.report(d.Name, paths, ImportImported, "as Force SSL")
+			return
+		}
+		h.imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("redirect to %s (%s) is not supported; create it manually", target, d.Args[0]))
+		return
+	}
+	h.imp.report(d.Name, paths, ImportDropped, "return is not supported")
+}
+
+func (h *nginxHost) unsupported(d *nginxDirective, paths []string) {
+	if nginxIgnored[d.Name] {
+		return
+	}
+	h.imp.report(d.Name, paths, ImportDropped, fmt.Sprintf("nginx directive %s (line %d) is not supported", d.Name, d.Line))
+}
+
+func (h *nginxHost) finish() ParsedHost {
+	imp := h.imp
+	imp.promoteLocation()
+	if hdrs := h.headers.handler(); hdrs != nil {
+		imp.advanced = append([]map[string]interface{}{hdrs}, imp.advanced...)
+	}
+	imp.finish()
+	return *imp.host
+}
+
+// parseNginx parses nginx configuration syntax into a directive tree.
+func parseNginx(content string) ([]*nginxDirective, error) {
+	tokens, err := tokenizeNginx(content)
+	if err != nil {
+		return nil, err
+	}
+	directives, i, err := parseNginxBlock(tokens, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	if i < len(tokens) {
+		return nil, fmt.Errorf("line %d: unexpected }", tokens[i].line)
+	}
+	return directives, nil
+}
+
+func parseNginxBlock(tokens []nginxToken, i int, nested bool) ([]*nginxDirective, int, error) {
+	var out []*nginxDirective
+	for i < len(tokens) {
+		t := tokens[i]
+		if !t.quoted && t.text == "}" {
+			if !nested {
+				return out, i, nil
+			}
+			return out, i + 1, nil
+		}
+		if !t.quoted && (t.text == "{" || t.text == ";") {
+			return nil, i, fmt.Errorf("line %d: unexpected %s", t.line, t.text)
+		}
+		d := &nginxDirective{Name: t.text, Line: t.line}
+		for i++; ; i++ {
+			if i >= len(tokens) {
+				return nil, i, fmt.Errorf("line %d: %s is not terminated", d.Line, d.Name)
+			}
+			a := tokens[i]
+			if !a.quoted && a.text == ";" {
+				i++
+				break
+			}
+			if !a.quoted && a.text == "{" {
+				block, next, err := parseNginxBlock(tokens, i+1, true)
+				if err != nil {
+					return nil, next, err
+				}
+				d.Block = block
+				if d.Block == nil {
+					d.Block = []*nginxDirective{}
+				}
+				i = next
+				break
+			}
+			if !a.quoted && a.text == "}" {
+				return nil, i, fmt.Errorf("line %d: %s is not terminated", d.Line, d.Name)
+			}
+			d.Args = append(d.Args, a.text)
+		}
+		out = append(out, d)
+	}
+	if nested {
+		return nil, i, fmt.Errorf("unexpected end of file: missing }")
+	}
+	return out, i, nil
+}
+
+func tokenizeNginx(s string) ([]nginxToken, error) {
+	var tokens []nginxToken
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, nginxToken{text: string(c), line: line})
+			i++
+		case c == '"' || c == '\'':
+			start := line
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				if s[j] == '\n' {
+					line++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("line %d: unterminated string", start)
+			}
+			tokens = append(tokens, nginxToken{text: b.String(), quoted: true, line: start})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n{};", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, nginxToken{text: s[i:j], line: line})
+			i = j
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,182 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nginxSites = `
+upstream app_servers {
+    server app1:8080;
+    server app2;
+}
+
+server {
+    listen 80;
+    server_name app.example.com www.example.com;
+    return 301 https://$host$request_uri;
+}
+
+server {
+    listen 443 ssl http2;
+    server_name app.example.com www.example.com;
+    ssl_certificate /etc/ssl/app.crt;
+    ssl_certificate_key /etc/ssl/app.key;
+
+    add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;
+    add_header X-Frame-Options SAMEORIGIN;
+    allow 10.0.0.0/8;
+    allow 192.168.1.5;
+    deny all;
+
+    location / {
+        proxy_pass http://app_servers;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection "upgrade";
+    }
+
+    location /api/ {
+        proxy_pass http://api:9000/;
+        proxy_set_header X-Api-Key "secret; with semicolon";
+    }
+
+    location ~ \.php$ {
+        fastcgi_pass unix:/run/php.sock;
+    }
+
+    location /static {
+        root /var/www; # served from disk
+    }
+}
+
+server {
+    listen 80 default_server;
+    server_name _;
+    return 444;
+}
+
+server {
+    server_name .example.org;
+    location /grafana {
+        proxy_pass https://grafana:3000;
+        allow 10.0.0.0/8;
+    }
+}
+
+include /etc/nginx/conf.d/*.conf;
+`
+
+func TestImportNginx(t *testing.T) {
+	result, err := ImportNginx(nginxSites)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"include /etc/nginx/conf.d/*.conf (line 61) is not followed; paste the included files",
+		"server block at line 47 has no server_name and was skipped",
+	}, result.Warnings)
+	require.Len(t, result.Hosts, 2)
+
+	app := result.Hosts[0]
+	assert.Equal(t, "app.example.com, www.example.com", app.DomainNames)
+	assert.True(t, app.SSLForced)
+	assert.Equal(t, "http", app.ForwardScheme)
+	assert.Equal(t, "app1", app.ForwardHost)
+	assert.Equal(t, 8080, app.ForwardPort)
+	assert.Equal(t, []string{"app1:8080", "app2:80"}, app.Upstreams)
+	assert.True(t, app.WebsocketSupport)
+	assert.True(t, app.HSTSEnabled)
+	assert.True(t, app.HSTSSubdomains)
+	assert.Equal(t, &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.0/8", "192.168.1.5"}}, app.AccessList)
+	assert.Equal(t, []ParsedLocation{{Path: "/api", ForwardScheme: "http", ForwardHost: "api", ForwardPort: 9000}}, app.Locations)
+
+	var headers map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(app.AdvancedConfig), &headers))
+	assert.Equal(t, map[string]interface{}{
+		"handler":  "headers",
+		"request":  map[string]interface{}{"set": map[string]interface{}{"X-Api-Key": []interface{}{"secret; with semicolon"}}},
+		"response": map[string]interface{}{"set": map[string]interface{}{"X-Frame-Options": []interface{}{"SAMEORIGIN"}}},
+	}, headers)
+
+	type outcome struct{ directive, path, status string }
+	var got []outcome
+	for _, r := range app.Report {
+		got = append(got, outcome{r.Directive, r.Path, r.Status})
+	}
+	assert.Equal(t, []outcome{
+		{"return", "", ImportImported},
+		{"ssl_certificate", "", ImportApproximated},
+		{"add_header", "", ImportImported},
+		{"add_header", "", ImportImported},
+		{"proxy_pass", "/", ImportApproximated},
+		{"proxy_set_header", "/", ImportImported},
+		{"proxy_pass", "/api/", ImportApproximated},
+		{"proxy_set_header", "/api/", ImportApproximated},
+		{"location", "\\.php$", ImportDropped},
+		{"root", "/static", ImportDropped},
+		{"allow", "", ImportImported},
+	}, got)
+
+	org := result.Hosts[1]
+	assert.Equal(t, "example.org, *.example.org", org.DomainNames)
+	assert.Equal(t, "https", org.ForwardScheme)
+	assert.Equal(t, "grafana", org.ForwardHost)
+	assert.Equal(t, 3000, org.ForwardPort)
+	require.Len(t, org.Locations, 1)
+	assert.Nil(t, org.AccessList)
+	var orgStatuses []string
+	for _, r := range org.Report {
+		orgStatuses = append(orgStatuses, r.Directive+" "+r.Status)
+	}
+	assert.Equal(t, []string{"proxy_pass imported", "allow dropped", "location approximated"}, orgStatuses)
+}
+
+func TestIPAccessList(t *testing.T) {
+	rules := func(spec ...string) []ipRule {
+		var out []ipRule
+		for i := 0; i < len(spec); i += 2 {
+			out = append(out, ipRule{allow: spec[i] == "allow", addr: spec[i+1]})
+		}
+		return out
+	}
+	tests := []struct {
+		name          string
+		rules         []ipRule
+		denyByDefault bool
+		acl           *ParsedAccessList
+		status        string
+	}{
+		{"none", nil, false, nil, ""},
+		{"allow without deny all", rules("allow", "10.0.0.1"), false, nil, ImportDropped},
+		{"whitelist", rules("allow", "10.0.0.1", "deny", "all"), false, &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.1"}}, ImportImported},
+		{"implicit deny all", rules("allow", "10.0.0.1"), true, &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.1"}}, ImportImported},
+		{"mixed", rules("deny", "10.0.0.2", "allow", "10.0.0.0/8", "deny", "all"), false, &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}, ImportApproximated},
+		{"blacklist", rules("deny", "1.2.3.4"), false, &ParsedAccessList{Type: "blacklist", CIDRs: []string{"1.2.3.4"}}, ImportImported},
+		{"blacklist then allow all", rules("deny", "1.2.3.4", "allow", "all"), true, &ParsedAccessList{Type: "blacklist", CIDRs: []string{"1.2.3.4"}}, ImportImported},
+		{"deny only with default deny", rules("deny", "1.2.3.4"), true, &ParsedAccessList{Type: "blacklist", CIDRs: []string{"1.2.3.4"}}, ImportApproximated},
+		{"deny all", rules("deny", "all"), false, nil, ImportDropped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, status, _ := ipAccessList("", tt.rules, tt.denyByDefault)
+			assert.Equal(t, tt.acl, acl)
+			assert.Equal(t, tt.status, status)
+		})
+	}
+}
+
+func TestParseNginx_Errors(t *testing.T) {
+	for input, want := range map[string]string{
+		"server {":                   "missing }",
+		"server { listen 80; } }":    "line 1: unexpected }",
+		"server { listen 80 }":       "listen is not terminated",
+		"server_name \"unterminated": "unterminated string",
+		"listen 80":                  "listen is not terminated",
+	} {
+		_, err := ImportNginx(input)
+		assert.ErrorContains(t, err, want, input)
+	}
+}
@@ -0,0 +1,429 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NPMSource is an Nginx Proxy Manager installation to import.
+type NPMSource struct {
+	// Database is the path to data/database.sqlite or to a MySQL/SQLite SQL dump.
+	Database string
+	// Snippets maps file names under data/nginx/custom to their content.
+	Snippets map[string]string
+}
+
+// npmTables are the Nginx Proxy Manager tables the importer reads.
+var npmTables = []string{
+	"proxy_host", "redirection_host", "dead_host", "stream",
+	"access_list", "access_list_auth", "access_list_client", "certificate",
+}
+
+// ImportNPM reads the hosts, access lists and certificates of an Nginx Proxy
+// Manager database into the same result a Caddyfile import produces.
+func ImportNPM(src NPMSource) (*ImportResult, error) {
+	tables, err := loadNPMTables(src.Database)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tables["proxy_host"]; !ok {
+		return nil, fmt.Errorf("no proxy_host table found; is this an Nginx Proxy Manager database?")
+	}
+
+	npm := &npmData{
+		accessLists:  byID(tables["access_list"]),
+		certificates: byID(tables["certificate"]),
+		auth:         byAccessList(tables["access_list_auth"]),
+		clients:      byAccessList(tables["access_list_client"]),
+	}
+	result := &ImportResult{
+		Hosts:     []ParsedHost{},
+		Conflicts: []string{},
+		Errors:    []string{},
+	}
+
+	var proxySnippets, redirectSnippets []string
+	names := make([]string, 0, len(src.Snippets))
+	for name := range src.Snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		base := strings.TrimSuffix(filepath.Base(name), ".conf")
+		switch {
+		case !hasNginxDirectives(src.Snippets[name]):
+			continue
+		case isNumber(base):
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s is generated by Nginx Proxy Manager from the database and was not read", name))
+		case strings.HasPrefix(base, "server_proxy"):
+			proxySnippets = append(proxySnippets, name)
+		case strings.HasPrefix(base, "server_redirect"):
+			redirectSnippets = append(redirectSnippets, name)
+		default:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("custom nginx snippet %s is not converted", name))
+		}
+	}
+
+	for _, row := range live(tables["proxy_host"]) {
+		result.Hosts = append(result.Hosts, npm.proxyHost(row, proxySnippets))
+	}
+	for _, row := range live(tables["redirection_host"]) {
+		result.Hosts = append(result.Hosts, npm.redirectionHost(row, redirectSnippets))
+	}
+	if n := len(live(tables["dead_host"])); n > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d 404 host(s) are not imported", n))
+	}
+	if n := len(live(tables["stream"])); n > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d stream(s) are not imported", n))
+	}
+	return result, nil
+}
+
+// npmData holds the rows that hosts reference by id.
+type npmData struct {
+	accessLists  map[string]npmRow
+	certificates map[string]npmRow
+	auth         map[string][]npmRow
+	clients      map[string][]npmRow
+
+	imp *hostImport // host being imported
+}
+
+func (n *npmData) proxyHost(row npmRow, snippets []string) ParsedHost {
+	host := ParsedHost{
+		DomainNames:      npmDomains(row["domain_names"]),
+		ForwardScheme:    row["forward_scheme"],
+		ForwardHost:      row["forward_host"],
+		ForwardPort:      row.int("forward_port"),
+		SSLForced:        row.bool("ssl_forced"),
+		WebsocketSupport: row.bool("allow_websocket_upgrade"),
+		HSTSEnabled:      row.bool("hsts_enabled"),
+		HSTSSubdomains:   row.bool("hsts_subdomains"),
+	}
+	if host.ForwardScheme == "" {
+		host.ForwardScheme = "http"
+	}
+	n.imp = &hostImport{host: &host}
+	n.imp.report("forward", nil, ImportImported, fmt.Sprintf("%s://%s:%d", host.ForwardScheme, host.ForwardHost, host.ForwardPort))
+
+	n.locations(row["locations"])
+	n.accessList(row["access_list_id"])
+	n.certificate(row["certificate_id"])
+	if strings.TrimSpace(row["advanced_config"]) != "" {
+		n.imp.report("advanced_config", nil, ImportDropped, "custom nginx configuration is not converted")
+	}
+	if row.bool("caching_enabled") {
+		n.imp.report("caching", nil, ImportDropped, "asset caching is not supported")
+	}
+	if _, ok := row["block_exploits"]; ok && !row.bool("block_exploits") {
+		n.imp.report("block_exploits", nil, ImportApproximated, "Charon blocks common exploits on every imported host")
+	}
+	if _, ok := row["enabled"]; ok && !row.bool("enabled") {
+		n.imp.report("enabled", nil, ImportApproximated, "host is disabled in Nginx Proxy Manager; Charon imports it enabled")
+	}
+	n.snippets(snippets)
+	n.imp.finish()
+	return host
+}
+
+func (n *npmData) redirectionHost(row npmRow, snippets []string) ParsedHost {
+	host := ParsedHost{
+		DomainNames: npmDomains(row["domain_names"]),
+		SSLForced:   row.bool("ssl_forced"),
+	}
+	n.imp = &hostImport{host: &host}
+	code, _ := strconv.Atoi(row["forward_http_code"])
+	if code == 0 {
+		code = 302
+	}
+	redirect := models.RedirectionHost{
+		DomainNames:       host.DomainNames,
+		ForwardScheme:     row["forward_scheme"],
+		ForwardDomainName: row["forward_domain_name"],
+		ForwardHTTPCode:   code,
+		PreservePath:      row.bool("preserve_path"),
+	}
+	target := redirect.ForwardDomainName
+	if redirect.ForwardScheme != "" && redirect.ForwardScheme != "auto" {
+		target = redirect.ForwardScheme + "://" + target
+	}
+	if err := ValidateRedirectionHost(&redirect); err != nil {
+		n.imp.report("redirection_host", nil, ImportDropped, fmt.Sprintf("redirect to %s (%d) is not supported: %v", target, code, err))
+	} else {
+		host.Redirect = &ParsedRedirect{
+			ForwardScheme:     redirect.ForwardScheme,
+			ForwardDomainName: redirect.ForwardDomainName,
+			ForwardHTTPCode:   redirect.ForwardHTTPCode,
+			PreservePath:      redirect.PreservePath,
+		}
+		n.imp.report("redirection_host", nil, ImportImported, fmt.Sprintf("as redirection host to %s (%d)", target, code))
+	}
+	n.snippets(snippets)
+	return host
+}
+
+// snippets reports the custom nginx snippets Nginx Proxy Manager included in the host.
+func (n *npmData) snippets(names []string) {
+	for _, name := range names {
+		n.imp.report("custom_snippet", nil, ImportDropped, fmt.Sprintf("custom nginx snippet %s is not converted", name))
+	}
+}
+
+func (n *npmData) locations(raw string) {
+	var locations []struct {
+		Path           string      `json:"path"`
+		ForwardScheme  string      `json:"forward_scheme"`
+		ForwardHost    string      `json:"forward_host"`
+		ForwardPort    json.Number `json:"forward_port"`
+		AdvancedConfig string      `json:"advanced_config"`
+	}
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(raw), &locations); err != nil {
+		n.imp.report("locations", nil, ImportDropped, "locations could not be read: "+err.Error())
+		return
+	}
+	for _, l := range locations {
+		paths := []string{l.Path}
+		port, _ := strconv.Atoi(l.ForwardPort.String())
+		loc := ParsedLocation{Path: l.Path, ForwardScheme: l.ForwardScheme, ForwardHost: l.ForwardHost, ForwardPort: port}
+		if loc.ForwardScheme == "" {
+			loc.ForwardScheme = "http"
+		}
+		status, detail := ImportImported, ""
+		if i := strings.Index(loc.ForwardHost, "/"); i >= 0 {
+			status, detail = ImportApproximated, fmt.Sprintf("upstream path %s is dropped; Charon locations forward the request path", loc.ForwardHost[i:])
+			loc.ForwardHost = loc.ForwardHost[:i]
+		}
+		n.imp.host.Locations = append(n.imp.host.Locations, loc)
+		n.imp.report("location", paths, status, detail)
+		if strings.TrimSpace(l.AdvancedConfig) != "" {
+			n.imp.report("advanced_config", paths, ImportDropped, "custom nginx configuration is not converted")
+		}
+	}
+}
+
+// accessList maps IP rules onto an access list and users onto a basic auth
+// handler kept as advanced config.
+func (n *npmData) accessList(id string) {
+	list, ok := n.accessLists[id]
+	if id == "" || id == "0" || !ok {
+		return
+	}
+	name := list["name"]
+
+	rules := make([]ipRule, 0, len(n.clients[id]))
+	for _, c := range n.clients[id] {
+		rules = append(rules, ipRule{allow: c["directive"] == "allow", addr: c["address"]})
+	}
+	// Nginx Proxy Manager ends the rules with "deny all"
+	if acl, status, detail := ipAccessList(name, rules, true); status != "" {
+		n.imp.host.AccessList = acl
+		n.imp.report("access_list", nil, status, fmt.Sprintf("access list %q: %s", name, detail))
+	}
+
+	users := n.auth[id]
+	if len(users) == 0 {
+		return
+	}
+	accounts := make([]map[string]interface{}, 0, len(users))
+	for _, u := range users {
+		hash, err := npmPasswordHash(u["password"])
+		if err != nil {
+			n.imp.report("access_list", nil, ImportDropped, fmt.Sprintf("access list %q: user %s: %v", name, u["username"], err))
+			continue
+		}
+		accounts = append(accounts, map[string]interface{}{"username": u["username"], "password": hash})
+	}
+	if len(accounts) == 0 {
+		return
+	}
+	n.imp.keepAdvanced("basicauth", basicAuthHandler(accounts), nil)
+	if n.imp.host.AccessList != nil && list.bool("satisfy_any") {
+		n.imp.report("access_list", nil, ImportApproximated, fmt.Sprintf("access list %q let either an allowed address or a login through; Charon requires both", name))
+	}
+}
+
+func (n *npmData) certificate(id string) {
+	cert, ok := n.certificates[id]
+	if id == "" || id == "0" || !ok {
+		return
+	}
+	name := cert["nice_name"]
+	if cert["provider"] == "letsencrypt" {
+		n.imp.report("certificate", nil, ImportImported, fmt.Sprintf("Let's Encrypt certificate %q is issued again by Charon", name))
+		return
+	}
+
+	var meta struct {
+		Certificate             string `json:"certificate"`
+		CertificateKey          string `json:"certificate_key"`
+		IntermediateCertificate string `json:"intermediate_certificate"`
+	}
+	_ = json.Unmarshal([]byte(cert["meta"]), &meta)
+	if meta.Certificate == "" || meta.CertificateKey == "" {
+		n.imp.report("certificate", nil, ImportDropped, fmt.Sprintf("custom certificate %q is not in the database; upload the files from data/custom_ssl/npm-%s", name, id))
+		return
+	}
+	chain := strings.TrimSpace(meta.Certificate) + "\n"
+	if meta.IntermediateCertificate != "" {
+		chain += strings.TrimSpace(meta.IntermediateCertificate) + "\n"
+	}
+	n.imp.host.Certificate = &ParsedCertificate{Name: name, Certificate: chain, PrivateKey: meta.CertificateKey}
+	n.imp.report("certificate", nil, ImportImported, fmt.Sprintf("custom certificate %q", name))
+}
+
+// npmPasswordHash returns a bcrypt hash for Caddy's basic auth. Nginx Proxy
+// Manager stores access list passwords as entered.
+func npmPasswordHash(password string) (string, error) {
+	switch {
+	case password == "":
+		return "", fmt.Errorf("no password stored")
+	case strings.HasPrefix(password, "$2"):
+		return password, nil
+	case strings.HasPrefix(password, "$apr1$"):
+		return "", fmt.Errorf("apr1 password hashes are not supported")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// npmDomains turns the JSON domain list into Charon's comma-separated form.
+func npmDomains(raw string) string {
+	var domains []string
+	if err := json.Unmarshal([]byte(raw), &domains); err != nil {
+		return raw
+	}
+	return strings.Join(domains, ", ")
+}
+
+func hasNginxDirectives(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// live drops soft-deleted rows.
+func live(rows []npmRow) []npmRow {
+	out := make([]npmRow, 0, len(rows))
+	for _, r := range rows {
+		if !r.bool("is_deleted") {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func byID(rows []npmRow) map[string]npmRow {
+	m := make(map[string]npmRow, len(rows))
+	for _, r := range live(rows) {
+		m[r["id"]] = r
+	}
+	return m
+}
+
+func byAccessList(rows []npmRow) map[string][]npmRow {
+	m := make(map[string][]npmRow)
+	for _, r := range rows {
+		m[r["access_list_id"]] = append(m[r["access_list_id"]], r)
+	}
+	return m
+}
+
+// loadNPMTables reads the importer's tables from a SQLite database file or a SQL dump.
+func loadNPMTables(path string) (map[string][]npmRow, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading database: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		return loadNPMSQLite(path)
+	}
+	return parseSQLDump(string(data), npmTables)
+}
+
+func loadNPMSQLite(path string) (map[string][]npmRow, error) {
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Clean(path)+"?mode=ro"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+
+	tables := make(map[string][]npmRow)
+	for _, table := range npmTables {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		rows, err := db.Table(table).Rows()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+		tables[table] = []npmRow{}
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("reading %s: %w", table, err)
+			}
+			row := npmRow{}
+			for i, v := range values {
+				switch x := v.(type) {
+				case nil:
+				case []byte:
+					row[strings.ToLower(cols[i])] = string(x)
+				case time.Time:
+					row[strings.ToLower(cols[i])] = x.Format(time.RFC3339)
+				case bool:
+					row[strings.ToLower(cols[i])] = strconv.FormatBool(x)
+				default:
+					row[strings.ToLower(cols[i])] = fmt.Sprint(x)
+				}
+			}
+			tables[table] = append(tables[table], row)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+	}
+	return tables, nil
+}
@@ -0,0 +1,383 @@
+package caddy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// npmRow is one database row keyed by column name. NULL columns are absent.
+type npmRow map[string]string
+
+func (r npmRow) bool(col string) bool {
+	v := strings.ToLower(r[col])
+	return v == "1" || v == "true"
+}
+
+func (r npmRow) int(col string) int {
+	n, _ := strconv.Atoi(r[col])
+	return n
+}
+
+type sqlTokenKind int
+
+const (
+	sqlWord   sqlTokenKind = iota // keywords, bare identifiers, numbers
+	sqlIdent                      // quoted identifiers
+	sqlString                     // string literals, unescaped
+	sqlPunct                      // ( ) , ; and other single characters
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// parseSQLDump reads the rows of the given tables from a mysqldump or sqlite3
+// .dump file. Only CREATE TABLE (for column order) and INSERT statements are read.
+func parseSQLDump(dump string, tables []string) (map[string][]npmRow, error) {
+	tokens, err := tokenizeSQL(dump, isMySQLDump(dump))
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		want[t] = true
+	}
+
+	columns := make(map[string][]string)
+	rows := make(map[string][]npmRow)
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && (tokens[i].kind != sqlPunct || tokens[i].text != ";") {
+			continue
+		}
+		stmt := tokens[start:i]
+		start = i + 1
+		if len(stmt) < 3 || stmt[0].kind != sqlWord {
+			continue
+		}
+		switch strings.ToUpper(stmt[0].text) {
+		case "CREATE":
+			if name, cols := parseCreateTable(stmt); want[name] {
+				columns[name] = cols
+			}
+		case "INSERT", "REPLACE":
+			name, cols, values, err := parseInsert(stmt)
+			if err != nil {
+				return nil, err
+			}
+			if !want[name] {
+				continue
+			}
+			if cols == nil {
+				cols = columns[name]
+			}
+			if _, ok := rows[name]; !ok {
+				rows[name] = []npmRow{}
+			}
+			for _, tuple := range values {
+				if len(tuple) != len(cols) {
+					return nil, fmt.Errorf("insert into %s: %d values for %d columns", name, len(tuple), len(cols))
+				}
+				row := npmRow{}
+				for j, v := range tuple {
+					if v != nil {
+						row[cols[j]] = *v
+					}
+				}
+				rows[name] = append(rows[name], row)
+			}
+		}
+	}
+	for name := range columns {
+		if _, ok := rows[name]; !ok {
+			rows[name] = []npmRow{}
+		}
+	}
+	return rows, nil
+}
+
+// isMySQLDump reports whether backslashes escape characters in string literals.
+// sqlite3 only doubles quotes.
+func isMySQLDump(dump string) bool {
+	return strings.Contains(dump, "-- MySQL dump") || strings.Contains(dump, "-- MariaDB dump") || strings.Contains(dump, "ENGINE=")
+}
+
+func tokenizeSQL(s string, backslashEscapes bool) ([]sqlToken, error) {
+	var tokens []sqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "--"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			text, next, err := readQuoted(s, i, backslashEscapes && c != '`')
+			if err != nil {
+				return nil, err
+			}
+			kind := sqlIdent
+			if c == '\'' || (c == '"' && backslashEscapes) {
+				kind = sqlString
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: text})
+			i = next
+		case isSQLWordChar(rune(c)) || (c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for j < len(s) && isSQLWordChar(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlWord, text: s[i:j]})
+			i = j
+		default:
+			tokens = append(tokens, sqlToken{kind: sqlPunct, text: string(c)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isSQLWordChar(r rune) bool {
+	return r == '_' || r == '.' || r == '$' || r == '+' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// readQuoted reads the literal starting at s[start] and returns its unescaped text
+// and the index after the closing quote.
+func readQuoted(s string, start int, backslashEscapes bool) (string, int, error) {
+	quote := s[start]
+	var b strings.Builder
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && backslashEscapes && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '0':
+				b.WriteByte(0)
+			case 'Z':
+				b.WriteByte(26)
+			default:
+				b.WriteByte(s[i])
+			}
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			b.WriteByte(quote)
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string literal")
+}
+
+// tableName lowercases a possibly schema-qualified table name.
+func tableName(t sqlToken) string {
+	name := t.text
+	if t.kind == sqlWord {
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+	}
+	return strings.ToLower(name)
+}
+
+// parseCreateTable returns the table name and column names of a CREATE TABLE statement.
+func parseCreateTable(stmt []sqlToken) (string, []string) {
+	i := 1
+	for i < len(stmt) && stmt[i].kind == sqlWord && strings.ToUpper(stmt[i].text) != "TABLE" {
+		i++ // TEMPORARY, ...
+	}
+	i++
+	for i < len(stmt) && stmt[i].kind == sqlWord && isOneOf(stmt[i].text, "IF", "NOT", "EXISTS") {
+		i++
+	}
+	if i >= len(stmt) {
+		return "", nil
+	}
+	name := tableName(stmt[i])
+	i++
+	if i >= len(stmt) || stmt[i].text != "(" {
+		return name, nil
+	}
+
+	var cols []string
+	depth := 0
+	expectColumn := true
+	for ; i < len(stmt); i++ {
+		t := stmt[i]
+		if t.kind == sqlPunct {
+			switch t.text {
+			case "(":
+				depth++
+				if depth == 1 {
+					expectColumn = true
+				}
+				continue
+			case ")":
+				depth--
+				continue
+			case ",":
+				if depth == 1 {
+					expectColumn = true
+				}
+				continue
+			}
+		}
+		if depth == 1 && expectColumn {
+			expectColumn = false
+			if t.kind == sqlWord && isOneOf(t.text, "PRIMARY", "KEY", "UNIQUE", "INDEX", "CONSTRAINT", "FOREIGN", "CHECK", "FULLTEXT") {
+				continue
+			}
+			cols = append(cols, strings.ToLower(t.text))
+		}
+	}
+	return name, cols
+}
+
+// parseInsert returns the table, the explicit column list (nil when omitted) and
+// the value tuples of an INSERT statement. NULL values are nil.
+func parseInsert(stmt []sqlToken) (string, []string, [][]*string, error) {
+	i := 1
+	for i < len(stmt) && stmt[i].kind == sqlWord && strings.ToUpper(stmt[i].text) != "INTO" {
+		i++ // IGNORE, OR REPLACE, ...
+	}
+	i++
+	if i >= len(stmt) {
+		return "", nil, nil, nil
+	}
+	name := tableName(stmt[i])
+	i++
+
+	var cols []string
+	if i < len(stmt) && stmt[i].text == "(" {
+		for i++; i < len(stmt) && stmt[i].text != ")"; i++ {
+			if stmt[i].kind != sqlPunct {
+				cols = append(cols, strings.ToLower(stmt[i].text))
+			}
+		}
+		i++
+	}
+	if i >= len(stmt) || !strings.EqualFold(stmt[i].text, "VALUES") {
+		return name, cols, nil, nil // INSERT ... SELECT is not supported
+	}
+	i++
+
+	var tuples [][]*string
+	for i < len(stmt) {
+		if stmt[i].text == "," {
+			i++
+			continue
+		}
+		if stmt[i].text != "(" {
+			break
+		}
+		i++
+		var tuple []*string
+		for i < len(stmt) && stmt[i].text != ")" {
+			v, next, err := sqlValue(stmt, i)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("insert into %s: %w", name, err)
+			}
+			tuple = append(tuple, v)
+			i = next
+			if i < len(stmt) && stmt[i].text == "," {
+				i++
+			}
+		}
+		i++
+		tuples = append(tuples, tuple)
+	}
+	return name, cols, tuples, nil
+}
+
+// sqlValue evaluates one value: a literal, NULL, or the replace()/char() calls
+// that sqlite3 .dump uses for strings containing newlines.
+func sqlValue(stmt []sqlToken, i int) (*string, int, error) {
+	if i >= len(stmt) {
+		return nil, i, fmt.Errorf("unexpected end of values")
+	}
+	t := stmt[i]
+	if t.kind == sqlString {
+		v := t.text
+		return &v, i + 1, nil
+	}
+	if t.kind != sqlWord {
+		return nil, i, fmt.Errorf("unexpected %q in values", t.text)
+	}
+	if strings.EqualFold(t.text, "NULL") {
+		return nil, i + 1, nil
+	}
+	if i+1 >= len(stmt) || stmt[i+1].text != "(" {
+		v := t.text
+		return &v, i + 1, nil
+	}
+
+	// Function call
+	var args []string
+	i += 2
+	for i < len(stmt) && stmt[i].text != ")" {
+		arg, next, err := sqlValue(stmt, i)
+		if err != nil {
+			return nil, i, err
+		}
+		if arg == nil {
+			args = append(args, "")
+		} else {
+			args = append(args, *arg)
+		}
+		i = next
+		if i < len(stmt) && stmt[i].text == "," {
+			i++
+		}
+	}
+	i++
+
+	var v string
+	switch strings.ToLower(t.text) {
+	case "char":
+		var b strings.Builder
+		for _, a := range args {
+			n, err := strconv.Atoi(a)
+			if err != nil {
+				return nil, i, fmt.Errorf("char(%s): %w", a, err)
+			}
+			b.WriteRune(rune(n))
+		}
+		v = b.String()
+	case "replace":
+		if len(args) != 3 {
+			return nil, i, fmt.Errorf("replace() takes 3 arguments")
+		}
+		v = strings.ReplaceAll(args[0], args[1], args[2])
+	default:
+		return nil, i, fmt.Errorf("unsupported function %s() in values", t.text)
+	}
+	return &v, i, nil
+}
+
+func isOneOf(word string, options ...string) bool {
+	for _, o := range options {
+		if strings.EqualFold(word, o) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package caddy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// npmFixtureSQL is an Nginx Proxy Manager database as `sqlite3 .dump` writes it.
+func npmFixtureSQL(t *testing.T, certPEM string) string {
+	meta, err := json.Marshal(map[string]string{"certificate": certPEM, "certificate_key": "KEY PEM"})
+	require.NoError(t, err)
+	return `PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE ` + "`proxy_host` (`id` integer not null primary key autoincrement, `is_deleted` integer default '0', `domain_names` json, `forward_host` varchar(255), `forward_port` integer, `access_list_id` integer default '0', `certificate_id` integer default '0', `ssl_forced` integer default '0', `caching_enabled` integer default '0', `block_exploits` integer default '0', `advanced_config` text, `allow_websocket_upgrade` integer default '0', `forward_scheme` varchar(255) default 'http', `enabled` integer default '1', `locations` json, `hsts_enabled` integer default '0', `hsts_subdomains` integer default '0'" + `);
+INSERT INTO proxy_host VALUES(1,0,'["app.example.com","www.example.com"]','app',8080,1,1,1,0,1,replace('location /x {\n  return 404;\n}','\n',char(10)),1,'http',1,'[{"path":"/api","forward_scheme":"http","forward_host":"api/v1","forward_port":9000,"advanced_config":""}]',1,1);
+INSERT INTO proxy_host VALUES(2,0,'["other.example.com"]','other',443,1,2,0,1,0,'',0,'https',0,NULL,0,0);
+INSERT INTO proxy_host VALUES(3,1,'["deleted.example.com"]','gone',80,0,0,0,0,1,'',0,'http',1,NULL,0,0);
+CREATE TABLE "redirection_host" ("id" integer primary key, "is_deleted" integer, "domain_names" json, "forward_domain_name" varchar(255), "forward_scheme" varchar(255), "forward_http_code" integer, "ssl_forced" integer);
+INSERT INTO redirection_host VALUES(1,0,'["old.example.com"]','new.example.com','auto',301,1);
+CREATE TABLE access_list (id integer primary key, is_deleted integer, name varchar(255), satisfy_any integer, pass_auth integer);
+INSERT INTO access_list VALUES(1,0,'Bob''s office',1,0);
+CREATE TABLE access_list_client (id integer primary key, access_list_id integer, address varchar(255), directive varchar(255));
+INSERT INTO access_list_client VALUES(1,1,'10.0.0.0/8','allow');
+INSERT INTO access_list_client VALUES(2,1,'all','deny');
+CREATE TABLE access_list_auth (id integer primary key, access_list_id integer, username varchar(255), password varchar(255));
+INSERT INTO access_list_auth VALUES(1,1,'bob','s3cret');
+CREATE TABLE certificate (id integer primary key, is_deleted integer, provider varchar(255), nice_name varchar(255), meta json);
+INSERT INTO certificate VALUES(1,0,'other','Corp','` + string(meta) + `');
+INSERT INTO certificate VALUES(2,0,'letsencrypt','other.example.com','{}');
+CREATE TABLE stream (id integer primary key, is_deleted integer);
+INSERT INTO stream VALUES(1,0);
+COMMIT;
+`
+}
+
+func TestImportNPM(t *testing.T) {
+	certPEM, _ := testCAPEM(t)
+	fixture := npmFixtureSQL(t, certPEM)
+	dir := t.TempDir()
+
+	// The same installation as a SQLite database file and as a dump
+	dbPath := filepath.Join(dir, "database.sqlite")
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(fixture).Error)
+	sqlDB, _ := db.DB()
+	require.NoError(t, sqlDB.Close())
+	dumpPath := filepath.Join(dir, "npm.sql")
+	require.NoError(t, os.WriteFile(dumpPath, []byte(fixture), 0o600))
+
+	snippets := map[string]string{
+		"server_proxy.conf": "proxy_set_header X-Custom 1;",
+		"http_top.conf":     "map $a $b {}",
+		"stream.conf":       "# nothing here\n",
+		"12.conf":           "server {}",
+	}
+
+	for _, path := range []string{dbPath, dumpPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			result, err := ImportNPM(NPMSource{Database: path, Snippets: snippets})
+			require.NoError(t, err)
+			require.Len(t, result.Hosts, 3, "deleted hosts are skipped")
+			assert.Equal(t, []string{
+				"12.conf is generated by Nginx Proxy Manager from the database and was not read",
+				"custom nginx snippet http_top.conf is not converted",
+				"1 stream(s) are not imported",
+			}, result.Warnings)
+
+			app := result.Hosts[0]
+			assert.Equal(t, "app.example.com, www.example.com", app.DomainNames)
+			assert.Equal(t, "app", app.ForwardHost)
+			assert.Equal(t, 8080, app.ForwardPort)
+			assert.True(t, app.SSLForced)
+			assert.True(t, app.WebsocketSupport)
+			assert.True(t, app.HSTSEnabled)
+			assert.True(t, app.HSTSSubdomains)
+			assert.Equal(t, []ParsedLocation{{Path: "/api", ForwardScheme: "http", ForwardHost: "api", ForwardPort: 9000}}, app.Locations)
+			assert.Equal(t, &ParsedAccessList{Name: "Bob's office", Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}, app.AccessList)
+			require.NotNil(t, app.Certificate)
+			assert.Equal(t, "Corp", app.Certificate.Name)
+			assert.Equal(t, "KEY PEM", app.Certificate.PrivateKey)
+
+			var auth map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(app.AdvancedConfig), &auth))
+			assert.Equal(t, "authentication", auth["handler"])
+			account := auth["providers"].(map[string]interface{})["http_basic"].(map[string]interface{})["accounts"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, "bob", account["username"])
+			assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(account["password"].(string)), []byte("s3cret")))
+
+			type outcome struct{ directive, status string }
+			var got []outcome
+			for _, r := range app.Report {
+				got = append(got, outcome{r.Directive, r.Status})
+			}
+			assert.Equal(t, []outcome{
+				{"forward", ImportImported},
+				{"location", ImportApproximated},
+				{"access_list", ImportImported},
+				{"basicauth", ImportImported},
+				{"access_list", ImportApproximated},
+				{"certificate", ImportImported},
+				{"advanced_config", ImportDropped},
+				{"custom_snippet", ImportDropped},
+			}, got)
+
+			other := result.Hosts[1]
+			assert.Equal(t, "https", other.ForwardScheme)
+			assert.Nil(t, other.Certificate, "Let's Encrypt certificates are issued again")
+			var otherDirectives []string
+			for _, r := range other.Report {
+				otherDirectives = append(otherDirectives, r.Directive)
+			}
+			assert.Equal(t, []string{"forward", "access_list", "basicauth", "access_list", "certificate", "caching", "block_exploits", "enabled", "custom_snippet"}, otherDirectives)
+
+			redirect := result.Hosts[2]
+			assert.Equal(t, "old.example.com", redirect.DomainNames)
+			assert.Empty(t, redirect.ForwardHost)
+			require.Len(t, redirect.Report, 1)
+			assert.Equal(t, ImportImported, redirect.Report[0].Status)
+			assert.Equal(t, "as redirection host to new.example.com (301)", redirect.Report[0].Detail)
+			assert.Equal(t, &ParsedRedirect{ForwardScheme: "auto", ForwardDomainName: "new.example.com", ForwardHTTPCode: 301}, redirect.Redirect)
+			assert.True(t, redirect.SSLForced)
+
+			redirects := ConvertToRedirectionHosts(result.Hosts)
+			require.Len(t, redirects, 1)
+			assert.Equal(t, "old.example.com", redirects[0].DomainNames)
+			assert.True(t, redirects[0].Enabled)
+		})
+	}
+}
+
+func TestImportNPM_NotNPM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	require.NoError(t, os.WriteFile(path, []byte("CREATE TABLE users (id integer);"), 0o600))
+	_, err := ImportNPM(NPMSource{Database: path})
+	assert.ErrorContains(t, err, "no proxy_host table")
+}
+
+func TestParseSQLDump_MySQL(t *testing.T) {
+	dump := "-- MySQL dump 10.13  Distrib 8.0.36\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"CREATE TABLE `proxy_host` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `domain_names` json NOT NULL,\n" +
+		"  `forward_port` decimal(10,2) DEFAULT ',',\n" +
+		"  `advanced_config` text NOT NULL,\n" +
+		"  `meta` json,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `proxy_host_owner_user_id_index` (`owner_user_id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb3;\n" +
+		"INSERT INTO `proxy_host` VALUES (1,'[\\\"a.example.com\\\"]',-1,'proxy_set_header X \\'y\\';\\nlocation ~ \\\\.php$ {}',NULL),(2,'[\\\"b.example.com\\\"]',80,'',NULL);\n" +
+		"INSERT INTO `users` VALUES (1,'admin');\n"
+
+	tables, err := parseSQLDump(dump, []string{"proxy_host"})
+	require.NoError(t, err)
+	require.NotContains(t, tables, "users")
+	require.Len(t, tables["proxy_host"], 2)
+
+	row := tables["proxy_host"][0]
+	assert.Equal(t, `["a.example.com"]`, row["domain_names"])
+	assert.Equal(t, "-1", row["forward_port"])
+	assert.Equal(t, "proxy_set_header X 'y';\nlocation ~ \\.php$ {}", row["advanced_config"])
+	_, hasMeta := row["meta"]
+	assert.False(t, hasMeta, "NULL columns are absent")
+	assert.Equal(t, "80", tables["proxy_host"][1]["forward_port"])
+}
+
+func TestParseSQLDump_Errors(t *testing.T) {
+	_, err := parseSQLDump("INSERT INTO proxy_host VALUES('open", []string{"proxy_host"})
+	assert.ErrorContains(t, err, "unterminated string")
+
+	_, err = parseSQLDump("INSERT INTO proxy_host (id, name) VALUES (1);", []string{"proxy_host"})
+	assert.ErrorContains(t, err, "1 values for 2 columns")
+
+	_, err = parseSQLDump("INSERT INTO proxy_host (id) VALUES (lower('A'));", []string{"proxy_host"})
+	assert.ErrorContains(t, err, "unsupported function lower()")
+}
+
+func TestConvertToProxyHosts_SharesNPMAccessListsAndCertificates(t *testing.T) {
+	certPEM, _ := testCAPEM(t)
+	acl := &ParsedAccessList{Name: "Office", Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}
+	cert := &ParsedCertificate{Name: "Corp", Certificate: certPEM, PrivateKey: "KEY PEM"}
+	hosts := ConvertToProxyHosts([]ParsedHost{
+		{DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, AccessList: acl, Certificate: cert},
+		{DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, AccessList: &ParsedAccessList{Name: "Office", Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}, Certificate: cert},
+		{DomainNames: "c.example.com", ForwardHost: "c", ForwardPort: 80, AccessList: &ParsedAccessList{Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}},
+	})
+	require.Len(t, hosts, 3)
+	assert.Same(t, hosts[0].AccessList, hosts[1].AccessList)
+	assert.Same(t, hosts[0].Certificate, hosts[1].Certificate)
+	assert.Equal(t, "Office", hosts[0].AccessList.Name)
+	assert.Equal(t, "Imported: c.example.com", hosts[2].AccessList.Name)
+
+	assert.Equal(t, "custom", hosts[0].Certificate.Provider)
+	assert.Equal(t, "KEY PEM", hosts[0].Certificate.PrivateKey)
+	require.NotNil(t, hosts[0].Certificate.ExpiresAt)
+	assert.Equal(t, "Company Client CA", hosts[0].Certificate.Domains)
+}
+
+func TestImportNPM_LocationsKeepAccessListLogin(t *testing.T) {
+	certPEM, _ := testCAPEM(t)
+	path := filepath.Join(t.TempDir(), "npm.sql")
+	require.NoError(t, os.WriteFile(path, []byte(npmFixtureSQL(t, certPEM)), 0o600))
+	result, err := ImportNPM(NPMSource{Database: path})
+	require.NoError(t, err)
+
+	hosts := ConvertToProxyHosts(result.Hosts[:1])
+	require.Len(t, hosts, 1)
+	require.Len(t, hosts[0].Locations, 1)
+	hosts[0].UUID, hosts[0].Enabled = "app", true
+	hosts[0].Locations[0].UUID = "api"
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	// The access list users guard the imported location as well as the host
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	guarded := map[string]bool{}
+	for _, r := range routes {
+		for _, h := range r.Handle {
+			if h["handler"] == "authentication" {
+				guarded[r.ID] = true
+			}
+		}
+	}
+	assert.True(t, guarded[LocationRouteID("app", "api")])
+	assert.True(t, guarded[HostRouteID("app")])
+}
+
+func TestImportResult_Redacted(t *testing.T) {
+	result := ImportResult{Hosts: []ParsedHost{{DomainNames: "a.example.com", Certificate: &ParsedCertificate{Name: "Corp", Certificate: "CERT", PrivateKey: "KEY"}}}}
+	redacted := result.Redacted()
+	assert.Empty(t, redacted.Hosts[0].Certificate.PrivateKey)
+	assert.Equal(t, "CERT", redacted.Hosts[0].Certificate.Certificate)
+	assert.Equal(t, "KEY", result.Hosts[0].Certificate.PrivateKey, "the original keeps the key for commit")
+
+	raw, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "private_key")
+}
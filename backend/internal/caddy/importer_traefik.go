@@ -0,0 +1,446 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// traefikConfig is the part of Traefik's file-provider dynamic configuration the importer reads.
+type traefikConfig struct {
+	HTTP *struct {
+		Routers     map[string]traefikRouter              `json:"routers"`
+		Services    map[string]traefikService             `json:"services"`
+		Middlewares map[string]map[string]json.RawMessage `json:"middlewares"`
+	} `json:"http"`
+	TCP *struct {
+		Routers map[string]json.RawMessage `json:"routers"`
+	} `json:"tcp"`
+	UDP *struct {
+		Routers map[string]json.RawMessage `json:"routers"`
+	} `json:"udp"`
+	TLS *struct {
+		Certificates []json.RawMessage `json:"certificates"`
+	} `json:"tls"`
+}
+
+type traefikRouter struct {
+	Rule        string          `json:"rule"`
+	Service     string          `json:"service"`
+	Middlewares []string        `json:"middlewares"`
+	TLS         json.RawMessage `json:"tls"`
+}
+
+type traefikService struct {
+	LoadBalancer *struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+	} `json:"loadBalancer"`
+}
+
+type traefikHeaders struct {
+	CustomRequestHeaders    map[string]string `json:"customRequestHeaders"`
+	CustomResponseHeaders   map[string]string `json:"customResponseHeaders"`
+	STSSeconds              int               `json:"stsSeconds"`
+	STSIncludeSubdomains    bool              `json:"stsIncludeSubdomains"`
+	FrameDeny               bool              `json:"frameDeny"`
+	CustomFrameOptionsValue string            `json:"customFrameOptionsValue"`
+	ContentTypeNosniff      bool              `json:"contentTypeNosniff"`
+	BrowserXSSFilter        bool              `json:"browserXssFilter"`
+	ContentSecurityPolicy   string            `json:"contentSecurityPolicy"`
+	ReferrerPolicy          string            `json:"referrerPolicy"`
+}
+
+// traefikMatcher matches one matcher call in a router rule, e.g. Host(`a.example.com`).
+var traefikMatcher = regexp.MustCompile("(!?)\\s*([A-Za-z]+)\\(([^)]*)\\)")
+
+// ImportTraefik reads the routers, services and middlewares of a Traefik
+// file-provider dynamic configuration. format is "yaml" or "toml".
+func ImportTraefik(content []byte, format string) (*ImportResult, error) {
+	var raw map[string]interface{}
+	switch format {
+	case "yaml":
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("parsing traefik yaml: %w", err)
+		}
+	case "toml":
+		if err := toml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("parsing traefik toml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported traefik format %q", format)
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing traefik config: %w", err)
+	}
+	var cfg traefikConfig
+	if err := json.Unmarshal(normalized, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing traefik config: %w", err)
+	}
+
+	result := &ImportResult{
+		Hosts:     []ParsedHost{},
+		Conflicts: []string{},
+		Errors:    []string{},
+	}
+	if cfg.TCP != nil && len(cfg.TCP.Routers) > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d TCP router(s) are not imported", len(cfg.TCP.Routers)))
+	}
+	if cfg.UDP != nil && len(cfg.UDP.Routers) > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d UDP router(s) are not imported", len(cfg.UDP.Routers)))
+	}
+	if cfg.TLS != nil && len(cfg.TLS.Certificates) > 0 {
+		result.Warnings = append(result.Warnings, "TLS certificate files are not read; upload them on the Certificates page")
+	}
+	if cfg.HTTP == nil {
+		return result, nil
+	}
+
+	t := &traefikImport{cfg: &cfg, hosts: map[string]*traefikHost{}}
+	names := make([]string, 0, len(cfg.HTTP.Routers))
+	for name := range cfg.HTTP.Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if warning := t.router(name, cfg.HTTP.Routers[name]); warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+	for _, key := range t.order {
+		result.Hosts = append(result.Hosts, t.hosts[key].finish())
+	}
+	return result, nil
+}
+
+// traefikImport groups routers by their Host rule; each group is one proxy host.
+type traefikImport struct {
+	cfg   *traefikConfig
+	hosts map[string]*traefikHost
+	order []string
+}
+
+type traefikHost struct {
+	imp        *hostImport
+	headers    headerRules
+	tls, plain bool // routers with and without TLS
+}
+
+// router maps one HTTP router. Routers that cannot be tied to a host return a warning.
+func (t *traefikImport) router(name string, r traefikRouter) string {
+	var domains, paths, unsupported []string
+	negated := false
+	for _, m := range traefikMatcher.FindAllStringSubmatch(r.Rule, -1) {
+		args := traefikArgs(m[3])
+		if m[1] != "" {
+			negated = true
+			continue
+		}
+		switch m[2] {
+		case "Host":
+			domains = append(domains, args...)
+		case "PathPrefix", "Path":
+			paths = append(paths, args...)
+		default:
+			unsupported = append(unsupported, m[2])
+		}
+	}
+	if len(domains) == 0 {
+		return fmt.Sprintf("router %s has no Host rule and was not imported", name)
+	}
+
+	key := strings.ToLower(strings.Join(domains, ", "))
+	h := t.hosts[key]
+	if h == nil {
+		h = &traefikHost{imp: &hostImport{host: &ParsedHost{DomainNames: strings.Join(domains, ", ")}}}
+		t.hosts[key] = h
+		t.order = append(t.order, key)
+	}
+	imp := h.imp
+
+	if len(unsupported) > 0 {
+		imp.report("rule", paths, ImportDropped, fmt.Sprintf("router %s matches by %s, which is not supported; the router was skipped", name, strings.Join(unsupported, ", ")))
+		return ""
+	}
+	if negated {
+		imp.report("rule", paths, ImportApproximated, fmt.Sprintf("router %s: negated matchers are ignored", name))
+	}
+	if len(r.TLS) > 0 && string(r.TLS) != "null" {
+		h.tls = true
+	} else {
+		h.plain = true
+	}
+
+	upstreams, scheme, ok := t.service(imp, name, r.Service, paths)
+	if !ok {
+		return ""
+	}
+	forwardHost, forwardPort := parseDial(upstreams[0])
+
+	locPaths := locationPaths(paths)
+	if len(locPaths) == 0 {
+		imp.host.ForwardScheme, imp.host.ForwardHost, imp.host.ForwardPort = scheme, forwardHost, forwardPort
+		imp.host.Upstreams = upstreams
+	}
+	for _, p := range locPaths {
+		imp.host.Locations = append(imp.host.Locations, ParsedLocation{Path: p, ForwardScheme: scheme, ForwardHost: forwardHost, ForwardPort: forwardPort})
+	}
+	if len(upstreams) > 1 {
+		imp.report("service", paths, ImportApproximated, fmt.Sprintf("service %s has %d servers; Charon proxies to the first (%s)", r.Service, len(upstreams), upstreams[0]))
+	} else {
+		imp.report("service", paths, ImportImported, r.Service)
+	}
+
+	for _, mw := range r.Middlewares {
+		t.middleware(h, mw, paths, 0)
+	}
+	return ""
+}
+
+// service resolves a load-balancer service to its server addresses and scheme.
+func (t *traefikImport) service(imp *hostImport, router, name string, paths []string) ([]string, string, bool) {
+	svc, found := t.cfg.HTTP.Services[traefikName(name)]
+	if !found || svc.LoadBalancer == nil || len(svc.LoadBalancer.Servers) == 0 {
+		detail := fmt.Sprintf("router %s: service %s is not a load balancer in this file", router, name)
+		if !found && strings.Contains(name, "@") {
+			detail = fmt.Sprintf("router %s: service %s comes from another provider", router, name)
+		}
+		imp.report("service", paths, ImportDropped, detail)
+		return nil, "", false
+	}
+
+	var upstreams []string
+	scheme := ""
+	for _, s := range svc.LoadBalancer.Servers {
+		u, err := url.Parse(s.URL)
+		if err != nil || u.Hostname() == "" {
+			imp.report("service", paths, ImportDropped, fmt.Sprintf("service %s: server URL %q is not valid", name, s.URL))
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		if scheme == "" {
+			scheme = u.Scheme
+			if strings.Trim(u.Path, "/") != "" {
+				imp.report("service", paths, ImportApproximated, fmt.Sprintf("service %s: upstream path %s is dropped", name, u.Path))
+			}
+		}
+		upstreams = append(upstreams, net.JoinHostPort(u.Hostname(), port))
+	}
+	return upstreams, scheme, len(upstreams) > 0
+}
+
+// middleware maps one middleware; chains are followed up to a small depth.
+func (t *traefikImport) middleware(h *traefikHost, ref string, paths []string, depth int) {
+	imp := h.imp
+	name := traefikName(ref)
+	mw, found := t.cfg.HTTP.Middlewares[name]
+	if !found || len(mw) != 1 {
+		imp.report("middleware", paths, ImportDropped, fmt.Sprintf("middleware %s is not defined in this file", ref))
+		return
+	}
+
+	for kind, raw := range mw {
+		switch kind {
+		case "chain":
+			var chain struct {
+				Middlewares []string `json:"middlewares"`
+			}
+			_ = json.Unmarshal(raw, &chain)
+			if depth > 5 {
+				imp.report(kind, paths, ImportDropped, fmt.Sprintf("middleware chain %s is nested too deeply", name))
+				return
+			}
+			for _, m := range chain.Middlewares {
+				t.middleware(h, m, paths, depth+1)
+			}
+		case "headers":
+			var hdr traefikHeaders
+			_ = json.Unmarshal(raw, &hdr)
+			t.headers(h, name, hdr, paths)
+		case "redirectScheme":
+			var redirect struct {
+				Scheme string `json:"scheme"`
+			}
+			_ = json.Unmarshal(raw, &redirect)
+			if redirect.Scheme == "https" {
+				imp.host.SSLForced = true
+				imp.report(kind, paths, ImportImported, "as Force SSL")
+			} else {
+				imp.report(kind, paths, ImportDropped, fmt.Sprintf("redirect to %s is not supported", redirect.Scheme))
+			}
+		case "basicAuth":
+			var auth struct {
+				Users     []string `json:"users"`
+				UsersFile string   `json:"usersFile"`
+			}
+			_ = json.Unmarshal(raw, &auth)
+			t.basicAuth(imp, name, auth.Users, auth.UsersFile, paths)
+		case "ipWhiteList", "ipAllowList":
+			var allow struct {
+				SourceRange []string `json:"sourceRange"`
+			}
+			_ = json.Unmarshal(raw, &allow)
+			switch {
+			case len(paths) > 0:
+				imp.report(kind, paths, ImportDropped, "IP rules on a path router are not supported")
+			case imp.host.AccessList != nil:
+				imp.report(kind, paths, ImportDropped, "only one IP access list per host is supported")
+			default:
+				imp.host.AccessList = &ParsedAccessList{Name: name, Type: "whitelist", CIDRs: allow.SourceRange}
+				imp.report(kind, paths, ImportImported, "as whitelist")
+			}
+		case "stripPrefix":
+			imp.report(kind, paths, ImportApproximated, "Charon locations forward the full path")
+		default:
+			imp.report(kind, paths, ImportDropped, fmt.Sprintf("middleware %s (%s) is not supported", name, kind))
+		}
+	}
+}
+
+func (t *traefikImport) headers(h *traefikHost, name string, hdr traefikHeaders, paths []string) {
+	imp := h.imp
+	if hdr.STSSeconds > 0 {
+		imp.host.HSTSEnabled = true
+		imp.host.HSTSSubdomains = hdr.STSIncludeSubdomains
+		if hdr.STSSeconds == 31536000 {
+			imp.report("headers", paths, ImportImported, "stsSeconds as HSTS")
+		} else {
+			imp.report("headers", paths, ImportApproximated, fmt.Sprintf("HSTS max-age %d imported with Charon's max-age of one year", hdr.STSSeconds))
+		}
+	}
+
+	response := map[string]string{}
+	if hdr.FrameDeny {
+		response["X-Frame-Options"] = "DENY"
+	}
+	if hdr.CustomFrameOptionsValue != "" {
+		response["X-Frame-Options"] = hdr.CustomFrameOptionsValue
+	}
+	if hdr.ContentTypeNosniff {
+		response["X-Content-Type-Options"] = "nosniff"
+	}
+	if hdr.BrowserXSSFilter {
+		response["X-XSS-Protection"] = "1; mode=block"
+	}
+	if hdr.ContentSecurityPolicy != "" {
+		response["Content-Security-Policy"] = hdr.ContentSecurityPolicy
+	}
+	if hdr.ReferrerPolicy != "" {
+		response["Referrer-Policy"] = hdr.ReferrerPolicy
+	}
+	for k, v := range hdr.CustomResponseHeaders {
+		response[k] = v
+	}
+	changed := false
+	for k, v := range response {
+		changed = true
+		if v == "" {
+			h.headers.remove(true, k)
+		} else {
+			h.headers.set(true, k, v)
+		}
+	}
+	for k, v := range hdr.CustomRequestHeaders {
+		changed = true
+		if v == "" {
+			h.headers.remove(false, k)
+		} else {
+			h.headers.set(false, k, v)
+		}
+	}
+	if !changed {
+		return
+	}
+	if len(paths) > 0 {
+		imp.report("headers", paths, ImportApproximated, fmt.Sprintf("middleware %s kept as advanced config; it applies to the whole host, not just this path", name))
+	} else {
+		imp.report("headers", paths, ImportImported, fmt.Sprintf("middleware %s as advanced config", name))
+	}
+}
+
+func (t *traefikImport) basicAuth(imp *hostImport, name string, users []string, usersFile string, paths []string) {
+	if usersFile != "" {
+		imp.report("basicAuth", paths, ImportDropped, fmt.Sprintf("middleware %s: usersFile %s is not read", name, usersFile))
+	}
+	var accounts []map[string]interface{}
+	for _, u := range users {
+		user, hash, _ := strings.Cut(u, ":")
+		if !strings.HasPrefix(hash, "$2") {
+			imp.report("basicAuth", paths, ImportDropped, fmt.Sprintf("middleware %s: user %s does not have a bcrypt hash", name, user))
+			continue
+		}
+		accounts = append(accounts, map[string]interface{}{"username": user, "password": hash})
+	}
+	if len(accounts) > 0 {
+		imp.keepAdvanced("basicAuth", basicAuthHandler(accounts), paths)
+	}
+}
+
+func (h *traefikHost) finish() ParsedHost {
+	imp := h.imp
+	imp.promoteLocation()
+	if h.tls && !h.plain {
+		imp.host.SSLForced = true
+	}
+	if hdrs := h.headers.handler(); hdrs != nil {
+		imp.advanced = append([]map[string]interface{}{hdrs}, imp.advanced...)
+	}
+	imp.finish()
+	return *imp.host
+}
+
+// traefikArgs splits matcher arguments: `a.example.com`, "b.example.com".
+func traefikArgs(s string) []string {
+	var args []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.Trim(strings.TrimSpace(a), "`\""); a != "" {
+			args = append(args, a)
+		}
+	}
+	return args
+}
+
+// traefikName strips the @file provider suffix from a reference.
+func traefikName(ref string) string {
+	if name, provider, ok := strings.Cut(ref, "@"); ok && provider == "file" {
+		return name
+	}
+	return ref
+}
+
+// TraefikFormat picks yaml or toml from a file name, falling back to the content.
+func TraefikFormat(filename string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		return "toml"
+	case ".yml", ".yaml":
+		return "yaml"
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return "toml"
+		}
+		return "yaml"
+	}
+	return "yaml"
+}
@@ -0,0 +1,187 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const traefikYAML = `
+http:
+  routers:
+    app:
+      rule: "Host(` + "`app.example.com`" + `)"
+      service: app
+      middlewares: [secure, office@file]
+      tls:
+        certResolver: le
+    app-api:
+      rule: "Host(` + "`app.example.com`" + `) && PathPrefix(` + "`/api`" + `)"
+      service: api
+      middlewares: [strip-api, limit]
+      tls: {}
+    mobile:
+      rule: "Host(` + "`app.example.com`" + `) && Headers(` + "`X-Mobile`, `1`" + `)"
+      service: app
+    dashboard:
+      rule: "Host(` + "`traefik.example.com`" + `)"
+      service: api@internal
+    catchall:
+      rule: "PathPrefix(` + "`/`" + `)"
+      service: app
+  services:
+    app:
+      loadBalancer:
+        servers:
+          - url: "http://app1:8080"
+          - url: "http://app2:8080"
+    api:
+      loadBalancer:
+        servers:
+          - url: "https://api:9443/"
+  middlewares:
+    secure:
+      chain:
+        middlewares: [hsts, login, https]
+    hsts:
+      headers:
+        stsSeconds: 31536000
+        stsIncludeSubdomains: true
+        frameDeny: true
+        customRequestHeaders:
+          X-Forwarded-User: ""
+          X-App: charon
+    login:
+      basicAuth:
+        users:
+          - "bob:$2y$05$abcdefghijklmnopqrstuu"
+          - "old:$apr1$xyz$hash"
+    https:
+      redirectScheme:
+        scheme: https
+    office:
+      ipAllowList:
+        sourceRange: ["10.0.0.0/8"]
+    strip-api:
+      stripPrefix:
+        prefixes: ["/api"]
+    limit:
+      rateLimit:
+        average: 100
+tcp:
+  routers:
+    db:
+      rule: "HostSNI(` + "`*`" + `)"
+      service: db
+`
+
+const traefikTOML = `
+[http.routers.app]
+  rule = "Host(` + "`app.example.com`, `www.example.com`" + `)"
+  service = "app"
+
+[http.services.app.loadBalancer]
+  [[http.services.app.loadBalancer.servers]]
+    url = "http://[fd00::1]:8080"
+`
+
+func TestImportTraefik_YAML(t *testing.T) {
+	result, err := ImportTraefik([]byte(traefikYAML), "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1 TCP router(s) are not imported", "router catchall has no Host rule and was not imported"}, result.Warnings)
+	require.Len(t, result.Hosts, 2)
+
+	app := result.Hosts[0]
+	assert.Equal(t, "app.example.com", app.DomainNames)
+	assert.Equal(t, "http", app.ForwardScheme)
+	assert.Equal(t, "app1", app.ForwardHost)
+	assert.Equal(t, 8080, app.ForwardPort)
+	assert.Equal(t, []string{"app1:8080", "app2:8080"}, app.Upstreams)
+	assert.True(t, app.SSLForced)
+	assert.True(t, app.HSTSEnabled)
+	assert.True(t, app.HSTSSubdomains)
+	assert.Equal(t, &ParsedAccessList{Name: "office", Type: "whitelist", CIDRs: []string{"10.0.0.0/8"}}, app.AccessList)
+	assert.Equal(t, []ParsedLocation{{Path: "/api", ForwardScheme: "https", ForwardHost: "api", ForwardPort: 9443}}, app.Locations)
+
+	var advanced []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(app.AdvancedConfig), &advanced))
+	require.Len(t, advanced, 2)
+	assert.Equal(t, map[string]interface{}{
+		"handler":  "headers",
+		"request":  map[string]interface{}{"set": map[string]interface{}{"X-App": []interface{}{"charon"}}, "delete": []interface{}{"X-Forwarded-User"}},
+		"response": map[string]interface{}{"set": map[string]interface{}{"X-Frame-Options": []interface{}{"DENY"}}},
+	}, advanced[0])
+	assert.Equal(t, "authentication", advanced[1]["handler"])
+	assert.Contains(t, app.AdvancedConfig, `"username":"bob"`)
+	assert.NotContains(t, app.AdvancedConfig, "apr1")
+
+	type outcome struct{ directive, path, status string }
+	var got []outcome
+	for _, r := range app.Report {
+		got = append(got, outcome{r.Directive, r.Path, r.Status})
+	}
+	assert.Equal(t, []outcome{
+		{"service", "", ImportApproximated},
+		{"headers", "", ImportImported},
+		{"headers", "", ImportImported},
+		{"basicAuth", "", ImportDropped},
+		{"basicAuth", "", ImportImported},
+		{"redirectScheme", "", ImportImported},
+		{"ipAllowList", "", ImportImported},
+		{"service", "/api", ImportImported},
+		{"stripPrefix", "/api", ImportApproximated},
+		{"rateLimit", "/api", ImportDropped},
+		{"rule", "", ImportDropped},
+	}, got)
+
+	dashboard := result.Hosts[1]
+	assert.Equal(t, "traefik.example.com", dashboard.DomainNames)
+	assert.Empty(t, dashboard.ForwardHost)
+	assert.Equal(t, "router dashboard: service api@internal comes from another provider", dashboard.Report[0].Detail)
+}
+
+func TestImportTraefik_TOML(t *testing.T) {
+	result, err := ImportTraefik([]byte(traefikTOML), TraefikFormat("dynamic.toml", nil))
+	require.NoError(t, err)
+	require.Len(t, result.Hosts, 1)
+	host := result.Hosts[0]
+	assert.Equal(t, "app.example.com, www.example.com", host.DomainNames)
+	assert.Equal(t, "fd00::1", host.ForwardHost)
+	assert.Equal(t, 8080, host.ForwardPort)
+	assert.False(t, host.SSLForced)
+}
+
+func TestImportTraefik_PathOnlyHost(t *testing.T) {
+	result, err := ImportTraefik([]byte(`
+http:
+  routers:
+    api:
+      rule: "Host(`+"`a.example.com`"+`) && PathPrefix(`+"`/api`"+`)"
+      service: api
+  services:
+    api:
+      loadBalancer:
+        servers: [{url: "http://api:9000"}]
+`), "yaml")
+	require.NoError(t, err)
+	host := result.Hosts[0]
+	assert.Equal(t, "api", host.ForwardHost, "the only upstream serves the whole host")
+	require.Len(t, host.Locations, 1)
+	last := host.Report[len(host.Report)-1]
+	assert.Equal(t, ImportApproximated, last.Status)
+	assert.Contains(t, last.Detail, "nothing serves the whole host")
+}
+
+func TestTraefikFormat(t *testing.T) {
+	assert.Equal(t, "yaml", TraefikFormat("dynamic.yml", nil))
+	assert.Equal(t, "toml", TraefikFormat("dynamic.TOML", nil))
+	assert.Equal(t, "toml", TraefikFormat("", []byte("# routers\n\n[http.routers.a]\n")))
+	assert.Equal(t, "yaml", TraefikFormat("", []byte("http:\n  routers: {}\n")))
+
+	_, err := ImportTraefik([]byte("http: ["), "yaml")
+	assert.ErrorContains(t, err, "parsing traefik yaml")
+	_, err = ImportTraefik([]byte("a"), "json")
+	assert.ErrorContains(t, err, "unsupported traefik format")
+}
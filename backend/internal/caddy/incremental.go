@@ -0,0 +1,186 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+)
+
+// maxRouteUpdates caps how many single-route requests are sent instead of one full load.
+const maxRouteUpdates = 10
+
+// HostRouteID is the @id of a proxy host's main route.
+func HostRouteID(hostUUID string) string {
+	return "charon-host-" + hostUUID
+}
+
+// LocationRouteID is the @id of a custom location route of a proxy host.
+func LocationRouteID(hostUUID, locationKey string) string {
+	return "charon-host-" + hostUUID + "-loc-" + locationKey
+}
+
+// routeUpdate is one request against Caddy's admin API that changes a single route.
+type routeUpdate struct {
+	Method string
+	Path   string
+	Route  *Route
+}
+
+// planRouteUpdates returns the single-route requests that turn the running config
+// into next, in the order they must be sent. It returns false when next differs in
+// anything but tagged routes, or when the change is too large to be worth it; a full
+// load is needed then.
+func planRouteUpdates(running, next *Config) ([]routeUpdate, bool) {
+	if running == nil || next == nil || running.Apps.HTTP == nil || next.Apps.HTTP == nil {
+		return nil, false
+	}
+	runningRest, err := withoutRoutes(running)
+	if err != nil {
+		return nil, false
+	}
+	nextRest, err := withoutRoutes(next)
+	if err != nil || !reflect.DeepEqual(runningRest, nextRest) {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(next.Apps.HTTP.Servers))
+	for name := range next.Apps.HTTP.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var updates []routeUpdate
+	for _, name := range names {
+		oldRoutes := running.Apps.HTTP.Servers[name].Routes
+		newRoutes := next.Apps.HTTP.Servers[name].Routes
+
+		oldIDs := make(map[string]bool)
+		for _, r := range oldRoutes {
+			if r.ID != "" {
+				oldIDs[r.ID] = true
+			}
+		}
+		newIDs := make(map[string]bool)
+		for _, r := range newRoutes {
+			if r.ID != "" {
+				newIDs[r.ID] = true
+			}
+		}
+
+		// Deletes first, then inserts by ascending index; current tracks what Caddy
+		// holds after each request so that insert indexes line up
+		current := make([]*Route, 0, len(oldRoutes))
+		for _, r := range oldRoutes {
+			if r.ID != "" && !newIDs[r.ID] {
+				updates = append(updates, routeUpdate{Method: http.MethodDelete, Path: "/id/" + url.PathEscape(r.ID)})
+				continue
+			}
+			current = append(current, r)
+		}
+		for i, r := range newRoutes {
+			if r.ID == "" || oldIDs[r.ID] {
+				continue
+			}
+			if i > len(current) {
+				return nil, false
+			}
+			current = append(current[:i], append([]*Route{r}, current[i:]...)...)
+			updates = append(updates, routeUpdate{
+				Method: http.MethodPut,
+				Path:   fmt.Sprintf("/config/apps/http/servers/%s/routes/%d", url.PathEscape(name), i),
+				Route:  r,
+			})
+		}
+
+		// Whatever is left must line up route for route; changed routes need an @id
+		if len(current) != len(newRoutes) {
+			return nil, false
+		}
+		for i, r := range newRoutes {
+			if current[i].ID != r.ID {
+				return nil, false
+			}
+			if jsonEqual(current[i], r) {
+				continue
+			}
+			if r.ID == "" {
+				return nil, false
+			}
+			updates = append(updates, routeUpdate{Method: http.MethodPatch, Path: "/id/" + url.PathEscape(r.ID), Route: r})
+		}
+	}
+
+	if len(updates) == 0 || len(updates) > maxRouteUpdates {
+		return nil, false
+	}
+	return updates, true
+}
+
+// withoutRoutes returns config as generic JSON with every HTTP server's routes removed.
+func withoutRoutes(config *Config) (map[string]interface{}, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	apps, _ := out["apps"].(map[string]interface{})
+	httpApp, _ := apps["http"].(map[string]interface{})
+	servers, _ := httpApp["servers"].(map[string]interface{})
+	for _, srv := range servers {
+		if s, ok := srv.(map[string]interface{}); ok {
+			delete(s, "routes")
+		}
+	}
+	return out, nil
+}
+
+// load applies config to Caddy. When only tagged routes changed since the last
+// apply, just those routes are sent; otherwise, or if a route request fails, the
+// whole config is loaded.
+func (m *Manager) load(ctx context.Context, config *Config) error {
+	m.applyMu.Lock()
+	defer m.applyMu.Unlock()
+
+	if updates, ok := planRouteUpdates(m.lastApplied, config); ok {
+		err := m.sendRouteUpdates(ctx, updates)
+		if err == nil {
+			logger.Log().WithField("updates", len(updates)).Debug("applied Caddy config through route updates")
+			m.lastApplied = config
+			return nil
+		}
+		logger.Log().WithError(err).Warn("incremental Caddy update failed, loading full config")
+	}
+
+	if err := m.client.Load(ctx, config); err != nil {
+		// The running config is unknown now; the next apply must be a full load
+		m.lastApplied = nil
+		return err
+	}
+	m.lastApplied = config
+	return nil
+}
+
+func (m *Manager) sendRouteUpdates(ctx context.Context, updates []routeUpdate) error {
+	for _, u := range updates {
+		var err error
+		switch u.Method {
+		case http.MethodDelete:
+			err = m.client.Delete(ctx, u.Path)
+		default:
+			err = m.client.Send(ctx, u.Method, u.Path, u.Route)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", u.Method, u.Path, err)
+		}
+	}
+	return nil
+}
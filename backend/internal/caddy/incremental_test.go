@@ -0,0 +1,174 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func routesConfig(routes ...*Route) *Config {
+	return &Config{Apps: Apps{HTTP: &HTTPApp{Servers: map[string]*Server{
+		"charon_server": {Listen: []string{":80"}, Routes: routes},
+	}}}}
+}
+
+func idRoute(id, dial string) *Route {
+	return &Route{ID: id, Match: []Match{{Host: []string{id + ".example.com"}}}, Handle: []Handler{ReverseProxyHandler(dial, false, "")}, Terminal: true}
+}
+
+func TestGenerateConfig_RouteIDs(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID: "h1", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true,
+		Locations: []models.Location{{UUID: "l1", Path: "/api", ForwardHost: "api", ForwardPort: 9000}, {Path: "/x", ForwardHost: "x", ForwardPort: 1}},
+	}, {
+		DomainNames: "untagged.example.com", ForwardHost: "u", ForwardPort: 80, Enabled: true,
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy-data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	// Hosts are emitted newest first
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 5)
+	assert.Empty(t, routes[0].ID, "hosts without a UUID are not tagged")
+	assert.Equal(t, LocationRouteID("h1", "l1"), routes[1].ID)
+	assert.Equal(t, LocationRouteID("h1", "1"), routes[2].ID)
+	assert.Equal(t, HostRouteID("h1"), routes[3].ID)
+	assert.Empty(t, routes[4].ID, "catch-all")
+
+	raw, err := json.Marshal(routes[3])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"@id":"charon-host-h1"`)
+}
+
+func TestPlanRouteUpdates(t *testing.T) {
+	catchAll := &Route{Handle: []Handler{RewriteHandler("/unknown.html")}, Terminal: true}
+	base := routesConfig(idRoute("a", "a:80"), idRoute("b", "b:80"), catchAll)
+
+	t.Run("changed route is patched", func(t *testing.T) {
+		updates, ok := planRouteUpdates(base, routesConfig(idRoute("a", "a:80"), idRoute("b", "b:8080"), catchAll))
+		require.True(t, ok)
+		require.Len(t, updates, 1)
+		assert.Equal(t, http.MethodPatch, updates[0].Method)
+		assert.Equal(t, "/id/b", updates[0].Path)
+		assert.Equal(t, "b", updates[0].Route.ID)
+	})
+
+	t.Run("removed route is deleted", func(t *testing.T) {
+		updates, ok := planRouteUpdates(base, routesConfig(idRoute("b", "b:80"), catchAll))
+		require.True(t, ok)
+		require.Len(t, updates, 1)
+		assert.Equal(t, http.MethodDelete, updates[0].Method)
+		assert.Equal(t, "/id/a", updates[0].Path)
+	})
+
+	t.Run("new route is inserted at its index", func(t *testing.T) {
+		updates, ok := planRouteUpdates(base, routesConfig(idRoute("a", "a:80"), idRoute("new", "n:80"), idRoute("b", "b:80"), catchAll))
+		require.True(t, ok)
+		require.Len(t, updates, 1)
+		assert.Equal(t, http.MethodPut, updates[0].Method)
+		assert.Equal(t, "/config/apps/http/servers/charon_server/routes/1", updates[0].Path)
+	})
+
+	t.Run("delete then insert keeps indexes consistent", func(t *testing.T) {
+		updates, ok := planRouteUpdates(base, routesConfig(idRoute("b", "b:80"), idRoute("new", "n:80"), catchAll))
+		require.True(t, ok)
+		require.Len(t, updates, 2)
+		assert.Equal(t, "DELETE /id/a", updates[0].Method+" "+updates[0].Path)
+		assert.Equal(t, "PUT /config/apps/http/servers/charon_server/routes/1", updates[1].Method+" "+updates[1].Path)
+	})
+
+	t.Run("needs a full load", func(t *testing.T) {
+		changedCatchAll := &Route{Handle: []Handler{RewriteHandler("/other.html")}, Terminal: true}
+		tlsChanged := routesConfig(idRoute("a", "a:80"), idRoute("b", "b:8080"), catchAll)
+		tlsChanged.Apps.TLS = &TLSApp{}
+		manyRoutes := routesConfig()
+		for i := 0; i <= maxRouteUpdates; i++ {
+			manyRoutes.Apps.HTTP.Servers["charon_server"].Routes = append(manyRoutes.Apps.HTTP.Servers["charon_server"].Routes, idRoute(string(rune('c'+i)), "x:80"))
+		}
+
+		cases := map[string]*Config{
+			"unchanged":          routesConfig(idRoute("a", "a:80"), idRoute("b", "b:80"), catchAll),
+			"untagged changed":   routesConfig(idRoute("a", "a:80"), idRoute("b", "b:80"), changedCatchAll),
+			"reordered":          routesConfig(idRoute("b", "b:80"), idRoute("a", "a:80"), catchAll),
+			"non-route change":   tlsChanged,
+			"too many changes":   manyRoutes,
+			"untagged removed":   routesConfig(idRoute("a", "a:80"), idRoute("b", "b:80")),
+			"nothing was loaded": nil,
+		}
+		for name, next := range cases {
+			_, ok := planRouteUpdates(base, next)
+			assert.False(t, ok, name)
+		}
+		_, ok := planRouteUpdates(nil, base)
+		assert.False(t, ok, "no running config")
+	})
+}
+
+func TestManager_ApplyConfigIncremental(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+	ctx := context.Background()
+
+	hostA := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&hostA).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"POST /load"}, admin.takeRequests(), "the first apply is a full load")
+
+	require.NoError(t, db.Model(&hostA).Update("forward_port", 8080).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"PATCH /id/" + HostRouteID("a")}, admin.takeRequests())
+
+	hostB := models.ProxyHost{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&hostB).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"PUT /config/apps/http/servers/charon_server/routes/0"}, admin.takeRequests())
+
+	require.NoError(t, db.Delete(&hostA).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"DELETE /id/" + HostRouteID("a")}, admin.takeRequests())
+
+	// Caddy ends up running exactly the generated config
+	hosts, err := manager.loadHosts()
+	require.NoError(t, err)
+	want, err := manager.generate(ctx, hosts, true)
+	require.NoError(t, err)
+	running, err := manager.client.GetConfig(ctx)
+	require.NoError(t, err)
+	assert.False(t, DiffConfigs(running, want).Changed)
+	admin.takeRequests()
+
+	// Anything beyond tagged routes needs a full load
+	require.NoError(t, db.Create(&models.Setting{Key: "caddy.acme_email", Value: "ops@example.com"}).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"POST /load"}, admin.takeRequests())
+}
+
+func TestManager_ApplyConfigIncrementalFallback(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+	ctx := context.Background()
+
+	host := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	admin.takeRequests()
+
+	admin.failRoutes = true
+	require.NoError(t, db.Model(&host).Update("forward_port", 8080).Error)
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"PATCH /id/" + HostRouteID("a"), "POST /load"}, admin.takeRequests())
+
+	// After a failed load the running config is unknown, so the next apply is a full load
+	admin.failLoad = true
+	require.NoError(t, db.Model(&host).Update("forward_port", 9090).Error)
+	require.Error(t, manager.ApplyConfig(ctx))
+	admin.failRoutes, admin.failLoad = false, false
+	admin.takeRequests()
+
+	require.NoError(t, manager.ApplyConfig(ctx))
+	assert.Equal(t, []string{"POST /load"}, admin.takeRequests())
+}
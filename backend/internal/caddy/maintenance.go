@@ -0,0 +1,180 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// maintenanceRetryAfter is the Retry-After of a window without an end, in seconds.
+const maintenanceRetryAfter = "300"
+
+// maxMaintenanceMinutes caps the length of a recurring window to a week.
+const maxMaintenanceMinutes = 7 * 24 * 60
+
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Down for maintenance</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 4em 1em;">
+<h1>Down for maintenance</h1>
+<p>{{host}} is undergoing maintenance and will be back shortly.</p>
+</body>
+</html>
+`
+
+// ValidateMaintenanceWindow checks the timing, allow-list and page of a window.
+func ValidateMaintenanceWindow(w *models.MaintenanceWindow) error {
+	if w.Schedule != "" {
+		if _, err := cron.ParseStandard(w.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+		if w.DurationMinutes < 1 || w.DurationMinutes > maxMaintenanceMinutes {
+			return fmt.Errorf("duration_minutes must be between 1 and %d for a schedule", maxMaintenanceMinutes)
+		}
+		if w.StartsAt != nil || w.EndsAt != nil {
+			return fmt.Errorf("starts_at and ends_at cannot be combined with a schedule")
+		}
+	} else {
+		if w.DurationMinutes != 0 {
+			return fmt.Errorf("duration_minutes needs a schedule; use ends_at for a one-off window")
+		}
+		if w.StartsAt != nil && w.EndsAt != nil && !w.EndsAt.After(*w.StartsAt) {
+			return fmt.Errorf("ends_at must be after starts_at")
+		}
+	}
+	for _, ip := range splitList(w.AllowIPs) {
+		if net.ParseIP(ip) == nil {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Errorf("allow_ips: %q is not an IP address or CIDR", ip)
+			}
+		}
+	}
+	if len(w.Body) > maxErrorPageSize {
+		return fmt.Errorf("body must be at most %d bytes", maxErrorPageSize)
+	}
+	for _, m := range errorPagePlaceholderRe.FindAllStringSubmatch(w.Body, -1) {
+		if m[1] != "host" && m[1] != "until" {
+			return fmt.Errorf("unknown placeholder %s", m[0])
+		}
+	}
+	return nil
+}
+
+// MaintenanceActive reports whether w is in effect at now and when it ends;
+// the end is nil for a one-off window without ends_at.
+func MaintenanceActive(w models.MaintenanceWindow, now time.Time) (bool, *time.Time) {
+	if !w.Enabled {
+		return false, nil
+	}
+	if w.Schedule != "" {
+		schedule, err := cron.ParseStandard(w.Schedule)
+		if err != nil || w.DurationMinutes <= 0 {
+			return false, nil
+		}
+		d := time.Duration(w.DurationMinutes) * time.Minute
+		// The window is open when a start falls within the last d
+		start := schedule.Next(now.Add(-d))
+		if start.After(now) {
+			return false, nil
+		}
+		end := start.Add(d)
+		return true, &end
+	}
+	if w.StartsAt != nil && now.Before(*w.StartsAt) {
+		return false, nil
+	}
+	if w.EndsAt != nil && !now.Before(*w.EndsAt) {
+		return false, nil
+	}
+	return true, w.EndsAt
+}
+
+// MaintenanceHandler answers 503 with the window's page to every client
+// outside its allow-list; allowed clients fall through to the backend.
+func MaintenanceHandler(w models.MaintenanceWindow, end *time.Time) Handler {
+	retryAfter, until := maintenanceRetryAfter, ""
+	if end != nil {
+		retryAfter = end.UTC().Format(http.TimeFormat)
+		until = end.Format("Mon, 02 Jan 2006 15:04 MST")
+	}
+	page := w.Body
+	if strings.TrimSpace(page) == "" {
+		page = defaultMaintenancePage
+	}
+	page = errorPagePlaceholderRe.ReplaceAllStringFunc(page, func(s string) string {
+		switch errorPagePlaceholderRe.FindStringSubmatch(s)[1] {
+		case "host":
+			return "{http.request.host}"
+		case "until":
+			return until
+		}
+		return s
+	})
+
+	route := map[string]interface{}{
+		"handle": []map[string]interface{}{{
+			"handler":     "static_response",
+			"status_code": http.StatusServiceUnavailable,
+			"headers": map[string][]string{
+				"Content-Type":  {"text/html; charset=utf-8"},
+				"Cache-Control": {"no-store"},
+				"Retry-After":   {retryAfter},
+			},
+			"body": page,
+		}},
+		"terminal": true,
+	}
+	if allow := splitList(w.AllowIPs); len(allow) > 0 {
+		route["match"] = []map[string]interface{}{
+			{"not": []map[string]interface{}{{"remote_ip": map[string]interface{}{"ranges": allow}}}},
+		}
+	}
+	return Handler{"handler": "subroute", "routes": []map[string]interface{}{route}}
+}
+
+// AddMaintenance puts the routes of hosts with an active window into
+// maintenance. When several windows of a host overlap, the one that lasts
+// longest is used.
+func AddMaintenance(config *Config, hosts []models.ProxyHost, windows []models.MaintenanceWindow, now time.Time) {
+	if config == nil || config.Apps.HTTP == nil {
+		return
+	}
+	server := config.Apps.HTTP.Servers["charon_server"]
+	if server == nil {
+		return
+	}
+
+	active := make(map[uint]models.MaintenanceWindow)
+	ends := make(map[uint]*time.Time)
+	for _, w := range windows {
+		ok, end := MaintenanceActive(w, now)
+		if !ok {
+			continue
+		}
+		if cur, seen := ends[w.ProxyHostID]; seen && (cur == nil || (end != nil && !end.After(*cur))) {
+			continue
+		}
+		active[w.ProxyHostID] = w
+		ends[w.ProxyHostID] = end
+	}
+
+	for _, host := range hosts {
+		w, ok := active[host.ID]
+		if !ok || host.UUID == "" {
+			continue
+		}
+		handler := MaintenanceHandler(w, ends[host.ID])
+		id := HostRouteID(host.UUID)
+		for _, route := range server.Routes {
+			if route.ID == id || strings.HasPrefix(route.ID, id+"-") {
+				route.Handle = append([]Handler{handler}, route.Handle...)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateMaintenanceWindow(t *testing.T) {
+	start := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	assert.NoError(t, ValidateMaintenanceWindow(&models.MaintenanceWindow{StartsAt: &start, EndsAt: &end, AllowIPs: "10.0.0.0/8, 192.0.2.7", Body: "{{host}} back at {{until}}"}))
+	assert.NoError(t, ValidateMaintenanceWindow(&models.MaintenanceWindow{Schedule: "0 2 * * 0", DurationMinutes: 60}))
+
+	tests := []struct {
+		window models.MaintenanceWindow
+		err    string
+	}{
+		{models.MaintenanceWindow{Schedule: "every sunday", DurationMinutes: 60}, "invalid schedule"},
+		{models.MaintenanceWindow{Schedule: "0 2 * * 0"}, "duration_minutes must be between"},
+		{models.MaintenanceWindow{Schedule: "0 2 * * 0", DurationMinutes: 60, StartsAt: &start}, "cannot be combined"},
+		{models.MaintenanceWindow{DurationMinutes: 60}, "duration_minutes needs a schedule"},
+		{models.MaintenanceWindow{StartsAt: &end, EndsAt: &start}, "ends_at must be after starts_at"},
+		{models.MaintenanceWindow{AllowIPs: "office"}, `"office" is not an IP address or CIDR`},
+		{models.MaintenanceWindow{Body: "{{status}}"}, "unknown placeholder"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, ValidateMaintenanceWindow(&tt.window), tt.err)
+	}
+}
+
+func TestMaintenanceActive(t *testing.T) {
+	now := time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC) // a Sunday
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	active, end := MaintenanceActive(models.MaintenanceWindow{StartsAt: &before, Enabled: true}, now)
+	assert.True(t, active)
+	assert.Nil(t, end, "no ends_at keeps the window open")
+
+	active, end = MaintenanceActive(models.MaintenanceWindow{StartsAt: &before, EndsAt: &after, Enabled: true}, now)
+	assert.True(t, active)
+	assert.Equal(t, after, *end)
+
+	active, _ = MaintenanceActive(models.MaintenanceWindow{StartsAt: &after, Enabled: true}, now)
+	assert.False(t, active, "not started yet")
+	active, _ = MaintenanceActive(models.MaintenanceWindow{StartsAt: &before, EndsAt: &now, Enabled: true}, now)
+	assert.False(t, active, "ended")
+	active, _ = MaintenanceActive(models.MaintenanceWindow{StartsAt: &before}, now)
+	assert.False(t, active, "disabled")
+
+	weekly := models.MaintenanceWindow{Schedule: "CRON_TZ=UTC 0 2 * * 0", DurationMinutes: 60, Enabled: true}
+	active, end = MaintenanceActive(weekly, now)
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC), end.UTC())
+	active, _ = MaintenanceActive(weekly, now.Add(time.Hour))
+	assert.False(t, active)
+	active, _ = MaintenanceActive(weekly, now.Add(-31*time.Minute))
+	assert.False(t, active)
+}
+
+func TestAddMaintenance(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+			Locations: []models.Location{{UUID: "api", Path: "/api", ForwardHost: "api", ForwardPort: 9000}}},
+		{ID: 2, UUID: "wiki", DomainNames: "wiki.example.com", ForwardHost: "wiki", ForwardPort: 80, Enabled: true},
+	}
+	cfg, err := GenerateConfig(hosts, "/data/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC)
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+	AddMaintenance(cfg, hosts, []models.MaintenanceWindow{
+		{ProxyHostID: 1, StartsAt: &start, EndsAt: &end, AllowIPs: "10.0.0.0/8", Enabled: true},
+		{ProxyHostID: 1, StartsAt: &end, EndsAt: &later, Enabled: true},
+		{ProxyHostID: 2, StartsAt: &start, EndsAt: &end, Enabled: false},
+	}, now)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	byID := make(map[string]*Route)
+	for _, r := range routes {
+		byID[r.ID] = r
+	}
+	want := MaintenanceHandler(models.MaintenanceWindow{AllowIPs: "10.0.0.0/8"}, &end)
+	for _, id := range []string{HostRouteID("app"), LocationRouteID("app", "api")} {
+		require.Contains(t, byID, id)
+		assert.Equal(t, want, byID[id].Handle[0])
+		assert.Equal(t, "reverse_proxy", byID[id].Handle[len(byID[id].Handle)-1]["handler"])
+	}
+	assert.Equal(t, "reverse_proxy", byID[HostRouteID("wiki")].Handle[0]["handler"], "disabled windows do nothing")
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	end := time.Date(2026, 10, 18, 3, 30, 0, 0, time.UTC)
+	out, err := json.Marshal(MaintenanceHandler(models.MaintenanceWindow{AllowIPs: "10.0.0.0/8", Body: "<p>{{host}} is back {{ until }}</p>"}, &end))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"handler": "subroute", "routes": [{
+		"match": [{"not": [{"remote_ip": {"ranges": ["10.0.0.0/8"]}}]}],
+		"handle": [{"handler": "static_response", "status_code": 503, "body": "<p>{http.request.host} is back Sun, 18 Oct 2026 03:30 UTC</p>",
+			"headers": {"Content-Type": ["text/html; charset=utf-8"], "Cache-Control": ["no-store"], "Retry-After": ["Sun, 18 Oct 2026 03:30:00 GMT"]}}],
+		"terminal": true
+	}]}`, string(out))
+
+	// Open-ended, everyone blocked, built-in page
+	h := MaintenanceHandler(models.MaintenanceWindow{}, nil)
+	route := h["routes"].([]map[string]interface{})[0]
+	resp := route["handle"].([]map[string]interface{})[0]
+	assert.Contains(t, resp["body"], "{http.request.host} is undergoing maintenance")
+	assert.Equal(t, []string{"300"}, resp["headers"].(map[string][]string)["Retry-After"])
+	assert.NotContains(t, route, "match")
+}
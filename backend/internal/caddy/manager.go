@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -40,6 +41,17 @@ type Manager struct {
 	frontendDir string
 	acmeStaging bool
 	securityCfg config.SecurityConfig
+	// onDemandAskURL is the permission endpoint Caddy calls before on-demand issuance
+	onDemandAskURL string
+	// modules are the modules of the Caddy binary; nil when unknown
+	modules ModuleSet
+	// sitesDir holds the releases of static sites
+	sitesDir string
+
+	// applyMu serializes applies; lastApplied is the config Caddy runs after the
+	// last successful apply, or nil when that is unknown
+	applyMu     sync.Mutex
+	lastApplied *Config
 }
 
 // NewManager creates a configuration manager.
@@ -54,14 +66,136 @@ func NewManager(client *Client, db *gorm.DB, configDir string, frontendDir strin
 	}
 }
 
+// SetOnDemandAskURL sets the endpoint Caddy asks before issuing on-demand certificates.
+// On-demand TLS stays disabled while this is empty.
+func (m *Manager) SetOnDemandAskURL(url string) {
+	m.onDemandAskURL = url
+}
+
+// SetSitesDir sets the directory static sites are deployed to. Static sites
+// stay out of the config while this is empty.
+func (m *Manager) SetSitesDir(dir string) {
+	m.sitesDir = dir
+}
+
+// SetModules records the modules compiled into the Caddy binary. Features whose
+// module is missing are left out of generated configs. Call before serving requests.
+func (m *Manager) SetModules(modules ModuleSet) {
+	m.modules = modules
+}
+
+// Modules returns the detected Caddy modules, or nil if they are unknown.
+func (m *Manager) Modules() ModuleSet {
+	return m.modules
+}
+
 // ApplyConfig generates configuration from database, validates it, applies to Caddy with rollback on failure.
 func (m *Manager) ApplyConfig(ctx context.Context) error {
-	// Fetch all proxy hosts from database
+	hosts, err := m.loadHosts()
+	if err != nil {
+		return err
+	}
+
+	config, err := m.generate(ctx, hosts, false)
+	if err != nil {
+		return err
+	}
+
+	// Log generated config size and a compact JSON snippet for debugging when in debug mode
+	if cfgJSON, jerr := jsonMarshalDebugFunc(config); jerr == nil {
+		logger.Log().WithField("config_json_len", len(cfgJSON)).Debug("generated Caddy config JSON")
+	} else {
+		logger.Log().WithError(jerr).Warn("failed to marshal generated config for debug logging")
+	}
+
+	// Validate before applying
+	if err := validateConfigFunc(config); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	// Save snapshot for rollback
+	snapshotPath, err := m.saveSnapshot(config)
+	if err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+
+	// Calculate config hash for audit trail
+	configJSON, _ := json.Marshal(config)
+	configHash := fmt.Sprintf("%x", sha256.Sum256(configJSON))
+
+	// Apply to Caddy
+	if err := m.load(ctx, config); err != nil {
+		// Remove the failed snapshot so rollback uses the previous one
+		_ = removeFileFunc(snapshotPath)
+
+		// Rollback on failure
+		if rollbackErr := m.rollback(ctx); rollbackErr != nil {
+			// If rollback fails, we still want to record the failure
+			m.recordConfigChange(configHash, false, err.Error())
+			return fmt.Errorf("apply failed: %w, rollback also failed: %v", err, rollbackErr)
+		}
+
+		// Record failed attempt
+		m.recordConfigChange(configHash, false, err.Error())
+		return fmt.Errorf("apply failed (rolled back): %w", err)
+	}
+
+	// Record successful application
+	m.recordConfigChange(configHash, true, "")
+
+	// Keep the applied config with its DB state so it can be browsed and restored
+	if _, err := m.recordSnapshot(ctx, config, ""); err != nil {
+		logger.Log().WithError(err).Warn("failed to record config snapshot")
+	}
+
+	// Cleanup old snapshots (keep last 10)
+	if err := m.rotateSnapshots(10); err != nil {
+		// Non-fatal - log but don't fail
+		logger.Log().WithError(err).Warn("warning: snapshot rotation failed")
+	}
+
+	return nil
+}
+
+// loadHosts fetches all proxy hosts with the associations GenerateConfig needs.
+func (m *Manager) loadHosts() ([]models.ProxyHost, error) {
 	var hosts []models.ProxyHost
-	if err := m.db.Preload("Locations").Preload("Certificate").Preload("AccessList").Find(&hosts).Error; err != nil {
-		return fmt.Errorf("fetch proxy hosts: %w", err)
+	if err := m.db.Preload("Locations").Preload("Certificate").Preload("AccessList").Preload("ClientCA").Find(&hosts).Error; err != nil {
+		return nil, fmt.Errorf("fetch proxy hosts: %w", err)
+	}
+	attachHostChildren(m.db, hosts)
+	return hosts, nil
+}
+
+// attachHostChildren loads the upstream groups and routing rules of hosts.
+// They are loaded apart from the hosts so that a failure only disables
+// traffic splitting or routing rules.
+func attachHostChildren(db *gorm.DB, hosts []models.ProxyHost) {
+	var groups []models.UpstreamGroup
+	if err := db.Order("id").Find(&groups).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load upstream groups")
 	}
+	var rules []models.RoutingRule
+	if err := db.Order("id").Find(&rules).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load routing rules")
+	}
+	groupsByHost := make(map[uint][]models.UpstreamGroup)
+	for _, g := range groups {
+		groupsByHost[g.ProxyHostID] = append(groupsByHost[g.ProxyHostID], g)
+	}
+	rulesByHost := make(map[uint][]models.RoutingRule)
+	for _, r := range rules {
+		rulesByHost[r.ProxyHostID] = append(rulesByHost[r.ProxyHostID], r)
+	}
+	for i := range hosts {
+		hosts[i].UpstreamGroups = groupsByHost[hosts[i].ID]
+		hosts[i].RoutingRules = rulesByHost[hosts[i].ID]
+	}
+}
 
+// generate builds the Caddy config for hosts from the current settings and security state.
+// A dry run computes ruleset file paths without writing or cleaning up files.
+func (m *Manager) generate(ctx context.Context, hosts []models.ProxyHost, dryRun bool) (*Config, error) {
 	// Fetch ACME email setting
 	var acmeEmailSetting models.Setting
 	var acmeEmail string
@@ -79,12 +213,39 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 	// Compute effective security flags (re-read runtime overrides)
 	_, aclEnabled, wafEnabled, rateLimitEnabled, crowdsecEnabled := m.computeEffectiveFlags(ctx)
 
+	// A handler the binary lacks would make Caddy reject the whole config
+	for _, gate := range []struct {
+		feature string
+		enabled *bool
+	}{
+		{FeatureWAF, &wafEnabled},
+		{FeatureCrowdSec, &crowdsecEnabled},
+		{FeatureRateLimit, &rateLimitEnabled},
+	} {
+		if missing := m.modules.MissingModule(gate.feature); missing != "" && *gate.enabled {
+			logger.Log().WithField("feature", gate.feature).WithField("module", missing).Warn("Caddy module missing; feature disabled")
+			*gate.enabled = false
+		}
+	}
+	if missing := m.modules.MissingModule(FeatureGeoIP); missing != "" && aclEnabled {
+		var dropped int
+		if hosts, dropped = withoutGeoACLs(hosts); dropped > 0 {
+			logger.Log().WithField("module", missing).WithField("hosts", dropped).Warn("Caddy module missing; geo-blocking access lists not applied")
+		}
+	}
+	// Advanced config naming a handler the binary lacks is left out for that
+	// host alone; the validator reports it as a missing_handler warning.
+	var skipped []string
+	if hosts, skipped = withoutMissingHandlers(hosts, m.modules); len(skipped) > 0 {
+		logger.Log().WithField("hosts", strings.Join(skipped, "; ")).Warn("Caddy module missing; advanced config of hosts not applied")
+	}
+
 	// Safety check: if Cerberus is enabled in DB and no admin whitelist configured,
 	// block applying changes to avoid accidental self-lockout.
 	var secCfg models.SecurityConfig
 	if err := m.db.Where("name = ?", "default").First(&secCfg).Error; err == nil {
 		if secCfg.Enabled && strings.TrimSpace(secCfg.AdminWhitelist) == "" {
-			return fmt.Errorf("refusing to apply config: Cerberus is enabled but admin_whitelist is empty; add an admin whitelist entry or generate a break-glass token")
+			return nil, fmt.Errorf("refusing to apply config: Cerberus is enabled but admin_whitelist is empty; add an admin whitelist entry or generate a break-glass token")
 		}
 	}
 
@@ -111,8 +272,10 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 	rulesetPaths := make(map[string]string)
 	if len(rulesets) > 0 {
 		corazaDir := filepath.Join(m.configDir, "coraza", "rulesets")
-		if err := os.MkdirAll(corazaDir, 0755); err != nil {
-			logger.Log().WithError(err).Warn("failed to create coraza rulesets dir")
+		if !dryRun {
+			if err := os.MkdirAll(corazaDir, 0755); err != nil {
+				logger.Log().WithError(err).Warn("failed to create coraza rulesets dir")
+			}
 		}
 		for _, rs := range rulesets {
 			// Sanitize name to a safe filename - prevent path traversal and special chars
@@ -154,6 +317,12 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 			shortHash := fmt.Sprintf("%x", hash)[:8]
 			filePath := filepath.Join(corazaDir, fmt.Sprintf("%s-%s.conf", safeName, shortHash))
 
+			// A dry run only needs the path, which is derived from the content
+			if dryRun {
+				rulesetPaths[rs.Name] = filePath
+				continue
+			}
+
 			// Write ruleset file with world-readable permissions so the Caddy
 			// process (which may run as an unprivileged user) can read it.
 			if err := writeFileFunc(filePath, []byte(content), 0644); err != nil {
@@ -166,37 +335,101 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 		}
 
 		// Cleanup stale ruleset files that are no longer in the database
-		if entries, err := readDirFunc(corazaDir); err == nil {
-			for _, entry := range entries {
-				if entry.IsDir() {
-					continue
-				}
-				fileName := entry.Name()
-				filePath := filepath.Join(corazaDir, fileName)
-				// Check if this file is in the current rulesetPaths
-				isActive := false
-				for _, activePath := range rulesetPaths {
-					if activePath == filePath {
-						isActive = true
-						break
+		if !dryRun {
+			if entries, err := readDirFunc(corazaDir); err == nil {
+				for _, entry := range entries {
+					if entry.IsDir() {
+						continue
 					}
-				}
-				if !isActive {
-					if err := removeFileFunc(filePath); err != nil {
-						logger.Log().WithError(err).WithField("path", filePath).Warn("failed to remove stale ruleset file")
-					} else {
-						logger.Log().WithField("path", filePath).Info("removed stale ruleset file")
+					fileName := entry.Name()
+					filePath := filepath.Join(corazaDir, fileName)
+					// Check if this file is in the current rulesetPaths
+					isActive := false
+					for _, activePath := range rulesetPaths {
+						if activePath == filePath {
+							isActive = true
+							break
+						}
+					}
+					if !isActive {
+						if err := removeFileFunc(filePath); err != nil {
+							logger.Log().WithError(err).WithField("path", filePath).Warn("failed to remove stale ruleset file")
+						} else {
+							logger.Log().WithField("path", filePath).Info("removed stale ruleset file")
+						}
 					}
 				}
+			} else {
+				logger.Log().WithError(err).Warn("failed to read coraza rulesets dir for cleanup")
 			}
-		} else {
-			logger.Log().WithError(err).Warn("failed to read coraza rulesets dir for cleanup")
 		}
 	}
 
 	config, err := generateConfigFunc(hosts, filepath.Join(m.configDir, "data"), acmeEmail, m.frontendDir, sslProvider, m.acmeStaging, crowdsecEnabled, wafEnabled, rateLimitEnabled, aclEnabled, adminWhitelist, rulesets, rulesetPaths, decisions, &secCfg)
 	if err != nil {
-		return fmt.Errorf("generate config: %w", err)
+		return nil, fmt.Errorf("generate config: %w", err)
+	}
+
+	var redirects []models.RedirectionHost
+	if err := m.db.Order("id").Find(&redirects).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load redirection hosts for generate config")
+	}
+	AddRedirectionRoutes(config, redirects)
+
+	if m.sitesDir != "" {
+		var sites []models.StaticSite
+		if err := m.db.Order("id").Find(&sites).Error; err != nil {
+			logger.Log().WithError(err).Warn("failed to load static sites for generate config")
+		}
+		AddStaticSites(config, sites, m.sitesDir)
+	}
+
+	var streams []models.Stream
+	if err := m.db.Order("id").Find(&streams).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load streams for generate config")
+	}
+	if missing := m.modules.MissingModule(FeatureStreams); missing != "" && len(streams) > 0 {
+		logger.Log().WithField("module", missing).WithField("streams", len(streams)).Warn("Caddy module missing; streams not applied")
+	} else {
+		AddStreams(config, streams)
+	}
+
+	var errorPages []models.ErrorPage
+	if err := m.db.Order("id").Find(&errorPages).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load error pages for generate config")
+	}
+	AddErrorPages(config, errorPages, hosts)
+
+	var windows []models.MaintenanceWindow
+	if err := m.db.Order("id").Find(&windows).Error; err != nil {
+		logger.Log().WithError(err).Warn("failed to load maintenance windows for generate config")
+	}
+	AddMaintenance(config, hosts, windows, time.Now())
+
+	// On-demand TLS for wildcard hosts and configured domain patterns
+	if m.onDemandAskURL != "" {
+		var onDemandSetting models.Setting
+		if err := m.db.Where("key = ?", OnDemandTLSEnabledSettingKey).First(&onDemandSetting).Error; err == nil && strings.EqualFold(onDemandSetting.Value, "true") {
+			var domainsSetting models.Setting
+			_ = m.db.Where("key = ?", OnDemandTLSDomainsSettingKey).First(&domainsSetting).Error
+			ConfigureOnDemandTLS(config, hosts, ParseOnDemandDomains(domainsSetting.Value), m.onDemandAskURL)
+		}
+	}
+
+	// PROXY protocol from a load balancer in front of Caddy. A bad trusted list
+	// must not be applied: the balancer's connections would all fail.
+	var proxyProtocolSetting models.Setting
+	if err := m.db.Where("key = ?", ProxyProtocolEnabledSettingKey).First(&proxyProtocolSetting).Error; err == nil && strings.EqualFold(proxyProtocolSetting.Value, "true") {
+		var trustedSetting models.Setting
+		_ = m.db.Where("key = ?", ProxyProtocolTrustedSettingKey).First(&trustedSetting).Error
+		trusted, err := ParseTrustedCIDRs(trustedSetting.Value)
+		if err != nil {
+			return nil, fmt.Errorf("refusing to apply config: %s: %w", ProxyProtocolTrustedSettingKey, err)
+		}
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("refusing to apply config: PROXY protocol is enabled but %s lists no trusted sources", ProxyProtocolTrustedSettingKey)
+		}
+		ConfigureProxyProtocol(config, trusted)
 	}
 
 	// Debug logging: WAF configuration state for troubleshooting integration issues
@@ -214,55 +447,7 @@ func (m *Manager) ApplyConfig(ctx context.Context) error {
 		}).Debug("WAF ruleset path mapping")
 	}
 
-	// Log generated config size and a compact JSON snippet for debugging when in debug mode
-	if cfgJSON, jerr := jsonMarshalDebugFunc(config); jerr == nil {
-		logger.Log().WithField("config_json_len", len(cfgJSON)).Debug("generated Caddy config JSON")
-	} else {
-		logger.Log().WithError(jerr).Warn("failed to marshal generated config for debug logging")
-	}
-
-	// Validate before applying
-	if err := validateConfigFunc(config); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
-	}
-
-	// Save snapshot for rollback
-	snapshotPath, err := m.saveSnapshot(config)
-	if err != nil {
-		return fmt.Errorf("save snapshot: %w", err)
-	}
-
-	// Calculate config hash for audit trail
-	configJSON, _ := json.Marshal(config)
-	configHash := fmt.Sprintf("%x", sha256.Sum256(configJSON))
-
-	// Apply to Caddy
-	if err := m.client.Load(ctx, config); err != nil {
-		// Remove the failed snapshot so rollback uses the previous one
-		_ = removeFileFunc(snapshotPath)
-
-		// Rollback on failure
-		if rollbackErr := m.rollback(ctx); rollbackErr != nil {
-			// If rollback fails, we still want to record the failure
-			m.recordConfigChange(configHash, false, err.Error())
-			return fmt.Errorf("apply failed: %w, rollback also failed: %v", err, rollbackErr)
-		}
-
-		// Record failed attempt
-		m.recordConfigChange(configHash, false, err.Error())
-		return fmt.Errorf("apply failed (rolled back): %w", err)
-	}
-
-	// Record successful application
-	m.recordConfigChange(configHash, true, "")
-
-	// Cleanup old snapshots (keep last 10)
-	if err := m.rotateSnapshots(10); err != nil {
-		// Non-fatal - log but don't fail
-		logger.Log().WithError(err).Warn("warning: snapshot rotation failed")
-	}
-
-	return nil
+	return config, nil
 }
 
 // saveSnapshot stores the config to disk with timestamp.
@@ -375,6 +560,23 @@ func (m *Manager) Ping(ctx context.Context) error {
 	return m.client.Ping(ctx)
 }
 
+// Reapply loads the last applied config into a Caddy that has restarted with
+// an empty one. When nothing was applied yet the config is generated from the
+// database.
+func (m *Manager) Reapply(ctx context.Context) error {
+	m.applyMu.Lock()
+	if config := m.lastApplied; config != nil {
+		defer m.applyMu.Unlock()
+		if err := m.client.Load(ctx, config); err != nil {
+			m.lastApplied = nil
+			return err
+		}
+		return nil
+	}
+	m.applyMu.Unlock()
+	return m.ApplyConfig(WithChangeInfo(ctx, ChangeInfo{Reason: "caddy restart"}))
+}
+
 // GetCurrentConfig retrieves the running config from Caddy.
 func (m *Manager) GetCurrentConfig(ctx context.Context) (*Config, error) {
 	return m.client.GetConfig(ctx)
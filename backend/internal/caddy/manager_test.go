@@ -194,11 +194,12 @@ func TestManager_RotateSnapshots(t *testing.T) {
 
 func TestManager_Rollback_Success(t *testing.T) {
 	// Mock Caddy Admin API
-	// First call succeeds (initial setup), second call fails (bad config), third call succeeds (rollback)
+	// First load succeeds (initial setup), second load fails (bad config), third load succeeds (rollback).
+	// Single-route updates get a 404, so the second apply falls back to a full load.
 	callCount := 0
 	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		callCount++
 		if r.URL.Path == "/load" && r.Method == "POST" {
+			callCount++
 			if callCount == 2 {
 				w.WriteHeader(http.StatusInternalServerError) // Fail the second apply
 				return
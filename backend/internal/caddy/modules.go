@@ -0,0 +1,151 @@
+package caddy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Caddy modules that Charon's optional features are built on. They come from
+// plugins, so a Caddy binary built without them cannot load configs using them.
+const (
+	ModuleWAF       = "http.handlers.waf"
+	ModuleCrowdSec  = "http.handlers.crowdsec"
+	ModuleRateLimit = "http.handlers.rate_limit"
+	ModuleGeoIP2    = "http.handlers.geoip2"
+	ModuleLayer4    = "layer4"
+)
+
+// Features gated on Caddy modules, named as in /security/status.
+const (
+	FeatureWAF       = "waf"
+	FeatureCrowdSec  = "crowdsec"
+	FeatureRateLimit = "rate_limit"
+	FeatureGeoIP     = "geoip"
+	FeatureStreams   = "streams"
+)
+
+var featureModules = map[string]string{
+	FeatureWAF:       ModuleWAF,
+	FeatureCrowdSec:  ModuleCrowdSec,
+	FeatureRateLimit: ModuleRateLimit,
+	FeatureGeoIP:     ModuleGeoIP2,
+	FeatureStreams:   ModuleLayer4,
+}
+
+// ModuleSet holds the IDs of the modules compiled into the Caddy binary.
+// A nil set means the modules could not be detected; every module is then
+// assumed to be present so behaviour matches a full build.
+type ModuleSet map[string]bool
+
+// Has reports whether the module with the given ID is available.
+func (s ModuleSet) Has(id string) bool {
+	return s == nil || s[id]
+}
+
+// MissingModule returns the module the feature needs but the binary lacks, or "".
+func (s ModuleSet) MissingModule(feature string) string {
+	if id, ok := featureModules[feature]; ok && !s.Has(id) {
+		return id
+	}
+	return ""
+}
+
+// ParseModuleList reads the output of `caddy list-modules`: one module ID per
+// line, in groups followed by indented summary lines.
+func ParseModuleList(output []byte) ModuleSet {
+	modules := make(ModuleSet)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			modules[fields[0]] = true
+		}
+	}
+	return modules
+}
+
+// DetectModules lists the modules of the Caddy binary by running `caddy list-modules`.
+func DetectModules(executor Executor, binary string) (ModuleSet, error) {
+	output, err := executor.Execute(binary, "list-modules")
+	if err != nil {
+		return nil, fmt.Errorf("caddy list-modules: %w", err)
+	}
+	modules := ParseModuleList(output)
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("caddy list-modules returned no modules")
+	}
+	return modules, nil
+}
+
+// withoutGeoACLs returns a copy of hosts with geo-blocking access lists
+// detached, for binaries without the geoip2 module.
+func withoutGeoACLs(hosts []models.ProxyHost) ([]models.ProxyHost, int) {
+	out := make([]models.ProxyHost, len(hosts))
+	copy(out, hosts)
+	dropped := 0
+	for i := range out {
+		if out[i].AccessList != nil && strings.HasPrefix(out[i].AccessList.Type, "geo_") {
+			out[i].AccessList = nil
+			dropped++
+		}
+	}
+	return out, dropped
+}
+
+// withoutMissingHandlers returns a copy of hosts in which enabled hosts whose
+// advanced config names a handler the binary lacks have no advanced config,
+// and the domains of those hosts.
+func withoutMissingHandlers(hosts []models.ProxyHost, modules ModuleSet) ([]models.ProxyHost, []string) {
+	out := make([]models.ProxyHost, len(hosts))
+	copy(out, hosts)
+	var skipped []string
+	for i := range out {
+		if out[i].Enabled && len(MissingHandlers(&out[i], modules)) > 0 {
+			out[i].AdvancedConfig = ""
+			skipped = append(skipped, out[i].DomainNames)
+		}
+	}
+	return out, skipped
+}
+
+// MissingHandlers returns the handlers named in the host's advanced config,
+// including handlers nested in subroutes, whose module the binary lacks, sorted
+// and each listed once.
+func MissingHandlers(host *models.ProxyHost, modules ModuleSet) []string {
+	if host.AdvancedConfig == "" || modules == nil {
+		return nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(host.AdvancedConfig), &parsed); err != nil {
+		return nil // GenerateConfig skips advanced config that does not parse
+	}
+	var missing []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if name, ok := t["handler"].(string); ok && name != "" && !modules.Has("http.handlers."+name) && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			for _, child := range t {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(parsed)
+	slices.Sort(missing)
+	return missing
+}
@@ -0,0 +1,128 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+const listModulesOutput = `admin.api.load
+http.handlers.reverse_proxy
+http.handlers.subroute
+http.handlers.static_response
+http.handlers.headers
+
+  Standard modules: 4
+
+http.handlers.crowdsec
+crowdsec
+
+  Non-standard modules: 2
+
+  Unknown modules: 0
+`
+
+func TestDetectModules(t *testing.T) {
+	modules, err := DetectModules(&MockExecutor{Output: []byte(listModulesOutput)}, "caddy")
+	require.NoError(t, err)
+	assert.Len(t, modules, 7)
+	assert.True(t, modules.Has(ModuleCrowdSec))
+	assert.False(t, modules.Has(ModuleWAF))
+	assert.Equal(t, ModuleWAF, modules.MissingModule(FeatureWAF))
+	assert.Empty(t, modules.MissingModule(FeatureCrowdSec))
+	assert.Empty(t, modules.MissingModule("unknown"))
+
+	_, err = DetectModules(&MockExecutor{Err: errors.New("exec: not found")}, "caddy")
+	assert.ErrorContains(t, err, "caddy list-modules")
+	_, err = DetectModules(&MockExecutor{Output: []byte("\n  Standard modules: 0\n")}, "caddy")
+	assert.Error(t, err)
+
+	// Unknown modules mean a full build
+	var unknown ModuleSet
+	assert.True(t, unknown.Has(ModuleWAF))
+	assert.Empty(t, unknown.MissingModule(FeatureGeoIP))
+}
+
+func TestMissingHandlers(t *testing.T) {
+	host := &models.ProxyHost{AdvancedConfig: `[
+		{"handler":"crowdsec"},
+		{"handler":"subroute","routes":[{"handle":[{"handler":"waf"},{"handler":"headers"}]},{"match":[{"path":["/x"]}]}]},
+		{"handler":"rate_limit"},
+		{"handler":"waf"}
+	]`}
+	modules := ParseModuleList([]byte(listModulesOutput))
+	assert.Equal(t, []string{"rate_limit", "waf"}, MissingHandlers(host, modules))
+
+	assert.Nil(t, MissingHandlers(host, nil))
+	assert.Nil(t, MissingHandlers(&models.ProxyHost{AdvancedConfig: "{"}, modules))
+}
+
+func TestManager_Generate_GatesFeaturesOnModules(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.SSLCertificate{}, &models.AccessList{}, &models.SecurityConfig{}, &models.SecurityRuleSet{}, &models.SecurityDecision{}, &models.ClientCA{}))
+
+	acl := models.AccessList{UUID: "geo", Name: "EU only", Type: "geo_whitelist", CountryCodes: "DE,FR", Enabled: true}
+	require.NoError(t, db.Create(&acl).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "h", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true, AccessListID: &acl.ID}).Error)
+	require.NoError(t, db.Create(&models.SecurityConfig{Name: "default", Enabled: true, AdminWhitelist: "10.0.0.1/32", WAFMode: "block"}).Error)
+	require.NoError(t, db.Create(&models.SecurityRuleSet{Name: "owasp-crs", Content: "rules"}).Error)
+
+	var gotWAF, gotCrowdSec, gotRateLimit bool
+	var gotACL *models.AccessList
+	orig := generateConfigFunc
+	generateConfigFunc = func(hosts []models.ProxyHost, storageDir string, acmeEmail string, frontendDir string, sslProvider string, acmeStaging bool, crowdsecEnabled bool, wafEnabled bool, rateLimitEnabled bool, aclEnabled bool, adminWhitelist string, rulesets []models.SecurityRuleSet, rulesetPaths map[string]string, decisions []models.SecurityDecision, secCfg *models.SecurityConfig) (*Config, error) {
+		gotWAF, gotCrowdSec, gotRateLimit = wafEnabled, crowdsecEnabled, rateLimitEnabled
+		gotACL = hosts[0].AccessList
+		return orig(hosts, storageDir, acmeEmail, frontendDir, sslProvider, acmeStaging, crowdsecEnabled, wafEnabled, rateLimitEnabled, aclEnabled, adminWhitelist, rulesets, rulesetPaths, decisions, secCfg)
+	}
+	defer func() { generateConfigFunc = orig }()
+
+	secCfg := config.SecurityConfig{CerberusEnabled: true, WAFMode: "block", CrowdSecMode: "local", RateLimitMode: "enabled", ACLMode: "enabled"}
+	manager := NewManager(nil, db, t.TempDir(), "", false, secCfg)
+
+	// Modules unknown: everything is generated as before
+	hosts, err := manager.loadHosts()
+	require.NoError(t, err)
+	_, err = manager.generate(context.Background(), hosts, true)
+	require.NoError(t, err)
+	assert.True(t, gotWAF)
+	assert.True(t, gotCrowdSec)
+	assert.True(t, gotRateLimit)
+	require.NotNil(t, gotACL)
+
+	manager.SetModules(ParseModuleList([]byte(listModulesOutput)))
+	cfg, err := manager.generate(context.Background(), hosts, true)
+	require.NoError(t, err)
+	assert.False(t, gotWAF)
+	assert.True(t, gotCrowdSec)
+	assert.False(t, gotRateLimit)
+	assert.Nil(t, gotACL, "geo access list needs geoip2")
+	assert.NotNil(t, hosts[0].AccessList, "the caller's hosts are not modified")
+
+	out, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), `"handler":"waf"`)
+	assert.NotContains(t, string(out), "geoip2")
+
+	// Advanced config naming a missing handler is left out for its host only
+	hosts[0].AdvancedConfig = `{"handler":"authentication","providers":{"http_basic":{"accounts":[]}}}`
+	cfg, err = manager.generate(context.Background(), hosts, true)
+	require.NoError(t, err)
+	out, err = json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), `"handler":"authentication"`)
+	assert.Contains(t, string(out), "app.example.com")
+	assert.NotEmpty(t, hosts[0].AdvancedConfig, "the caller's hosts are not modified")
+}
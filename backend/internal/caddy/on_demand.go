@@ -0,0 +1,155 @@
+package caddy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Settings that control on-demand TLS.
+const (
+	OnDemandTLSEnabledSettingKey   = "caddy.on_demand_tls.enabled"
+	OnDemandTLSDomainsSettingKey   = "caddy.on_demand_tls.domains"
+	OnDemandTLSRateLimitSettingKey = "caddy.on_demand_tls.rate_limit"
+)
+
+// DefaultOnDemandTLSRateLimit is the number of certificates approved per hour when no limit is configured.
+const DefaultOnDemandTLSRateLimit = 10
+
+// onDemandAskSecretFile holds the per-install secret that Caddy sends to the
+// permission endpoint, in the Caddy config directory.
+const onDemandAskSecretFile = "on_demand_ask.secret"
+
+// OnDemandAskSecretEnv is the environment variable an exported Caddyfile reads
+// the permission endpoint secret from.
+const OnDemandAskSecretEnv = "CHARON_ON_DEMAND_ASK_SECRET"
+
+// LoadOnDemandAskSecret returns the secret for the on-demand TLS permission
+// endpoint, creating it on first use. The endpoint only answers callers that
+// know it, so a request relayed through a proxied Charon UI can't use it.
+func LoadOnDemandAskSecret(configDir string) (string, error) {
+	path := filepath.Join(configDir, onDemandAskSecretFile)
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("read on-demand ask secret: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate on-demand ask secret: %w", err)
+	}
+	secret := hex.EncodeToString(buf)
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		return "", fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write on-demand ask secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ParseOnDemandDomains splits a comma or newline separated list of domain patterns.
+// Patterns are lower-cased; empty entries are dropped.
+func ParseOnDemandDomains(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+	patterns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(f), "."))
+		if f != "" {
+			patterns = append(patterns, f)
+		}
+	}
+	return patterns
+}
+
+// MatchDomainPattern reports whether domain matches pattern. A leading "*." matches
+// exactly one label, the same way Caddy matches wildcard hosts and certificates.
+func MatchDomainPattern(pattern, domain string) bool {
+	pattern = strings.ToLower(pattern)
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if pattern == domain {
+		return true
+	}
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	label, rest, found := strings.Cut(domain, ".")
+	return found && label != "" && rest == pattern[2:]
+}
+
+// ConfigureOnDemandTLS adds an on-demand automation policy to a generated config.
+// The policy covers the configured patterns plus every wildcard proxy host, so those
+// names get a certificate on first handshake instead of needing a DNS-01 wildcard.
+// Caddy asks askURL (GET ?domain=) for permission before each issuance.
+func ConfigureOnDemandTLS(config *Config, hosts []models.ProxyHost, patterns []string, askURL string) {
+	if config == nil || askURL == "" {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		seen[p] = true
+	}
+	for _, host := range hosts {
+		if !host.Enabled {
+			continue
+		}
+		for _, d := range strings.Split(host.DomainNames, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if strings.HasPrefix(d, "*.") {
+				seen[d] = true
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return
+	}
+	subjects := make([]string, 0, len(seen))
+	for s := range seen {
+		subjects = append(subjects, s)
+	}
+	sort.Strings(subjects)
+
+	if config.Apps.TLS == nil {
+		config.Apps.TLS = &TLSApp{}
+	}
+	if config.Apps.TLS.Automation == nil {
+		config.Apps.TLS.Automation = &AutomationConfig{}
+	}
+	automation := config.Apps.TLS.Automation
+
+	// Reuse the issuers of the catch-all policy so on-demand certs come from the same CA
+	var issuers []interface{}
+	for _, p := range automation.Policies {
+		if len(p.Subjects) == 0 {
+			issuers = p.IssuersRaw
+			break
+		}
+	}
+
+	// Policies with subjects must precede the catch-all policy
+	automation.Policies = append([]*AutomationPolicy{{
+		Subjects:   subjects,
+		IssuersRaw: issuers,
+		OnDemand:   true,
+	}}, automation.Policies...)
+	automation.OnDemand = &OnDemandConfig{
+		Permission: map[string]interface{}{
+			"module":   "http",
+			"endpoint": askURL,
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package caddy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestParseOnDemandDomains(t *testing.T) {
+	assert.Equal(t, []string{"*.a.example.com", "b.example.com", "c.example.com"}, ParseOnDemandDomains(" *.A.example.com,\nb.example.com.\n\n c.example.com "))
+	assert.Empty(t, ParseOnDemandDomains(""))
+}
+
+func TestMatchDomainPattern(t *testing.T) {
+	assert.True(t, MatchDomainPattern("example.com", "EXAMPLE.com."))
+	assert.True(t, MatchDomainPattern("*.example.com", "a.example.com"))
+	assert.False(t, MatchDomainPattern("*.example.com", "example.com"))
+	assert.False(t, MatchDomainPattern("*.example.com", "a.b.example.com"))
+	assert.False(t, MatchDomainPattern("*.example.com", "aexample.com"))
+	assert.False(t, MatchDomainPattern("example.com", "a.example.com"))
+}
+
+func TestConfigureOnDemandTLS(t *testing.T) {
+	hosts := []models.ProxyHost{
+		{UUID: "1", DomainNames: "*.tenants.example.com, app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+		{UUID: "2", DomainNames: "*.disabled.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: false},
+	}
+	config, err := GenerateConfig(hosts, "/tmp/caddy/data", "admin@example.com", "", "letsencrypt", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	ConfigureOnDemandTLS(config, hosts, []string{"*.customers.example.com"}, "http://127.0.0.1:8080/api/v1/tls/ask")
+
+	automation := config.Apps.TLS.Automation
+	require.Len(t, automation.Policies, 2)
+	onDemand := automation.Policies[0]
+	assert.True(t, onDemand.OnDemand)
+	assert.Equal(t, []string{"*.customers.example.com", "*.tenants.example.com"}, onDemand.Subjects)
+	assert.Equal(t, automation.Policies[1].IssuersRaw, onDemand.IssuersRaw)
+	assert.Empty(t, automation.Policies[1].Subjects, "catch-all policy stays last")
+
+	require.NotNil(t, automation.OnDemand)
+	assert.Equal(t, "http", automation.OnDemand.Permission["module"])
+	assert.Equal(t, "http://127.0.0.1:8080/api/v1/tls/ask", automation.OnDemand.Permission["endpoint"])
+
+	b, err := json.Marshal(config.Apps.TLS)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"on_demand":true`)
+	assert.Contains(t, string(b), `"permission":{"endpoint":"http://127.0.0.1:8080/api/v1/tls/ask","module":"http"}`)
+}
+
+func TestConfigureOnDemandTLS_NothingToCover(t *testing.T) {
+	config, err := GenerateConfig(nil, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	ConfigureOnDemandTLS(config, nil, nil, "http://127.0.0.1:8080/api/v1/tls/ask")
+	assert.Nil(t, config.Apps.TLS)
+
+	// Without an ask URL on-demand TLS is never enabled
+	ConfigureOnDemandTLS(config, nil, []string{"*.example.com"}, "")
+	assert.Nil(t, config.Apps.TLS)
+
+	ConfigureOnDemandTLS(config, nil, []string{"*.example.com"}, "http://127.0.0.1:8080/api/v1/tls/ask")
+	require.NotNil(t, config.Apps.TLS)
+	assert.Nil(t, config.Apps.TLS.Automation.Policies[0].IssuersRaw)
+}
+
+func TestLoadOnDemandAskSecret(t *testing.T) {
+	dir := t.TempDir()
+	secret, err := LoadOnDemandAskSecret(dir)
+	require.NoError(t, err)
+	assert.Len(t, secret, 64)
+
+	again, err := LoadOnDemandAskSecret(dir)
+	require.NoError(t, err)
+	assert.Equal(t, secret, again, "the secret is kept across restarts")
+
+	info, err := os.Stat(filepath.Join(dir, onDemandAskSecretFile))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
@@ -0,0 +1,224 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// compressionEncodings are the encodings the encode handler is built with.
+var compressionEncodings = map[string]bool{"gzip": true, "zstd": true}
+
+// maxUpstreamTimeout bounds the upstream timeouts and keep-alive of a host.
+const maxUpstreamTimeout = 24 * 60 * 60
+
+// CacheRule sets Cache-Control on responses to requests matching Paths.
+type CacheRule struct {
+	Paths []string
+	Value string
+}
+
+// ParseCacheRules parses a host's cache rules: one rule per line, a
+// comma-separated list of path patterns followed by the Cache-Control value,
+// e.g. "/assets/*,*.woff2 public, max-age=31536000, immutable". Blank lines
+// and lines starting with # are ignored.
+func ParseCacheRules(raw string) ([]CacheRule, error) {
+	var rules []CacheRule
+	for i, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("cache rule on line %d needs paths and a Cache-Control value", i+1)
+		}
+		paths := splitList(fields[0])
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("cache rule on line %d has no paths", i+1)
+		}
+		for _, p := range paths {
+			if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "*") {
+				return nil, fmt.Errorf("cache rule on line %d: path %q must start with / or *", i+1, p)
+			}
+		}
+		rules = append(rules, CacheRule{Paths: paths, Value: strings.Join(fields[1:], " ")})
+	}
+	return rules, nil
+}
+
+// ValidatePerformance checks the compression, upstream and cache settings of a proxy host.
+func ValidatePerformance(host *models.ProxyHost) error {
+	for _, e := range splitList(host.Compression) {
+		if !compressionEncodings[e] {
+			return fmt.Errorf("unsupported compression %q: must be gzip or zstd", e)
+		}
+	}
+	if host.CompressionTypes != "" && len(splitList(host.Compression)) == 0 {
+		return fmt.Errorf("compression_types needs compression")
+	}
+	for _, t := range splitList(host.CompressionTypes) {
+		if !strings.Contains(t, "/") {
+			return fmt.Errorf("compression type %q must be a content type such as text/html or text/*", t)
+		}
+	}
+
+	timeouts := []struct {
+		name  string
+		value int
+	}{
+		{"upstream_connect_timeout", host.UpstreamConnectTimeout},
+		{"upstream_read_timeout", host.UpstreamReadTimeout},
+		{"upstream_write_timeout", host.UpstreamWriteTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value < 0 || t.value > maxUpstreamTimeout {
+			return fmt.Errorf("%s must be between 0 and %d seconds", t.name, maxUpstreamTimeout)
+		}
+	}
+	if host.UpstreamKeepalive < -1 || host.UpstreamKeepalive > maxUpstreamTimeout {
+		return fmt.Errorf("upstream_keepalive must be -1 (disabled) or between 0 and %d seconds", maxUpstreamTimeout)
+	}
+	if err := ValidateUpstreamProtocol(host.UpstreamProtocol); err != nil {
+		return err
+	}
+	if err := ValidateUpstreamProxyProtocol(host.UpstreamProxyProtocol); err != nil {
+		return err
+	}
+	for _, loc := range host.Locations {
+		if err := ValidateUpstreamProtocol(loc.UpstreamProtocol); err != nil {
+			return fmt.Errorf("location %s: %w", loc.Path, err)
+		}
+	}
+	if host.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size cannot be negative")
+	}
+
+	_, err := ParseCacheRules(host.CacheRules)
+	return err
+}
+
+// compressionTypeMatches returns the Content-Type header matchers for the
+// compressed types. Each ends in * so "text/html" also matches responses sent
+// as "text/html; charset=utf-8".
+func compressionTypeMatches(types string) []string {
+	matches := splitList(types)
+	for i, t := range matches {
+		if !strings.HasSuffix(t, "*") {
+			matches[i] = t + "*"
+		}
+	}
+	return matches
+}
+
+// buildPerformanceHandlers returns the handlers that run before a host's
+// reverse proxy: compression, the request body limit and Cache-Control rules.
+func buildPerformanceHandlers(host *models.ProxyHost) ([]Handler, error) {
+	if err := ValidatePerformance(host); err != nil {
+		return nil, err
+	}
+	var handlers []Handler
+
+	if encodings := splitList(host.Compression); len(encodings) > 0 {
+		enc := make(map[string]interface{}, len(encodings))
+		for _, e := range encodings {
+			enc[e] = map[string]interface{}{}
+		}
+		h := Handler{"handler": "encode", "encodings": enc, "prefer": encodings}
+		if types := compressionTypeMatches(host.CompressionTypes); len(types) > 0 {
+			h["match"] = map[string]interface{}{
+				"headers": map[string][]string{"Content-Type": types},
+			}
+		}
+		handlers = append(handlers, h)
+	}
+
+	if host.MaxBodySize > 0 {
+		handlers = append(handlers, Handler{
+			"handler":  "request_body",
+			"max_size": int64(host.MaxBodySize) << 20,
+		})
+	}
+
+	rules, _ := ParseCacheRules(host.CacheRules)
+	if len(rules) > 0 {
+		// Deferred headers are set when the upstream writes its response, so
+		// they replace its Cache-Control. The outermost wrapper writes last,
+		// which makes the first matching rule win.
+		routes := make([]map[string]interface{}, 0, len(rules))
+		for _, r := range rules {
+			routes = append(routes, map[string]interface{}{
+				"match": []map[string]interface{}{{"path": r.Paths}},
+				"handle": []Handler{{
+					"handler": "headers",
+					"response": map[string]interface{}{
+						"set":      map[string][]string{"Cache-Control": {r.Value}},
+						"deferred": true,
+					},
+				}},
+			})
+		}
+		handlers = append(handlers, Handler{"handler": "subroute", "routes": routes})
+	}
+	return handlers, nil
+}
+
+// applyUpstreamSettings sets a host's timeouts, keep-alive, buffering and
+// PROXY protocol and the HTTP versions of protocol on its reverse_proxy handler.
+//
+// HTTP/2-only upstreams are treated as gRPC: responses are never buffered, as
+// that would stall streaming calls, and the WebSocket upgrade headers are left
+// out since HTTP/2 forbids them. Caddy passes response trailers such as
+// grpc-status through on its own.
+func applyUpstreamSettings(proxy Handler, host *models.ProxyHost, protocol string) Handler {
+	if host.Buffering && !IsHTTP2Only(protocol) {
+		delete(proxy, "flush_interval")
+	}
+	if IsHTTP2Only(protocol) {
+		if headers, ok := proxy["headers"].(map[string]interface{}); ok {
+			if req, ok := headers["request"].(map[string]interface{}); ok {
+				if set, ok := req["set"].(map[string][]string); ok {
+					delete(set, "Upgrade")
+					delete(set, "Connection")
+					if len(set) == 0 {
+						delete(proxy, "headers")
+					}
+				}
+			}
+		}
+	}
+
+	transport := map[string]interface{}{}
+	seconds := func(n int) string { return (time.Duration(n) * time.Second).String() }
+	if host.UpstreamConnectTimeout > 0 {
+		transport["dial_timeout"] = seconds(host.UpstreamConnectTimeout)
+	}
+	if host.UpstreamReadTimeout > 0 {
+		transport["read_timeout"] = seconds(host.UpstreamReadTimeout)
+	}
+	if host.UpstreamWriteTimeout > 0 {
+		transport["write_timeout"] = seconds(host.UpstreamWriteTimeout)
+	}
+	switch {
+	case host.UpstreamKeepalive < 0:
+		transport["keep_alive"] = map[string]interface{}{"enabled": false}
+	case host.UpstreamKeepalive > 0:
+		transport["keep_alive"] = map[string]interface{}{"idle_timeout": seconds(host.UpstreamKeepalive)}
+	}
+	if versions := transportVersions(protocol); versions != nil {
+		transport["versions"] = versions
+	}
+	if protocol == UpstreamProtocolH2 {
+		transport["tls"] = map[string]interface{}{}
+	}
+	if host.UpstreamProxyProtocol != "" {
+		transport["proxy_protocol"] = host.UpstreamProxyProtocol
+	}
+	if len(transport) > 0 {
+		transport["protocol"] = "http"
+		proxy["transport"] = transport
+	}
+	return proxy
+}
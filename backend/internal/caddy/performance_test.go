@@ -0,0 +1,113 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestParseCacheRules(t *testing.T) {
+	rules, err := ParseCacheRules("# static files\n/assets/*,*.woff2  public, max-age=31536000, immutable\n\n*.html no-cache\n")
+	require.NoError(t, err)
+	assert.Equal(t, []CacheRule{
+		{Paths: []string{"/assets/*", "*.woff2"}, Value: "public, max-age=31536000, immutable"},
+		{Paths: []string{"*.html"}, Value: "no-cache"},
+	}, rules)
+
+	_, err = ParseCacheRules("/assets/*")
+	assert.ErrorContains(t, err, "line 1 needs paths and a Cache-Control value")
+	_, err = ParseCacheRules("\nassets/* no-store")
+	assert.ErrorContains(t, err, `line 2: path "assets/*" must start with / or *`)
+}
+
+func TestValidatePerformance(t *testing.T) {
+	assert.NoError(t, ValidatePerformance(&models.ProxyHost{
+		Compression: "zstd, gzip", CompressionTypes: "text/*, application/json",
+		UpstreamConnectTimeout: 5, UpstreamReadTimeout: 600, UpstreamKeepalive: -1, MaxBodySize: 10240,
+	}))
+
+	tests := []struct {
+		host models.ProxyHost
+		err  string
+	}{
+		{models.ProxyHost{Compression: "br"}, `unsupported compression "br"`},
+		{models.ProxyHost{CompressionTypes: "text/*"}, "compression_types needs compression"},
+		{models.ProxyHost{Compression: "gzip", CompressionTypes: "html"}, "must be a content type"},
+		{models.ProxyHost{UpstreamReadTimeout: -1}, "upstream_read_timeout must be between 0 and"},
+		{models.ProxyHost{UpstreamWriteTimeout: 90000}, "upstream_write_timeout"},
+		{models.ProxyHost{UpstreamKeepalive: -2}, "upstream_keepalive"},
+		{models.ProxyHost{MaxBodySize: -1}, "max_body_size"},
+		{models.ProxyHost{CacheRules: "static no-store"}, "must start with / or *"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, ValidatePerformance(&tt.host), tt.err)
+	}
+}
+
+func TestGenerateConfig_Performance(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID: "cloud", DomainNames: "cloud.example.com", ForwardHost: "nextcloud", ForwardPort: 80, Enabled: true,
+		Compression: "zstd,gzip", CompressionTypes: "text/*, application/json", MaxBodySize: 16,
+		UpstreamConnectTimeout: 5, UpstreamReadTimeout: 3600, UpstreamKeepalive: 90, Buffering: true,
+		CacheRules: "/static/*,*.css public, max-age=86400",
+		Locations:  []models.Location{{UUID: "dav", Path: "/remote.php", ForwardHost: "nextcloud", ForwardPort: 80}},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 2)
+	for _, route := range routes {
+		handle := route.Handle
+		require.Len(t, handle, 4, route.ID)
+		out, err := json.Marshal(handle)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"handler": "encode", "encodings": {"zstd": {}, "gzip": {}}, "prefer": ["zstd", "gzip"],
+			 "match": {"headers": {"Content-Type": ["text/*", "application/json*"]}}},
+			{"handler": "request_body", "max_size": 16777216},
+			{"handler": "subroute", "routes": [{
+				"match": [{"path": ["/static/*", "*.css"]}],
+				"handle": [{"handler": "headers", "response": {"set": {"Cache-Control": ["public, max-age=86400"]}, "deferred": true}}]
+			}]},
+			{"handler": "reverse_proxy", "upstreams": [{"dial": "nextcloud:80"}], "transport": {
+				"protocol": "http", "dial_timeout": "5s", "read_timeout": "1h0m0s", "keep_alive": {"idle_timeout": "1m30s"}
+			}}
+		]`, string(out))
+	}
+
+	// Defaults leave the proxy streaming with Caddy's transport
+	cfg, err = GenerateConfig([]models.ProxyHost{{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, UpstreamKeepalive: -1}},
+		"/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	proxy := cfg.Apps.HTTP.Servers["charon_server"].Routes[0].Handle[0]
+	assert.Equal(t, -1, proxy["flush_interval"])
+	assert.Equal(t, map[string]interface{}{"protocol": "http", "keep_alive": map[string]interface{}{"enabled": false}}, proxy["transport"])
+
+	// Invalid settings fail generation like an invalid TLS policy
+	_, err = GenerateConfig([]models.ProxyHost{{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true, Compression: "br"}},
+		"/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	assert.ErrorContains(t, err, "performance settings for host b")
+}
+
+func TestExportCaddyfile_Performance(t *testing.T) {
+	out := ExportCaddyfile([]models.ProxyHost{{
+		ID: 1, UUID: "media", DomainNames: "media.example.com", ForwardHost: "jellyfin", ForwardPort: 8096, Enabled: true,
+		Compression: "gzip", MaxBodySize: 100, UpstreamReadTimeout: 600, UpstreamKeepalive: -1, Buffering: true,
+		CacheRules: "/web/* public, max-age=3600",
+	}}, CaddyfileOptions{})
+
+	for _, want := range []string{
+		"\tencode gzip\n",
+		"\trequest_body {\n\t\tmax_size 100MB\n\t}\n",
+		"\t@cache1 path /web/*\n\theader @cache1 >Cache-Control \"public, max-age=3600\"\n",
+		"\treverse_proxy jellyfin:8096 {\n\t\ttransport http {\n\t\t\tread_timeout 10m0s\n\t\t\tkeepalive off\n\t\t}\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+	assert.NotContains(t, out, "flush_interval")
+}
@@ -0,0 +1,396 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Diff actions.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// ConfigPreview is the result of a dry run: the config that would be loaded
+// and how it differs from the config Caddy is running.
+type ConfigPreview struct {
+	Config *Config     `json:"config"`
+	Diff   *ConfigDiff `json:"diff"`
+	// ValidationError is set when the config would be rejected before reaching Caddy
+	ValidationError string `json:"validation_error,omitempty"`
+	// CurrentError is set when the running config could not be fetched; the diff is then against an empty config
+	CurrentError string `json:"current_error,omitempty"`
+}
+
+// ConfigDiff summarises the differences between two configs.
+type ConfigDiff struct {
+	Changed bool          `json:"changed"`
+	Routes  []RouteChange `json:"routes"`
+	TLS     []TLSChange   `json:"tls"`
+	// Other lists top-level sections (logging, storage, servers) that changed outside routes and TLS
+	Other []string `json:"other"`
+}
+
+// RouteChange describes one route that was added, removed or changed.
+// Routes are identified by server and matcher, so a route whose handlers
+// change is reported as changed rather than removed and added.
+type RouteChange struct {
+	Action string   `json:"action"`
+	Server string   `json:"server"`
+	Key    string   `json:"key"`
+	Hosts  []string `json:"hosts,omitempty"`
+	Paths  []string `json:"paths,omitempty"`
+	Before *Route   `json:"before,omitempty"`
+	After  *Route   `json:"after,omitempty"`
+}
+
+// TLSChange describes a change to a TLS connection policy, automation policy or loaded certificate.
+type TLSChange struct {
+	Action string      `json:"action"`
+	Kind   string      `json:"kind"` // connection_policy, automation_policy, on_demand, certificate
+	Key    string      `json:"key"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Preview generates the config from the current database state without applying it.
+// If proposed is set, it is previewed as if saved: it replaces the host with the
+// same UUID, or is added as a new host.
+func (m *Manager) Preview(ctx context.Context, proposed *models.ProxyHost) (*ConfigPreview, error) {
+	hosts, err := m.loadHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	if proposed != nil {
+		if err := m.loadAssociations(proposed); err != nil {
+			return nil, err
+		}
+		replaced := false
+		for i := range hosts {
+			if proposed.UUID != "" && hosts[i].UUID == proposed.UUID {
+				// Upstream groups and routing rules are saved separately and stay with the host
+				replacement := *proposed
+				replacement.UpstreamGroups, replacement.RoutingRules = hosts[i].UpstreamGroups, hosts[i].RoutingRules
+				hosts[i] = replacement
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			// New hosts sort last, i.e. newest
+			hosts = append(hosts, *proposed)
+		}
+	}
+
+	config, err := m.generate(ctx, hosts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ConfigPreview{Config: config}
+	if err := validateConfigFunc(config); err != nil {
+		preview.ValidationError = err.Error()
+	}
+
+	current, err := m.client.GetConfig(ctx)
+	if err != nil {
+		preview.CurrentError = err.Error()
+		current = nil
+	}
+	preview.Diff = DiffConfigs(current, config)
+	return preview, nil
+}
+
+// loadAssociations resolves the foreign keys of an unsaved host the way loadHosts preloads them.
+func (m *Manager) loadAssociations(host *models.ProxyHost) error {
+	host.Certificate, host.AccessList, host.ClientCA = nil, nil, nil
+	if host.CertificateID != nil {
+		var cert models.SSLCertificate
+		if err := m.db.First(&cert, *host.CertificateID).Error; err != nil {
+			return fmt.Errorf("certificate %d: %w", *host.CertificateID, err)
+		}
+		host.Certificate = &cert
+	}
+	if host.AccessListID != nil {
+		var acl models.AccessList
+		if err := m.db.First(&acl, *host.AccessListID).Error; err != nil {
+			return fmt.Errorf("access list %d: %w", *host.AccessListID, err)
+		}
+		host.AccessList = &acl
+	}
+	if host.ClientCAID != nil {
+		var ca models.ClientCA
+		if err := m.db.First(&ca, *host.ClientCAID).Error; err != nil {
+			return fmt.Errorf("client CA %d: %w", *host.ClientCAID, err)
+		}
+		host.ClientCA = &ca
+	}
+	return nil
+}
+
+// DiffConfigs compares two configs. A nil config is treated as empty.
+func DiffConfigs(before, after *Config) *ConfigDiff {
+	if before == nil {
+		before = &Config{}
+	}
+	if after == nil {
+		after = &Config{}
+	}
+	diff := &ConfigDiff{Routes: []RouteChange{}, TLS: []TLSChange{}, Other: []string{}}
+
+	diffRoutes(diff, before, after)
+	diffTLS(diff, before, after)
+
+	if !jsonEqual(before.Logging, after.Logging) {
+		diff.Other = append(diff.Other, "logging")
+	}
+	if !jsonEqual(before.Storage, after.Storage) {
+		diff.Other = append(diff.Other, "storage")
+	}
+	for _, name := range unionKeys(serversOf(before), serversOf(after)) {
+		b, a := serversOf(before)[name], serversOf(after)[name]
+		if b == nil || a == nil {
+			diff.Other = append(diff.Other, "server "+name)
+			continue
+		}
+		// Compare server settings other than routes and TLS policies, which are reported above
+		bs, as := *b, *a
+		bs.Routes, as.Routes = nil, nil
+		bs.TLSConnPolicies, as.TLSConnPolicies = nil, nil
+		if !jsonEqual(bs, as) {
+			diff.Other = append(diff.Other, "server "+name)
+		}
+	}
+
+	diff.Changed = len(diff.Routes) > 0 || len(diff.TLS) > 0 || len(diff.Other) > 0
+	return diff
+}
+
+func diffRoutes(diff *ConfigDiff, before, after *Config) {
+	bServers, aServers := serversOf(before), serversOf(after)
+	for _, name := range unionKeys(bServers, aServers) {
+		var bRoutes, aRoutes []*Route
+		if s := bServers[name]; s != nil {
+			bRoutes = s.Routes
+		}
+		if s := aServers[name]; s != nil {
+			aRoutes = s.Routes
+		}
+		bKeyed, bOrder := keyRoutes(bRoutes)
+		aKeyed, aOrder := keyRoutes(aRoutes)
+
+		for _, key := range aOrder {
+			a := aKeyed[key]
+			b, existed := bKeyed[key]
+			switch {
+			case !existed:
+				diff.Routes = append(diff.Routes, newRouteChange(DiffAdded, name, key, nil, a))
+			case !jsonEqual(b, a):
+				diff.Routes = append(diff.Routes, newRouteChange(DiffChanged, name, key, b, a))
+			}
+		}
+		for _, key := range bOrder {
+			if _, ok := aKeyed[key]; !ok {
+				diff.Routes = append(diff.Routes, newRouteChange(DiffRemoved, name, key, bKeyed[key], nil))
+			}
+		}
+	}
+}
+
+func newRouteChange(action, server, key string, before, after *Route) RouteChange {
+	rc := RouteChange{Action: action, Server: server, Key: key, Before: before, After: after}
+	r := after
+	if r == nil {
+		r = before
+	}
+	for _, m := range r.Match {
+		rc.Hosts = append(rc.Hosts, m.Host...)
+		rc.Paths = append(rc.Paths, m.Path...)
+	}
+	return rc
+}
+
+// keyRoutes identifies routes by their matchers. Repeated matchers get a #n suffix.
+func keyRoutes(routes []*Route) (map[string]*Route, []string) {
+	keyed := make(map[string]*Route, len(routes))
+	order := make([]string, 0, len(routes))
+	for _, r := range routes {
+		base := routeKey(r)
+		key := base
+		for n := 2; keyed[key] != nil; n++ {
+			key = fmt.Sprintf("%s#%d", base, n)
+		}
+		keyed[key] = r
+		order = append(order, key)
+	}
+	return keyed, order
+}
+
+func routeKey(r *Route) string {
+	if len(r.Match) == 0 {
+		return "(catch-all)"
+	}
+	parts := make([]string, 0, len(r.Match))
+	for _, m := range r.Match {
+		hosts := append([]string(nil), m.Host...)
+		sort.Strings(hosts)
+		key := strings.Join(hosts, ",")
+		if len(m.Path) > 0 {
+			key += " " + strings.Join(m.Path, ",")
+		}
+		if key == "" {
+			key = "(matcher)"
+		}
+		parts = append(parts, key)
+	}
+	return strings.Join(parts, " | ")
+}
+
+func diffTLS(diff *ConfigDiff, before, after *Config) {
+	// Connection policies, per server, keyed by SNI
+	bServers, aServers := serversOf(before), serversOf(after)
+	for _, name := range unionKeys(bServers, aServers) {
+		b, a := map[string]interface{}{}, map[string]interface{}{}
+		if s := bServers[name]; s != nil {
+			for _, p := range s.TLSConnPolicies {
+				b[connPolicyKey(name, p)] = p
+			}
+		}
+		if s := aServers[name]; s != nil {
+			for _, p := range s.TLSConnPolicies {
+				a[connPolicyKey(name, p)] = p
+			}
+		}
+		diffKeyed(diff, "connection_policy", b, a)
+	}
+
+	// Automation policies keyed by subjects
+	b, a := map[string]interface{}{}, map[string]interface{}{}
+	for _, p := range automationOf(before).Policies {
+		b[subjectsKey(p.Subjects)] = p
+	}
+	for _, p := range automationOf(after).Policies {
+		a[subjectsKey(p.Subjects)] = p
+	}
+	diffKeyed(diff, "automation_policy", b, a)
+
+	if !jsonEqual(automationOf(before).OnDemand, automationOf(after).OnDemand) {
+		change := TLSChange{Action: DiffChanged, Kind: "on_demand", Key: "permission"}
+		switch {
+		case automationOf(before).OnDemand == nil:
+			change.Action = DiffAdded
+		case automationOf(after).OnDemand == nil:
+			change.Action = DiffRemoved
+		}
+		if automationOf(before).OnDemand != nil {
+			change.Before = automationOf(before).OnDemand
+		}
+		if automationOf(after).OnDemand != nil {
+			change.After = automationOf(after).OnDemand
+		}
+		diff.TLS = append(diff.TLS, change)
+	}
+
+	// Loaded certificates keyed by tag; only report identity, never the key material
+	b, a = map[string]interface{}{}, map[string]interface{}{}
+	for _, c := range loadedPEMOf(before) {
+		b[strings.Join(c.Tags, ",")] = fmt.Sprintf("%x", certFingerprint(c.Certificate))
+	}
+	for _, c := range loadedPEMOf(after) {
+		a[strings.Join(c.Tags, ",")] = fmt.Sprintf("%x", certFingerprint(c.Certificate))
+	}
+	diffKeyed(diff, "certificate", b, a)
+}
+
+func diffKeyed(diff *ConfigDiff, kind string, before, after map[string]interface{}) {
+	for _, key := range unionKeys(before, after) {
+		b, inBefore := before[key]
+		a, inAfter := after[key]
+		switch {
+		case !inBefore:
+			diff.TLS = append(diff.TLS, TLSChange{Action: DiffAdded, Kind: kind, Key: key, After: a})
+		case !inAfter:
+			diff.TLS = append(diff.TLS, TLSChange{Action: DiffRemoved, Kind: kind, Key: key, Before: b})
+		case !jsonEqual(b, a):
+			diff.TLS = append(diff.TLS, TLSChange{Action: DiffChanged, Kind: kind, Key: key, Before: b, After: a})
+		}
+	}
+}
+
+func connPolicyKey(server string, p *TLSConnectionPolicy) string {
+	if p.Match == nil || len(p.Match.SNI) == 0 {
+		return server + ": (default)"
+	}
+	return server + ": " + subjectsKey(p.Match.SNI)
+}
+
+func subjectsKey(subjects []string) string {
+	if len(subjects) == 0 {
+		return "(default)"
+	}
+	s := append([]string(nil), subjects...)
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func serversOf(c *Config) map[string]*Server {
+	if c.Apps.HTTP == nil {
+		return nil
+	}
+	return c.Apps.HTTP.Servers
+}
+
+func automationOf(c *Config) *AutomationConfig {
+	if c.Apps.TLS == nil || c.Apps.TLS.Automation == nil {
+		return &AutomationConfig{}
+	}
+	return c.Apps.TLS.Automation
+}
+
+func loadedPEMOf(c *Config) []LoadPEMConfig {
+	if c.Apps.TLS == nil || c.Apps.TLS.Certificates == nil {
+		return nil
+	}
+	return c.Apps.TLS.Certificates.LoadPEM
+}
+
+func certFingerprint(pemData string) []byte {
+	sum := sha256.Sum256([]byte(pemData))
+	return sum[:8]
+}
+
+// unionKeys returns the sorted union of the keys of two maps.
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonEqual compares values by their JSON encoding, which is how Caddy sees them.
+func jsonEqual(a, b interface{}) bool {
+	aj, aerr := json.Marshal(a)
+	bj, berr := json.Marshal(b)
+	if aerr != nil || berr != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
@@ -0,0 +1,196 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func findRouteChange(changes []RouteChange, key string) *RouteChange {
+	for i := range changes {
+		if changes[i].Key == key {
+			return &changes[i]
+		}
+	}
+	return nil
+}
+
+func TestDiffConfigs(t *testing.T) {
+	before, err := GenerateConfig([]models.ProxyHost{
+		{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true},
+		{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true},
+	}, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	after, err := GenerateConfig([]models.ProxyHost{
+		{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 8080, Enabled: true},
+		{UUID: "c", DomainNames: "c.example.com", ForwardHost: "c", ForwardPort: 80, Enabled: true, TLSMinVersion: "tls1.3"},
+	}, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	diff := DiffConfigs(before, after)
+	assert.True(t, diff.Changed)
+	require.Len(t, diff.Routes, 3)
+
+	changed := findRouteChange(diff.Routes, "a.example.com")
+	require.NotNil(t, changed)
+	assert.Equal(t, DiffChanged, changed.Action)
+	assert.Equal(t, "charon_server", changed.Server)
+	assert.Equal(t, []string{"a.example.com"}, changed.Hosts)
+
+	assert.Equal(t, DiffAdded, findRouteChange(diff.Routes, "c.example.com").Action)
+	assert.Equal(t, DiffRemoved, findRouteChange(diff.Routes, "b.example.com").Action)
+
+	// The new host brings its own policy plus the default one
+	require.Len(t, diff.TLS, 2)
+	for _, c := range diff.TLS {
+		assert.Equal(t, "connection_policy", c.Kind)
+		assert.Equal(t, DiffAdded, c.Action)
+	}
+	assert.Empty(t, diff.Other)
+
+	// Identical configs produce no diff, including after a JSON round trip through Caddy
+	b, err := json.Marshal(after)
+	require.NoError(t, err)
+	var roundTrip Config
+	require.NoError(t, json.Unmarshal(b, &roundTrip))
+	assert.False(t, DiffConfigs(&roundTrip, after).Changed)
+}
+
+func TestDiffConfigs_FromEmpty(t *testing.T) {
+	after, err := GenerateConfig([]models.ProxyHost{
+		{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true},
+	}, "/tmp/caddy/data", "admin@example.com", "", "letsencrypt", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	diff := DiffConfigs(nil, after)
+	require.Len(t, diff.Routes, 1)
+	assert.Equal(t, DiffAdded, diff.Routes[0].Action)
+	require.Len(t, diff.TLS, 1)
+	assert.Equal(t, "automation_policy", diff.TLS[0].Kind)
+	assert.Equal(t, "(default)", diff.TLS[0].Key)
+	assert.ElementsMatch(t, []string{"logging", "storage", "server charon_server"}, diff.Other)
+}
+
+func TestManager_Preview(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.CaddyConfig{}, &models.SSLCertificate{}, &models.AccessList{}, &models.ClientCA{}, &models.SecurityConfig{}, &models.SecurityRuleSet{}, &models.SecurityDecision{}))
+
+	existing := models.ProxyHost{UUID: "existing", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&existing).Error)
+
+	tmpDir := t.TempDir()
+	running, err := GenerateConfig([]models.ProxyHost{existing}, tmpDir+"/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	loads := 0
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/config/" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(running)
+		case r.URL.Path == "/load":
+			loads++
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer caddyServer.Close()
+
+	manager := NewManager(NewClient(caddyServer.URL), db, tmpDir, "", false, config.SecurityConfig{})
+
+	// No pending changes
+	preview, err := manager.Preview(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, preview.CurrentError)
+	assert.Empty(t, preview.ValidationError)
+	assert.False(t, preview.Diff.Changed, "%+v", preview.Diff)
+
+	// Proposed edit of the existing host
+	edited := existing
+	edited.ForwardPort = 9090
+	preview, err = manager.Preview(context.Background(), &edited)
+	require.NoError(t, err)
+	require.Len(t, preview.Diff.Routes, 1)
+	assert.Equal(t, DiffChanged, preview.Diff.Routes[0].Action)
+
+	// Proposed new host
+	preview, err = manager.Preview(context.Background(), &models.ProxyHost{DomainNames: "new.example.com", ForwardHost: "new", ForwardPort: 80, Enabled: true})
+	require.NoError(t, err)
+	require.Len(t, preview.Diff.Routes, 1)
+	assert.Equal(t, DiffAdded, preview.Diff.Routes[0].Action)
+	assert.Equal(t, "new.example.com", preview.Diff.Routes[0].Key)
+	require.NotNil(t, preview.Config)
+
+	// Unknown associations are rejected
+	missing := uint(42)
+	_, err = manager.Preview(context.Background(), &models.ProxyHost{DomainNames: "mtls.example.com", ForwardHost: "x", ForwardPort: 80, Enabled: true, ClientAuthMode: "require", ClientCAID: &missing})
+	assert.Error(t, err)
+
+	// Nothing was saved or loaded
+	var count int64
+	db.Model(&models.ProxyHost{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 0, loads)
+	db.Model(&models.CaddyConfig{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestManager_Preview_KeepsTrafficSplit(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.Setting{}, &models.CaddyConfig{}, &models.SSLCertificate{}, &models.AccessList{}, &models.ClientCA{}, &models.SecurityConfig{}, &models.SecurityRuleSet{}, &models.SecurityDecision{}))
+
+	existing := models.ProxyHost{UUID: "split", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&existing).Error)
+	require.NoError(t, db.Create(&models.UpstreamGroup{UUID: "canary", ProxyHostID: existing.ID, Name: "canary", Upstreams: "app-v2:80", Weight: 10}).Error)
+	require.NoError(t, db.Create(&models.RoutingRule{UUID: "mobile", ProxyHostID: existing.ID, Headers: "User-Agent: *MyApp*", Upstreams: "mobile:8080", Enabled: true}).Error)
+
+	manager := NewManager(NewClient("http://127.0.0.1:1"), db, t.TempDir(), "", false, config.SecurityConfig{})
+
+	// Editing the host keeps its canary split and routing rule in the preview
+	edited := existing
+	edited.ForwardPort = 9090
+	preview, err := manager.Preview(context.Background(), &edited)
+	require.NoError(t, err)
+	out, err := json.Marshal(preview.Config)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"weights":[9,1]`)
+	assert.Contains(t, string(out), `"dial":"app-v2:80"`)
+	assert.Contains(t, string(out), `"@id":"`+RoutingRuleRouteID("split", "mobile")+`"`)
+	assert.Contains(t, string(out), `"dial":"app:9090"`)
+}
+
+func TestManager_Preview_CaddyUnreachable(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.SSLCertificate{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer caddyServer.Close()
+
+	manager := NewManager(NewClient(caddyServer.URL), db, t.TempDir(), "", false, config.SecurityConfig{})
+	preview, err := manager.Preview(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Contains(t, preview.CurrentError, "status 500")
+	require.Len(t, preview.Diff.Routes, 1)
+	assert.Equal(t, DiffAdded, preview.Diff.Routes[0].Action)
+}
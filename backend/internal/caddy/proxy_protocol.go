@@ -0,0 +1,58 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Settings that control the PROXY protocol on Caddy's HTTP listeners.
+const (
+	ProxyProtocolEnabledSettingKey = "caddy.proxy_protocol.enabled"
+	ProxyProtocolTrustedSettingKey = "caddy.proxy_protocol.trusted"
+)
+
+// proxyProtocolTimeout bounds how long a connection may take to send its PROXY header.
+const proxyProtocolTimeout = "5s"
+
+// ParseTrustedCIDRs splits a comma or newline separated list of load balancer
+// addresses. Single IPs are turned into /32 or /128 ranges.
+func ParseTrustedCIDRs(raw string) ([]string, error) {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+	cidrs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if _, ipnet, err := net.ParseCIDR(f); err == nil {
+			cidrs = append(cidrs, ipnet.String())
+			continue
+		}
+		ip := net.ParseIP(f)
+		if ip == nil {
+			return nil, fmt.Errorf("trusted source %q must be an IP address or CIDR", f)
+		}
+		if ip.To4() != nil {
+			cidrs = append(cidrs, ip.String()+"/32")
+		} else {
+			cidrs = append(cidrs, ip.String()+"/128")
+		}
+	}
+	return cidrs, nil
+}
+
+// ConfigureProxyProtocol makes every HTTP server of a generated config read
+// the PROXY protocol header that a layer 4 load balancer puts in front of each
+// connection, so that the client address it carries becomes the remote address.
+// Headers are only accepted from the trusted ranges. The wrapper has to come
+// before TLS, which Caddy would otherwise put first.
+func ConfigureProxyProtocol(config *Config, trusted []string) {
+	if config == nil || config.Apps.HTTP == nil || len(trusted) == 0 {
+		return
+	}
+	for _, server := range config.Apps.HTTP.Servers {
+		server.ListenerWrappers = []map[string]interface{}{
+			{"wrapper": "proxy_protocol", "timeout": proxyProtocolTimeout, "allow": trusted},
+			{"wrapper": "tls"},
+		}
+	}
+}
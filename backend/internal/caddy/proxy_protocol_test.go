@@ -0,0 +1,112 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestParseTrustedCIDRs(t *testing.T) {
+	cidrs, err := ParseTrustedCIDRs("10.1.2.3/8, 192.168.1.5\n2001:db8::1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.5/32", "2001:db8::1/128"}, cidrs)
+
+	cidrs, err = ParseTrustedCIDRs("")
+	require.NoError(t, err)
+	assert.Empty(t, cidrs)
+
+	_, err = ParseTrustedCIDRs("10.0.0.0/8, lb.internal")
+	assert.ErrorContains(t, err, `trusted source "lb.internal"`)
+}
+
+func TestConfigureProxyProtocol(t *testing.T) {
+	hosts := []models.ProxyHost{{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	ConfigureProxyProtocol(cfg, nil)
+	assert.Nil(t, cfg.Apps.HTTP.Servers["charon_server"].ListenerWrappers, "no trusted sources, no wrapper")
+
+	ConfigureProxyProtocol(cfg, []string{"10.0.0.0/8"})
+	out, err := json.Marshal(cfg.Apps.HTTP.Servers["charon_server"].ListenerWrappers)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"wrapper": "proxy_protocol", "timeout": "5s", "allow": ["10.0.0.0/8"]},
+		{"wrapper": "tls"}
+	]`, string(out))
+}
+
+func TestManager_ApplyConfig_ProxyProtocol(t *testing.T) {
+	var loaded []byte
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" {
+			loaded, _ = io.ReadAll(r.Body)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer caddyServer.Close()
+
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.CaddyConfig{}, &models.SSLCertificate{}))
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "php", DomainNames: "php.example.com", ForwardHost: "unix//run/php/fpm.sock", Enabled: true}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: ProxyProtocolEnabledSettingKey, Value: "true"}).Error)
+	manager := NewManager(NewClient(caddyServer.URL), db, t.TempDir(), "", false, config.SecurityConfig{})
+
+	// Enabled without trusted sources: the balancer's connections would break, so nothing is applied
+	err = manager.ApplyConfig(context.Background())
+	assert.ErrorContains(t, err, "lists no trusted sources")
+	assert.Nil(t, loaded)
+
+	require.NoError(t, db.Create(&models.Setting{Key: ProxyProtocolTrustedSettingKey, Value: "10.0.0.0/8"}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	assert.Contains(t, string(loaded), `"listener_wrappers":[{"allow":["10.0.0.0/8"],"timeout":"5s","wrapper":"proxy_protocol"},{"wrapper":"tls"}]`)
+	assert.Contains(t, string(loaded), `"upstreams":[{"dial":"unix//run/php/fpm.sock"}]`)
+
+	out, err := manager.ExportCaddyfile(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, out, "\tservers {\n\t\tlistener_wrappers {\n\t\t\tproxy_protocol {\n\t\t\t\ttimeout 5s\n\t\t\t\tallow 10.0.0.0/8\n\t\t\t}\n\t\t\ttls\n\t\t}\n\t}\n")
+	assert.Contains(t, out, "\treverse_proxy unix//run/php/fpm.sock {\n")
+}
+
+func TestValidate_UnixSocketUpstreams(t *testing.T) {
+	route := func(dial string) *Config {
+		return &Config{Apps: Apps{HTTP: &HTTPApp{Servers: map[string]*Server{"srv": {
+			Listen: []string{":80"},
+			Routes: []*Route{{Match: []Match{{Host: []string{"php.example.com"}}}, Handle: []Handler{ReverseProxyHandler(dial, false, "none")}}},
+		}}}}}
+	}
+	assert.NoError(t, Validate(route("unix//run/php/fpm.sock")))
+	assert.ErrorContains(t, Validate(route("unix/run/php/fpm.sock")), "must be unix// followed by an absolute path")
+
+	assert.Equal(t, "unix//run/app.sock", UpstreamDial("unix//run/app.sock", 8080))
+	assert.Equal(t, "app:8080", UpstreamDial("app", 8080))
+	assert.NoError(t, checkUpstreamAddress("unix//run/app.sock"))
+}
+
+func TestUpstreamProxyProtocol(t *testing.T) {
+	host := models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true, UpstreamProxyProtocol: "v2"}
+	require.NoError(t, ValidatePerformance(&host))
+	cfg, err := GenerateConfig([]models.ProxyHost{host}, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	proxy := cfg.Apps.HTTP.Servers["charon_server"].Routes[0].Handle[0]
+	assert.Equal(t, map[string]interface{}{"protocol": "http", "proxy_protocol": "v2"}, proxy["transport"])
+
+	out := ExportCaddyfile([]models.ProxyHost{host}, CaddyfileOptions{})
+	assert.Contains(t, out, "\t\ttransport http {\n\t\t\tproxy_protocol v2\n\t\t}\n")
+
+	host.UpstreamProxyProtocol = "v3"
+	assert.ErrorContains(t, ValidatePerformance(&host), "upstream_proxy_protocol must be v1 or v2")
+}
@@ -0,0 +1,204 @@
+package caddy
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// RedirectRouteID is the @id of a redirection host's route.
+func RedirectRouteID(hostUUID string) string {
+	return "charon-redirect-" + hostUUID
+}
+
+// redirectCodes are the status codes a redirection host may answer with.
+var redirectCodes = map[int]bool{301: true, 302: true, 307: true, 308: true}
+
+// SplitDomains returns the lowercased, non-empty entries of a comma-separated
+// domain list, the way GenerateConfig reads a host's domains.
+func SplitDomains(domainNames string) []string {
+	var domains []string
+	for _, d := range strings.Split(domainNames, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// ValidateRedirectionHost checks the domains, status code and target of a
+// redirection host and fills in the defaults for scheme and status code.
+func ValidateRedirectionHost(r *models.RedirectionHost) error {
+	if len(SplitDomains(r.DomainNames)) == 0 {
+		return fmt.Errorf("domain_names is required")
+	}
+	if r.ForwardHTTPCode == 0 {
+		r.ForwardHTTPCode = 301
+	}
+	if !redirectCodes[r.ForwardHTTPCode] {
+		return fmt.Errorf("forward_http_code must be 301, 302, 307 or 308")
+	}
+	if r.ForwardScheme == "" {
+		r.ForwardScheme = "auto"
+	}
+	_, err := RedirectLocation(r)
+	return err
+}
+
+// RedirectLocation returns the Location header a redirection host answers with.
+// Request placeholders are left for Caddy to fill in.
+func RedirectLocation(r *models.RedirectionHost) (string, error) {
+	if target := strings.TrimSpace(r.TargetURL); target != "" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("target_url must be an absolute http or https URL")
+		}
+		if !r.PreservePath {
+			return target, nil
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return "", fmt.Errorf("target_url cannot have a query or fragment when the path is preserved")
+		}
+		return strings.TrimSuffix(target, "/") + "{http.request.uri}", nil
+	}
+
+	domain := strings.TrimSpace(r.ForwardDomainName)
+	if domain == "" {
+		return "", fmt.Errorf("target_url or forward_domain_name is required")
+	}
+	if strings.Contains(domain, "://") || strings.ContainsAny(domain, " ?#{}") {
+		return "", fmt.Errorf("forward_domain_name must be a domain, optionally with a port and path")
+	}
+	scheme := r.ForwardScheme
+	switch scheme {
+	case "", "auto":
+		scheme = "{http.request.scheme}"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("forward_scheme must be auto, http or https")
+	}
+	location := scheme + "://" + strings.TrimSuffix(domain, "/")
+	if r.PreservePath {
+		location += "{http.request.uri}"
+	}
+	return location, nil
+}
+
+// RedirectHandler creates a static_response handler that redirects to location.
+func RedirectHandler(location string, code int) Handler {
+	return Handler{
+		"handler":     "static_response",
+		"status_code": code,
+		"headers": map[string][]string{
+			"Location": {location},
+		},
+	}
+}
+
+// AddRedirectionRoutes adds a route for every enabled redirection host to the
+// Charon server, ahead of the catch-all route. Domains already routed to a proxy
+// host keep going there; among redirection hosts the newest wins. Caddy obtains
+// certificates for the domains like for any other route.
+func AddRedirectionRoutes(config *Config, redirects []models.RedirectionHost) {
+	if config == nil || len(redirects) == 0 {
+		return
+	}
+	routed := routedDomains(config)
+
+	var routes []*Route
+	for i := len(redirects) - 1; i >= 0; i-- {
+		r := redirects[i]
+		if !r.Enabled {
+			continue
+		}
+		var domains []string
+		for _, d := range SplitDomains(r.DomainNames) {
+			if routed[d] {
+				logger.Log().WithField("domain", d).WithField("redirection_host", r.UUID).Warn("Skipping redirect for domain that is already routed")
+				continue
+			}
+			routed[d] = true
+			domains = append(domains, d)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+		location, err := RedirectLocation(&r)
+		if err != nil {
+			logger.Log().WithField("redirection_host", r.UUID).WithError(err).Warn("Skipping invalid redirection host")
+			continue
+		}
+		code := r.ForwardHTTPCode
+		if !redirectCodes[code] {
+			code = 301
+		}
+
+		if r.SSLForced {
+			routes = append(routes, &Route{
+				ID:       RedirectRouteID(r.UUID) + "-https",
+				Match:    []Match{{Host: domains, Protocol: "http"}},
+				Handle:   []Handler{RedirectHandler("https://{http.request.host}{http.request.uri}", 308)},
+				Terminal: true,
+			})
+		}
+		routes = append(routes, &Route{
+			ID:       RedirectRouteID(r.UUID),
+			Match:    []Match{{Host: domains}},
+			Handle:   []Handler{RedirectHandler(location, code)},
+			Terminal: true,
+		})
+	}
+
+	addRoutesBeforeCatchAll(config, routes)
+}
+
+// routedDomains returns the domains the Charon server already routes.
+func routedDomains(config *Config) map[string]bool {
+	routed := make(map[string]bool)
+	if config.Apps.HTTP == nil || config.Apps.HTTP.Servers["charon_server"] == nil {
+		return routed
+	}
+	for _, route := range config.Apps.HTTP.Servers["charon_server"].Routes {
+		for _, m := range route.Match {
+			for _, h := range m.Host {
+				routed[h] = true
+			}
+		}
+	}
+	return routed
+}
+
+// addRoutesBeforeCatchAll adds routes to the Charon server ahead of its
+// catch-all route, creating the server if there are no proxy hosts.
+func addRoutesBeforeCatchAll(config *Config, routes []*Route) {
+	if len(routes) == 0 {
+		return
+	}
+	var server *Server
+	if config.Apps.HTTP != nil {
+		server = config.Apps.HTTP.Servers["charon_server"]
+	}
+	if server == nil {
+		if config.Apps.HTTP == nil {
+			config.Apps.HTTP = &HTTPApp{Servers: map[string]*Server{}}
+		}
+		server = &Server{
+			Listen:    []string{":80", ":443"},
+			AutoHTTPS: &AutoHTTPSConfig{},
+			Logs:      &ServerLogs{DefaultLoggerName: "access_log"},
+		}
+		config.Apps.HTTP.Servers["charon_server"] = server
+	}
+
+	// The catch-all route has no matcher and must stay last
+	n := len(server.Routes)
+	if n > 0 && len(server.Routes[n-1].Match) == 0 {
+		catchAll := server.Routes[n-1]
+		server.Routes = append(append(server.Routes[:n-1], routes...), catchAll)
+		return
+	}
+	server.Routes = append(server.Routes, routes...)
+}
@@ -0,0 +1,100 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestRedirectLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		host models.RedirectionHost
+		want string
+		err  string
+	}{
+		{"target url", models.RedirectionHost{TargetURL: "https://new.example.com/landing?x=1"}, "https://new.example.com/landing?x=1", ""},
+		{"target url keeping path", models.RedirectionHost{TargetURL: "https://new.example.com/", PreservePath: true}, "https://new.example.com{http.request.uri}", ""},
+		{"target with query keeping path", models.RedirectionHost{TargetURL: "https://new.example.com/?x=1", PreservePath: true}, "", "query or fragment"},
+		{"relative target", models.RedirectionHost{TargetURL: "/elsewhere"}, "", "absolute http or https URL"},
+		{"auto scheme", models.RedirectionHost{ForwardDomainName: "example.com", PreservePath: true}, "{http.request.scheme}://example.com{http.request.uri}", ""},
+		{"fixed scheme", models.RedirectionHost{ForwardScheme: "https", ForwardDomainName: "example.com:8443/app/"}, "https://example.com:8443/app", ""},
+		{"bad scheme", models.RedirectionHost{ForwardScheme: "ftp", ForwardDomainName: "example.com"}, "", "forward_scheme"},
+		{"domain with scheme", models.RedirectionHost{ForwardDomainName: "https://example.com"}, "", "forward_domain_name must be a domain"},
+		{"no target", models.RedirectionHost{}, "", "target_url or forward_domain_name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RedirectLocation(&tt.host)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateRedirectionHost(t *testing.T) {
+	host := models.RedirectionHost{DomainNames: "old.example.com", ForwardDomainName: "new.example.com"}
+	require.NoError(t, ValidateRedirectionHost(&host))
+	assert.Equal(t, 301, host.ForwardHTTPCode)
+	assert.Equal(t, "auto", host.ForwardScheme)
+
+	host.ForwardHTTPCode = 303
+	assert.ErrorContains(t, ValidateRedirectionHost(&host), "forward_http_code")
+	assert.ErrorContains(t, ValidateRedirectionHost(&models.RedirectionHost{DomainNames: " , "}), "domain_names is required")
+}
+
+func TestAddRedirectionRoutes(t *testing.T) {
+	cfg, err := GenerateConfig([]models.ProxyHost{
+		{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+	}, "/data/caddy/data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	AddRedirectionRoutes(cfg, []models.RedirectionHost{
+		{UUID: "old", DomainNames: "www.example.com, old.example.com", ForwardDomainName: "older.example.com", ForwardHTTPCode: 302, Enabled: true},
+		{UUID: "www", DomainNames: "WWW.example.com, App.example.com", TargetURL: "https://example.com", PreservePath: true, ForwardHTTPCode: 308, SSLForced: true, Enabled: true},
+		{UUID: "off", DomainNames: "off.example.com", ForwardDomainName: "example.com", Enabled: false},
+	})
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 5)
+	assert.Equal(t, HostRouteID("app"), routes[0].ID)
+
+	// Newest redirect wins www; app.example.com stays with the proxy host
+	assert.Equal(t, RedirectRouteID("www")+"-https", routes[1].ID)
+	assert.Equal(t, []Match{{Host: []string{"www.example.com"}, Protocol: "http"}}, routes[1].Match)
+	out, err := json.Marshal(routes[2])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"@id": "charon-redirect-www",
+		"match": [{"host": ["www.example.com"]}],
+		"handle": [{"handler": "static_response", "status_code": 308, "headers": {"Location": ["https://example.com{http.request.uri}"]}}],
+		"terminal": true
+	}`, string(out))
+
+	assert.Equal(t, RedirectRouteID("old"), routes[3].ID)
+	assert.Equal(t, []string{"old.example.com"}, routes[3].Match[0].Host)
+	assert.Equal(t, 302, routes[3].Handle[0]["status_code"])
+
+	// The catch-all stays last
+	assert.Empty(t, routes[4].Match)
+}
+
+func TestAddRedirectionRoutes_WithoutProxyHosts(t *testing.T) {
+	cfg, err := GenerateConfig(nil, "/data/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	AddRedirectionRoutes(cfg, []models.RedirectionHost{{UUID: "r", DomainNames: "r.example.com", ForwardDomainName: "example.com", Enabled: true}})
+
+	server := cfg.Apps.HTTP.Servers["charon_server"]
+	require.NotNil(t, server)
+	assert.Equal(t, []string{":80", ":443"}, server.Listen)
+	require.Len(t, server.Routes, 1)
+	assert.Equal(t, 301, server.Routes[0].Handle[0]["status_code"])
+}
@@ -0,0 +1,195 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Actions of a routing rule.
+const (
+	RuleActionProxy    = "proxy"
+	RuleActionRespond  = "respond"
+	RuleActionRedirect = "redirect"
+)
+
+var ruleMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodOptions: true, http.MethodConnect: true, http.MethodTrace: true,
+}
+
+// RoutingRuleRouteID is the @id of a routing rule's route.
+func RoutingRuleRouteID(hostUUID, ruleUUID string) string {
+	return HostRouteID(hostUUID) + "-rule-" + ruleUUID
+}
+
+// ValidateRoutingRule checks a routing rule and fills in the default action
+// and status code.
+func ValidateRoutingRule(rule *models.RoutingRule) error {
+	match, err := RoutingRuleMatch(rule, nil)
+	if err != nil {
+		return err
+	}
+	if len(match.Path) == 0 && len(match.Method) == 0 && len(match.Header) == 0 && len(match.Query) == 0 && match.RemoteIP == nil {
+		return fmt.Errorf("at least one matcher is required: paths, methods, headers, query or remote_ips")
+	}
+
+	if rule.Action == "" {
+		rule.Action = RuleActionProxy
+	}
+	switch rule.Action {
+	case RuleActionProxy:
+		upstreams := splitList(rule.Upstreams)
+		if len(upstreams) == 0 {
+			return fmt.Errorf("upstreams is required for action proxy")
+		}
+		for _, u := range upstreams {
+			if err := checkUpstreamAddress(u); err != nil {
+				return err
+			}
+		}
+	case RuleActionRespond:
+		if rule.StatusCode == 0 {
+			rule.StatusCode = http.StatusOK
+		}
+		if rule.StatusCode < 200 || rule.StatusCode > 599 {
+			return fmt.Errorf("status_code must be between 200 and 599")
+		}
+	case RuleActionRedirect:
+		if rule.StatusCode == 0 {
+			rule.StatusCode = http.StatusFound
+		}
+		if !redirectCodes[rule.StatusCode] {
+			return fmt.Errorf("status_code must be 301, 302, 307 or 308 for action redirect")
+		}
+		target := strings.TrimSpace(rule.TargetURL)
+		if !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return fmt.Errorf("target_url must be a path or an http or https URL")
+		}
+		rule.TargetURL = target
+	default:
+		return fmt.Errorf("action must be proxy, respond or redirect")
+	}
+	return nil
+}
+
+// RoutingRuleMatch builds the matcher of a rule for the given host names.
+func RoutingRuleMatch(rule *models.RoutingRule, hosts []string) (Match, error) {
+	match := Match{Host: hosts}
+
+	for _, p := range splitList(rule.Paths) {
+		if !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "*") {
+			return Match{}, fmt.Errorf("path %q must start with / or *", p)
+		}
+		match.Path = append(match.Path, p)
+	}
+
+	for _, m := range splitList(rule.Methods) {
+		m = strings.ToUpper(m)
+		if !ruleMethods[m] {
+			return Match{}, fmt.Errorf("unknown method %q", m)
+		}
+		match.Method = append(match.Method, m)
+	}
+
+	for i, line := range strings.Split(rule.Headers, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(line, ":")
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return Match{}, fmt.Errorf("header rule on line %d must be \"Name: value\" or a header name", i+1)
+		}
+		if match.Header == nil {
+			match.Header = make(map[string][]string)
+		}
+		values, ok := match.Header[name]
+		if !ok {
+			values = []string{}
+		}
+		if value = strings.TrimSpace(value); hasValue && value != "" {
+			values = append(values, value)
+		}
+		match.Header[name] = values
+	}
+
+	for i, line := range strings.Split(rule.Query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return Match{}, fmt.Errorf("query rule on line %d must be key=value", i+1)
+		}
+		if match.Query == nil {
+			match.Query = make(map[string][]string)
+		}
+		match.Query[key] = append(match.Query[key], strings.TrimSpace(value))
+	}
+
+	for _, r := range splitList(rule.RemoteIPs) {
+		if _, _, err := net.ParseCIDR(r); err != nil && net.ParseIP(r) == nil {
+			return Match{}, fmt.Errorf("remote IP %q must be an IP address or CIDR", r)
+		}
+		if match.RemoteIP == nil {
+			match.RemoteIP = &RemoteIPMatch{}
+		}
+		match.RemoteIP.Ranges = append(match.RemoteIP.Ranges, r)
+	}
+	return match, nil
+}
+
+// routingRuleAction returns the handler that answers requests matched by rule.
+// proxy builds the reverse_proxy handler for an upstream dial address.
+func routingRuleAction(rule *models.RoutingRule, proxy func(dial string) Handler) Handler {
+	switch rule.Action {
+	case RuleActionRespond:
+		h := Handler{"handler": "static_response", "status_code": rule.StatusCode}
+		if rule.Body != "" {
+			h["body"] = rule.Body
+		}
+		return h
+	case RuleActionRedirect:
+		return Handler{
+			"handler":     "static_response",
+			"status_code": rule.StatusCode,
+			"headers":     map[string][]string{"Location": {rule.TargetURL}},
+		}
+	}
+	upstreams := splitList(rule.Upstreams)
+	h := proxy(upstreams[0])
+	if len(upstreams) > 1 {
+		list := make([]map[string]interface{}, 0, len(upstreams))
+		for _, u := range upstreams {
+			list = append(list, map[string]interface{}{"dial": u})
+		}
+		h["upstreams"] = list
+	}
+	return h
+}
+
+// sortedRoutingRules returns the enabled rules of a host in the order they
+// are matched: by priority, then oldest first.
+func sortedRoutingRules(rules []models.RoutingRule) []models.RoutingRule {
+	var out []models.RoutingRule
+	for _, r := range rules {
+		if r.Enabled {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority < out[j].Priority
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
@@ -0,0 +1,189 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateRoutingRule(t *testing.T) {
+	rule := models.RoutingRule{Headers: "User-Agent: *MyApp*", Upstreams: "mobile:8080"}
+	require.NoError(t, ValidateRoutingRule(&rule))
+	assert.Equal(t, RuleActionProxy, rule.Action)
+
+	respond := models.RoutingRule{Methods: "trace", Action: RuleActionRespond}
+	require.NoError(t, ValidateRoutingRule(&respond))
+	assert.Equal(t, 200, respond.StatusCode)
+
+	redirect := models.RoutingRule{Query: "lang=de", Action: RuleActionRedirect, TargetURL: " https://de.example.com{http.request.uri} "}
+	require.NoError(t, ValidateRoutingRule(&redirect))
+	assert.Equal(t, 302, redirect.StatusCode)
+	assert.Equal(t, "https://de.example.com{http.request.uri}", redirect.TargetURL)
+
+	tests := []struct {
+		rule models.RoutingRule
+		err  string
+	}{
+		{models.RoutingRule{Upstreams: "a:1"}, "at least one matcher is required"},
+		{models.RoutingRule{Paths: "webhook", Upstreams: "a:1"}, `path "webhook" must start with / or *`},
+		{models.RoutingRule{Methods: "FETCH", Upstreams: "a:1"}, `unknown method "FETCH"`},
+		{models.RoutingRule{Headers: "Bad Name: x", Upstreams: "a:1"}, "header rule on line 1"},
+		{models.RoutingRule{Query: "\nlang", Upstreams: "a:1"}, "query rule on line 2 must be key=value"},
+		{models.RoutingRule{RemoteIPs: "github", Upstreams: "a:1"}, `remote IP "github"`},
+		{models.RoutingRule{Paths: "/x"}, "upstreams is required"},
+		{models.RoutingRule{Paths: "/x", Upstreams: "ci"}, "must be host:port"},
+		{models.RoutingRule{Paths: "/x", Action: RuleActionRespond, StatusCode: 99}, "status_code must be between 200 and 599"},
+		{models.RoutingRule{Paths: "/x", Action: RuleActionRedirect, TargetURL: "/new", StatusCode: 303}, "301, 302, 307 or 308"},
+		{models.RoutingRule{Paths: "/x", Action: RuleActionRedirect, TargetURL: "ftp://x"}, "target_url must be a path"},
+		{models.RoutingRule{Paths: "/x", Action: "drop"}, "action must be proxy, respond or redirect"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, ValidateRoutingRule(&tt.rule), tt.err)
+	}
+}
+
+func TestRoutingRuleMatch(t *testing.T) {
+	rule := models.RoutingRule{
+		Paths:     "/webhook, /hooks/*",
+		Methods:   "post, PUT",
+		Headers:   "x-github-event: push\nX-GitHub-Event: release\nX-Hub-Signature-256",
+		Query:     "token=*\ntoken=abc",
+		RemoteIPs: "140.82.112.0/20, 192.30.252.1",
+	}
+	match, err := RoutingRuleMatch(&rule, []string{"ci.example.com"})
+	require.NoError(t, err)
+	out, err := json.Marshal(match)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"host": ["ci.example.com"],
+		"path": ["/webhook", "/hooks/*"],
+		"method": ["POST", "PUT"],
+		"header": {"X-Github-Event": ["push", "release"], "X-Hub-Signature-256": []},
+		"query": {"token": ["*", "abc"]},
+		"remote_ip": {"ranges": ["140.82.112.0/20", "192.30.252.1"]}
+	}`, string(out))
+}
+
+func TestGenerateConfig_RoutingRules(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true, HSTSEnabled: true,
+		Locations: []models.Location{{UUID: "api", Path: "/api", ForwardHost: "api", ForwardPort: 9000}},
+		RoutingRules: []models.RoutingRule{
+			{ID: 3, UUID: "ci", Priority: 10, Paths: "/webhook", RemoteIPs: "140.82.112.0/20", Upstreams: "ci:8080, ci2:8080", Enabled: true},
+			{ID: 2, UUID: "mobile", Priority: 10, Headers: "User-Agent: *MyApp*", Upstreams: "mobile:8080", Enabled: true},
+			{ID: 4, UUID: "old", Priority: 1, Paths: "/old/*", Action: RuleActionRedirect, TargetURL: "/new", StatusCode: 308, Enabled: true},
+			{ID: 5, UUID: "off", Paths: "/x", Action: RuleActionRespond, Enabled: false},
+			{ID: 6, UUID: "bad", Paths: "nope", Upstreams: "a:1", Enabled: true},
+		},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 5)
+	// By priority, then oldest first; disabled and invalid rules are left out
+	assert.Equal(t, RoutingRuleRouteID("app", "old"), routes[0].ID)
+	assert.Equal(t, RoutingRuleRouteID("app", "mobile"), routes[1].ID)
+	assert.Equal(t, RoutingRuleRouteID("app", "ci"), routes[2].ID)
+	assert.Equal(t, LocationRouteID("app", "api"), routes[3].ID)
+	assert.Equal(t, HostRouteID("app"), routes[4].ID)
+
+	out, err := json.Marshal(routes[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"@id": "charon-host-app-rule-old",
+		"match": [{"host": ["app.example.com"], "path": ["/old/*"]}],
+		"handle": [
+			{"handler": "headers", "response": {"set": {"Strict-Transport-Security": ["max-age=31536000"]}}},
+			{"handler": "static_response", "status_code": 308, "headers": {"Location": ["/new"]}}
+		],
+		"terminal": true
+	}`, string(out))
+
+	assert.Equal(t, map[string][]string{"User-Agent": {"*MyApp*"}}, routes[1].Match[0].Header)
+	proxy := routes[2].Handle[len(routes[2].Handle)-1]
+	assert.Equal(t, "reverse_proxy", proxy["handler"])
+	assert.Equal(t, []map[string]interface{}{{"dial": "ci:8080"}, {"dial": "ci2:8080"}}, proxy["upstreams"])
+
+	// Maintenance covers rule routes too
+	start := time.Now().Add(-time.Minute)
+	AddMaintenance(cfg, hosts, []models.MaintenanceWindow{{ProxyHostID: 1, StartsAt: &start, Enabled: true}}, time.Now())
+	for _, r := range routes {
+		assert.Equal(t, "subroute", r.Handle[0]["handler"], r.ID)
+	}
+}
+
+func TestGenerateConfig_RoutingRulesCarryAdvancedConfig(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		AdvancedConfig: `{"handler":"authentication","providers":{"http_basic":{"accounts":[{"username":"admin","password":"hash"}]}}}`,
+		Locations:      []models.Location{{UUID: "api", Path: "/api", ForwardHost: "api", ForwardPort: 9000}},
+		RoutingRules:   []models.RoutingRule{{ID: 1, UUID: "mobile", Headers: "User-Agent: *MyApp*", Upstreams: "mobile:8080", Enabled: true}},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 3)
+	// Host auth applies to routing rules, not only the main route
+	for _, r := range []*Route{routes[0], routes[2]} {
+		require.GreaterOrEqual(t, len(r.Handle), 2, r.ID)
+		assert.Equal(t, "authentication", r.Handle[len(r.Handle)-2]["handler"], r.ID)
+	}
+	assert.Equal(t, RoutingRuleRouteID("app", "mobile"), routes[0].ID)
+	assert.Equal(t, HostRouteID("app"), routes[2].ID)
+}
+
+func TestGenerateConfig_LocationsSkipAdvancedConfig(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		AdvancedConfig: `[{"handler":"headers","response":{"set":{"X-Env":["prod"]}}},{"handler":"static_response","body":"maintenance"}]`,
+		Locations: []models.Location{
+			{UUID: "api", Path: "/api", ForwardHost: "api", ForwardPort: 9000},
+			{UUID: "admin", Path: "/admin", ForwardHost: "admin", ForwardPort: 9001, InheritAdvanced: true},
+		},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 3)
+	// A location is what it was before routing rules existed: only its own proxy
+	assert.Equal(t, LocationRouteID("app", "api"), routes[0].ID)
+	require.Len(t, routes[0].Handle, 1)
+	assert.Equal(t, "reverse_proxy", routes[0].Handle[0]["handler"])
+	assert.Equal(t, "api:9000", routes[0].Handle[0]["upstreams"].([]map[string]interface{})[0]["dial"])
+
+	// Opting in adds the headers, but not the static_response that would shadow the proxy
+	assert.Equal(t, LocationRouteID("app", "admin"), routes[1].ID)
+	require.Len(t, routes[1].Handle, 2)
+	assert.Equal(t, "headers", routes[1].Handle[0]["handler"])
+	assert.Equal(t, "reverse_proxy", routes[1].Handle[1]["handler"])
+
+	assert.Equal(t, "static_response", routes[2].Handle[1]["handler"])
+}
+
+func TestExportCaddyfile_RoutingRules(t *testing.T) {
+	out := ExportCaddyfile([]models.ProxyHost{{
+		ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		RoutingRules: []models.RoutingRule{
+			{ID: 1, Paths: "/webhook", Methods: "POST", Headers: "X-GitHub-Event", Query: "ref=main", RemoteIPs: "140.82.112.0/20", Upstreams: "ci:8080", Enabled: true},
+			{ID: 2, Headers: "User-Agent: *My App*", Action: RuleActionRespond, Body: "Use the web app", StatusCode: 403, Enabled: true},
+			{ID: 3, Name: "broken", Paths: "nope", Upstreams: "a:1", Enabled: true},
+		},
+	}}, CaddyfileOptions{})
+
+	for _, want := range []string{
+		"\t@rule1 {\n\t\tpath /webhook\n\t\tmethod POST\n\t\theader X-Github-Event *\n\t\tquery ref=main\n\t\tremote_ip 140.82.112.0/20\n\t}\n\thandle @rule1 {\n\t\treverse_proxy ci:8080 {\n",
+		"\t@rule2 {\n\t\theader User-Agent \"*My App*\"\n\t}\n\thandle @rule2 {\n\t\trespond \"Use the web app\" 403\n\t}\n",
+		"\t# NOTE: routing rule \"broken\" is invalid and not exported",
+		"\thandle {\n\t\treverse_proxy app:80 {\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
@@ -0,0 +1,472 @@
+package caddy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// snapshotKeep is how many unnamed snapshots are kept; named snapshots are never rotated.
+const snapshotKeep = 50
+
+// ErrSnapshotNoState is returned when rolling back to a snapshot without stored database state.
+var ErrSnapshotNoState = errors.New("snapshot has no stored database state")
+
+// ChangeInfo describes who triggered a config change and why. It is recorded on snapshots.
+type ChangeInfo struct {
+	Actor  string
+	Reason string
+}
+
+type changeInfoKey struct{}
+
+// WithChangeInfo attaches change metadata to ctx for the snapshot taken by ApplyConfig.
+func WithChangeInfo(ctx context.Context, info ChangeInfo) context.Context {
+	return context.WithValue(ctx, changeInfoKey{}, info)
+}
+
+func changeInfoFrom(ctx context.Context) ChangeInfo {
+	info, _ := ctx.Value(changeInfoKey{}).(ChangeInfo)
+	return info
+}
+
+// SnapshotState is the database state stored with a snapshot. Sections that
+// are nil (snapshots taken before they were stored) are left alone on restore.
+type SnapshotState struct {
+	ProxyHosts         []models.ProxyHost         `json:"proxy_hosts"`
+	AccessLists        []models.AccessList        `json:"access_lists"`
+	ClientCAs          []models.ClientCA          `json:"client_cas"`
+	RedirectionHosts   []models.RedirectionHost   `json:"redirection_hosts"`
+	Streams            []models.Stream            `json:"streams"`
+	ErrorPages         []models.ErrorPage         `json:"error_pages"`
+	MaintenanceWindows []models.MaintenanceWindow `json:"maintenance_windows"`
+	StaticSites        []models.StaticSite        `json:"static_sites"`
+	// Settings holds the snapshotSettingKeys that are set
+	Settings []models.Setting `json:"settings"`
+}
+
+// snapshotSettingKeys are the settings the generated config depends on that
+// are stored with snapshots.
+var snapshotSettingKeys = []string{
+	OnDemandTLSEnabledSettingKey,
+	OnDemandTLSDomainsSettingKey,
+	OnDemandTLSRateLimitSettingKey,
+	ProxyProtocolEnabledSettingKey,
+	ProxyProtocolTrustedSettingKey,
+}
+
+// exportState reads the state that GenerateConfig's output depends on and that rollback restores.
+func (m *Manager) exportState() (*SnapshotState, error) {
+	state := &SnapshotState{
+		ProxyHosts:         []models.ProxyHost{},
+		AccessLists:        []models.AccessList{},
+		ClientCAs:          []models.ClientCA{},
+		RedirectionHosts:   []models.RedirectionHost{},
+		Streams:            []models.Stream{},
+		ErrorPages:         []models.ErrorPage{},
+		MaintenanceWindows: []models.MaintenanceWindow{},
+		StaticSites:        []models.StaticSite{},
+		Settings:           []models.Setting{},
+	}
+	if err := m.db.Preload("Locations").Order("id").Find(&state.ProxyHosts).Error; err != nil {
+		return nil, fmt.Errorf("export proxy hosts: %w", err)
+	}
+	attachHostChildren(m.db, state.ProxyHosts)
+	if err := m.db.Order("id").Find(&state.AccessLists).Error; err != nil {
+		return nil, fmt.Errorf("export access lists: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.ClientCAs).Error; err != nil {
+		return nil, fmt.Errorf("export client CAs: %w", err)
+	}
+	if err := m.db.Where("key IN ?", snapshotSettingKeys).Order("key").Find(&state.Settings).Error; err != nil {
+		return nil, fmt.Errorf("export settings: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.RedirectionHosts).Error; err != nil {
+		return nil, fmt.Errorf("export redirection hosts: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.Streams).Error; err != nil {
+		return nil, fmt.Errorf("export streams: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.ErrorPages).Error; err != nil {
+		return nil, fmt.Errorf("export error pages: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.MaintenanceWindows).Error; err != nil {
+		return nil, fmt.Errorf("export maintenance windows: %w", err)
+	}
+	if err := m.db.Order("id").Find(&state.StaticSites).Error; err != nil {
+		return nil, fmt.Errorf("export static sites: %w", err)
+	}
+	return state, nil
+}
+
+// recordSnapshot stores config and the current database state as a snapshot.
+// Unnamed snapshots identical to the latest one are not stored again.
+func (m *Manager) recordSnapshot(ctx context.Context, config *Config, name string) (*models.ConfigSnapshot, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	state, err := m.exportState()
+	if err != nil {
+		return nil, err
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("marshal state: %w", err)
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256(configJSON))
+
+	if name == "" {
+		var latest models.ConfigSnapshot
+		if err := m.db.Order("id desc").First(&latest).Error; err == nil && latest.ConfigHash == hash && latest.State == string(stateJSON) {
+			return &latest, nil
+		}
+	}
+
+	info := changeInfoFrom(ctx)
+	snap := &models.ConfigSnapshot{
+		UUID:       uuid.NewString(),
+		Name:       name,
+		Actor:      info.Actor,
+		Reason:     info.Reason,
+		ConfigHash: hash,
+		Config:     string(configJSON),
+		State:      string(stateJSON),
+	}
+	if err := m.db.Create(snap).Error; err != nil {
+		return nil, fmt.Errorf("save snapshot: %w", err)
+	}
+
+	// Rotate unnamed snapshots
+	var stale []uint
+	if err := m.db.Model(&models.ConfigSnapshot{}).Where("name = ?", "").Order("id desc").Offset(snapshotKeep).Pluck("id", &stale).Error; err == nil && len(stale) > 0 {
+		if err := m.db.Delete(&models.ConfigSnapshot{}, stale).Error; err != nil {
+			logger.Log().WithError(err).Warn("failed to rotate config snapshots")
+		}
+	}
+
+	return snap, nil
+}
+
+// ListSnapshots returns snapshot metadata, newest first.
+func (m *Manager) ListSnapshots() ([]models.ConfigSnapshot, error) {
+	var snaps []models.ConfigSnapshot
+	if err := m.db.Omit("config", "state").Order("id desc").Find(&snaps).Error; err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+// GetSnapshot returns a snapshot and its decoded config.
+func (m *Manager) GetSnapshot(id uint) (*models.ConfigSnapshot, *Config, error) {
+	var snap models.ConfigSnapshot
+	if err := m.db.First(&snap, id).Error; err != nil {
+		return nil, nil, err
+	}
+	var config Config
+	if err := json.Unmarshal([]byte(snap.Config), &config); err != nil {
+		return nil, nil, fmt.Errorf("decode snapshot config: %w", err)
+	}
+	return &snap, &config, nil
+}
+
+// CreateSnapshot stores the running config and current database state under name.
+func (m *Manager) CreateSnapshot(ctx context.Context, name string) (*models.ConfigSnapshot, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	config, err := m.client.GetConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch running config: %w", err)
+	}
+	return m.recordSnapshot(ctx, config, name)
+}
+
+// DiffSnapshot diffs snapshot id against snapshot againstID, or against the
+// running config when againstID is 0. The result shows what rolling back to id would change.
+func (m *Manager) DiffSnapshot(ctx context.Context, id, againstID uint) (*ConfigDiff, error) {
+	_, target, err := m.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *Config
+	if againstID == 0 {
+		if base, err = m.client.GetConfig(ctx); err != nil {
+			return nil, fmt.Errorf("fetch running config: %w", err)
+		}
+	} else if _, base, err = m.GetSnapshot(againstID); err != nil {
+		return nil, err
+	}
+	return DiffConfigs(base, target), nil
+}
+
+// RollbackToSnapshot restores the database state stored with a snapshot and applies
+// the config generated from it. If the apply fails the previous database state is put back.
+func (m *Manager) RollbackToSnapshot(ctx context.Context, id uint) error {
+	var snap models.ConfigSnapshot
+	if err := m.db.First(&snap, id).Error; err != nil {
+		return err
+	}
+	if snap.State == "" {
+		return ErrSnapshotNoState
+	}
+	var state SnapshotState
+	if err := json.Unmarshal([]byte(snap.State), &state); err != nil {
+		return fmt.Errorf("decode snapshot state: %w", err)
+	}
+
+	previous, err := m.exportState()
+	if err != nil {
+		return err
+	}
+	if err := m.restoreState(&state); err != nil {
+		return fmt.Errorf("restore snapshot state: %w", err)
+	}
+
+	info := changeInfoFrom(ctx)
+	info.Reason = fmt.Sprintf("rollback to snapshot %d", snap.ID)
+	if err := m.ApplyConfig(WithChangeInfo(ctx, info)); err != nil {
+		if restoreErr := m.restoreState(previous); restoreErr != nil {
+			return fmt.Errorf("apply snapshot: %w; restoring previous database state also failed: %v", err, restoreErr)
+		}
+		return fmt.Errorf("apply snapshot: %w", err)
+	}
+	return nil
+}
+
+// restoreState replaces the rows a SnapshotState holds with state, keeping IDs
+// so that uptime monitors and other references stay attached. Rows that belong
+// to proxy hosts the restore drops are deleted with them.
+func (m *Manager) restoreState(state *SnapshotState) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		all := tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		if state.ClientCAs != nil {
+			if err := all.Delete(&models.ClientCA{}).Error; err != nil {
+				return err
+			}
+			for i := range state.ClientCAs {
+				if err := insertExact(tx, &state.ClientCAs[i]); err != nil {
+					return fmt.Errorf("restore client CA %s: %w", state.ClientCAs[i].UUID, err)
+				}
+			}
+		}
+
+		// Fail instead of silently dropping a certificate or client CA the snapshot relies on
+		for _, host := range state.ProxyHosts {
+			if host.CertificateID != nil {
+				if err := tx.Select("id").First(&models.SSLCertificate{}, *host.CertificateID).Error; err != nil {
+					return fmt.Errorf("host %s references certificate %d which no longer exists", host.UUID, *host.CertificateID)
+				}
+			}
+			if host.ClientCAID != nil {
+				if err := tx.Select("id").First(&models.ClientCA{}, *host.ClientCAID).Error; err != nil {
+					return fmt.Errorf("host %s references client CA %d which no longer exists", host.UUID, *host.ClientCAID)
+				}
+			}
+		}
+
+		if err := all.Delete(&models.Location{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&models.UpstreamGroup{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&models.RoutingRule{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&models.ProxyHost{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&models.AccessList{}).Error; err != nil {
+			return err
+		}
+
+		for i := range state.AccessLists {
+			if err := insertExact(tx, &state.AccessLists[i]); err != nil {
+				return fmt.Errorf("restore access list %s: %w", state.AccessLists[i].UUID, err)
+			}
+		}
+		hostIDs := make([]uint, 0, len(state.ProxyHosts))
+		for _, host := range state.ProxyHosts {
+			locations, groups, rules := host.Locations, host.UpstreamGroups, host.RoutingRules
+			host.Locations, host.UpstreamGroups, host.RoutingRules = nil, nil, nil
+			host.Certificate, host.AccessList, host.ClientCA = nil, nil, nil
+			if err := insertExact(tx, &host); err != nil {
+				return fmt.Errorf("restore proxy host %s: %w", host.UUID, err)
+			}
+			hostIDs = append(hostIDs, host.ID)
+			for i := range locations {
+				locations[i].ProxyHostID = host.ID
+				if err := insertExact(tx, &locations[i]); err != nil {
+					return fmt.Errorf("restore location %s: %w", locations[i].UUID, err)
+				}
+			}
+			for i := range groups {
+				groups[i].ProxyHostID = host.ID
+				if err := insertExact(tx, &groups[i]); err != nil {
+					return fmt.Errorf("restore upstream group %s: %w", groups[i].UUID, err)
+				}
+			}
+			for i := range rules {
+				rules[i].ProxyHostID = host.ID
+				if err := insertExact(tx, &rules[i]); err != nil {
+					return fmt.Errorf("restore routing rule %s: %w", rules[i].UUID, err)
+				}
+			}
+		}
+		if err := deleteDroppedHostRows(tx, hostIDs, state.Streams); err != nil {
+			return err
+		}
+
+		if state.RedirectionHosts != nil {
+			if err := all.Delete(&models.RedirectionHost{}).Error; err != nil {
+				return err
+			}
+			for i := range state.RedirectionHosts {
+				if err := insertExact(tx, &state.RedirectionHosts[i]); err != nil {
+					return fmt.Errorf("restore redirection host %s: %w", state.RedirectionHosts[i].UUID, err)
+				}
+			}
+		}
+		if state.Streams != nil {
+			if err := all.Delete(&models.Stream{}).Error; err != nil {
+				return err
+			}
+			for i := range state.Streams {
+				if err := insertExact(tx, &state.Streams[i]); err != nil {
+					return fmt.Errorf("restore stream %s: %w", state.Streams[i].UUID, err)
+				}
+			}
+		}
+		if state.ErrorPages != nil {
+			if err := all.Delete(&models.ErrorPage{}).Error; err != nil {
+				return err
+			}
+			for i := range state.ErrorPages {
+				if err := insertExact(tx, &state.ErrorPages[i]); err != nil {
+					return fmt.Errorf("restore error page %s: %w", state.ErrorPages[i].UUID, err)
+				}
+			}
+		}
+		if state.MaintenanceWindows != nil {
+			if err := all.Delete(&models.MaintenanceWindow{}).Error; err != nil {
+				return err
+			}
+			for i := range state.MaintenanceWindows {
+				if err := insertExact(tx, &state.MaintenanceWindows[i]); err != nil {
+					return fmt.Errorf("restore maintenance window %s: %w", state.MaintenanceWindows[i].UUID, err)
+				}
+			}
+		}
+		if state.StaticSites != nil {
+			// Releases are files on disk: sites keep the release they serve now,
+			// and sites deleted since the snapshot come back undeployed
+			var current []models.StaticSite
+			if err := tx.Find(&current).Error; err != nil {
+				return err
+			}
+			deployed := make(map[string]models.StaticSite, len(current))
+			for _, site := range current {
+				deployed[site.UUID] = site
+			}
+			if err := all.Delete(&models.StaticSite{}).Error; err != nil {
+				return err
+			}
+			for i := range state.StaticSites {
+				site := &state.StaticSites[i]
+				now := deployed[site.UUID]
+				site.CurrentRelease, site.PreviousRelease, site.DeployedAt = now.CurrentRelease, now.PreviousRelease, now.DeployedAt
+				if err := insertExact(tx, site); err != nil {
+					return fmt.Errorf("restore static site %s: %w", site.UUID, err)
+				}
+			}
+		}
+
+		if state.Settings != nil {
+			if err := tx.Where("key IN ?", snapshotSettingKeys).Delete(&models.Setting{}).Error; err != nil {
+				return err
+			}
+			for _, setting := range state.Settings {
+				setting.ID = 0
+				if err := tx.Create(&setting).Error; err != nil {
+					return fmt.Errorf("restore setting %s: %w", setting.Key, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// deleteDroppedHostRows deletes the maintenance windows, host error pages and
+// uptime monitors (with their heartbeats) of proxy hosts not in hostIDs, so a
+// restore that drops a host leaves nothing pointing at its ID. Uptime monitors
+// of streams not in streams go as well, unless streams is nil because the
+// snapshot predates them.
+func deleteDroppedHostRows(tx *gorm.DB, hostIDs []uint, streams []models.Stream) error {
+	dropped := func(q *gorm.DB) *gorm.DB {
+		q = q.Where("proxy_host_id IS NOT NULL")
+		if len(hostIDs) > 0 {
+			q = q.Where("proxy_host_id NOT IN ?", hostIDs)
+		}
+		return q
+	}
+	if err := dropped(tx).Delete(&models.MaintenanceWindow{}).Error; err != nil {
+		return fmt.Errorf("delete maintenance windows of dropped hosts: %w", err)
+	}
+	if err := dropped(tx).Delete(&models.ErrorPage{}).Error; err != nil {
+		return fmt.Errorf("delete error pages of dropped hosts: %w", err)
+	}
+	if err := deleteUptimeMonitors(tx, dropped(tx.Model(&models.UptimeMonitor{}))); err != nil {
+		return fmt.Errorf("delete uptime monitors of dropped hosts: %w", err)
+	}
+
+	if streams == nil {
+		return nil
+	}
+	q := tx.Model(&models.UptimeMonitor{}).Where("stream_id IS NOT NULL")
+	if len(streams) > 0 {
+		streamIDs := make([]uint, len(streams))
+		for i := range streams {
+			streamIDs[i] = streams[i].ID
+		}
+		q = q.Where("stream_id NOT IN ?", streamIDs)
+	}
+	if err := deleteUptimeMonitors(tx, q); err != nil {
+		return fmt.Errorf("delete uptime monitors of dropped streams: %w", err)
+	}
+	return nil
+}
+
+// deleteUptimeMonitors deletes the uptime monitors matched by query and their heartbeats.
+func deleteUptimeMonitors(tx, query *gorm.DB) error {
+	var monitorIDs []string
+	if err := query.Pluck("id", &monitorIDs).Error; err != nil {
+		return err
+	}
+	if len(monitorIDs) == 0 {
+		return nil
+	}
+	if err := tx.Where("monitor_id IN ?", monitorIDs).Delete(&models.UptimeHeartbeat{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", monitorIDs).Delete(&models.UptimeMonitor{}).Error
+}
+
+// insertExact inserts v with its ID and then writes every field again, because
+// Create replaces zero values with column defaults (enabled=false would become true).
+func insertExact[T any](tx *gorm.DB, v *T) error {
+	exact := *v
+	if err := tx.Create(v).Error; err != nil {
+		return err
+	}
+	return tx.Save(&exact).Error
+}
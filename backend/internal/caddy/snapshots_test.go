@@ -0,0 +1,541 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// fakeCaddyAdmin stands in for Caddy's admin API: /load stores the config, GET /config/ returns it,
+// and /id/<id> and PUT .../routes/<n> change single routes of the stored config.
+type fakeCaddyAdmin struct {
+	mu       sync.Mutex
+	current  []byte
+	failLoad bool
+	// failRoutes makes single-route requests fail
+	failRoutes bool
+	// requests records "METHOD path" for every request
+	requests []string
+}
+
+func (f *fakeCaddyAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.URL.Path == "/load" && r.Method == http.MethodPost:
+		if f.failLoad {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.current, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Path == "/config/" && r.Method == http.MethodGet:
+		if f.current == nil {
+			_, _ = w.Write([]byte("null"))
+			return
+		}
+		_, _ = w.Write(f.current)
+	case strings.HasPrefix(r.URL.Path, "/id/") || strings.HasPrefix(r.URL.Path, "/config/apps/http/servers/"):
+		if f.failRoutes || !f.changeRoute(r) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeCaddyAdmin) changeRoute(r *http.Request) bool {
+	var cfg Config
+	if f.current == nil || json.Unmarshal(f.current, &cfg) != nil || cfg.Apps.HTTP == nil {
+		return false
+	}
+	var route Route
+	if r.Method != http.MethodDelete {
+		if json.NewDecoder(r.Body).Decode(&route) != nil {
+			return false
+		}
+	}
+
+	done := false
+	if id := strings.TrimPrefix(r.URL.Path, "/id/"); id != r.URL.Path {
+		for _, srv := range cfg.Apps.HTTP.Servers {
+			for i, existing := range srv.Routes {
+				if existing.ID != id {
+					continue
+				}
+				switch r.Method {
+				case http.MethodPatch:
+					srv.Routes[i] = &route
+					done = true
+				case http.MethodDelete:
+					srv.Routes = append(srv.Routes[:i], srv.Routes[i+1:]...)
+					done = true
+				}
+				break
+			}
+		}
+	} else if r.Method == http.MethodPut {
+		// .../servers/<name>/routes/<index>
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/config/apps/http/servers/"), "/")
+		if len(parts) != 3 || parts[1] != "routes" {
+			return false
+		}
+		idx, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return false
+		}
+		srv := cfg.Apps.HTTP.Servers[parts[0]]
+		if srv == nil || idx > len(srv.Routes) {
+			return false
+		}
+		srv.Routes = append(srv.Routes[:idx], append([]*Route{&route}, srv.Routes[idx:]...)...)
+		done = true
+	}
+	if done {
+		f.current, _ = json.Marshal(cfg)
+	}
+	return done
+}
+
+// takeRequests returns the recorded requests and resets the log.
+func (f *fakeCaddyAdmin) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reqs := f.requests
+	f.requests = nil
+	return reqs
+}
+
+func setupSnapshotManager(t *testing.T) (*Manager, *gorm.DB, *fakeCaddyAdmin) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.AccessList{}, &models.Setting{}, &models.CaddyConfig{}, &models.SSLCertificate{}, &models.ClientCA{}, &models.ConfigSnapshot{}, &models.RedirectionHost{},
+		&models.Stream{}, &models.StaticSite{}, &models.MaintenanceWindow{}, &models.ErrorPage{}, &models.UptimeMonitor{}, &models.UptimeHeartbeat{}))
+
+	admin := &fakeCaddyAdmin{}
+	srv := httptest.NewServer(admin)
+	t.Cleanup(srv.Close)
+	return NewManager(NewClient(srv.URL), db, t.TempDir(), "", false, config.SecurityConfig{}), db, admin
+}
+
+func TestManager_ApplyConfigRecordsSnapshots(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+
+	ctx := WithChangeInfo(context.Background(), ChangeInfo{Actor: "1", Reason: "POST /api/v1/proxy-hosts"})
+	require.NoError(t, manager.ApplyConfig(ctx))
+	// Identical state is not recorded twice
+	require.NoError(t, manager.ApplyConfig(ctx))
+
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	assert.Equal(t, "1", snaps[0].Actor)
+	assert.Equal(t, "POST /api/v1/proxy-hosts", snaps[0].Reason)
+	assert.Empty(t, snaps[0].Config, "list omits the config body")
+
+	snap, cfg, err := manager.GetSnapshot(snaps[0].ID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, snap.State)
+	require.NotNil(t, cfg.Apps.HTTP)
+	assert.Len(t, cfg.Apps.HTTP.Servers["charon_server"].Routes, 1)
+
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err = manager.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+
+	diff, err := manager.DiffSnapshot(context.Background(), snaps[1].ID, snaps[0].ID)
+	require.NoError(t, err)
+	require.Len(t, diff.Routes, 1)
+	assert.Equal(t, DiffRemoved, diff.Routes[0].Action)
+	assert.Equal(t, "b.example.com", diff.Routes[0].Key)
+
+	// Against the running config, the latest snapshot has no changes
+	diff, err = manager.DiffSnapshot(context.Background(), snaps[0].ID, 0)
+	require.NoError(t, err)
+	assert.False(t, diff.Changed)
+}
+
+func TestManager_RollbackToSnapshot(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+
+	acl := models.AccessList{UUID: "acl", Name: "office", Type: "whitelist", IPRules: `[{"cidr":"10.0.0.0/8"}]`, Enabled: false}
+	require.NoError(t, db.Create(&acl).Error)
+	host := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, AccessListID: &acl.ID}
+	require.NoError(t, db.Create(&host).Error)
+	paused := models.ProxyHost{UUID: "p", DomainNames: "paused.example.com", ForwardHost: "p", ForwardPort: 80}
+	require.NoError(t, db.Create(&paused).Error)
+	require.NoError(t, db.Model(&paused).Update("enabled", false).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	good := snaps[0].ID
+
+	// Drift: edit, delete and add
+	require.NoError(t, db.Model(&host).Update("forward_port", 8080).Error)
+	require.NoError(t, db.Delete(&acl).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "new", DomainNames: "new.example.com", ForwardHost: "n", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	ctx := WithChangeInfo(context.Background(), ChangeInfo{Actor: "7"})
+	require.NoError(t, manager.RollbackToSnapshot(ctx, good))
+
+	var hosts []models.ProxyHost
+	require.NoError(t, db.Order("id").Find(&hosts).Error)
+	require.Len(t, hosts, 2)
+	assert.Equal(t, host.ID, hosts[0].ID)
+	assert.Equal(t, 80, hosts[0].ForwardPort)
+	assert.False(t, hosts[1].Enabled, "zero values survive the restore")
+
+	var restoredACL models.AccessList
+	require.NoError(t, db.First(&restoredACL, acl.ID).Error)
+	assert.False(t, restoredACL.Enabled)
+
+	// Caddy runs the restored config and the rollback is itself a snapshot
+	var running Config
+	require.NoError(t, json.Unmarshal(admin.current, &running))
+	// Only the enabled host is routed
+	assert.Len(t, running.Apps.HTTP.Servers["charon_server"].Routes, 1)
+	snaps, err = manager.ListSnapshots()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("rollback to snapshot %d", good), snaps[0].Reason)
+	assert.Equal(t, "7", snaps[0].Actor)
+}
+
+func TestManager_RollbackToSnapshot_RedirectionHosts(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+	redirect := models.RedirectionHost{UUID: "r", DomainNames: "old.example.com", ForwardDomainName: "new.example.com", ForwardHTTPCode: 301, Enabled: true}
+	require.NoError(t, db.Create(&redirect).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	good := snaps[0].ID
+
+	// Drift: disable the redirect and add another
+	require.NoError(t, db.Model(&redirect).Update("enabled", false).Error)
+	require.NoError(t, db.Create(&models.RedirectionHost{UUID: "r2", DomainNames: "other.example.com", ForwardDomainName: "new.example.com", Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	require.NoError(t, manager.RollbackToSnapshot(context.Background(), good))
+
+	var redirects []models.RedirectionHost
+	require.NoError(t, db.Find(&redirects).Error)
+	require.Len(t, redirects, 1)
+	assert.Equal(t, redirect.ID, redirects[0].ID)
+	assert.True(t, redirects[0].Enabled)
+
+	var running Config
+	require.NoError(t, json.Unmarshal(admin.current, &running))
+	routes := running.Apps.HTTP.Servers["charon_server"].Routes
+	var ids []string
+	for _, route := range routes {
+		ids = append(ids, route.ID)
+	}
+	assert.Contains(t, ids, RedirectRouteID("r"))
+	assert.NotContains(t, ids, RedirectRouteID("r2"))
+}
+
+func TestManager_RestoreStateStreams(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	stream := models.Stream{UUID: "mqtt", Name: "MQTT", ListenPort: 1883, Protocol: "tcp", Upstreams: "broker:1883", Enabled: true}
+	require.NoError(t, db.Create(&stream).Error)
+
+	state, err := manager.exportState()
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&stream).Update("upstreams", "other:1883").Error)
+	require.NoError(t, db.Create(&models.Stream{UUID: "ssh", ListenPort: 2222, Upstreams: "git:22", Enabled: true}).Error)
+
+	require.NoError(t, manager.restoreState(state))
+	var streams []models.Stream
+	require.NoError(t, db.Find(&streams).Error)
+	require.Len(t, streams, 1)
+	assert.Equal(t, stream.ID, streams[0].ID)
+	assert.Equal(t, "broker:1883", streams[0].Upstreams)
+
+	// Snapshots taken before streams were stored leave them alone
+	state.Streams = nil
+	require.NoError(t, db.Create(&models.Stream{UUID: "ssh", ListenPort: 2222, Upstreams: "git:22", Enabled: true}).Error)
+	require.NoError(t, manager.restoreState(state))
+	var count int64
+	db.Model(&models.Stream{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestManager_RollbackToSnapshot_StreamMonitors(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	mqtt := models.Stream{UUID: "mqtt", Name: "MQTT", ListenPort: 1883, Protocol: "tcp", Upstreams: "broker:1883", Enabled: true}
+	require.NoError(t, db.Create(&mqtt).Error)
+	require.NoError(t, db.Create(&models.UptimeMonitor{ID: "mqtt", StreamID: &mqtt.ID}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	good := snaps[0].ID
+
+	// Drift: add a stream with a monitor
+	ssh := models.Stream{UUID: "ssh", Name: "SSH", ListenPort: 2222, Protocol: "tcp", Upstreams: "git:22", Enabled: true}
+	require.NoError(t, db.Create(&ssh).Error)
+	require.NoError(t, db.Create(&models.UptimeMonitor{ID: "ssh", StreamID: &ssh.ID}).Error)
+	require.NoError(t, db.Create(&models.UptimeHeartbeat{MonitorID: "ssh", Status: "up"}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	require.NoError(t, manager.RollbackToSnapshot(context.Background(), good))
+
+	var monitors []models.UptimeMonitor
+	require.NoError(t, db.Find(&monitors).Error)
+	require.Len(t, monitors, 1, "the dropped stream's monitor is removed")
+	assert.Equal(t, "mqtt", monitors[0].ID)
+	var count int64
+	db.Model(&models.UptimeHeartbeat{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestManager_RestoreStateErrorPages(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	host := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+	hostPage := models.ErrorPage{UUID: "host", ProxyHostID: &host.ID, StatusCode: 502, Body: "down", Enabled: true}
+	require.NoError(t, db.Create(&hostPage).Error)
+
+	state, err := manager.exportState()
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&hostPage).Update("enabled", false).Error)
+	require.NoError(t, db.Create(&models.ErrorPage{UUID: "global", StatusCode: 404, Body: "missing", Enabled: true}).Error)
+
+	require.NoError(t, manager.restoreState(state))
+	var pages []models.ErrorPage
+	require.NoError(t, db.Find(&pages).Error)
+	require.Len(t, pages, 1)
+	assert.Equal(t, hostPage.ID, pages[0].ID)
+	assert.True(t, pages[0].Enabled)
+	require.NotNil(t, pages[0].ProxyHostID)
+	assert.Equal(t, host.ID, *pages[0].ProxyHostID)
+}
+
+func TestManager_RollbackToSnapshot_MaintenanceWindows(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+	host := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	good := snaps[0].ID
+
+	// Drift: put the host into maintenance
+	require.NoError(t, db.Create(&models.MaintenanceWindow{UUID: "mw", ProxyHostID: host.ID, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	assert.Contains(t, string(admin.current), "Down for maintenance")
+
+	require.NoError(t, manager.RollbackToSnapshot(context.Background(), good))
+	var count int64
+	db.Model(&models.MaintenanceWindow{}).Count(&count)
+	assert.Zero(t, count)
+	assert.NotContains(t, string(admin.current), "Down for maintenance")
+}
+
+func TestManager_RestoreStateStaticSites(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	docs := models.StaticSite{UUID: "docs", DomainNames: "docs.example.com", IndexFiles: "index.html", CurrentRelease: "r1", Enabled: true}
+	gone := models.StaticSite{UUID: "gone", DomainNames: "gone.example.com", IndexFiles: "index.html", CurrentRelease: "r1", Enabled: true}
+	require.NoError(t, db.Create(&docs).Error)
+	require.NoError(t, db.Create(&gone).Error)
+
+	state, err := manager.exportState()
+	require.NoError(t, err)
+	// Drift: change and redeploy docs, delete the other site, add a new one
+	require.NoError(t, db.Model(&docs).Updates(map[string]interface{}{"spa_fallback": true, "current_release": "r2", "previous_release": "r1"}).Error)
+	require.NoError(t, db.Delete(&gone).Error)
+	require.NoError(t, db.Create(&models.StaticSite{UUID: "new", DomainNames: "new.example.com", IndexFiles: "index.html", Enabled: true}).Error)
+
+	require.NoError(t, manager.restoreState(state))
+	var sites []models.StaticSite
+	require.NoError(t, db.Order("id").Find(&sites).Error)
+	require.Len(t, sites, 2)
+	assert.False(t, sites[0].SPAFallback)
+	assert.Equal(t, "r2", sites[0].CurrentRelease, "the deployed release stays")
+	assert.Equal(t, "r1", sites[0].PreviousRelease)
+	assert.Equal(t, "gone", sites[1].UUID)
+	assert.Empty(t, sites[1].CurrentRelease, "its files were deleted with it")
+}
+
+func TestManager_RollbackToSnapshot_ProxyProtocol(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	good := snaps[0].ID
+
+	// Drift: accept the PROXY protocol from a load balancer
+	require.NoError(t, db.Create(&models.Setting{Key: ProxyProtocolEnabledSettingKey, Value: "true"}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: ProxyProtocolTrustedSettingKey, Value: "10.0.0.0/8"}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	assert.Contains(t, string(admin.current), "proxy_protocol")
+
+	require.NoError(t, manager.RollbackToSnapshot(context.Background(), good))
+	assert.Empty(t, manager.setting(ProxyProtocolEnabledSettingKey))
+	assert.Empty(t, manager.setting(ProxyProtocolTrustedSettingKey))
+	assert.NotContains(t, string(admin.current), "proxy_protocol")
+}
+
+func TestManager_RestoreStateLocations(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	host := models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true,
+		Locations:      []models.Location{{UUID: "loc", Path: "/api", ForwardHost: "api", ForwardPort: 9000}},
+		UpstreamGroups: []models.UpstreamGroup{{UUID: "canary", Name: "canary", Upstreams: "a-v2:80", Weight: 10}}}
+	require.NoError(t, db.Create(&host).Error)
+
+	state, err := manager.exportState()
+	require.NoError(t, err)
+	require.NoError(t, db.Where("proxy_host_id = ?", host.ID).Delete(&models.Location{}).Error)
+	require.NoError(t, db.Model(&models.UpstreamGroup{}).Where("proxy_host_id = ?", host.ID).Update("weight", 100).Error)
+
+	require.NoError(t, manager.restoreState(state))
+	var restored models.ProxyHost
+	require.NoError(t, db.Preload("Locations").Preload("UpstreamGroups").First(&restored, host.ID).Error)
+	require.Len(t, restored.Locations, 1)
+	assert.Equal(t, "/api", restored.Locations[0].Path)
+	require.Len(t, restored.UpstreamGroups, 1)
+	assert.Equal(t, 10, restored.UpstreamGroups[0].Weight)
+}
+
+func TestManager_RestoreStateClientCAsSettingsAndDroppedHosts(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	ca := models.ClientCA{UUID: "ca", Name: "corp", Certificate: "pem"}
+	require.NoError(t, db.Create(&ca).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, ClientCAID: &ca.ID}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: OnDemandTLSEnabledSettingKey, Value: "true"}).Error)
+
+	state, err := manager.exportState()
+	require.NoError(t, err)
+
+	// Drift: rename the CA, change settings and add a host with child rows
+	require.NoError(t, db.Model(&ca).Update("name", "renamed").Error)
+	require.NoError(t, db.Model(&models.Setting{}).Where("key = ?", OnDemandTLSEnabledSettingKey).Update("value", "false").Error)
+	require.NoError(t, db.Create(&models.Setting{Key: OnDemandTLSDomainsSettingKey, Value: "*.example.com"}).Error)
+	dropped := models.ProxyHost{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&dropped).Error)
+	require.NoError(t, db.Create(&models.MaintenanceWindow{UUID: "mw", ProxyHostID: dropped.ID}).Error)
+	require.NoError(t, db.Create(&models.ErrorPage{UUID: "ep", ProxyHostID: &dropped.ID, StatusCode: 502}).Error)
+	require.NoError(t, db.Create(&models.ErrorPage{UUID: "global", StatusCode: 404}).Error)
+	require.NoError(t, db.Create(&models.UptimeMonitor{ID: "mon", ProxyHostID: &dropped.ID}).Error)
+	require.NoError(t, db.Create(&models.UptimeHeartbeat{MonitorID: "mon", Status: "up"}).Error)
+
+	// As in snapshots taken before error pages and maintenance windows were
+	// stored, so only the dropped host's rows go
+	state.ErrorPages, state.MaintenanceWindows = nil, nil
+	require.NoError(t, manager.restoreState(state))
+
+	var restoredCA models.ClientCA
+	require.NoError(t, db.First(&restoredCA, ca.ID).Error)
+	assert.Equal(t, "corp", restoredCA.Name)
+	var setting models.Setting
+	require.NoError(t, db.Where("key = ?", OnDemandTLSEnabledSettingKey).First(&setting).Error)
+	assert.Equal(t, "true", setting.Value)
+	assert.Error(t, db.Where("key = ?", OnDemandTLSDomainsSettingKey).First(&models.Setting{}).Error, "settings unset in the snapshot are removed")
+
+	var count int64
+	db.Model(&models.MaintenanceWindow{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.UptimeMonitor{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.UptimeHeartbeat{}).Count(&count)
+	assert.Zero(t, count)
+	var pages []models.ErrorPage
+	require.NoError(t, db.Find(&pages).Error)
+	require.Len(t, pages, 1, "the global error page is kept")
+	assert.Equal(t, "global", pages[0].UUID)
+
+	// Snapshots taken before client CAs and settings were stored leave them alone
+	state.ClientCAs, state.Settings = nil, nil
+	require.NoError(t, db.Model(&ca).Update("name", "renamed").Error)
+	require.NoError(t, manager.restoreState(state))
+	require.NoError(t, db.First(&restoredCA, ca.ID).Error)
+	assert.Equal(t, "renamed", restoredCA.Name)
+}
+
+func TestManager_RollbackToSnapshot_Failures(t *testing.T) {
+	manager, db, admin := setupSnapshotManager(t)
+
+	cert := models.SSLCertificate{UUID: "c", Name: "c", Provider: "custom"}
+	require.NoError(t, db.Create(&cert).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true, CertificateID: &cert.ID}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err := manager.ListSnapshots()
+	require.NoError(t, err)
+	withCert := snaps[0].ID
+
+	// Missing referenced certificate: nothing changes
+	require.NoError(t, db.Model(&models.ProxyHost{}).Where("uuid = ?", "a").Update("certificate_id", nil).Error)
+	require.NoError(t, db.Delete(&cert).Error)
+	err = manager.RollbackToSnapshot(context.Background(), withCert)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+	var count int64
+	db.Model(&models.ProxyHost{}).Where("certificate_id IS NULL").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Caddy rejects the config: previous DB state is put back
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "b", DomainNames: "b.example.com", ForwardHost: "b", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+	snaps, err = manager.ListSnapshots()
+	require.NoError(t, err)
+	twoHosts := snaps[0].ID
+	require.NoError(t, db.Where("uuid = ?", "b").Delete(&models.ProxyHost{}).Error)
+
+	admin.failLoad = true
+	require.Error(t, manager.RollbackToSnapshot(context.Background(), twoHosts))
+	db.Model(&models.ProxyHost{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Snapshot without state
+	legacy := models.ConfigSnapshot{UUID: "legacy", Config: "{}"}
+	require.NoError(t, db.Create(&legacy).Error)
+	assert.ErrorIs(t, manager.RollbackToSnapshot(context.Background(), legacy.ID), ErrSnapshotNoState)
+	assert.ErrorIs(t, manager.RollbackToSnapshot(context.Background(), 9999), gorm.ErrRecordNotFound)
+}
+
+func TestManager_CreateSnapshotAndRotation(t *testing.T) {
+	manager, db, _ := setupSnapshotManager(t)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "a", DomainNames: "a.example.com", ForwardHost: "a", ForwardPort: 80, Enabled: true}).Error)
+	require.NoError(t, manager.ApplyConfig(context.Background()))
+
+	named, err := manager.CreateSnapshot(context.Background(), "before migration")
+	require.NoError(t, err)
+	assert.Equal(t, "before migration", named.Name)
+	_, err = manager.CreateSnapshot(context.Background(), "")
+	assert.Error(t, err)
+
+	for i := 0; i < snapshotKeep+5; i++ {
+		require.NoError(t, db.Model(&models.ProxyHost{}).Where("uuid = ?", "a").Update("forward_port", 1000+i).Error)
+		require.NoError(t, manager.ApplyConfig(context.Background()))
+	}
+
+	var unnamed, total int64
+	db.Model(&models.ConfigSnapshot{}).Where("name = ?", "").Count(&unnamed)
+	db.Model(&models.ConfigSnapshot{}).Count(&total)
+	assert.Equal(t, int64(snapshotKeep), unnamed)
+	assert.Equal(t, int64(snapshotKeep+1), total, "named snapshot is kept")
+}
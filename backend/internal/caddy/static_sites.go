@@ -0,0 +1,125 @@
+package caddy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// StaticSiteRouteID is the @id of a static site's route.
+func StaticSiteRouteID(siteUUID string) string {
+	return "charon-site-" + siteUUID
+}
+
+// StaticSiteRoot is the directory a site is served from: a symlink to its
+// current release, so that a deploy switches versions without a config change.
+func StaticSiteRoot(sitesDir, siteUUID string) string {
+	return filepath.Join(sitesDir, siteUUID, "current")
+}
+
+// ValidateStaticSite checks the domains and index files of a site and fills in
+// the default index file.
+func ValidateStaticSite(site *models.StaticSite) error {
+	if len(SplitDomains(site.DomainNames)) == 0 {
+		return fmt.Errorf("domain_names is required")
+	}
+	if strings.TrimSpace(site.IndexFiles) == "" {
+		site.IndexFiles = "index.html"
+	}
+	for _, name := range splitList(site.IndexFiles) {
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return fmt.Errorf("index file %q must be a file name", name)
+		}
+	}
+	return nil
+}
+
+// StaticSiteHandlers serves root with file_server. Single-page apps get the
+// first index file for paths that match no file or directory, like try_files.
+func StaticSiteHandlers(site *models.StaticSite, root string) []Handler {
+	indexNames := splitList(site.IndexFiles)
+	if len(indexNames) == 0 {
+		indexNames = []string{"index.html"}
+	}
+
+	var handlers []Handler
+	if site.SPAFallback {
+		handlers = append(handlers, Handler{
+			"handler": "subroute",
+			"routes": []map[string]interface{}{{
+				"match": []map[string]interface{}{{
+					"file": map[string]interface{}{
+						"root":      root,
+						"try_files": []string{"{http.request.uri.path}", "{http.request.uri.path}/", "/" + indexNames[0]},
+					},
+				}},
+				"handle": []map[string]interface{}{{
+					"handler": "rewrite",
+					"uri":     "{http.matchers.file.relative}",
+				}},
+			}},
+		})
+	}
+
+	fileServer := Handler{
+		"handler":     "file_server",
+		"root":        root,
+		"index_names": indexNames,
+	}
+	if site.Browse {
+		fileServer["browse"] = map[string]interface{}{}
+	}
+	if site.Precompressed {
+		fileServer["precompressed"] = map[string]interface{}{"br": map[string]interface{}{}, "zstd": map[string]interface{}{}, "gzip": map[string]interface{}{}}
+		fileServer["precompressed_order"] = []string{"br", "zstd", "gzip"}
+	}
+	return append(handlers, fileServer)
+}
+
+// AddStaticSites adds a route for every enabled, deployed static site to the
+// Charon server, ahead of the catch-all route. Domains that are already routed
+// keep their route; among static sites the newest wins.
+func AddStaticSites(config *Config, sites []models.StaticSite, sitesDir string) {
+	if config == nil || len(sites) == 0 {
+		return
+	}
+	// Caddy resolves relative paths against its own working directory
+	if abs, err := filepath.Abs(sitesDir); err == nil {
+		sitesDir = abs
+	}
+	routed := routedDomains(config)
+
+	var routes []*Route
+	for i := len(sites) - 1; i >= 0; i-- {
+		site := sites[i]
+		if !site.Enabled || site.CurrentRelease == "" {
+			continue
+		}
+		if err := ValidateStaticSite(&site); err != nil {
+			logger.Log().WithField("static_site", site.UUID).WithError(err).Warn("Skipping invalid static site")
+			continue
+		}
+		var domains []string
+		for _, d := range SplitDomains(site.DomainNames) {
+			if routed[d] {
+				logger.Log().WithField("domain", d).WithField("static_site", site.UUID).Warn("Skipping static site for domain that is already routed")
+				continue
+			}
+			routed[d] = true
+			domains = append(domains, d)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+		routes = append(routes, &Route{
+			ID:       StaticSiteRouteID(site.UUID),
+			Match:    []Match{{Host: domains}},
+			Handle:   StaticSiteHandlers(&site, StaticSiteRoot(sitesDir, site.UUID)),
+			Terminal: true,
+		})
+	}
+	addRoutesBeforeCatchAll(config, routes)
+}
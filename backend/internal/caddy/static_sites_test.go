@@ -0,0 +1,60 @@
+package caddy
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateStaticSite(t *testing.T) {
+	site := models.StaticSite{DomainNames: "docs.example.com"}
+	require.NoError(t, ValidateStaticSite(&site))
+	assert.Equal(t, "index.html", site.IndexFiles)
+
+	assert.ErrorContains(t, ValidateStaticSite(&models.StaticSite{DomainNames: " , "}), "domain_names is required")
+	assert.ErrorContains(t, ValidateStaticSite(&models.StaticSite{DomainNames: "a.example.com", IndexFiles: "../index.html"}), "must be a file name")
+}
+
+func TestStaticSiteHandlers(t *testing.T) {
+	out, err := json.Marshal(StaticSiteHandlers(&models.StaticSite{IndexFiles: "index.html,index.htm", SPAFallback: true, Browse: true, Precompressed: true}, "/data/sites/s/current"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"handler": "subroute", "routes": [{
+			"match": [{"file": {"root": "/data/sites/s/current", "try_files": ["{http.request.uri.path}", "{http.request.uri.path}/", "/index.html"]}}],
+			"handle": [{"handler": "rewrite", "uri": "{http.matchers.file.relative}"}]
+		}]},
+		{"handler": "file_server", "root": "/data/sites/s/current", "index_names": ["index.html", "index.htm"], "browse": {},
+			"precompressed": {"br": {}, "zstd": {}, "gzip": {}}, "precompressed_order": ["br", "zstd", "gzip"]}
+	]`, string(out))
+
+	plain := StaticSiteHandlers(&models.StaticSite{}, "/srv")
+	assert.Equal(t, []Handler{{"handler": "file_server", "root": "/srv", "index_names": []string{"index.html"}}}, plain)
+}
+
+func TestAddStaticSites(t *testing.T) {
+	cfg, err := GenerateConfig([]models.ProxyHost{
+		{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true},
+	}, "/data/caddy/data", "", "/frontend", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	AddStaticSites(cfg, []models.StaticSite{
+		{UUID: "docs", DomainNames: "docs.example.com, app.example.com", CurrentRelease: "r1", Enabled: true},
+		{UUID: "new", DomainNames: "new.example.com", Enabled: true},
+		{UUID: "off", DomainNames: "off.example.com", CurrentRelease: "r1", Enabled: false},
+	}, "data/sites")
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 3)
+	assert.Equal(t, StaticSiteRouteID("docs"), routes[1].ID)
+	assert.Equal(t, []string{"docs.example.com"}, routes[1].Match[0].Host, "app.example.com stays with the proxy host")
+	root := routes[1].Handle[0]["root"].(string)
+	assert.True(t, filepath.IsAbs(root))
+	assert.True(t, strings.HasSuffix(root, filepath.Join("data", "sites", "docs", "current")), root)
+	assert.Empty(t, routes[2].Match, "the catch-all stays last")
+}
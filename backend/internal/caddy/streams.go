@@ -0,0 +1,214 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// TLS modes of a stream.
+const (
+	StreamTLSNone      = ""
+	StreamTLSSNI       = "sni"       // route by SNI and pass TLS through to the upstream
+	StreamTLSTerminate = "terminate" // route by SNI, terminate TLS and forward plaintext
+)
+
+// StreamRouteID is the @id of a stream's layer4 route.
+func StreamRouteID(streamUUID string) string {
+	return "charon-stream-" + streamUUID
+}
+
+// streamServerName names the layer4 server for a protocol and port.
+func streamServerName(protocol string, port int) string {
+	return fmt.Sprintf("stream_%s_%d", protocol, port)
+}
+
+// ValidateStream checks a stream on its own and fills in the default protocol.
+func ValidateStream(s *models.Stream) error {
+	if s.Protocol == "" {
+		s.Protocol = "tcp"
+	}
+	if s.Protocol != "tcp" && s.Protocol != "udp" {
+		return fmt.Errorf("protocol must be tcp or udp")
+	}
+	if s.ListenPort < 1 || s.ListenPort > 65535 {
+		return fmt.Errorf("listen_port must be between 1 and 65535")
+	}
+	upstreams := splitList(s.Upstreams)
+	if len(upstreams) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	for _, u := range upstreams {
+		if err := checkUpstreamAddress(u); err != nil {
+			return err
+		}
+	}
+
+	switch s.TLSMode {
+	case StreamTLSNone:
+		if s.ServerNames != "" {
+			return fmt.Errorf("server_names needs tls_mode sni or terminate")
+		}
+	case StreamTLSSNI, StreamTLSTerminate:
+		if s.Protocol == "udp" {
+			return fmt.Errorf("tls_mode %s is only available for tcp", s.TLSMode)
+		}
+		if len(SplitDomains(s.ServerNames)) == 0 {
+			return fmt.Errorf("server_names is required for tls_mode %s", s.TLSMode)
+		}
+		if s.TLSMode == StreamTLSTerminate {
+			for _, name := range SplitDomains(s.ServerNames) {
+				if strings.Contains(name, "*") {
+					return fmt.Errorf("server name %s: wildcards cannot be terminated; use tls_mode sni", name)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("tls_mode must be empty, sni or terminate")
+	}
+	return nil
+}
+
+// checkUpstreamAddress checks that u is a host:port or unix//path dial address.
+func checkUpstreamAddress(u string) error {
+	if _, ok := UnixSocketPath(u); ok {
+		return checkUnixSocket(u)
+	}
+	host, port, err := net.SplitHostPort(u)
+	if err != nil || host == "" {
+		return fmt.Errorf("upstream %q must be host:port", u)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("upstream %q has an invalid port", u)
+	}
+	return nil
+}
+
+// CheckStreamConflicts reports whether stream can listen next to the other
+// streams and to Caddy's and Charon's own ports. Streams may share a TCP port
+// only when every one of them routes by SNI, each with different names.
+func CheckStreamConflicts(stream models.Stream, others []models.Stream, opts ValidationOptions) error {
+	if stream.Protocol == "tcp" {
+		for _, p := range serverListenPorts {
+			if stream.ListenPort == p {
+				return fmt.Errorf("port %d/tcp is used by Caddy's HTTP server", p)
+			}
+		}
+		if opts.CharonPort != 0 && stream.ListenPort == opts.CharonPort {
+			return fmt.Errorf("port %d/tcp is used by Charon", stream.ListenPort)
+		}
+		if opts.AdminPort != 0 && stream.ListenPort == opts.AdminPort {
+			return fmt.Errorf("port %d/tcp is used by the Caddy admin API", stream.ListenPort)
+		}
+	} else if stream.ListenPort == 443 {
+		return fmt.Errorf("port 443/udp is used by Caddy for HTTP/3")
+	}
+
+	names := make(map[string]bool)
+	for _, n := range SplitDomains(stream.ServerNames) {
+		names[n] = true
+	}
+	for _, o := range others {
+		if o.ID == stream.ID || !o.Enabled || o.ListenPort != stream.ListenPort || o.Protocol != stream.Protocol {
+			continue
+		}
+		label := o.Name
+		if label == "" {
+			label = o.UUID
+		}
+		if stream.TLSMode == StreamTLSNone || o.TLSMode == StreamTLSNone {
+			return fmt.Errorf("port %d/%s is already used by stream %s", stream.ListenPort, stream.Protocol, label)
+		}
+		for _, n := range SplitDomains(o.ServerNames) {
+			if names[n] {
+				return fmt.Errorf("server name %s on port %d is already routed by stream %s", n, stream.ListenPort, label)
+			}
+		}
+	}
+	return nil
+}
+
+// StreamProxyHandler creates a layer4 proxy handler for the upstreams.
+func StreamProxyHandler(protocol string, upstreams []string) Handler {
+	list := make([]map[string]interface{}, 0, len(upstreams))
+	for _, u := range upstreams {
+		dial := u
+		if protocol == "udp" {
+			dial = "udp/" + u
+		}
+		list = append(list, map[string]interface{}{"dial": []string{dial}})
+	}
+	return Handler{"handler": "proxy", "upstreams": list}
+}
+
+// AddStreams compiles the enabled streams into the layer4 app, one server per
+// listen port and protocol. Names of streams that terminate TLS are added to
+// the certificates Caddy obtains automatically.
+func AddStreams(config *Config, streams []models.Stream) {
+	if config == nil {
+		return
+	}
+	servers := make(map[string]*Layer4Server)
+	plain := make(map[string]bool)
+	var automate []string
+
+	// Newest first, as for proxy hosts: its SNI route is matched first
+	for i := len(streams) - 1; i >= 0; i-- {
+		s := streams[i]
+		if !s.Enabled {
+			continue
+		}
+		if err := ValidateStream(&s); err != nil {
+			logger.Log().WithField("stream", s.UUID).WithError(err).Warn("Skipping invalid stream")
+			continue
+		}
+		name := streamServerName(s.Protocol, s.ListenPort)
+		if plain[name] {
+			logger.Log().WithField("stream", s.UUID).WithField("port", s.ListenPort).Warn("Skipping stream on a port already used by a plain stream")
+			continue
+		}
+		server := servers[name]
+		if server == nil {
+			server = &Layer4Server{Listen: []string{fmt.Sprintf("%s/:%d", s.Protocol, s.ListenPort)}}
+			servers[name] = server
+		}
+
+		route := &Layer4Route{ID: StreamRouteID(s.UUID)}
+		if s.TLSMode == StreamTLSNone {
+			if len(server.Routes) > 0 {
+				logger.Log().WithField("stream", s.UUID).WithField("port", s.ListenPort).Warn("Skipping plain stream on a port already used by other streams")
+				continue
+			}
+			plain[name] = true
+		} else {
+			serverNames := SplitDomains(s.ServerNames)
+			route.Match = []map[string]interface{}{{"tls": map[string]interface{}{"sni": serverNames}}}
+			if s.TLSMode == StreamTLSTerminate {
+				route.Handle = append(route.Handle, Handler{"handler": "tls"})
+				automate = append(automate, serverNames...)
+			}
+		}
+		route.Handle = append(route.Handle, StreamProxyHandler(s.Protocol, splitList(s.Upstreams)))
+		server.Routes = append(server.Routes, route)
+	}
+	if len(servers) == 0 {
+		return
+	}
+
+	config.Apps.Layer4 = &Layer4App{Servers: servers}
+	if len(automate) > 0 {
+		sort.Strings(automate)
+		if config.Apps.TLS == nil {
+			config.Apps.TLS = &TLSApp{}
+		}
+		if config.Apps.TLS.Certificates == nil {
+			config.Apps.TLS.Certificates = &CertificatesConfig{}
+		}
+		config.Apps.TLS.Certificates.Automate = append(config.Apps.TLS.Certificates.Automate, automate...)
+	}
+}
@@ -0,0 +1,117 @@
+package caddy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateStream(t *testing.T) {
+	s := models.Stream{ListenPort: 5432, Upstreams: "db:5432, [fd00::2]:5432"}
+	require.NoError(t, ValidateStream(&s))
+	assert.Equal(t, "tcp", s.Protocol)
+
+	tests := []struct {
+		stream models.Stream
+		err    string
+	}{
+		{models.Stream{Protocol: "sctp", ListenPort: 1, Upstreams: "a:1"}, "protocol must be tcp or udp"},
+		{models.Stream{ListenPort: 70000, Upstreams: "a:1"}, "listen_port"},
+		{models.Stream{ListenPort: 22}, "at least one upstream"},
+		{models.Stream{ListenPort: 22, Upstreams: "bastion"}, `upstream "bastion" must be host:port`},
+		{models.Stream{ListenPort: 22, Upstreams: "bastion:0"}, "invalid port"},
+		{models.Stream{ListenPort: 22, Upstreams: "a:1", ServerNames: "a.example.com"}, "server_names needs tls_mode"},
+		{models.Stream{Protocol: "udp", ListenPort: 53, Upstreams: "a:53", TLSMode: StreamTLSSNI, ServerNames: "a"}, "only available for tcp"},
+		{models.Stream{ListenPort: 8883, Upstreams: "a:1883", TLSMode: StreamTLSTerminate}, "server_names is required"},
+		{models.Stream{ListenPort: 8883, Upstreams: "a:1883", TLSMode: StreamTLSTerminate, ServerNames: "*.example.com"}, "wildcards cannot be terminated"},
+		{models.Stream{ListenPort: 8883, Upstreams: "a:1883", TLSMode: "mtls"}, "tls_mode must be"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, ValidateStream(&tt.stream), tt.err)
+	}
+}
+
+func TestCheckStreamConflicts(t *testing.T) {
+	opts := ValidationOptions{CharonPort: 8080, AdminPort: 2019}
+	others := []models.Stream{
+		{ID: 1, Name: "postgres", ListenPort: 5432, Protocol: "tcp", Enabled: true},
+		{ID: 2, Name: "mqtt", ListenPort: 8883, Protocol: "tcp", TLSMode: StreamTLSSNI, ServerNames: "mqtt.example.com", Enabled: true},
+		{ID: 3, Name: "old", ListenPort: 6000, Protocol: "tcp", Enabled: false},
+	}
+
+	check := func(s models.Stream) error { return CheckStreamConflicts(s, others, opts) }
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 443, Protocol: "tcp"}), "Caddy's HTTP server")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 443, Protocol: "udp"}), "HTTP/3")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 8080, Protocol: "tcp"}), "used by Charon")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 2019, Protocol: "tcp"}), "admin API")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 5432, Protocol: "tcp"}), "already used by stream postgres")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 8883, Protocol: "tcp"}), "already used by stream mqtt")
+	assert.ErrorContains(t, check(models.Stream{ListenPort: 8883, Protocol: "tcp", TLSMode: StreamTLSTerminate, ServerNames: "MQTT.example.com"}), "already routed by stream mqtt")
+
+	assert.NoError(t, check(models.Stream{ListenPort: 8883, Protocol: "tcp", TLSMode: StreamTLSTerminate, ServerNames: "broker.example.com"}))
+	assert.NoError(t, check(models.Stream{ListenPort: 5432, Protocol: "udp"}))
+	assert.NoError(t, check(models.Stream{ListenPort: 6000, Protocol: "tcp"}), "disabled streams do not listen")
+	assert.NoError(t, check(models.Stream{ID: 1, ListenPort: 5432, Protocol: "tcp"}), "a stream does not collide with itself")
+}
+
+func TestAddStreams(t *testing.T) {
+	cfg := &Config{}
+	AddStreams(cfg, []models.Stream{
+		{UUID: "pg", ListenPort: 5432, Protocol: "tcp", Upstreams: "db1:5432,db2:5432", Enabled: true},
+		{UUID: "dns", ListenPort: 53, Protocol: "udp", Upstreams: "resolver:53", Enabled: true},
+		{UUID: "mqtt", ListenPort: 8883, Protocol: "tcp", Upstreams: "mqtt:1883", TLSMode: StreamTLSTerminate, ServerNames: "mqtt.example.com", Enabled: true},
+		{UUID: "ssh", ListenPort: 8883, Protocol: "tcp", Upstreams: "bastion:22", TLSMode: StreamTLSSNI, ServerNames: "ssh.example.com", Enabled: true},
+		{UUID: "off", ListenPort: 9000, Protocol: "tcp", Upstreams: "x:1", Enabled: false},
+	})
+
+	require.NotNil(t, cfg.Apps.Layer4)
+	out, err := json.Marshal(cfg.Apps.Layer4)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"servers": {
+		"stream_tcp_5432": {"listen": ["tcp/:5432"], "routes": [
+			{"@id": "charon-stream-pg", "handle": [{"handler": "proxy", "upstreams": [{"dial": ["db1:5432"]}, {"dial": ["db2:5432"]}]}]}
+		]},
+		"stream_udp_53": {"listen": ["udp/:53"], "routes": [
+			{"@id": "charon-stream-dns", "handle": [{"handler": "proxy", "upstreams": [{"dial": ["udp/resolver:53"]}]}]}
+		]},
+		"stream_tcp_8883": {"listen": ["tcp/:8883"], "routes": [
+			{"@id": "charon-stream-ssh", "match": [{"tls": {"sni": ["ssh.example.com"]}}], "handle": [{"handler": "proxy", "upstreams": [{"dial": ["bastion:22"]}]}]},
+			{"@id": "charon-stream-mqtt", "match": [{"tls": {"sni": ["mqtt.example.com"]}}], "handle": [{"handler": "tls"}, {"handler": "proxy", "upstreams": [{"dial": ["mqtt:1883"]}]}]}
+		]}
+	}}`, string(out))
+	require.NotNil(t, cfg.Apps.TLS)
+	assert.Equal(t, []string{"mqtt.example.com"}, cfg.Apps.TLS.Certificates.Automate)
+
+	// Nothing enabled, no layer4 app
+	empty := &Config{}
+	AddStreams(empty, []models.Stream{{UUID: "off", ListenPort: 9000, Upstreams: "x:1"}})
+	assert.Nil(t, empty.Apps.Layer4)
+}
+
+func TestManager_Generate_StreamsNeedLayer4(t *testing.T) {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.Setting{}, &models.Stream{}))
+	require.NoError(t, db.Create(&models.Stream{UUID: "pg", ListenPort: 5432, Protocol: "tcp", Upstreams: "db:5432", Enabled: true}).Error)
+
+	manager := NewManager(nil, db, t.TempDir(), "", false, config.SecurityConfig{})
+	cfg, err := manager.generate(context.Background(), nil, true)
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Apps.Layer4)
+	assert.Contains(t, cfg.Apps.Layer4.Servers, "stream_tcp_5432")
+
+	manager.SetModules(ParseModuleList([]byte(listModulesOutput)))
+	cfg, err = manager.generate(context.Background(), nil, true)
+	require.NoError(t, err)
+	assert.Nil(t, cfg.Apps.Layer4)
+}
@@ -0,0 +1,325 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+)
+
+// States of the supervised Caddy process.
+const (
+	ProcessStopped   = "stopped"
+	ProcessStarting  = "starting"
+	ProcessRunning   = "running"
+	ProcessUnhealthy = "unhealthy"
+	ProcessBackoff   = "backoff"
+)
+
+// ProcessStatus describes the supervised Caddy process.
+type ProcessStatus struct {
+	Supervised     bool       `json:"supervised"`
+	State          string     `json:"state,omitempty"`
+	PID            int        `json:"pid,omitempty"`
+	Restarts       int        `json:"restarts"`
+	HealthFailures int        `json:"health_failures"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	LastExit       string     `json:"last_exit,omitempty"`
+	LastExitAt     *time.Time `json:"last_exit_at,omitempty"`
+	NextRestartAt  *time.Time `json:"next_restart_at,omitempty"`
+}
+
+// process is a started Caddy process.
+type process interface {
+	Pid() int
+	Wait() error
+	Signal(sig os.Signal) error
+	Kill() error
+}
+
+// Supervisor runs the Caddy binary as a child of Charon. It watches the admin
+// API, restarts Caddy with exponential backoff when it exits or stops
+// answering, and loads the last good config into every new process.
+type Supervisor struct {
+	manager *Manager
+	start   func() (process, error)
+
+	healthInterval time.Duration
+	startTimeout   time.Duration
+	stopTimeout    time.Duration
+	unhealthyAfter int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	stableAfter    time.Duration
+
+	mu     sync.Mutex
+	status ProcessStatus
+}
+
+// NewSupervisor creates a supervisor that runs `<binary> run --config <configDir>/bootstrap.json`.
+// The bootstrap config is empty; Charon loads the real one once the admin API answers.
+func NewSupervisor(manager *Manager, binary, configDir string) (*Supervisor, error) {
+	bootstrap := filepath.Join(configDir, "bootstrap.json")
+	if err := os.WriteFile(bootstrap, []byte(`{"apps":{}}`), 0o644); err != nil {
+		return nil, fmt.Errorf("write bootstrap config: %w", err)
+	}
+	s := newSupervisor(manager, func() (process, error) {
+		return startCaddy(binary, "run", "--config", bootstrap)
+	})
+	return s, nil
+}
+
+func newSupervisor(manager *Manager, start func() (process, error)) *Supervisor {
+	return &Supervisor{
+		manager:        manager,
+		start:          start,
+		healthInterval: 5 * time.Second,
+		startTimeout:   30 * time.Second,
+		stopTimeout:    10 * time.Second,
+		unhealthyAfter: 3,
+		minBackoff:     time.Second,
+		maxBackoff:     time.Minute,
+		stableAfter:    time.Minute,
+		status:         ProcessStatus{Supervised: true, State: ProcessStopped},
+	}
+}
+
+// Status returns a copy of the current process status.
+func (s *Supervisor) Status() ProcessStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *Supervisor) update(fn func(st *ProcessStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+}
+
+// Run starts Caddy and keeps it running until ctx is cancelled, then stops it.
+func (s *Supervisor) Run(ctx context.Context) {
+	backoff := s.minBackoff
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			s.update(func(st *ProcessStatus) {
+				st.State = ProcessStopped
+				st.PID = 0
+				st.NextRestartAt = nil
+			})
+			return
+		}
+
+		// A process that stayed up for a while gets a fresh backoff
+		if time.Since(started) >= s.stableAfter {
+			backoff = s.minBackoff
+		}
+		now := time.Now()
+		next := now.Add(backoff)
+		s.update(func(st *ProcessStatus) {
+			st.State = ProcessBackoff
+			st.PID = 0
+			st.Restarts++
+			st.LastExit = err.Error()
+			st.LastExitAt = &now
+			st.NextRestartAt = &next
+		})
+		logger.Log().WithError(err).WithField("backoff", backoff.String()).Warn("Caddy stopped, restarting")
+
+		select {
+		case <-ctx.Done():
+			s.update(func(st *ProcessStatus) {
+				st.State = ProcessStopped
+				st.NextRestartAt = nil
+			})
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// runOnce starts one Caddy process and watches it until it exits, fails its
+// health checks or ctx is cancelled. It always returns a non-nil error.
+func (s *Supervisor) runOnce(ctx context.Context) error {
+	proc, err := s.start()
+	if err != nil {
+		return fmt.Errorf("start caddy: %w", err)
+	}
+	now := time.Now()
+	s.update(func(st *ProcessStatus) {
+		st.State = ProcessStarting
+		st.PID = proc.Pid()
+		st.HealthFailures = 0
+		st.StartedAt = &now
+		st.NextRestartAt = nil
+	})
+	logger.Log().WithField("pid", proc.Pid()).Info("Started Caddy")
+
+	exited := make(chan error, 1)
+	go func() { exited <- proc.Wait() }()
+
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+	deadline := now.Add(s.startTimeout)
+	healthy := false
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			s.stop(proc, exited)
+			return ctx.Err()
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("caddy exited")
+			}
+			return err
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, s.healthInterval)
+		err := s.manager.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			failures = 0
+			s.update(func(st *ProcessStatus) {
+				st.State = ProcessRunning
+				st.HealthFailures = 0
+			})
+			if !healthy {
+				healthy = true
+				if err := s.manager.Reapply(ctx); err != nil {
+					logger.Log().WithError(err).Error("Failed to load config into restarted Caddy")
+				}
+			}
+			continue
+		}
+		if !healthy {
+			if time.Now().After(deadline) {
+				s.kill(proc, exited)
+				return fmt.Errorf("admin API not ready after %s: %w", s.startTimeout, err)
+			}
+			continue
+		}
+		failures++
+		s.update(func(st *ProcessStatus) {
+			st.State = ProcessUnhealthy
+			st.HealthFailures = failures
+		})
+		if failures >= s.unhealthyAfter {
+			s.kill(proc, exited)
+			return fmt.Errorf("health check failed %d times: %w", failures, err)
+		}
+	}
+}
+
+// stop asks Caddy to shut down gracefully and kills it after stopTimeout.
+func (s *Supervisor) stop(proc process, exited <-chan error) {
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		s.kill(proc, exited)
+		return
+	}
+	select {
+	case <-exited:
+	case <-time.After(s.stopTimeout):
+		s.kill(proc, exited)
+	}
+}
+
+func (s *Supervisor) kill(proc process, exited <-chan error) {
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		logger.Log().WithError(err).Warn("Failed to kill Caddy")
+	}
+	<-exited
+}
+
+// execProcess adapts exec.Cmd to process.
+type execProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p *execProcess) Pid() int                   { return p.cmd.Process.Pid }
+func (p *execProcess) Wait() error                { return p.cmd.Wait() }
+func (p *execProcess) Signal(sig os.Signal) error { return p.cmd.Process.Signal(sig) }
+func (p *execProcess) Kill() error                { return p.cmd.Process.Kill() }
+
+func startCaddy(binary string, args ...string) (process, error) {
+	cmd := exec.Command(binary, args...) // #nosec G204 -- binary comes from CHARON_CADDY_BINARY
+	cmd.Stdout = &caddyLogWriter{}
+	cmd.Stderr = &caddyLogWriter{}
+	cmd.SysProcAttr = childProcAttr()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &execProcess{cmd: cmd}, nil
+}
+
+// caddyLogWriter forwards Caddy's output to Charon's logger line by line.
+// Caddy logs JSON; its level and message are kept when a line parses.
+type caddyLogWriter struct {
+	buf []byte
+}
+
+var _ io.Writer = (*caddyLogWriter)(nil)
+
+func (w *caddyLogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		logCaddyLine(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func logCaddyLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	entry := logger.Log().WithField("source", "caddy")
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		entry.Info(line)
+		return
+	}
+	msg, _ := fields["msg"].(string)
+	levelName, _ := fields["level"].(string)
+	delete(fields, "msg")
+	delete(fields, "level")
+	delete(fields, "ts")
+	for k, v := range fields {
+		entry = entry.WithField("caddy_"+k, v)
+	}
+
+	level, err := logrus.ParseLevel(levelName)
+	if err != nil {
+		level = logrus.InfoLevel
+	} else if level < logrus.ErrorLevel {
+		// Caddy's fatal and panic must not take Charon down with them
+		level = logrus.ErrorLevel
+	}
+	entry.Log(level, msg)
+}
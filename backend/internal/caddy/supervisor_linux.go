@@ -0,0 +1,8 @@
+package caddy
+
+import "syscall"
+
+// childProcAttr makes the kernel stop Caddy if Charon dies without stopping it.
+func childProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
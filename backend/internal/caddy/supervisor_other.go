@@ -0,0 +1,9 @@
+//go:build !linux
+
+package caddy
+
+import "syscall"
+
+func childProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,168 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/config"
+)
+
+type fakeProcess struct {
+	pid  int
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newFakeProcess(pid int) *fakeProcess {
+	return &fakeProcess{pid: pid, done: make(chan struct{})}
+}
+
+func (p *fakeProcess) exit(err error) {
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+	})
+}
+
+func (p *fakeProcess) Pid() int    { return p.pid }
+func (p *fakeProcess) Wait() error { <-p.done; return p.err }
+func (p *fakeProcess) Signal(os.Signal) error {
+	p.exit(errors.New("signal: terminated"))
+	return nil
+}
+func (p *fakeProcess) Kill() error {
+	p.exit(errors.New("signal: killed"))
+	return nil
+}
+
+func TestSupervisor_RestartsAndReappliesConfig(t *testing.T) {
+	var healthy atomic.Bool
+	var loads atomic.Int32
+	healthy.Store(true)
+	caddyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/load" {
+			loads.Add(1)
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer caddyServer.Close()
+
+	manager := NewManager(NewClient(caddyServer.URL), nil, t.TempDir(), "", false, config.SecurityConfig{})
+	manager.lastApplied = &Config{Apps: Apps{}}
+
+	var mu sync.Mutex
+	var procs []*fakeProcess
+	starts := 0
+	sup := newSupervisor(manager, func() (process, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		starts++
+		if starts == 2 {
+			return nil, errors.New("exec: caddy: not found")
+		}
+		p := newFakeProcess(100 + starts)
+		procs = append(procs, p)
+		return p, nil
+	})
+	sup.healthInterval = 5 * time.Millisecond
+	sup.minBackoff = 5 * time.Millisecond
+	sup.maxBackoff = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sup.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return sup.Status().State == ProcessRunning }, time.Second, time.Millisecond)
+	assert.Equal(t, 101, sup.Status().PID)
+	require.Eventually(t, func() bool { return loads.Load() == 1 }, time.Second, time.Millisecond)
+
+	// A crash and a failed start are both restarted after a backoff
+	mu.Lock()
+	procs[0].exit(errors.New("exit status 1"))
+	mu.Unlock()
+	require.Eventually(t, func() bool {
+		st := sup.Status()
+		return st.State == ProcessRunning && st.PID == 103
+	}, time.Second, time.Millisecond)
+	status := sup.Status()
+	assert.Equal(t, 2, status.Restarts)
+	assert.Equal(t, "start caddy: exec: caddy: not found", status.LastExit)
+	require.Eventually(t, func() bool { return loads.Load() == 2 }, time.Second, time.Millisecond)
+
+	// A process that stops answering is killed and replaced
+	healthy.Store(false)
+	require.Eventually(t, func() bool { return sup.Status().Restarts == 3 }, time.Second, time.Millisecond)
+	assert.Contains(t, sup.Status().LastExit, "health check failed 3 times")
+	healthy.Store(true)
+	require.Eventually(t, func() bool { return sup.Status().State == ProcessRunning }, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+	status = sup.Status()
+	assert.Equal(t, ProcessStopped, status.State)
+	assert.Zero(t, status.PID)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range procs {
+		assert.Error(t, p.Wait(), "every process was stopped")
+	}
+}
+
+func TestSupervisor_AdminAPINeverReady(t *testing.T) {
+	manager := NewManager(NewClient("http://127.0.0.1:1"), nil, t.TempDir(), "", false, config.SecurityConfig{})
+	proc := newFakeProcess(7)
+	sup := newSupervisor(manager, func() (process, error) { return proc, nil })
+	sup.healthInterval = time.Millisecond
+	sup.startTimeout = 10 * time.Millisecond
+	sup.minBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sup.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return sup.Status().State == ProcessBackoff }, time.Second, time.Millisecond)
+	status := sup.Status()
+	assert.Contains(t, status.LastExit, "admin API not ready")
+	assert.EqualError(t, proc.Wait(), "signal: killed")
+	require.NotNil(t, status.NextRestartAt)
+
+	cancel()
+	<-done
+	assert.Equal(t, ProcessStopped, sup.Status().State)
+}
+
+func TestCaddyLogWriter(t *testing.T) {
+	w := &caddyLogWriter{}
+	chunk := []byte(`{"level":"info","ts":1,"logger":"tls","msg":"ready"}` + "\nplain ")
+	n, err := w.Write(chunk)
+	require.NoError(t, err)
+	assert.Equal(t, len(chunk), n)
+	assert.Equal(t, "plain ", string(w.buf))
+	_, _ = w.Write([]byte("text\n"))
+	assert.Empty(t, w.buf)
+
+	// Fatal lines from Caddy are logged, not acted on
+	logCaddyLine(`{"level":"fatal","msg":"boom"}`)
+	logCaddyLine(`{"level":"panic","msg":"boom"}`)
+}
@@ -0,0 +1,164 @@
+package caddy
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Client authentication modes accepted on a proxy host.
+const (
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify_if_given"
+)
+
+// clientAuthModes maps proxy host modes to Caddy's client_authentication modes.
+var clientAuthModes = map[string]string{
+	ClientAuthRequire:       "require_and_verify",
+	ClientAuthVerifyIfGiven: "verify_if_given",
+}
+
+// tlsVersions lists the protocol versions Caddy accepts, in ascending order.
+var tlsVersions = []string{"tls1.2", "tls1.3"}
+
+var tlsALPNProtocols = map[string]bool{"h3": true, "h2": true, "http/1.1": true}
+
+// ValidateTLSPolicy checks the TLS connection policy fields of a proxy host.
+// It does not check that the referenced client CA exists.
+func ValidateTLSPolicy(host *models.ProxyHost) error {
+	minIdx, maxIdx := -1, -1
+	for i, v := range tlsVersions {
+		if host.TLSMinVersion == v {
+			minIdx = i
+		}
+		if host.TLSMaxVersion == v {
+			maxIdx = i
+		}
+	}
+	if host.TLSMinVersion != "" && minIdx < 0 {
+		return fmt.Errorf("invalid tls_min_version %q: must be one of %s", host.TLSMinVersion, strings.Join(tlsVersions, ", "))
+	}
+	if host.TLSMaxVersion != "" && maxIdx < 0 {
+		return fmt.Errorf("invalid tls_max_version %q: must be one of %s", host.TLSMaxVersion, strings.Join(tlsVersions, ", "))
+	}
+	if minIdx >= 0 && maxIdx >= 0 && minIdx > maxIdx {
+		return fmt.Errorf("tls_min_version %s is higher than tls_max_version %s", host.TLSMinVersion, host.TLSMaxVersion)
+	}
+
+	if suites := splitList(host.TLSCipherSuites); len(suites) > 0 {
+		if host.TLSMinVersion == "tls1.3" {
+			return fmt.Errorf("tls_cipher_suites cannot be set when tls_min_version is tls1.3 (TLS 1.3 suites are not configurable)")
+		}
+		allowed := configurableCipherSuites()
+		for _, s := range suites {
+			if !allowed[s] {
+				return fmt.Errorf("unsupported cipher suite %q", s)
+			}
+		}
+	}
+
+	for _, p := range splitList(host.TLSALPN) {
+		if !tlsALPNProtocols[p] {
+			return fmt.Errorf("unsupported ALPN protocol %q: must be h3, h2 or http/1.1", p)
+		}
+	}
+
+	if host.ClientAuthMode != "" {
+		if _, ok := clientAuthModes[host.ClientAuthMode]; !ok {
+			return fmt.Errorf("invalid client_auth_mode %q: must be %s or %s", host.ClientAuthMode, ClientAuthRequire, ClientAuthVerifyIfGiven)
+		}
+		if host.ClientCAID == nil {
+			return fmt.Errorf("client_auth_mode %s requires a client CA (client_ca_id)", host.ClientAuthMode)
+		}
+	}
+	return nil
+}
+
+// configurableCipherSuites returns the secure TLS 1.2 cipher suites by name.
+func configurableCipherSuites() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, cs := range tls.CipherSuites() {
+		for _, v := range cs.SupportedVersions {
+			if v == tls.VersionTLS12 {
+				allowed[cs.Name] = true
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// buildTLSConnectionPolicy returns the TLS connection policy for a host, or nil
+// when the host keeps Caddy's defaults.
+func buildTLSConnectionPolicy(host *models.ProxyHost, domains []string) (*TLSConnectionPolicy, error) {
+	if host.TLSMinVersion == "" && host.TLSMaxVersion == "" && host.TLSCipherSuites == "" && host.TLSALPN == "" && host.ClientAuthMode == "" {
+		return nil, nil
+	}
+
+	policy := &TLSConnectionPolicy{
+		Match:        &TLSPolicyMatch{SNI: domains},
+		ProtocolMin:  host.TLSMinVersion,
+		ProtocolMax:  host.TLSMaxVersion,
+		CipherSuites: splitList(host.TLSCipherSuites),
+		ALPN:         splitList(host.TLSALPN),
+	}
+
+	if host.ClientAuthMode != "" {
+		mode, ok := clientAuthModes[host.ClientAuthMode]
+		if !ok {
+			return nil, fmt.Errorf("invalid client_auth_mode %q", host.ClientAuthMode)
+		}
+		// Never fall back to an unverified policy: without a CA the host would be open
+		if host.ClientCA == nil {
+			return nil, fmt.Errorf("client CA for host %s is missing", host.UUID)
+		}
+		certs, err := clientCACertsDER(host.ClientCA.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("client CA %q: %w", host.ClientCA.Name, err)
+		}
+		policy.ClientAuthentication = &ClientAuthentication{
+			CA: map[string]interface{}{
+				"provider":         "inline",
+				"trusted_ca_certs": certs,
+			},
+			Mode: mode,
+		}
+	}
+
+	return policy, nil
+}
+
+// clientCACertsDER returns the base64 DER encoding of every certificate in a PEM bundle.
+func clientCACertsDER(bundle string) ([]string, error) {
+	var certs []string
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, base64.StdEncoding.EncodeToString(block.Bytes))
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in PEM bundle")
+	}
+	return certs, nil
+}
+
+// splitList splits a comma-separated list, trimming blanks.
+func splitList(raw string) []string {
+	var out []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
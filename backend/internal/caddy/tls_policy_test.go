@@ -0,0 +1,138 @@
+package caddy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func testCAPEM(t *testing.T) (string, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Company Client CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), der
+}
+
+func TestValidateTLSPolicy(t *testing.T) {
+	caID := uint(1)
+	tests := []struct {
+		name    string
+		host    models.ProxyHost
+		wantErr string
+	}{
+		{"defaults", models.ProxyHost{}, ""},
+		{"versions", models.ProxyHost{TLSMinVersion: "tls1.2", TLSMaxVersion: "tls1.3"}, ""},
+		{"bad min", models.ProxyHost{TLSMinVersion: "tls1.0"}, "invalid tls_min_version"},
+		{"bad max", models.ProxyHost{TLSMaxVersion: "ssl3"}, "invalid tls_max_version"},
+		{"min above max", models.ProxyHost{TLSMinVersion: "tls1.3", TLSMaxVersion: "tls1.2"}, "higher than"},
+		{"ciphers", models.ProxyHost{TLSCipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}, ""},
+		{"unknown cipher", models.ProxyHost{TLSCipherSuites: "TLS_RSA_WITH_RC4_128_SHA"}, "unsupported cipher suite"},
+		{"tls13 suite", models.ProxyHost{TLSCipherSuites: "TLS_AES_128_GCM_SHA256"}, "unsupported cipher suite"},
+		{"ciphers with tls1.3 only", models.ProxyHost{TLSMinVersion: "tls1.3", TLSCipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}, "cannot be set"},
+		{"alpn", models.ProxyHost{TLSALPN: "h2,http/1.1"}, ""},
+		{"bad alpn", models.ProxyHost{TLSALPN: "spdy/3"}, "unsupported ALPN"},
+		{"mtls", models.ProxyHost{ClientAuthMode: "require", ClientCAID: &caID}, ""},
+		{"mtls without ca", models.ProxyHost{ClientAuthMode: "verify_if_given"}, "requires a client CA"},
+		{"bad mode", models.ProxyHost{ClientAuthMode: "optional", ClientCAID: &caID}, "invalid client_auth_mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTLSPolicy(&tt.host)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateConfig_TLSConnectionPolicies(t *testing.T) {
+	caPEM, caDER := testCAPEM(t)
+	caID := uint(7)
+	hosts := []models.ProxyHost{
+		{
+			UUID: "plain", DomainNames: "plain.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		},
+		{
+			UUID: "admin", DomainNames: "admin.example.com, tools.example.com", ForwardHost: "admin", ForwardPort: 8080, Enabled: true,
+			TLSMinVersion: "tls1.3", TLSALPN: "h2, http/1.1",
+			ClientAuthMode: "require", ClientCAID: &caID, ClientCA: &models.ClientCA{ID: caID, Name: "corp", Certificate: caPEM},
+		},
+		{
+			UUID: "legacy", DomainNames: "legacy.example.com", ForwardHost: "legacy", ForwardPort: 80, Enabled: true,
+			TLSMinVersion: "tls1.2", TLSMaxVersion: "tls1.2", TLSCipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		},
+	}
+
+	config, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	server := config.Apps.HTTP.Servers["charon_server"]
+	require.Len(t, server.TLSConnPolicies, 3)
+
+	// Hosts are processed newest first
+	legacy := server.TLSConnPolicies[0]
+	assert.Equal(t, []string{"legacy.example.com"}, legacy.Match.SNI)
+	assert.Equal(t, "tls1.2", legacy.ProtocolMax)
+	assert.Equal(t, []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, legacy.CipherSuites)
+	assert.Nil(t, legacy.ClientAuthentication)
+
+	admin := server.TLSConnPolicies[1]
+	assert.Equal(t, []string{"admin.example.com", "tools.example.com"}, admin.Match.SNI)
+	assert.Equal(t, "tls1.3", admin.ProtocolMin)
+	assert.Equal(t, []string{"h2", "http/1.1"}, admin.ALPN)
+	require.NotNil(t, admin.ClientAuthentication)
+	assert.Equal(t, "require_and_verify", admin.ClientAuthentication.Mode)
+	assert.Equal(t, "inline", admin.ClientAuthentication.CA["provider"])
+	assert.Equal(t, []string{base64.StdEncoding.EncodeToString(caDER)}, admin.ClientAuthentication.CA["trusted_ca_certs"])
+
+	// Default policy for every other name
+	assert.Equal(t, &TLSConnectionPolicy{}, server.TLSConnPolicies[2])
+	require.NotNil(t, server.StrictSNIHost)
+	assert.True(t, *server.StrictSNIHost)
+}
+
+func TestGenerateConfig_NoTLSConnectionPolicies(t *testing.T) {
+	hosts := []models.ProxyHost{{UUID: "a", DomainNames: "a.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}}
+	config, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	server := config.Apps.HTTP.Servers["charon_server"]
+	assert.Empty(t, server.TLSConnPolicies)
+	assert.Nil(t, server.StrictSNIHost)
+}
+
+func TestGenerateConfig_ClientAuthWithoutCAFails(t *testing.T) {
+	caID := uint(7)
+	hosts := []models.ProxyHost{{
+		UUID: "admin", DomainNames: "admin.example.com", ForwardHost: "admin", ForwardPort: 80, Enabled: true,
+		ClientAuthMode: "verify_if_given", ClientCAID: &caID,
+	}}
+	_, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "client CA")
+}
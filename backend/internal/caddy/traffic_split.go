@@ -0,0 +1,142 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// StickyCookieName is the cookie that keeps a client on one upstream of a split host.
+const StickyCookieName = "charon_upstream"
+
+// ValidateUpstreamGroup checks an upstream group on its own.
+func ValidateUpstreamGroup(g *models.UpstreamGroup) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	upstreams := splitList(g.Upstreams)
+	if len(upstreams) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	for _, u := range upstreams {
+		if err := checkUpstreamAddress(u); err != nil {
+			return err
+		}
+	}
+	if g.Weight < 0 || g.Weight > 100 {
+		return fmt.Errorf("weight must be between 0 and 100")
+	}
+	return nil
+}
+
+// ValidateTrafficSplit checks the upstream groups of a host together: names
+// must be unique and the weights may not add up to more than 100.
+func ValidateTrafficSplit(groups []models.UpstreamGroup) error {
+	names := make(map[string]bool)
+	total := 0
+	for i := range groups {
+		if err := ValidateUpstreamGroup(&groups[i]); err != nil {
+			return fmt.Errorf("upstream group %q: %w", groups[i].Name, err)
+		}
+		key := strings.ToLower(groups[i].Name)
+		if names[key] {
+			return fmt.Errorf("upstream group %q is defined twice", groups[i].Name)
+		}
+		names[key] = true
+		total += groups[i].Weight
+	}
+	if total > 100 {
+		return fmt.Errorf("upstream group weights add up to %d%%; at most 100%% is allowed", total)
+	}
+	return nil
+}
+
+// PrimaryWeight is the percentage of traffic a host's own upstream receives.
+func PrimaryWeight(groups []models.UpstreamGroup) int {
+	primary := 100
+	for _, g := range groups {
+		primary -= g.Weight
+	}
+	if primary < 0 {
+		return 0
+	}
+	return primary
+}
+
+// trafficSplit returns the dial addresses of a split host and the weight of
+// each. Every upstream of a group gets an equal part of the group's share.
+// It returns nil when no group takes any traffic.
+func trafficSplit(primaryDial string, groups []models.UpstreamGroup) ([]string, []int) {
+	type share struct {
+		dials  []string
+		weight int
+	}
+	shares := []share{{dials: []string{primaryDial}, weight: PrimaryWeight(groups)}}
+	split := false
+	for _, g := range groups {
+		if g.Weight > 0 {
+			shares = append(shares, share{dials: splitList(g.Upstreams), weight: g.Weight})
+			split = true
+		}
+	}
+	if !split {
+		return nil, nil
+	}
+
+	// Scale by the least common multiple of the group sizes so that the
+	// per-upstream weights stay whole numbers.
+	scale := 1
+	for _, s := range shares {
+		scale = scale / gcd(scale, len(s.dials)) * len(s.dials)
+	}
+	var dials []string
+	var weights []int
+	for _, s := range shares {
+		if s.weight == 0 {
+			continue
+		}
+		for _, d := range s.dials {
+			dials = append(dials, d)
+			weights = append(weights, s.weight*scale/len(s.dials))
+		}
+	}
+	divisor := 0
+	for _, w := range weights {
+		divisor = gcd(divisor, w)
+	}
+	for i := range weights {
+		weights[i] /= divisor
+	}
+	return dials, weights
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// applyTrafficSplit spreads a host's reverse_proxy handler over its upstream
+// groups with weighted round robin. With sticky sessions a cookie pins each
+// client to the upstream it was first sent to.
+func applyTrafficSplit(proxy Handler, primaryDial string, host *models.ProxyHost) Handler {
+	dials, weights := trafficSplit(primaryDial, host.UpstreamGroups)
+	if dials == nil {
+		return proxy
+	}
+	upstreams := make([]map[string]interface{}, 0, len(dials))
+	for _, d := range dials {
+		upstreams = append(upstreams, map[string]interface{}{"dial": d})
+	}
+	proxy["upstreams"] = upstreams
+
+	policy := map[string]interface{}{"policy": "weighted_round_robin", "weights": weights}
+	if host.StickySessions {
+		policy = map[string]interface{}{"policy": "cookie", "name": StickyCookieName, "fallback": policy}
+	}
+	proxy["load_balancing"] = map[string]interface{}{"selection_policy": policy}
+	return proxy
+}
@@ -0,0 +1,101 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateTrafficSplit(t *testing.T) {
+	groups := []models.UpstreamGroup{
+		{Name: " canary ", Upstreams: "app-v2:8080", Weight: 10},
+		{Name: "green", Upstreams: "green-1:80, green-2:80", Weight: 90},
+	}
+	require.NoError(t, ValidateTrafficSplit(groups))
+	assert.Equal(t, "canary", groups[0].Name)
+	assert.Equal(t, 0, PrimaryWeight(groups))
+
+	tests := []struct {
+		groups []models.UpstreamGroup
+		err    string
+	}{
+		{[]models.UpstreamGroup{{Upstreams: "a:1"}}, "name is required"},
+		{[]models.UpstreamGroup{{Name: "a"}}, "at least one upstream"},
+		{[]models.UpstreamGroup{{Name: "a", Upstreams: "app"}}, "must be host:port"},
+		{[]models.UpstreamGroup{{Name: "a", Upstreams: "a:1", Weight: 101}}, "weight must be between 0 and 100"},
+		{[]models.UpstreamGroup{{Name: "a", Upstreams: "a:1"}, {Name: "A", Upstreams: "b:1"}}, `"A" is defined twice`},
+		{[]models.UpstreamGroup{{Name: "a", Upstreams: "a:1", Weight: 60}, {Name: "b", Upstreams: "b:1", Weight: 50}}, "add up to 110%"},
+	}
+	for _, tt := range tests {
+		assert.ErrorContains(t, ValidateTrafficSplit(tt.groups), tt.err)
+	}
+}
+
+func TestTrafficSplit(t *testing.T) {
+	dials, weights := trafficSplit("app:80", []models.UpstreamGroup{{Name: "canary", Upstreams: "v2:80", Weight: 0}})
+	assert.Nil(t, dials, "groups without weight leave the host unsplit")
+	assert.Nil(t, weights)
+
+	dials, weights = trafficSplit("app:80", []models.UpstreamGroup{{Name: "canary", Upstreams: "v2:80", Weight: 10}})
+	assert.Equal(t, []string{"app:80", "v2:80"}, dials)
+	assert.Equal(t, []int{9, 1}, weights)
+
+	// A group's share is spread over its upstreams
+	dials, weights = trafficSplit("app:80", []models.UpstreamGroup{
+		{Name: "green", Upstreams: "g1:80,g2:80,g3:80", Weight: 30},
+		{Name: "off", Upstreams: "x:80", Weight: 0},
+	})
+	assert.Equal(t, []string{"app:80", "g1:80", "g2:80", "g3:80"}, dials)
+	assert.Equal(t, []int{7, 1, 1, 1}, weights)
+
+	// Full cutover drops the host's own upstream
+	dials, weights = trafficSplit("blue:80", []models.UpstreamGroup{{Name: "green", Upstreams: "green:80", Weight: 100}})
+	assert.Equal(t, []string{"green:80"}, dials)
+	assert.Equal(t, []int{1}, weights)
+}
+
+func TestGenerateConfig_TrafficSplit(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true, StickySessions: true,
+		UpstreamGroups: []models.UpstreamGroup{{Name: "canary", Upstreams: "app-v2:80", Weight: 25}},
+		Locations:      []models.Location{{UUID: "api", Path: "/api", ForwardHost: "api", ForwardPort: 9000}},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 2)
+	assert.NotContains(t, routes[0].Handle[0], "load_balancing", "locations keep their own upstream")
+
+	out, err := json.Marshal(routes[1].Handle[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"handler": "reverse_proxy", "flush_interval": -1,
+		"upstreams": [{"dial": "app:80"}, {"dial": "app-v2:80"}],
+		"load_balancing": {"selection_policy": {
+			"policy": "cookie", "name": "charon_upstream",
+			"fallback": {"policy": "weighted_round_robin", "weights": [3, 1]}
+		}}
+	}`, string(out))
+
+	hosts[0].UpstreamGroups = append(hosts[0].UpstreamGroups, models.UpstreamGroup{Name: "canary", Upstreams: "v3:80"})
+	_, err = GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	assert.ErrorContains(t, err, "traffic split for host app")
+}
+
+func TestExportCaddyfile_TrafficSplit(t *testing.T) {
+	host := models.ProxyHost{
+		ID: 1, UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true,
+		UpstreamGroups: []models.UpstreamGroup{{Name: "canary", Upstreams: "app-v2:80", Weight: 10}},
+	}
+	out := ExportCaddyfile([]models.ProxyHost{host}, CaddyfileOptions{})
+	assert.Contains(t, out, "\treverse_proxy app:80 app-v2:80 {\n\t\tflush_interval -1\n\t\tlb_policy weighted_round_robin 9 1\n\t}\n")
+
+	host.StickySessions = true
+	out = ExportCaddyfile([]models.ProxyHost{host}, CaddyfileOptions{})
+	assert.Contains(t, out, "\t\tlb_policy cookie charon_upstream {\n\t\t\tfallback weighted_round_robin 9 1\n\t\t}\n")
+}
@@ -51,8 +51,9 @@ type Storage struct {
 
 // Apps contains all Caddy app modules.
 type Apps struct {
-	HTTP *HTTPApp `json:"http,omitempty"`
-	TLS  *TLSApp  `json:"tls,omitempty"`
+	HTTP   *HTTPApp   `json:"http,omitempty"`
+	TLS    *TLSApp    `json:"tls,omitempty"`
+	Layer4 *Layer4App `json:"layer4,omitempty"`
 }
 
 // HTTPApp configures the HTTP app.
@@ -60,12 +61,60 @@ type HTTPApp struct {
 	Servers map[string]*Server `json:"servers"`
 }
 
+// Layer4App configures the layer4 app (caddy-l4 plugin) that proxies raw TCP and UDP.
+type Layer4App struct {
+	Servers map[string]*Layer4Server `json:"servers"`
+}
+
+// Layer4Server listens on addresses such as "tcp/:5432" and routes each connection.
+type Layer4Server struct {
+	Listen []string       `json:"listen"`
+	Routes []*Layer4Route `json:"routes"`
+}
+
+// Layer4Route handles connections that match any of its matcher sets.
+type Layer4Route struct {
+	ID     string                   `json:"@id,omitempty"`
+	Match  []map[string]interface{} `json:"match,omitempty"`
+	Handle []Handler                `json:"handle"`
+}
+
 // Server represents an HTTP server instance.
 type Server struct {
-	Listen    []string         `json:"listen"`
-	Routes    []*Route         `json:"routes"`
-	AutoHTTPS *AutoHTTPSConfig `json:"automatic_https,omitempty"`
-	Logs      *ServerLogs      `json:"logs,omitempty"`
+	Listen           []string                 `json:"listen"`
+	ListenerWrappers []map[string]interface{} `json:"listener_wrappers,omitempty"`
+	Routes           []*Route                 `json:"routes"`
+	AutoHTTPS        *AutoHTTPSConfig         `json:"automatic_https,omitempty"`
+	Logs             *ServerLogs              `json:"logs,omitempty"`
+	TLSConnPolicies  []*TLSConnectionPolicy   `json:"tls_connection_policies,omitempty"`
+	StrictSNIHost    *bool                    `json:"strict_sni_host,omitempty"`
+	Errors           *HTTPErrorConfig         `json:"errors,omitempty"`
+}
+
+// HTTPErrorConfig holds the routes that handle errors raised by a server's handlers.
+type HTTPErrorConfig struct {
+	Routes []*Route `json:"routes"`
+}
+
+// TLSConnectionPolicy configures TLS handshakes for connections matching an SNI.
+type TLSConnectionPolicy struct {
+	Match                *TLSPolicyMatch       `json:"match,omitempty"`
+	ProtocolMin          string                `json:"protocol_min,omitempty"`
+	ProtocolMax          string                `json:"protocol_max,omitempty"`
+	CipherSuites         []string              `json:"cipher_suites,omitempty"`
+	ALPN                 []string              `json:"alpn,omitempty"`
+	ClientAuthentication *ClientAuthentication `json:"client_authentication,omitempty"`
+}
+
+// TLSPolicyMatch selects the connections a TLS connection policy applies to.
+type TLSPolicyMatch struct {
+	SNI []string `json:"sni,omitempty"`
+}
+
+// ClientAuthentication configures client certificate (mTLS) verification.
+type ClientAuthentication struct {
+	CA   map[string]interface{} `json:"ca,omitempty"`
+	Mode string                 `json:"mode,omitempty"`
 }
 
 // AutoHTTPSConfig controls automatic HTTPS behavior.
@@ -82,6 +131,8 @@ type ServerLogs struct {
 
 // Route represents an HTTP route (matcher + handlers).
 type Route struct {
+	// ID is Caddy's @id; tagged routes can be updated in place through /id/<ID>
+	ID       string    `json:"@id,omitempty"`
 	Match    []Match   `json:"match,omitempty"`
 	Handle   []Handler `json:"handle"`
 	Terminal bool      `json:"terminal,omitempty"`
@@ -89,8 +140,19 @@ type Route struct {
 
 // Match represents a request matcher.
 type Match struct {
-	Host []string `json:"host,omitempty"`
-	Path []string `json:"path,omitempty"`
+	Host       []string            `json:"host,omitempty"`
+	Path       []string            `json:"path,omitempty"`
+	Method     []string            `json:"method,omitempty"`
+	Header     map[string][]string `json:"header,omitempty"` // An empty value list only checks that the header is present
+	Query      map[string][]string `json:"query,omitempty"`
+	RemoteIP   *RemoteIPMatch      `json:"remote_ip,omitempty"`
+	Protocol   string              `json:"protocol,omitempty"`
+	Expression string              `json:"expression,omitempty"` // CEL expression; placeholders such as {http.error.status_code} are allowed
+}
+
+// RemoteIPMatch matches the address of the connecting client.
+type RemoteIPMatch struct {
+	Ranges []string `json:"ranges"`
 }
 
 // Handler is the interface for all handler types.
@@ -197,7 +259,8 @@ type TLSApp struct {
 
 // CertificatesConfig configures manual certificate loading.
 type CertificatesConfig struct {
-	LoadPEM []LoadPEMConfig `json:"load_pem,omitempty"`
+	LoadPEM  []LoadPEMConfig `json:"load_pem,omitempty"`
+	Automate []string        `json:"automate,omitempty"` // names to obtain and renew certificates for
 }
 
 // LoadPEMConfig defines a PEM-loaded certificate.
@@ -210,10 +273,18 @@ type LoadPEMConfig struct {
 // AutomationConfig controls certificate automation.
 type AutomationConfig struct {
 	Policies []*AutomationPolicy `json:"policies,omitempty"`
+	OnDemand *OnDemandConfig     `json:"on_demand,omitempty"`
 }
 
 // AutomationPolicy defines certificate management for specific domains.
 type AutomationPolicy struct {
 	Subjects   []string      `json:"subjects,omitempty"`
 	IssuersRaw []interface{} `json:"issuers,omitempty"`
+	OnDemand   bool          `json:"on_demand,omitempty"`
+}
+
+// OnDemandConfig configures on-demand certificate issuance.
+// Permission is the module Caddy consults before obtaining a certificate at handshake time.
+type OnDemandConfig struct {
+	Permission map[string]interface{} `json:"permission,omitempty"`
 }
@@ -0,0 +1,35 @@
+package caddy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unixDialPrefix marks a unix socket dial address in Caddy, e.g.
+// unix//run/php/php-fpm.sock.
+const unixDialPrefix = "unix/"
+
+// UnixSocketPath returns the socket path of a unix//path forward host or dial
+// address, and whether it is one.
+func UnixSocketPath(addr string) (string, bool) {
+	return strings.CutPrefix(addr, unixDialPrefix)
+}
+
+// UpstreamDial is the dial address of a forward host and port. Unix sockets
+// have no port, so it is ignored for them.
+func UpstreamDial(forwardHost string, forwardPort int) string {
+	if _, ok := UnixSocketPath(forwardHost); ok {
+		return forwardHost
+	}
+	return forwardHost + ":" + strconv.Itoa(forwardPort)
+}
+
+// checkUnixSocket checks a unix//path dial address.
+func checkUnixSocket(addr string) error {
+	path, _ := UnixSocketPath(addr)
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t\n") {
+		return fmt.Errorf("unix socket %q must be unix// followed by an absolute path", addr)
+	}
+	return nil
+}
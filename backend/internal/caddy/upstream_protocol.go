@@ -0,0 +1,62 @@
+package caddy
+
+import (
+	"fmt"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// HTTP versions spoken to an upstream.
+const (
+	UpstreamProtocolAuto  = "auto"  // HTTP/1.1, or HTTP/2 when the upstream offers it over TLS
+	UpstreamProtocolHTTP1 = "http1" // HTTP/1.1 only
+	UpstreamProtocolH2C   = "h2c"   // HTTP/2 over cleartext, as gRPC servers without TLS expect
+	UpstreamProtocolH2    = "h2"    // HTTP/2 over TLS
+)
+
+// ValidateUpstreamProtocol checks an upstream protocol; empty means auto.
+func ValidateUpstreamProtocol(protocol string) error {
+	switch protocol {
+	case "", UpstreamProtocolAuto, UpstreamProtocolHTTP1, UpstreamProtocolH2C, UpstreamProtocolH2:
+		return nil
+	}
+	return fmt.Errorf("upstream_protocol must be auto, http1, h2c or h2")
+}
+
+// ValidateUpstreamProxyProtocol checks the PROXY protocol version sent to
+// upstreams; empty sends none.
+func ValidateUpstreamProxyProtocol(version string) error {
+	switch version {
+	case "", "v1", "v2":
+		return nil
+	}
+	return fmt.Errorf("upstream_proxy_protocol must be v1 or v2")
+}
+
+// LocationProtocol is the upstream protocol of a location: its own, or the host's.
+func LocationProtocol(host *models.ProxyHost, loc *models.Location) string {
+	if loc.UpstreamProtocol != "" {
+		return loc.UpstreamProtocol
+	}
+	return host.UpstreamProtocol
+}
+
+// IsHTTP2Only reports whether a protocol only speaks HTTP/2 to the upstream,
+// as gRPC does.
+func IsHTTP2Only(protocol string) bool {
+	return protocol == UpstreamProtocolH2C || protocol == UpstreamProtocolH2
+}
+
+// transportVersions returns the HTTP versions of the reverse_proxy transport
+// for a protocol, or nil to keep Caddy's default.
+func transportVersions(protocol string) []string {
+	switch protocol {
+	case UpstreamProtocolHTTP1:
+		return []string{"1.1"}
+	case UpstreamProtocolH2C:
+		return []string{"h2c", "2"}
+	case UpstreamProtocolH2:
+		return []string{"2"}
+	}
+	return nil
+}
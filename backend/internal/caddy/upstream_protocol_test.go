@@ -0,0 +1,62 @@
+package caddy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func TestValidateUpstreamProtocol(t *testing.T) {
+	for _, p := range []string{"", "auto", "http1", "h2c", "h2"} {
+		assert.NoError(t, ValidateUpstreamProtocol(p))
+	}
+	assert.ErrorContains(t, ValidateUpstreamProtocol("grpc"), "upstream_protocol must be auto, http1, h2c or h2")
+
+	host := models.ProxyHost{Locations: []models.Location{{Path: "/rpc", UpstreamProtocol: "http3"}}}
+	assert.ErrorContains(t, ValidatePerformance(&host), "location /rpc: upstream_protocol")
+}
+
+func TestGenerateConfig_UpstreamProtocol(t *testing.T) {
+	hosts := []models.ProxyHost{{
+		UUID: "grpc", DomainNames: "grpc.example.com", ForwardHost: "grpc", ForwardPort: 50051, Enabled: true,
+		UpstreamProtocol: UpstreamProtocolH2C, Buffering: true, WebsocketSupport: true, UpstreamReadTimeout: 300,
+		Locations: []models.Location{
+			{UUID: "web", Path: "/web", ForwardHost: "web", ForwardPort: 80, UpstreamProtocol: UpstreamProtocolHTTP1},
+			{UUID: "tls", Path: "/tls", ForwardHost: "secure", ForwardPort: 443, UpstreamProtocol: UpstreamProtocolH2},
+		},
+	}}
+	cfg, err := GenerateConfig(hosts, "/tmp/caddy/data", "", "", "", false, false, false, false, false, "", nil, nil, nil, nil)
+	require.NoError(t, err)
+	routes := cfg.Apps.HTTP.Servers["charon_server"].Routes
+	require.Len(t, routes, 3)
+
+	// gRPC upstreams stream and get no WebSocket headers, even with buffering on
+	out, err := json.Marshal(routes[2].Handle[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"handler": "reverse_proxy", "flush_interval": -1,
+		"upstreams": [{"dial": "grpc:50051"}],
+		"transport": {"protocol": "http", "read_timeout": "5m0s", "versions": ["h2c", "2"]}
+	}`, string(out))
+
+	web := routes[0].Handle[0]
+	assert.NotContains(t, web, "flush_interval", "other locations keep the host's buffering")
+	assert.Contains(t, web, "headers")
+	assert.Equal(t, []string{"1.1"}, web["transport"].(map[string]interface{})["versions"])
+
+	secure := routes[1].Handle[0]["transport"].(map[string]interface{})
+	assert.Equal(t, []string{"2"}, secure["versions"])
+	assert.Equal(t, map[string]interface{}{}, secure["tls"])
+}
+
+func TestExportCaddyfile_UpstreamProtocol(t *testing.T) {
+	out := ExportCaddyfile([]models.ProxyHost{{
+		ID: 1, UUID: "grpc", DomainNames: "grpc.example.com", ForwardHost: "grpc", ForwardPort: 50051, Enabled: true,
+		UpstreamProtocol: UpstreamProtocolH2, Buffering: true, WebsocketSupport: true,
+	}}, CaddyfileOptions{})
+	assert.Contains(t, out, "\treverse_proxy grpc:50051 {\n\t\tflush_interval -1\n\t\ttransport http {\n\t\t\tversions 2\n\t\t\ttls\n\t\t}\n\t}\n")
+}
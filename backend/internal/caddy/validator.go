@@ -86,8 +86,13 @@ func validateRoute(route *Route, seenHosts map[string]bool) error {
 		return fmt.Errorf("route has no handlers")
 	}
 
-	// Check for duplicate host matchers
+	// Check for duplicate host matchers. Routes that also match on path,
+	// method, headers and so on (locations, routing rules) only narrow a
+	// host's catch-all route and may share its host names.
 	for _, match := range route.Match {
+		if !hostOnly(match) {
+			continue
+		}
 		for _, host := range match.Host {
 			if seenHosts[host] {
 				return fmt.Errorf("duplicate host matcher: %s", host)
@@ -106,6 +111,11 @@ func validateRoute(route *Route, seenHosts map[string]bool) error {
 	return nil
 }
 
+func hostOnly(m Match) bool {
+	return len(m.Path) == 0 && len(m.Method) == 0 && len(m.Header) == 0 && len(m.Query) == 0 &&
+		m.RemoteIP == nil && m.Protocol == "" && m.Expression == ""
+}
+
 func validateHandler(handler Handler) error {
 	handlerType, ok := handler["handler"].(string)
 	if !ok {
@@ -139,7 +149,13 @@ func validateReverseProxy(handler Handler) error {
 			return fmt.Errorf("upstream %d missing dial address", i)
 		}
 
-		// Validate dial address format (host:port)
+		// Unix sockets are unix//path; everything else is host:port
+		if _, ok := UnixSocketPath(dial); ok {
+			if err := checkUnixSocket(dial); err != nil {
+				return fmt.Errorf("upstream %d: %w", i, err)
+			}
+			continue
+		}
 		if _, _, err := net.SplitHostPort(dial); err != nil {
 			return fmt.Errorf("upstream %d has invalid dial address %s: %w", i, dial, err)
 		}
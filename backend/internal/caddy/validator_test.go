@@ -62,6 +62,36 @@ func TestValidate_DuplicateHosts(t *testing.T) {
 	require.Contains(t, err.Error(), "duplicate host")
 }
 
+func TestValidate_NarrowerRoutesShareHost(t *testing.T) {
+	config := &Config{
+		Apps: Apps{
+			HTTP: &HTTPApp{
+				Servers: map[string]*Server{
+					"srv": {
+						Listen: []string{":80"},
+						Routes: []*Route{
+							{
+								Match:  []Match{{Host: []string{"test.com"}, Path: []string{"/api/*"}}},
+								Handle: []Handler{ReverseProxyHandler("api:8080", false, "none")},
+							},
+							{
+								Match:  []Match{{Host: []string{"test.com"}, Header: map[string][]string{"User-Agent": {"*MyApp*"}}}},
+								Handle: []Handler{ReverseProxyHandler("mobile:8080", false, "none")},
+							},
+							{
+								Match:  []Match{{Host: []string{"test.com"}}},
+								Handle: []Handler{ReverseProxyHandler("app:8080", false, "none")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, Validate(config))
+}
+
 func TestValidate_NoListenAddresses(t *testing.T) {
 	config := &Config{
 		Apps: Apps{
@@ -15,6 +15,7 @@ type Config struct {
 	CaddyAdminAPI   string
 	CaddyConfigDir  string
 	CaddyBinary     string
+	CaddySupervise  bool
 	ImportCaddyfile string
 	ImportDir       string
 	JWTSecret       string
@@ -44,6 +45,7 @@ func Load() (Config, error) {
 		CaddyAdminAPI:   getEnvAny("http://localhost:2019", "CHARON_CADDY_ADMIN_API", "CPM_CADDY_ADMIN_API"),
 		CaddyConfigDir:  getEnvAny(filepath.Join("data", "caddy"), "CHARON_CADDY_CONFIG_DIR", "CPM_CADDY_CONFIG_DIR"),
 		CaddyBinary:     getEnvAny("caddy", "CHARON_CADDY_BINARY", "CPM_CADDY_BINARY"),
+		CaddySupervise:  getEnvAny("false", "CHARON_CADDY_SUPERVISE", "CPM_CADDY_SUPERVISE") == "true",
 		ImportCaddyfile: getEnvAny("/import/Caddyfile", "CHARON_IMPORT_CADDYFILE", "CPM_IMPORT_CADDYFILE"),
 		ImportDir:       getEnvAny(filepath.Join("data", "imports"), "CHARON_IMPORT_DIR", "CPM_IMPORT_DIR"),
 		JWTSecret:       getEnvAny("change-me-in-production", "CHARON_JWT_SECRET", "CPM_JWT_SECRET"),
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// CertificateExpiryState tracks which expiry thresholds have already been
+// notified for a certificate. The state is tied to a specific expiry date so
+// that a renewed certificate starts alerting from scratch.
+type CertificateExpiryState struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	CertificateID     uint      `json:"certificate_id" gorm:"uniqueIndex;not null"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	LastThresholdDays int       `json:"last_threshold_days"` // smallest threshold (in days) already notified, 0 = none
+	ExpiredNotified   bool      `json:"expired_notified"`
+	LastNotifiedAt    time.Time `json:"last_notified_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
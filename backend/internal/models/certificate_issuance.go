@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// CertificateIssuance tracks Caddy's ACME issuance attempts for a single domain.
+// It is populated from Caddy's TLS log events (see services.CertificateIssuanceService).
+type CertificateIssuance struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Domain        string     `json:"domain" gorm:"uniqueIndex;not null"`
+	Status        string     `json:"status"` // obtaining, issued, retrying, failed
+	Issuer        string     `json:"issuer"`
+	ChallengeType string     `json:"challenge_type"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	ErrorKind     string     `json:"error_kind"` // dns, connection, rate_limited, unauthorized, other
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextRetryAt   *time.Time `json:"next_retry_at,omitempty"`
+	IssuedAt      *time.Time `json:"issued_at,omitempty"`
+	LastEventAt   time.Time  `json:"last_event_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
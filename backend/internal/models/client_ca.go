@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// ClientCA is an uploaded certificate authority used to verify client
+// certificates (mutual TLS) on proxy hosts.
+type ClientCA struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UUID        string    `json:"uuid" gorm:"uniqueIndex"`
+	Name        string    `json:"name"`
+	Certificate string    `json:"certificate" gorm:"type:text"` // PEM bundle, one or more CA certificates
+	Subject     string    `json:"subject"`
+	ExpiresAt   time.Time `json:"expires_at"` // earliest expiry in the bundle
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ConfigSnapshot is a Caddy configuration that was applied, together with the
+// database state it was generated from, so either can be restored later.
+type ConfigSnapshot struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UUID       string    `json:"uuid" gorm:"uniqueIndex"`
+	Name       string    `json:"name"`   // Set for snapshots taken on request; named snapshots are never rotated
+	Actor      string    `json:"actor"`  // User ID that triggered the change, empty for system changes
+	Reason     string    `json:"reason"` // e.g. "PUT /api/v1/proxy-hosts/<uuid>"
+	ConfigHash string    `json:"config_hash" gorm:"index"`
+	Config     string    `json:"-" gorm:"type:text"` // Caddy JSON
+	State      string    `json:"-" gorm:"type:text"` // JSON export of proxy hosts, locations and access lists
+	CreatedAt  time.Time `json:"created_at"`
+}
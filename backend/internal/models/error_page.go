@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ErrorPage is an HTML page Caddy serves instead of its bare error response
+// for one status code, either for a single proxy host or for all of them.
+type ErrorPage struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UUID        string `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID *uint  `json:"proxy_host_id" gorm:"index"`  // nil for the global page
+	StatusCode  int    `json:"status_code" gorm:"not null"` // 403, 404, 502, 503 or 504
+	Body        string `json:"body" gorm:"type:text"`       // HTML with {{host}}, {{status}}, {{status_text}} and {{request_id}}
+	Enabled     bool   `json:"enabled" gorm:"default:true"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -4,12 +4,13 @@ import (
 	"time"
 )
 
-// ImportSession tracks Caddyfile import operations with pending state
-// until user reviews and confirms via UI.
+// ImportSession tracks config import operations (Caddyfile, Nginx Proxy Manager,
+// Traefik, nginx) with pending state until user reviews and confirms via UI.
 type ImportSession struct {
 	ID              uint       `json:"id" gorm:"primaryKey"`
 	UUID            string     `json:"uuid" gorm:"uniqueIndex"`
-	SourceFile      string     `json:"source_file"`                       // Path to original Caddyfile
+	Source          string     `json:"source" gorm:"default:'caddyfile'"` // "caddyfile", "npm", "traefik", "nginx"
+	SourceFile      string     `json:"source_file"`                       // Path to the uploaded config or NPM database
 	Status          string     `json:"status" gorm:"default:'pending'"`   // "pending", "reviewing", "committed", "rejected", "failed"
 	ParsedData      string     `json:"parsed_data" gorm:"type:text"`      // JSON representation of detected hosts
 	ConflictReport  string     `json:"conflict_report" gorm:"type:text"`  // JSON array of conflicts
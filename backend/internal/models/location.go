@@ -6,13 +6,15 @@ import (
 
 // Location represents a custom path-based proxy configuration within a ProxyHost.
 type Location struct {
-	ID            uint      `json:"id" gorm:"primaryKey"`
-	UUID          string    `json:"uuid" gorm:"uniqueIndex;not null"`
-	ProxyHostID   uint      `json:"proxy_host_id" gorm:"not null;index"`
-	Path          string    `json:"path" gorm:"not null"` // e.g., /api, /admin
-	ForwardScheme string    `json:"forward_scheme" gorm:"default:http"`
-	ForwardHost   string    `json:"forward_host" gorm:"not null"`
-	ForwardPort   int       `json:"forward_port" gorm:"not null"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	UUID             string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID      uint      `json:"proxy_host_id" gorm:"not null;index"`
+	Path             string    `json:"path" gorm:"not null"` // e.g., /api, /admin
+	ForwardScheme    string    `json:"forward_scheme" gorm:"default:http"`
+	ForwardHost      string    `json:"forward_host" gorm:"not null"`
+	ForwardPort      int       `json:"forward_port" gorm:"not null"`
+	UpstreamProtocol string    `json:"upstream_protocol"`       // Overrides the host's upstream protocol; empty inherits it
+	InheritAdvanced  bool      `json:"inherit_advanced_config"` // Also runs the host's non-terminal advanced_config handlers
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// MaintenanceWindow puts a proxy host into maintenance, either from StartsAt
+// (until EndsAt, or until it is disabled) or recurring on a cron Schedule.
+// During the window the host answers 503 with a maintenance page, except for
+// clients in AllowIPs.
+type MaintenanceWindow struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UUID            string     `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID     uint       `json:"proxy_host_id" gorm:"not null;index"`
+	Name            string     `json:"name"`
+	StartsAt        *time.Time `json:"starts_at"`             // one-off window; defaults to now
+	EndsAt          *time.Time `json:"ends_at"`               // nil keeps a one-off window open
+	Schedule        string     `json:"schedule"`              // cron expression for a recurring window, e.g. "0 2 * * 0"
+	DurationMinutes int        `json:"duration_minutes"`      // length of each recurring window
+	AllowIPs        string     `json:"allow_ips"`             // Comma-separated IPs/CIDRs that still reach the backend
+	Body            string     `json:"body" gorm:"type:text"` // maintenance page HTML; empty for the built-in page
+	Enabled         bool       `json:"enabled" gorm:"default:true"`
+
+	// Active reports whether the window is in effect right now; not stored
+	Active bool `json:"active" gorm:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
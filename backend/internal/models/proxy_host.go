@@ -29,6 +29,37 @@ type ProxyHost struct {
 	AdvancedConfig       string          `json:"advanced_config" gorm:"type:text"`
 	AdvancedConfigBackup string          `json:"advanced_config_backup" gorm:"type:text"`
 
+	// TLS connection policy. Empty values keep Caddy's defaults.
+	TLSMinVersion   string `json:"tls_min_version"`   // tls1.2, tls1.3
+	TLSMaxVersion   string `json:"tls_max_version"`   // tls1.2, tls1.3
+	TLSCipherSuites string `json:"tls_cipher_suites"` // Comma-separated allow-list (Go/IANA names)
+	TLSALPN         string `json:"tls_alpn"`          // Comma-separated: h3, h2, http/1.1
+
+	// Mutual TLS: verify client certificates against an uploaded CA
+	ClientAuthMode string    `json:"client_auth_mode"` // "", require, verify_if_given
+	ClientCAID     *uint     `json:"client_ca_id"`
+	ClientCA       *ClientCA `json:"client_ca" gorm:"foreignKey:ClientCAID"`
+
+	// Performance. Empty or zero values keep Caddy's defaults.
+	Compression            string `json:"compression"`                  // Comma-separated encodings in order of preference: zstd, gzip
+	CompressionTypes       string `json:"compression_types"`            // Comma-separated content types, e.g. text/*; empty uses Caddy's list
+	UpstreamConnectTimeout int    `json:"upstream_connect_timeout"`     // Seconds
+	UpstreamReadTimeout    int    `json:"upstream_read_timeout"`        // Seconds
+	UpstreamWriteTimeout   int    `json:"upstream_write_timeout"`       // Seconds
+	UpstreamKeepalive      int    `json:"upstream_keepalive"`           // Idle seconds; -1 disables keep-alive
+	UpstreamProtocol       string `json:"upstream_protocol"`            // auto, http1, h2c (gRPC over cleartext) or h2 (HTTP/2 over TLS); empty is auto
+	UpstreamProxyProtocol  string `json:"upstream_proxy_protocol"`      // v1 or v2 to send a PROXY protocol header to the upstream; empty sends none
+	MaxBodySize            int    `json:"max_body_size"`                // Megabytes; 0 is unlimited
+	Buffering              bool   `json:"buffering"`                    // Buffer responses instead of flushing every write
+	CacheRules             string `json:"cache_rules" gorm:"type:text"` // One rule per line: <paths> <Cache-Control value>
+
+	// Traffic splitting between the forward host and weighted upstream groups
+	UpstreamGroups []UpstreamGroup `json:"upstream_groups,omitempty" gorm:"foreignKey:ProxyHostID;constraint:OnDelete:CASCADE"`
+	StickySessions bool            `json:"sticky_sessions"` // Keep each client on one upstream with a cookie
+
+	// Routing rules on headers, method, query and client IP; matched before locations
+	RoutingRules []RoutingRule `json:"routing_rules,omitempty" gorm:"foreignKey:ProxyHostID;constraint:OnDelete:CASCADE"`
+
 	// Forward Auth / User Gateway settings
 	// When enabled, Caddy will use forward_auth to verify user access via Charon
 	ForwardAuthEnabled bool `json:"forward_auth_enabled" gorm:"default:false"`
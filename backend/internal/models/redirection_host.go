@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// RedirectionHost answers every request for its domains with a redirect to
+// another URL, e.g. an old domain to a new one or www to the apex domain.
+type RedirectionHost struct {
+	ID                uint   `json:"id" gorm:"primaryKey"`
+	UUID              string `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name              string `json:"name"`
+	DomainNames       string `json:"domain_names" gorm:"not null"`       // Comma-separated list
+	TargetURL         string `json:"target_url"`                         // Full target URL; takes precedence over scheme + domain
+	ForwardScheme     string `json:"forward_scheme" gorm:"default:auto"` // auto (keep the request's), http, https
+	ForwardDomainName string `json:"forward_domain_name"`
+	ForwardHTTPCode   int    `json:"forward_http_code" gorm:"default:301"` // 301, 302, 307, 308
+	PreservePath      bool   `json:"preserve_path"`                        // Append the request path and query to the target
+	SSLForced         bool   `json:"ssl_forced" gorm:"default:false"`      // Upgrade to HTTPS on the source domain first
+	Enabled           bool   `json:"enabled" gorm:"default:true"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// RoutingRule sends the requests of a proxy host that match all of its
+// matchers to another upstream, or answers them with a response or redirect.
+type RoutingRule struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UUID        string `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID uint   `json:"proxy_host_id" gorm:"not null;index"`
+	Name        string `json:"name"`
+	Priority    int    `json:"priority"` // Lower runs first
+
+	// Matchers; empty ones are not checked
+	Paths     string `json:"paths"`                    // Comma-separated path patterns, e.g. /webhook, /api/*
+	Methods   string `json:"methods"`                  // Comma-separated, e.g. GET, POST
+	Headers   string `json:"headers" gorm:"type:text"` // One "Name: value" per line; * wildcards, a bare name checks presence
+	Query     string `json:"query" gorm:"type:text"`   // One "key=value" per line; * matches any value
+	RemoteIPs string `json:"remote_ips"`               // Comma-separated IPs and CIDRs
+
+	// Action
+	Action     string `json:"action" gorm:"default:proxy"` // proxy, respond, redirect
+	Upstreams  string `json:"upstreams"`                   // proxy: comma-separated host:port list
+	StatusCode int    `json:"status_code"`                 // respond (default 200), redirect (default 302)
+	Body       string `json:"body" gorm:"type:text"`       // respond
+	TargetURL  string `json:"target_url"`                  // redirect; placeholders such as {http.request.uri} are allowed
+	Enabled    bool   `json:"enabled" gorm:"default:true"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// StaticSite serves files from its current release under data/sites/<uuid>,
+// e.g. for documentation or landing pages.
+type StaticSite struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UUID            string     `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name            string     `json:"name"`
+	DomainNames     string     `json:"domain_names" gorm:"not null"`          // Comma-separated list
+	IndexFiles      string     `json:"index_files" gorm:"default:index.html"` // Comma-separated list
+	SPAFallback     bool       `json:"spa_fallback"`                          // serve the first index file for paths without a file
+	Browse          bool       `json:"browse"`                                // list directories without an index file
+	Precompressed   bool       `json:"precompressed"`                         // serve .br, .zst and .gz files next to the originals
+	CurrentRelease  string     `json:"current_release"`
+	PreviousRelease string     `json:"previous_release"`
+	DeployedAt      *time.Time `json:"deployed_at"`
+	Enabled         bool       `json:"enabled" gorm:"default:true"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Stream forwards raw TCP or UDP connections on a listen port to upstreams,
+// e.g. for game servers, MQTT brokers, SSH or databases.
+type Stream struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UUID        string `json:"uuid" gorm:"uniqueIndex;not null"`
+	Name        string `json:"name"`
+	ListenPort  int    `json:"listen_port" gorm:"not null"`
+	Protocol    string `json:"protocol" gorm:"default:tcp"` // tcp, udp
+	Upstreams   string `json:"upstreams" gorm:"not null"`   // Comma-separated host:port list
+	TLSMode     string `json:"tls_mode"`                    // "" (plain), sni (route by SNI, pass TLS through), terminate
+	ServerNames string `json:"server_names"`                // Comma-separated SNI names for sni and terminate
+	Enabled     bool   `json:"enabled" gorm:"default:true"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UpstreamGroup is a weighted set of backends that takes a share of a proxy
+// host's traffic, for canary and blue/green deployments. The host's own
+// upstream receives whatever share the groups leave.
+type UpstreamGroup struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UUID        string    `json:"uuid" gorm:"uniqueIndex;not null"`
+	ProxyHostID uint      `json:"proxy_host_id" gorm:"not null;index"`
+	Name        string    `json:"name" gorm:"not null"`      // e.g. canary, green; unique per host
+	Upstreams   string    `json:"upstreams" gorm:"not null"` // Comma-separated host:port list
+	Weight      int       `json:"weight"`                    // Percent of the host's traffic, 0-100
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
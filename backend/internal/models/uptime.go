@@ -11,10 +11,12 @@ type UptimeMonitor struct {
 	ID             string    `gorm:"primaryKey" json:"id"`
 	ProxyHostID    *uint     `json:"proxy_host_id"`    // Optional link to proxy host
 	RemoteServerID *uint     `json:"remote_server_id"` // Optional link to remote server
+	StreamID       *uint     `json:"stream_id"`        // Optional link to TCP/UDP stream
 	UptimeHostID   *string   `json:"uptime_host_id"`   // Link to parent host for grouping
 	Name           string    `json:"name"`
-	Type           string    `json:"type"` // http, tcp, ping
+	Type           string    `json:"type"` // http, tcp, grpc, ping
 	URL            string    `json:"url"`
+	GRPCService    string    `json:"grpc_service"`  // Service asked about by grpc checks; empty checks the whole server
 	UpstreamHost   string    `json:"upstream_host"` // The actual backend host/IP (for grouping)
 	Interval       int       `json:"interval"`      // seconds
 	Enabled        bool      `json:"enabled"`
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/util"
+)
+
+// CertExpiryThresholdsSettingKey stores the comma-separated list of day thresholds
+// (e.g. "30,14,7,1") at which certificate expiry notifications are sent.
+const CertExpiryThresholdsSettingKey = "certificates.expiry_thresholds"
+
+// DefaultCertExpiryThresholds is used when no thresholds are configured.
+var DefaultCertExpiryThresholds = []int{30, 14, 7, 1}
+
+// CertificateExpiryService periodically checks all known certificates (ACME and custom)
+// and sends "cert" notifications when they cross a configured expiry threshold.
+type CertificateExpiryService struct {
+	db                  *gorm.DB
+	notificationService *NotificationService
+	certService         *CertificateService
+	Cron                *cron.Cron
+	now                 func() time.Time
+}
+
+// NewCertificateExpiryService creates a new expiry watcher. certService is optional;
+// when set, ACME certificates on disk are synced into the DB before each check.
+func NewCertificateExpiryService(db *gorm.DB, ns *NotificationService, certService *CertificateService) *CertificateExpiryService {
+	return &CertificateExpiryService{
+		db:                  db,
+		notificationService: ns,
+		certService:         certService,
+		Cron:                cron.New(),
+		now:                 time.Now,
+	}
+}
+
+// Start runs the expiry check once in the background and then schedules it
+// hourly, so that a restart doesn't delay alerts for certificates that crossed
+// a threshold while Charon was down, nor hold up startup while it checks.
+func (s *CertificateExpiryService) Start() {
+	go func() {
+		if err := s.CheckAll(); err != nil {
+			logger.Log().WithError(err).Error("CertificateExpiryService: startup check failed")
+		}
+	}()
+	if _, err := s.Cron.AddFunc("@hourly", func() {
+		if err := s.CheckAll(); err != nil {
+			logger.Log().WithError(err).Error("CertificateExpiryService: scheduled check failed")
+		}
+	}); err != nil {
+		logger.Log().WithError(err).Error("Failed to schedule certificate expiry check")
+		return
+	}
+	s.Cron.Start()
+}
+
+// Stop halts the scheduler.
+func (s *CertificateExpiryService) Stop() {
+	s.Cron.Stop()
+}
+
+// GetThresholds returns the configured thresholds in days, sorted descending.
+func (s *CertificateExpiryService) GetThresholds() []int {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", CertExpiryThresholdsSettingKey).First(&setting).Error; err != nil {
+		return append([]int{}, DefaultCertExpiryThresholds...)
+	}
+	thresholds, err := ParseCertExpiryThresholds(setting.Value)
+	if err != nil || len(thresholds) == 0 {
+		logger.Log().WithField("value", util.SanitizeForLog(setting.Value)).Warn("CertificateExpiryService: invalid thresholds setting, using defaults")
+		return append([]int{}, DefaultCertExpiryThresholds...)
+	}
+	return thresholds
+}
+
+// ParseCertExpiryThresholds parses a comma-separated list of positive day counts.
+// Duplicates are removed and the result is sorted descending (e.g. [30 14 7 1]).
+func ParseCertExpiryThresholds(raw string) ([]int, error) {
+	seen := make(map[int]bool)
+	var thresholds []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		days, err := strconv.Atoi(part)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid threshold %q: must be a positive number of days", part)
+		}
+		if seen[days] {
+			continue
+		}
+		seen[days] = true
+		thresholds = append(thresholds, days)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(thresholds)))
+	return thresholds, nil
+}
+
+// CheckAll evaluates every certificate against the configured thresholds and
+// sends at most one notification per threshold per certificate expiry date.
+func (s *CertificateExpiryService) CheckAll() error {
+	if s.certService != nil {
+		if err := s.certService.SyncFromDisk(); err != nil {
+			logger.Log().WithError(err).Warn("CertificateExpiryService: disk sync failed, checking DB state only")
+		}
+	}
+
+	var certs []models.SSLCertificate
+	if err := s.db.Find(&certs).Error; err != nil {
+		return fmt.Errorf("fetch certificates: %w", err)
+	}
+
+	thresholds := s.GetThresholds()
+	now := s.now()
+
+	for i := range certs {
+		if err := s.checkCertificate(&certs[i], thresholds, now); err != nil {
+			logger.Log().WithError(err).WithField("certificate", util.SanitizeForLog(certs[i].Name)).Error("CertificateExpiryService: check failed")
+		}
+	}
+
+	s.cleanupStaleStates(certs)
+	return nil
+}
+
+func (s *CertificateExpiryService) checkCertificate(cert *models.SSLCertificate, thresholds []int, now time.Time) error {
+	if cert.ExpiresAt == nil {
+		return nil
+	}
+	expiresAt := *cert.ExpiresAt
+
+	var state models.CertificateExpiryState
+	err := s.db.Where("certificate_id = ?", cert.ID).First(&state).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		state = models.CertificateExpiryState{CertificateID: cert.ID, ExpiresAt: expiresAt}
+	case err != nil:
+		return fmt.Errorf("load expiry state: %w", err)
+	case !state.ExpiresAt.Equal(expiresAt):
+		// Certificate was renewed (or replaced) - start alerting from scratch
+		state.ExpiresAt = expiresAt
+		state.LastThresholdDays = 0
+		state.ExpiredNotified = false
+	}
+
+	remaining := expiresAt.Sub(now)
+	notify := false
+
+	if remaining <= 0 {
+		if !state.ExpiredNotified {
+			state.ExpiredNotified = true
+			notify = true
+			s.sendExpiredNotification(cert, expiresAt)
+		}
+	} else {
+		// Days remaining, rounded up so a cert expiring in 6.5 days counts as 7
+		daysLeft := int((remaining + 24*time.Hour - 1) / (24 * time.Hour))
+		crossed := 0
+		for _, t := range thresholds {
+			if daysLeft <= t {
+				crossed = t // thresholds are sorted descending, keep the smallest crossed
+			}
+		}
+		if crossed > 0 && (state.LastThresholdDays == 0 || crossed < state.LastThresholdDays) {
+			state.LastThresholdDays = crossed
+			notify = true
+			s.sendExpiringNotification(cert, expiresAt, daysLeft, crossed)
+		}
+	}
+
+	if notify {
+		state.LastNotifiedAt = now
+	}
+	if state.ID == 0 {
+		return s.db.Create(&state).Error
+	}
+	return s.db.Save(&state).Error
+}
+
+func (s *CertificateExpiryService) sendExpiringNotification(cert *models.SSLCertificate, expiresAt time.Time, daysLeft, threshold int) {
+	name := certDisplayName(cert)
+	title := fmt.Sprintf("⚠️ Certificate %s expires in %d day(s)", name, daysLeft)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Certificate: %s\n", name))
+	sb.WriteString(fmt.Sprintf("Domains: %s\n", cert.Domains))
+	sb.WriteString(fmt.Sprintf("Provider: %s\n", cert.Provider))
+	sb.WriteString(fmt.Sprintf("Expires: %s\n", expiresAt.Format(time.RFC1123)))
+	if cert.Provider == "custom" {
+		sb.WriteString("This is a custom certificate and will not be renewed automatically.\n")
+	}
+
+	s.dispatch(models.NotificationTypeWarning, title, sb.String(), map[string]interface{}{
+		"Name":      util.SanitizeForLog(name),
+		"Domains":   util.SanitizeForLog(cert.Domains),
+		"Provider":  cert.Provider,
+		"ExpiresAt": expiresAt.Format(time.RFC3339),
+		"DaysLeft":  daysLeft,
+		"Threshold": threshold,
+		"Action":    "expiring",
+	})
+}
+
+func (s *CertificateExpiryService) sendExpiredNotification(cert *models.SSLCertificate, expiresAt time.Time) {
+	name := certDisplayName(cert)
+	title := fmt.Sprintf("🔴 Certificate %s has EXPIRED", name)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Certificate: %s\n", name))
+	sb.WriteString(fmt.Sprintf("Domains: %s\n", cert.Domains))
+	sb.WriteString(fmt.Sprintf("Provider: %s\n", cert.Provider))
+	sb.WriteString(fmt.Sprintf("Expired: %s\n", expiresAt.Format(time.RFC1123)))
+
+	s.dispatch(models.NotificationTypeError, title, sb.String(), map[string]interface{}{
+		"Name":      util.SanitizeForLog(name),
+		"Domains":   util.SanitizeForLog(cert.Domains),
+		"Provider":  cert.Provider,
+		"ExpiresAt": expiresAt.Format(time.RFC3339),
+		"DaysLeft":  0,
+		"Action":    "expired",
+	})
+}
+
+func (s *CertificateExpiryService) dispatch(nType models.NotificationType, title, message string, data map[string]interface{}) {
+	if s.notificationService == nil {
+		return
+	}
+	_, _ = s.notificationService.Create(nType, title, message)
+	s.notificationService.SendExternal(context.Background(), "cert", title, message, data)
+	logger.Log().WithField("title", util.SanitizeForLog(title)).Info("CertificateExpiryService: sent expiry notification")
+}
+
+// cleanupStaleStates removes state rows for certificates that no longer exist.
+func (s *CertificateExpiryService) cleanupStaleStates(certs []models.SSLCertificate) {
+	ids := make([]uint, 0, len(certs))
+	for _, c := range certs {
+		ids = append(ids, c.ID)
+	}
+	q := s.db.Model(&models.CertificateExpiryState{})
+	if len(ids) > 0 {
+		q = q.Where("certificate_id NOT IN ?", ids)
+	} else {
+		q = q.Where("1 = 1")
+	}
+	if err := q.Delete(&models.CertificateExpiryState{}).Error; err != nil {
+		logger.Log().WithError(err).Warn("CertificateExpiryService: failed to clean up stale expiry state")
+	}
+}
+
+func certDisplayName(cert *models.SSLCertificate) string {
+	if cert.Name != "" {
+		return cert.Name
+	}
+	return cert.Domains
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func setupCertExpiryTestDB(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&models.SSLCertificate{},
+		&models.CertificateExpiryState{},
+		&models.Setting{},
+		&models.Notification{},
+		&models.NotificationProvider{},
+	))
+	return db
+}
+
+func countCertNotifications(t *testing.T, db *gorm.DB) int64 {
+	var count int64
+	require.NoError(t, db.Model(&models.Notification{}).Count(&count).Error)
+	return count
+}
+
+func TestParseCertExpiryThresholds(t *testing.T) {
+	got, err := ParseCertExpiryThresholds("7, 30,1,14,7")
+	require.NoError(t, err)
+	assert.Equal(t, []int{30, 14, 7, 1}, got)
+
+	_, err = ParseCertExpiryThresholds("30,abc")
+	assert.Error(t, err)
+
+	_, err = ParseCertExpiryThresholds("-1")
+	assert.Error(t, err)
+}
+
+func TestCertificateExpiryService_GetThresholds(t *testing.T) {
+	db := setupCertExpiryTestDB(t)
+	svc := NewCertificateExpiryService(db, nil, nil)
+
+	assert.Equal(t, DefaultCertExpiryThresholds, svc.GetThresholds())
+
+	db.Create(&models.Setting{Key: CertExpiryThresholdsSettingKey, Value: "10,3"})
+	assert.Equal(t, []int{10, 3}, svc.GetThresholds())
+
+	db.Model(&models.Setting{}).Where("key = ?", CertExpiryThresholdsSettingKey).Update("value", "bogus")
+	assert.Equal(t, DefaultCertExpiryThresholds, svc.GetThresholds())
+}
+
+func TestCertificateExpiryService_NotifiesEachThresholdOnce(t *testing.T) {
+	db := setupCertExpiryTestDB(t)
+	ns := NewNotificationService(db)
+	svc := NewCertificateExpiryService(db, ns, nil)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	expires := now.Add(20 * 24 * time.Hour)
+	cert := models.SSLCertificate{UUID: "c1", Name: "example", Provider: "custom", Domains: "example.com", ExpiresAt: &expires}
+	require.NoError(t, db.Create(&cert).Error)
+
+	// 20 days left crosses the 30 day threshold
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(1), countCertNotifications(t, db))
+
+	var state models.CertificateExpiryState
+	require.NoError(t, db.Where("certificate_id = ?", cert.ID).First(&state).Error)
+	assert.Equal(t, 30, state.LastThresholdDays)
+
+	// Same threshold again: no new notification
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(1), countCertNotifications(t, db))
+
+	// Jump straight to 5 days left: only the 7 day threshold fires (not 14 as well)
+	now = expires.Add(-5 * 24 * time.Hour)
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(2), countCertNotifications(t, db))
+	require.NoError(t, db.Where("certificate_id = ?", cert.ID).First(&state).Error)
+	assert.Equal(t, 7, state.LastThresholdDays)
+
+	// Expired
+	now = expires.Add(time.Hour)
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(3), countCertNotifications(t, db))
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(3), countCertNotifications(t, db))
+
+	var last models.Notification
+	require.NoError(t, db.Order("created_at desc").First(&last).Error)
+	assert.Equal(t, models.NotificationTypeError, last.Type)
+	assert.Contains(t, last.Title, "EXPIRED")
+}
+
+func TestCertificateExpiryService_StartChecksImmediately(t *testing.T) {
+	db := setupCertExpiryTestDB(t)
+	ns := NewNotificationService(db)
+	svc := NewCertificateExpiryService(db, ns, nil)
+
+	expires := time.Now().Add(3 * 24 * time.Hour)
+	cert := models.SSLCertificate{UUID: "c-start", Name: "soon", Provider: "custom", Domains: "soon.example.com", ExpiresAt: &expires}
+	require.NoError(t, db.Create(&cert).Error)
+
+	svc.Start()
+	defer svc.Stop()
+
+	// The first check doesn't wait for the hourly schedule
+	assert.Eventually(t, func() bool {
+		var count int64
+		db.Model(&models.Notification{}).Count(&count)
+		return count == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Len(t, svc.Cron.Entries(), 1)
+}
+
+func TestCertificateExpiryService_ResetsAfterRenewal(t *testing.T) {
+	db := setupCertExpiryTestDB(t)
+	ns := NewNotificationService(db)
+	svc := NewCertificateExpiryService(db, ns, nil)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	expires := now.Add(10 * 24 * time.Hour)
+	cert := models.SSLCertificate{UUID: "c2", Name: "renewed", Provider: "letsencrypt", Domains: "renew.example.com", ExpiresAt: &expires}
+	require.NoError(t, db.Create(&cert).Error)
+
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(1), countCertNotifications(t, db))
+
+	// Renewal pushes expiry out 90 days: state resets, nothing to notify
+	renewed := now.Add(90 * 24 * time.Hour)
+	require.NoError(t, db.Model(&cert).Update("expires_at", &renewed).Error)
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(1), countCertNotifications(t, db))
+
+	var state models.CertificateExpiryState
+	require.NoError(t, db.Where("certificate_id = ?", cert.ID).First(&state).Error)
+	assert.Equal(t, 0, state.LastThresholdDays)
+	assert.True(t, state.ExpiresAt.Equal(renewed))
+
+	// 60 days later the renewed cert crosses 30 days again and alerts anew
+	now = now.Add(65 * 24 * time.Hour)
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(2), countCertNotifications(t, db))
+}
+
+func TestCertificateExpiryService_SkipsCertsWithoutExpiryAndCleansState(t *testing.T) {
+	db := setupCertExpiryTestDB(t)
+	svc := NewCertificateExpiryService(db, NewNotificationService(db), nil)
+
+	cert := models.SSLCertificate{UUID: "c3", Name: "no-expiry", Provider: "custom", Domains: "none.example.com"}
+	require.NoError(t, db.Create(&cert).Error)
+	require.NoError(t, db.Create(&models.CertificateExpiryState{CertificateID: 9999}).Error)
+
+	require.NoError(t, svc.CheckAll())
+	assert.Equal(t, int64(0), countCertNotifications(t, db))
+
+	var count int64
+	db.Model(&models.CertificateExpiryState{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Supported certificate export formats.
+const (
+	CertExportFormatPEM    = "pem"    // single file: fullchain followed by private key
+	CertExportFormatZip    = "zip"    // cert.pem, chain.pem, fullchain.pem and privkey.pem
+	CertExportFormatPKCS12 = "pkcs12" // password-protected .p12 bundle
+)
+
+var (
+	ErrCertExportFormat      = errors.New("unsupported export format")
+	ErrCertKeyUnavailable    = errors.New("private key for certificate is not available")
+	ErrCertExportPassword    = errors.New("a password is required for PKCS#12 export")
+	ErrCertExportInvalidData = errors.New("stored certificate material is invalid")
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// CertificateExport is a rendered certificate bundle ready to be downloaded.
+type CertificateExport struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	Certificate *models.SSLCertificate
+}
+
+// ExportCertificate renders the certificate with the given ID in the requested format.
+// Custom certificates are read from the database; ACME certificates additionally need
+// their private key, which is located in Caddy's storage next to the .crt file.
+func (s *CertificateService) ExportCertificate(id uint, format, password string) (*CertificateExport, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = CertExportFormatPEM
+	}
+	if format == "p12" || format == "pfx" {
+		format = CertExportFormatPKCS12
+	}
+	switch format {
+	case CertExportFormatPEM, CertExportFormatZip:
+	case CertExportFormatPKCS12:
+		if password == "" {
+			return nil, ErrCertExportPassword
+		}
+	default:
+		return nil, ErrCertExportFormat
+	}
+
+	var cert models.SSLCertificate
+	if err := s.db.First(&cert, id).Error; err != nil {
+		return nil, err
+	}
+
+	certPEM, keyPEM, err := s.loadCertificateMaterial(&cert)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, chain, err := splitCertificateChain(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := exportBaseName(&cert)
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+	var chainPEM []byte
+	for _, c := range chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	fullchain := append(append([]byte{}, leafPEM...), chainPEM...)
+	keyOut := []byte(strings.TrimSpace(keyPEM) + "\n")
+
+	export := &CertificateExport{Certificate: &cert}
+	switch format {
+	case CertExportFormatPEM:
+		export.Filename = baseName + ".pem"
+		export.ContentType = "application/x-pem-file"
+		export.Data = append(fullchain, keyOut...)
+	case CertExportFormatZip:
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		files := []struct {
+			name string
+			data []byte
+		}{
+			{"cert.pem", leafPEM},
+			{"chain.pem", chainPEM},
+			{"fullchain.pem", fullchain},
+			{"privkey.pem", keyOut},
+		}
+		for _, f := range files {
+			w, err := zw.Create(f.name)
+			if err != nil {
+				return nil, fmt.Errorf("create zip entry: %w", err)
+			}
+			if _, err := w.Write(f.data); err != nil {
+				return nil, fmt.Errorf("write zip entry: %w", err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("finalize zip: %w", err)
+		}
+		export.Filename = baseName + ".zip"
+		export.ContentType = "application/zip"
+		export.Data = buf.Bytes()
+	case CertExportFormatPKCS12:
+		data, err := pkcs12.Modern.Encode(key, leaf, chain, password)
+		if err != nil {
+			return nil, fmt.Errorf("encode pkcs12: %w", err)
+		}
+		export.Filename = baseName + ".p12"
+		export.ContentType = "application/x-pkcs12"
+		export.Data = data
+	}
+
+	return export, nil
+}
+
+// loadCertificateMaterial returns the PEM certificate (chain) and private key for a certificate.
+func (s *CertificateService) loadCertificateMaterial(cert *models.SSLCertificate) (string, string, error) {
+	if cert.PrivateKey != "" {
+		return cert.Certificate, cert.PrivateKey, nil
+	}
+	if !strings.HasPrefix(cert.Provider, "letsencrypt") {
+		return "", "", ErrCertKeyUnavailable
+	}
+
+	// ACME certificates: Caddy stores <domain>/<domain>.crt and <domain>.key under
+	// certificates/<issuer>/. Prefer the on-disk cert too, since it may be fresher than the DB copy.
+	// Wildcard names are stored with "*." written as "wildcard_.".
+	certRoot := filepath.Join(s.dataDir, "certificates")
+	storageName := cert.Domains
+	if strings.HasPrefix(storageName, "*.") {
+		storageName = "wildcard_" + strings.TrimPrefix(storageName, "*")
+	}
+	wantName := storageName + ".crt"
+	var certPath string
+	_ = filepath.Walk(certRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != wantName {
+			return nil
+		}
+		// Mirror SyncFromDisk: staging certificates live in acme-staging directories
+		if strings.Contains(path, "acme-staging") != strings.Contains(cert.Provider, "staging") {
+			return nil
+		}
+		certPath = path
+		return filepath.SkipAll
+	})
+	if certPath == "" {
+		return "", "", ErrCertKeyUnavailable
+	}
+
+	keyData, err := os.ReadFile(strings.TrimSuffix(certPath, ".crt") + ".key")
+	if err != nil {
+		return "", "", ErrCertKeyUnavailable
+	}
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		certData = []byte(cert.Certificate)
+	}
+	return string(certData), string(keyData), nil
+}
+
+// splitCertificateChain parses a PEM bundle into the leaf and the remaining chain.
+func splitCertificateChain(certPEM string) (*x509.Certificate, []*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(certPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrCertExportInvalidData, err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("%w: no certificate found", ErrCertExportInvalidData)
+	}
+	return certs[0], certs[1:], nil
+}
+
+// parsePrivateKeyPEM accepts PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) encoded keys.
+func parsePrivateKeyPEM(keyPEM string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("%w: invalid private key PEM", ErrCertExportInvalidData)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("%w: unsupported private key type", ErrCertExportInvalidData)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unable to parse private key", ErrCertExportInvalidData)
+}
+
+func exportBaseName(cert *models.SSLCertificate) string {
+	name := cert.Name
+	if name == "" {
+		name = strings.Split(cert.Domains, ",")[0]
+	}
+	name = strings.ReplaceAll(name, "*", "wildcard")
+	name = unsafeFilenameChars.ReplaceAllString(strings.TrimSpace(name), "_")
+	name = strings.Trim(name, "._")
+	if name == "" {
+		name = "certificate"
+	}
+	return name
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// generateTestCertAndKey returns a self-signed EC certificate and its PKCS#8 key as PEM.
+func generateTestCertAndKey(t *testing.T, domain string) (string, string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(keyPEM)
+}
+
+func setupCertExportTest(t *testing.T) (*CertificateService, *gorm.DB, string) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.SSLCertificate{}, &models.ProxyHost{}))
+	dataDir := t.TempDir()
+	return newTestCertificateService(dataDir, db), db, dataDir
+}
+
+func TestExportCertificate_CustomPEM(t *testing.T) {
+	svc, db, _ := setupCertExportTest(t)
+	certPEM, keyPEM := generateTestCertAndKey(t, "mail.example.com")
+	cert := models.SSLCertificate{UUID: "u1", Name: "Mail Server", Provider: "custom", Domains: "mail.example.com", Certificate: certPEM, PrivateKey: keyPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	export, err := svc.ExportCertificate(cert.ID, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Mail_Server.pem", export.Filename)
+	assert.Contains(t, string(export.Data), "BEGIN CERTIFICATE")
+	assert.Contains(t, string(export.Data), "BEGIN PRIVATE KEY")
+}
+
+func TestExportCertificate_Zip(t *testing.T) {
+	svc, db, _ := setupCertExportTest(t)
+	certPEM, keyPEM := generateTestCertAndKey(t, "zip.example.com")
+	cert := models.SSLCertificate{UUID: "u2", Name: "zip.example.com", Provider: "custom", Domains: "zip.example.com", Certificate: certPEM, PrivateKey: keyPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	export, err := svc.ExportCertificate(cert.ID, "zip", "")
+	require.NoError(t, err)
+	assert.Equal(t, "application/zip", export.ContentType)
+
+	zr, err := zip.NewReader(bytes.NewReader(export.Data), int64(len(export.Data)))
+	require.NoError(t, err)
+	names := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		names[f.Name] = string(b)
+	}
+	assert.Contains(t, names, "cert.pem")
+	assert.Contains(t, names, "chain.pem")
+	assert.Contains(t, names, "fullchain.pem")
+	assert.Contains(t, names["privkey.pem"], "PRIVATE KEY")
+}
+
+func TestExportCertificate_PKCS12(t *testing.T) {
+	svc, db, _ := setupCertExportTest(t)
+	certPEM, keyPEM := generateTestCertAndKey(t, "p12.example.com")
+	cert := models.SSLCertificate{UUID: "u3", Name: "p12", Provider: "custom", Domains: "p12.example.com", Certificate: certPEM, PrivateKey: keyPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	_, err := svc.ExportCertificate(cert.ID, "pkcs12", "")
+	assert.ErrorIs(t, err, ErrCertExportPassword)
+
+	export, err := svc.ExportCertificate(cert.ID, "pfx", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "p12.p12", export.Filename)
+
+	key, leaf, _, err := pkcs12.DecodeChain(export.Data, "s3cret")
+	require.NoError(t, err)
+	assert.NotNil(t, key)
+	assert.Equal(t, "p12.example.com", leaf.Subject.CommonName)
+
+	_, _, _, err = pkcs12.DecodeChain(export.Data, "wrong")
+	assert.Error(t, err)
+}
+
+func TestExportCertificate_ACMEFromDisk(t *testing.T) {
+	svc, db, dataDir := setupCertExportTest(t)
+	certPEM, keyPEM := generateTestCertAndKey(t, "acme.example.com")
+
+	dir := filepath.Join(dataDir, "certificates", "acme-v02.api.letsencrypt.org-directory", "acme.example.com")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme.example.com.crt"), []byte(certPEM), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "acme.example.com.key"), []byte(keyPEM), 0600))
+
+	cert := models.SSLCertificate{UUID: "u4", Name: "acme.example.com", Provider: "letsencrypt", Domains: "acme.example.com", Certificate: certPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	export, err := svc.ExportCertificate(cert.ID, "pem", "")
+	require.NoError(t, err)
+	assert.Contains(t, string(export.Data), "BEGIN PRIVATE KEY")
+
+	// Staging cert with the same domain has no key on disk in a staging directory
+	staging := models.SSLCertificate{UUID: "u5", Name: "staging", Provider: "letsencrypt-staging", Domains: "acme.example.com", Certificate: certPEM}
+	require.NoError(t, db.Create(&staging).Error)
+	_, err = svc.ExportCertificate(staging.ID, "pem", "")
+	assert.ErrorIs(t, err, ErrCertKeyUnavailable)
+}
+
+func TestExportCertificate_ACMEWildcardFromDisk(t *testing.T) {
+	svc, db, dataDir := setupCertExportTest(t)
+	certPEM, keyPEM := generateTestCertAndKey(t, "*.example.com")
+
+	dir := filepath.Join(dataDir, "certificates", "acme-v02.api.letsencrypt.org-directory", "wildcard_.example.com")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wildcard_.example.com.crt"), []byte(certPEM), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wildcard_.example.com.key"), []byte(keyPEM), 0600))
+
+	cert := models.SSLCertificate{UUID: "u6", Name: "*.example.com", Provider: "letsencrypt", Domains: "*.example.com", Certificate: certPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	export, err := svc.ExportCertificate(cert.ID, "pem", "")
+	require.NoError(t, err)
+	assert.Contains(t, string(export.Data), "BEGIN PRIVATE KEY")
+}
+
+func TestExportCertificate_Errors(t *testing.T) {
+	svc, db, _ := setupCertExportTest(t)
+	certPEM, _ := generateTestCertAndKey(t, "nokey.example.com")
+	cert := models.SSLCertificate{UUID: "u6", Name: "nokey", Provider: "custom", Domains: "nokey.example.com", Certificate: certPEM}
+	require.NoError(t, db.Create(&cert).Error)
+
+	_, err := svc.ExportCertificate(cert.ID, "der", "")
+	assert.ErrorIs(t, err, ErrCertExportFormat)
+
+	_, err = svc.ExportCertificate(cert.ID, "pem", "")
+	assert.ErrorIs(t, err, ErrCertKeyUnavailable)
+
+	_, err = svc.ExportCertificate(9999, "pem", "")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
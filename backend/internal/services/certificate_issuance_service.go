@@ -0,0 +1,517 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+	"github.com/Wikid82/charon/backend/internal/util"
+)
+
+// Issuance statuses reported per domain.
+const (
+	IssuanceStatusObtaining = "obtaining"
+	IssuanceStatusIssued    = "issued"
+	IssuanceStatusRetrying  = "retrying"
+	IssuanceStatusFailed    = "failed"
+)
+
+var obtainErrorDomainRegex = regexp.MustCompile(`^\[([^\]]+)\]`)
+
+// hostnameRegex matches DNS hostnames: dot-separated labels of letters, digits
+// and inner hyphens, each at most 63 characters.
+var hostnameRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// CertificateIssuanceService follows Caddy's TLS log to track ACME issuance per domain,
+// and performs pre-flight checks that a domain can pass an HTTP-01 challenge.
+type CertificateIssuanceService struct {
+	db     *gorm.DB
+	logDir string
+
+	// Network operations used by the pre-flight check, replaceable in tests
+	lookupHost  func(ctx context.Context, host string) ([]string, error)
+	dialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	// logWait is how long the pre-flight check waits for its request to show up in access.log
+	logWait time.Duration
+
+	mu     sync.Mutex
+	offset int64
+	stop   chan struct{}
+}
+
+// NewCertificateIssuanceService creates the service. logDir is the directory Caddy
+// writes access.log and the TLS event log (caddy.TLSLogFilename) into.
+func NewCertificateIssuanceService(db *gorm.DB, logDir string) *CertificateIssuanceService {
+	return &CertificateIssuanceService{
+		db:          db,
+		logDir:      logDir,
+		lookupHost:  net.DefaultResolver.LookupHost,
+		dialContext: (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		logWait:     3 * time.Second,
+	}
+}
+
+// caddyTLSLogEntry is the subset of Caddy's JSON log line we care about.
+type caddyTLSLogEntry struct {
+	Level      string          `json:"level"`
+	Timestamp  float64         `json:"ts"`
+	Logger     string          `json:"logger"`
+	Msg        string          `json:"msg"`
+	Identifier string          `json:"identifier"`
+	Issuer     string          `json:"issuer"`
+	CA         string          `json:"ca"`
+	Challenge  string          `json:"challenge_type"`
+	Error      string          `json:"error"`
+	Attempt    int             `json:"attempt"`
+	RetryingIn float64         `json:"retrying_in"` // seconds
+	Problem    json.RawMessage `json:"problem"`
+}
+
+// Start polls the TLS log at the given interval until Stop is called.
+func (s *CertificateIssuanceService) Start(interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.PollLog(); err != nil {
+				logger.Log().WithError(err).Debug("CertificateIssuanceService: poll failed")
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop halts background polling.
+func (s *CertificateIssuanceService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// PollLog reads any new lines from the TLS log and applies them.
+// Log rotation (file shrinking) resets the read offset.
+func (s *CertificateIssuanceService) PollLog() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.logDir, caddy.TLSLogFilename)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < s.offset {
+		s.offset = 0
+	}
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			s.offset += int64(len(line))
+			if perr := s.ProcessLogLine(line); perr != nil {
+				logger.Log().WithError(perr).Debug("CertificateIssuanceService: skipping log line")
+			}
+		}
+		if err != nil {
+			// Partial trailing line: leave it for the next poll
+			break
+		}
+	}
+	return nil
+}
+
+// ProcessLogLine applies a single Caddy TLS log event to the per-domain issuance state.
+// Events older than the last processed event for a domain are ignored, so
+// re-reading the log after a restart does not double count attempts.
+func (s *CertificateIssuanceService) ProcessLogLine(line []byte) error {
+	var entry caddyTLSLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(entry.Logger, "tls.") {
+		return nil
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(entry.Identifier))
+	if domain == "" && entry.Error != "" {
+		if m := obtainErrorDomainRegex.FindStringSubmatch(entry.Error); len(m) == 2 {
+			domain = strings.ToLower(m[1])
+		}
+	}
+	if domain == "" {
+		return nil
+	}
+
+	ts := time.Now()
+	if entry.Timestamp > 0 {
+		sec, frac := math.Modf(entry.Timestamp)
+		ts = time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	var rec models.CertificateIssuance
+	err := s.db.Where("domain = ?", domain).First(&rec).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && !ts.After(rec.LastEventAt) {
+		return nil
+	}
+
+	msg := strings.ToLower(entry.Msg)
+	changed := true
+	switch {
+	case msg == "obtaining certificate" || msg == "renewing certificate":
+		rec.Status = IssuanceStatusObtaining
+		rec.Attempts++
+		rec.LastAttemptAt = &ts
+		rec.NextRetryAt = nil
+	case msg == "trying to solve challenge":
+		rec.ChallengeType = entry.Challenge
+		if entry.CA != "" {
+			rec.Issuer = entry.CA
+		}
+		changed = rec.ID != 0 || rec.Status != ""
+	case strings.Contains(msg, "certificate obtained successfully") || strings.Contains(msg, "certificate renewed successfully"):
+		rec.Status = IssuanceStatusIssued
+		rec.IssuedAt = &ts
+		rec.LastError = ""
+		rec.ErrorKind = ""
+		rec.NextRetryAt = nil
+		rec.Attempts = 0
+		if entry.Issuer != "" {
+			rec.Issuer = entry.Issuer
+		}
+	case msg == "will retry":
+		rec.Status = IssuanceStatusRetrying
+		if entry.Error != "" {
+			rec.LastError = entry.Error
+			rec.ErrorKind = ClassifyIssuanceError(entry.Error)
+		}
+		if entry.Attempt > rec.Attempts {
+			rec.Attempts = entry.Attempt
+		}
+		if entry.RetryingIn > 0 {
+			next := ts.Add(time.Duration(entry.RetryingIn * float64(time.Second)))
+			rec.NextRetryAt = &next
+		}
+	case entry.Level == "error":
+		errText := entry.Error
+		if len(entry.Problem) > 0 {
+			var problem struct {
+				Type   string `json:"type"`
+				Detail string `json:"detail"`
+			}
+			if json.Unmarshal(entry.Problem, &problem) == nil && problem.Detail != "" {
+				errText = strings.TrimSpace(problem.Type + " " + problem.Detail)
+			}
+		}
+		if errText == "" {
+			errText = entry.Msg
+		}
+		rec.Status = IssuanceStatusFailed
+		rec.LastError = errText
+		rec.ErrorKind = ClassifyIssuanceError(errText)
+		if entry.Issuer != "" {
+			rec.Issuer = entry.Issuer
+		}
+		if rec.LastAttemptAt == nil {
+			rec.LastAttemptAt = &ts
+		}
+	default:
+		changed = false
+	}
+
+	if !changed {
+		return nil
+	}
+
+	rec.Domain = domain
+	rec.LastEventAt = ts
+	if rec.ID == 0 {
+		return s.db.Create(&rec).Error
+	}
+	return s.db.Save(&rec).Error
+}
+
+// ClassifyIssuanceError maps an ACME error string to a coarse category for the UI.
+func ClassifyIssuanceError(errText string) string {
+	e := strings.ToLower(errText)
+	switch {
+	case strings.Contains(e, "ratelimited") || strings.Contains(e, "rate limit") || strings.Contains(e, "too many"):
+		return "rate_limited"
+	case strings.Contains(e, "dns problem") || strings.Contains(e, "nxdomain") || strings.Contains(e, "no valid a records") || strings.Contains(e, "no such host"):
+		return "dns"
+	case strings.Contains(e, "acme:error:connection") || strings.Contains(e, "connection refused") || strings.Contains(e, "timeout during connect") || strings.Contains(e, "firewall"):
+		return "connection"
+	case strings.Contains(e, "unauthorized") || strings.Contains(e, "invalid response"):
+		return "unauthorized"
+	default:
+		return "other"
+	}
+}
+
+// List returns the issuance status of all tracked domains.
+func (s *CertificateIssuanceService) List() ([]models.CertificateIssuance, error) {
+	var res []models.CertificateIssuance
+	if err := s.db.Order("domain asc").Find(&res).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Get returns the issuance status of a single domain.
+func (s *CertificateIssuanceService) Get(domain string) (*models.CertificateIssuance, error) {
+	var rec models.CertificateIssuance
+	if err := s.db.Where("domain = ?", strings.ToLower(strings.TrimSpace(domain))).First(&rec).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// PreflightCheck is the outcome of one pre-flight step.
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Warning bool   `json:"warning,omitempty"`
+	Message string `json:"message"`
+}
+
+// PreflightResult summarises whether a domain is ready for HTTP-01 issuance.
+type PreflightResult struct {
+	Domain      string           `json:"domain"`
+	ResolvedIPs []string         `json:"resolved_ips"`
+	Checks      []PreflightCheck `json:"checks"`
+	OK          bool             `json:"ok"`
+}
+
+// Preflight verifies that a domain resolves and that an HTTP request for an
+// ACME challenge path reaches this Charon instance's Caddy. Reachability is
+// proven by requesting a random challenge token and finding it in Caddy's access log.
+// Domains resolving to private or loopback addresses are only requested when an
+// existing proxy host serves them, so the check can't be used to probe internal services.
+func (s *CertificateIssuanceService) Preflight(ctx context.Context, domain string) *PreflightResult {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	res := &PreflightResult{Domain: domain, ResolvedIPs: []string{}, OK: true}
+	add := func(c PreflightCheck) {
+		res.Checks = append(res.Checks, c)
+		if !c.OK && !c.Warning {
+			res.OK = false
+		}
+	}
+
+	if domain == "" {
+		add(PreflightCheck{Name: "domain", Message: "domain is required"})
+		return res
+	}
+	if strings.HasPrefix(domain, "*.") {
+		add(PreflightCheck{Name: "domain", Warning: true, Message: "wildcard certificates require the DNS-01 challenge; HTTP-01 checks skipped"})
+		return res
+	}
+	if ip := net.ParseIP(domain); ip != nil {
+		add(PreflightCheck{Name: "domain", Warning: true, Message: "IP addresses cannot get publicly trusted ACME certificates"})
+		return res
+	}
+	if len(domain) > 253 || !hostnameRegex.MatchString(domain) {
+		add(PreflightCheck{Name: "domain", Message: "domain must be a hostname, such as app.example.com"})
+		return res
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ips, err := s.lookupHost(lookupCtx, domain)
+	cancel()
+	if err != nil || len(ips) == 0 {
+		msg := "domain does not resolve"
+		if err != nil {
+			msg = fmt.Sprintf("DNS lookup failed: %v", err)
+		}
+		add(PreflightCheck{Name: "dns", Message: msg})
+		return res
+	}
+	res.ResolvedIPs = ips
+	dnsCheck := PreflightCheck{Name: "dns", OK: true, Message: "resolves to " + strings.Join(ips, ", ")}
+	for _, ipStr := range ips {
+		if ip := net.ParseIP(ipStr); ip != nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+			if !s.isHostDomain(domain) {
+				add(PreflightCheck{Name: "dns", Message: "resolves to " + strings.Join(ips, ", ") + "; private addresses are only checked for domains of existing proxy hosts"})
+				return res
+			}
+			dnsCheck.Warning = true
+			dnsCheck.Message += " (private address: public ACME validators cannot reach it)"
+			break
+		}
+	}
+	add(dnsCheck)
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		add(PreflightCheck{Name: "http01", Message: "failed to generate token"})
+		return res
+	}
+	nonce := "charon-preflight-" + hex.EncodeToString(token)
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		add(PreflightCheck{Name: "http01", Message: err.Error()})
+		return res
+	}
+	resp, err := s.challengeClient(ips).Do(req)
+	if err != nil {
+		add(PreflightCheck{Name: "http01", Message: fmt.Sprintf("port 80 not reachable: %v", err)})
+		return res
+	}
+	_ = resp.Body.Close()
+
+	if s.accessLogContains(nonce, s.logWait) {
+		add(PreflightCheck{Name: "http01", OK: true, Message: "challenge path reaches this instance"})
+	} else {
+		add(PreflightCheck{Name: "http01", Message: fmt.Sprintf("port 80 answered (HTTP %d) but the request did not reach this instance; check DNS and port forwarding", resp.StatusCode)})
+	}
+	return res
+}
+
+// challengeClient returns an HTTP client that connects to port 80 of the
+// addresses the pre-flight check already resolved and vetted, in order, instead
+// of resolving the domain again: a second lookup could return an address that
+// was never checked.
+func (s *CertificateIssuanceService) challengeClient(ips []string) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var lastErr error
+				for _, ip := range ips {
+					conn, err := s.dialContext(ctx, network, net.JoinHostPort(ip, "80"))
+					if err == nil {
+						return conn, nil
+					}
+					lastErr = err
+				}
+				return nil, lastErr
+			},
+			DisableKeepAlives: true,
+		},
+		// ACME validators follow redirects, but Caddy never redirects challenge paths;
+		// a redirect here means something other than Caddy answered.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// isHostDomain reports whether domain is one of the domain names of a proxy host.
+func (s *CertificateIssuanceService) isHostDomain(domain string) bool {
+	var names []string
+	if err := s.db.Model(&models.ProxyHost{}).Pluck("domain_names", &names).Error; err != nil {
+		return false
+	}
+	for _, list := range names {
+		for _, d := range strings.Split(list, ",") {
+			if strings.EqualFold(strings.TrimSpace(d), domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accessLogContains waits up to timeout for needle to appear near the end of access.log.
+func (s *CertificateIssuanceService) accessLogContains(needle string, timeout time.Duration) bool {
+	path := filepath.Join(s.logDir, "access.log")
+	deadline := time.Now().Add(timeout)
+	for {
+		if data, err := readFileTail(path, 256*1024); err == nil && strings.Contains(string(data), needle) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func readFileTail(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	start := info.Size() - max
+	if start < 0 {
+		start = 0
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// PreflightDomains runs Preflight for each comma-separated domain and logs failures.
+func (s *CertificateIssuanceService) PreflightDomains(ctx context.Context, domainNames string) ([]*PreflightResult, bool) {
+	ok := true
+	var results []*PreflightResult
+	for _, d := range strings.Split(domainNames, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		r := s.Preflight(ctx, d)
+		if !r.OK {
+			ok = false
+			logger.Log().WithField("domain", util.SanitizeForLog(d)).Info("ACME pre-flight check failed")
+		}
+		results = append(results, r)
+	}
+	return results, ok
+}
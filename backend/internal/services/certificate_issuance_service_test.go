@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func setupIssuanceTest(t *testing.T) (*CertificateIssuanceService, *gorm.DB, string) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.CertificateIssuance{}, &models.ProxyHost{}))
+	logDir := t.TempDir()
+	return NewCertificateIssuanceService(db, logDir), db, logDir
+}
+
+const (
+	tlsLogObtaining = `{"level":"info","ts":1700000000.1,"logger":"tls.obtain","msg":"obtaining certificate","identifier":"example.com"}`
+	tlsLogChallenge = `{"level":"info","ts":1700000000.2,"logger":"tls.issuance.acme.acme_client","msg":"trying to solve challenge","identifier":"example.com","challenge_type":"http-01","ca":"https://acme-v02.api.letsencrypt.org/directory"}`
+	tlsLogFailed    = `{"level":"error","ts":1700000001.0,"logger":"tls.issuance.acme.acme_client","msg":"challenge failed","identifier":"example.com","challenge_type":"http-01","problem":{"type":"urn:ietf:params:acme:error:connection","detail":"203.0.113.5: Timeout during connect (likely firewall problem)"}}`
+	tlsLogRetry     = `{"level":"error","ts":1700000002.0,"logger":"tls.obtain","msg":"will retry","error":"[example.com] Obtain: [example.com] solving challenge: 203.0.113.5: Timeout during connect","attempt":1,"retrying_in":60,"elapsed":2.1,"max_duration":2592000}`
+	tlsLogSuccess   = `{"level":"info","ts":1700000100.0,"logger":"tls.obtain","msg":"certificate obtained successfully","identifier":"example.com","issuer":"acme-v02.api.letsencrypt.org-directory"}`
+)
+
+func TestCertificateIssuanceService_ProcessLogLines(t *testing.T) {
+	svc, _, _ := setupIssuanceTest(t)
+
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogObtaining)))
+	rec, err := svc.Get("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, IssuanceStatusObtaining, rec.Status)
+	assert.Equal(t, 1, rec.Attempts)
+
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogChallenge)))
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogFailed)))
+	rec, _ = svc.Get("example.com")
+	assert.Equal(t, IssuanceStatusFailed, rec.Status)
+	assert.Equal(t, "http-01", rec.ChallengeType)
+	assert.Equal(t, "connection", rec.ErrorKind)
+	assert.Contains(t, rec.LastError, "Timeout during connect")
+
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogRetry)))
+	rec, _ = svc.Get("example.com")
+	assert.Equal(t, IssuanceStatusRetrying, rec.Status)
+	require.NotNil(t, rec.NextRetryAt)
+	assert.Equal(t, int64(1700000062), rec.NextRetryAt.Unix())
+
+	// Replaying an old event must not change state
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogObtaining)))
+	rec, _ = svc.Get("example.com")
+	assert.Equal(t, IssuanceStatusRetrying, rec.Status)
+	assert.Equal(t, 1, rec.Attempts)
+
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogSuccess)))
+	rec, _ = svc.Get("example.com")
+	assert.Equal(t, IssuanceStatusIssued, rec.Status)
+	assert.Empty(t, rec.LastError)
+	assert.Nil(t, rec.NextRetryAt)
+	require.NotNil(t, rec.IssuedAt)
+	assert.Equal(t, "acme-v02.api.letsencrypt.org-directory", rec.Issuer)
+}
+
+func TestCertificateIssuanceService_IgnoresUnrelatedLines(t *testing.T) {
+	svc, db, _ := setupIssuanceTest(t)
+
+	assert.Error(t, svc.ProcessLogLine([]byte("not json")))
+	require.NoError(t, svc.ProcessLogLine([]byte(`{"level":"info","logger":"http.log.access","msg":"handled request"}`)))
+	require.NoError(t, svc.ProcessLogLine([]byte(`{"level":"info","logger":"tls.obtain","msg":"acquiring lock"}`)))
+	// A challenge event without a prior attempt does not create a record
+	require.NoError(t, svc.ProcessLogLine([]byte(tlsLogChallenge)))
+
+	var count int64
+	db.Model(&models.CertificateIssuance{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestCertificateIssuanceService_PollLog(t *testing.T) {
+	svc, _, logDir := setupIssuanceTest(t)
+	path := filepath.Join(logDir, caddy.TLSLogFilename)
+
+	// Missing file is not an error
+	require.NoError(t, svc.PollLog())
+
+	require.NoError(t, os.WriteFile(path, []byte(tlsLogObtaining+"\n"+tlsLogRetry+"\n"+`{"level":"info","ts":17`), 0644))
+	require.NoError(t, svc.PollLog())
+	rec, err := svc.Get("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, IssuanceStatusRetrying, rec.Status)
+
+	// Rotation: file replaced with a shorter one
+	require.NoError(t, os.WriteFile(path, []byte(tlsLogSuccess+"\n"), 0644))
+	require.NoError(t, svc.PollLog())
+	rec, _ = svc.Get("example.com")
+	assert.Equal(t, IssuanceStatusIssued, rec.Status)
+
+	list, err := svc.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestClassifyIssuanceError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  string
+		want string
+	}{
+		{"rate limited", `HTTP 429 urn:ietf:params:acme:error:rateLimited - Error creating new order :: too many certificates (5) already issued for this exact set of domains in the last 168h0m0s`, "rate_limited"},
+		{"nxdomain", `HTTP 400 urn:ietf:params:acme:error:dns - DNS problem: NXDOMAIN looking up A for example.com - check that a DNS record exists for this domain`, "dns"},
+		{"no A records", `HTTP 400 urn:ietf:params:acme:error:dns - no valid A records found for example.com; no valid AAAA records found for example.com`, "dns"},
+		{"connection refused", `HTTP 400 urn:ietf:params:acme:error:connection - 203.0.113.5: Fetching http://example.com/.well-known/acme-challenge/abc: Connection refused`, "connection"},
+		{"firewall", `[example.com] Obtain: [example.com] solving challenge: 203.0.113.5: Timeout during connect (likely firewall problem)`, "connection"},
+		{"invalid response", `HTTP 403 urn:ietf:params:acme:error:unauthorized - 203.0.113.5: Invalid response from http://example.com/.well-known/acme-challenge/abc: 404`, "unauthorized"},
+		// Local network errors talking to the CA are not validation failures
+		{"reset by CA", `making new ACME client: performing request: Get "https://acme-v02.api.letsencrypt.org/directory": read tcp 10.0.0.2:51234->172.65.32.248:443: read: connection reset by peer`, "other"},
+		{"unknown", "something odd", "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClassifyIssuanceError(tt.err))
+		})
+	}
+}
+
+func TestCertificateIssuanceService_Preflight(t *testing.T) {
+	svc, _, logDir := setupIssuanceTest(t)
+
+	svc.logWait = 300 * time.Millisecond
+
+	// Stand-in for Caddy: records the request path in access.log like Caddy's access logger would
+	reachesUs := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reachesUs {
+			f, err := os.OpenFile(filepath.Join(logDir, "access.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err == nil {
+				_, _ = fmt.Fprintf(f, `{"request":{"uri":%q}}`+"\n", r.URL.Path)
+				_ = f.Close()
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	// The request goes to the address the lookup returned, not a second lookup
+	var dialed []string
+	svc.dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		if address != "203.0.113.10:80" {
+			return nil, errors.New("connection refused")
+		}
+		return net.Dial(network, srv.Listener.Addr().String())
+	}
+	svc.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"203.0.113.9", "203.0.113.10"}, nil
+	}
+
+	res := svc.Preflight(context.Background(), "app.example.com")
+	assert.True(t, res.OK, "%+v", res.Checks)
+	require.Len(t, res.Checks, 2)
+	assert.Equal(t, "http01", res.Checks[1].Name)
+	assert.True(t, res.Checks[1].OK)
+	assert.Equal(t, []string{"203.0.113.9:80", "203.0.113.10:80"}, dialed)
+
+	reachesUs = false
+	res = svc.Preflight(context.Background(), "app.example.com")
+	assert.False(t, res.OK)
+	assert.Contains(t, res.Checks[1].Message, "did not reach this instance")
+
+	svc.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	res = svc.Preflight(context.Background(), "missing.example.com")
+	assert.False(t, res.OK)
+	assert.Equal(t, "dns", res.Checks[0].Name)
+
+	res = svc.Preflight(context.Background(), "*.example.com")
+	assert.True(t, res.OK)
+	assert.True(t, res.Checks[0].Warning)
+
+	results, ok := svc.PreflightDomains(context.Background(), "missing.example.com, *.example.com")
+	assert.False(t, ok)
+	assert.Len(t, results, 2)
+}
+
+func TestCertificateIssuanceService_PreflightRejectsNonHostnames(t *testing.T) {
+	svc, _, _ := setupIssuanceTest(t)
+	svc.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		t.Fatalf("lookup of %q", host)
+		return nil, nil
+	}
+
+	for _, domain := range []string{"app.example.com:8080", "app.example.com/admin", "user@app.example.com", "app..example.com", "-app.example.com", strings.Repeat("a", 64) + ".example.com"} {
+		res := svc.Preflight(context.Background(), domain)
+		assert.False(t, res.OK, domain)
+		require.Len(t, res.Checks, 1, domain)
+		assert.Equal(t, "domain must be a hostname, such as app.example.com", res.Checks[0].Message, domain)
+	}
+}
+
+func TestCertificateIssuanceService_PreflightPrivateAddresses(t *testing.T) {
+	svc, db, _ := setupIssuanceTest(t)
+	svc.logWait = 0
+
+	requested := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	svc.dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return net.Dial(network, srv.Listener.Addr().String())
+	}
+	svc.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"127.0.0.1"}, nil
+	}
+
+	// Not a host domain: refused without a request
+	res := svc.Preflight(context.Background(), "app.example.com")
+	assert.False(t, res.OK)
+	require.Len(t, res.Checks, 1)
+	assert.Equal(t, "dns", res.Checks[0].Name)
+	assert.Contains(t, res.Checks[0].Message, "existing proxy hosts")
+	assert.Equal(t, 0, requested)
+
+	// Served by a proxy host: checked, with a warning
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "ph-1", DomainNames: "other.example.com, app.example.com", ForwardHost: "app", ForwardPort: 80}).Error)
+	res = svc.Preflight(context.Background(), "app.example.com")
+	require.Len(t, res.Checks, 2)
+	assert.True(t, res.Checks[0].Warning)
+	assert.Equal(t, 1, requested)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrClientCAInUse is returned when a client CA is linked to one or more proxy hosts.
+var ErrClientCAInUse = errors.New("client CA is in use by one or more proxy hosts")
+
+// ClientCAService manages uploaded certificate authorities used for client certificate (mTLS) verification.
+type ClientCAService struct {
+	db *gorm.DB
+}
+
+// NewClientCAService creates a new client CA service.
+func NewClientCAService(db *gorm.DB) *ClientCAService {
+	return &ClientCAService{db: db}
+}
+
+// List returns all client CAs.
+func (s *ClientCAService) List() ([]models.ClientCA, error) {
+	var cas []models.ClientCA
+	if err := s.db.Order("name").Find(&cas).Error; err != nil {
+		return nil, err
+	}
+	return cas, nil
+}
+
+// Create validates a PEM bundle of CA certificates and stores it.
+func (s *ClientCAService) Create(name, bundle string) (*models.ClientCA, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		if !cert.IsCA {
+			return nil, fmt.Errorf("certificate %q is not a CA certificate", cert.Subject.CommonName)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("invalid certificate PEM")
+	}
+
+	expiresAt := certs[0].NotAfter
+	for _, c := range certs[1:] {
+		if c.NotAfter.Before(expiresAt) {
+			expiresAt = c.NotAfter
+		}
+	}
+
+	ca := &models.ClientCA{
+		UUID:        uuid.New().String(),
+		Name:        name,
+		Certificate: bundle,
+		Subject:     certs[0].Subject.String(),
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.db.Create(ca).Error; err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// Delete removes a client CA that no proxy host references.
+func (s *ClientCAService) Delete(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.ProxyHost{}).Where("client_ca_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("check client CA linkage: %w", err)
+	}
+	if count > 0 {
+		return ErrClientCAInUse
+	}
+
+	var ca models.ClientCA
+	if err := s.db.First(&ca, id).Error; err != nil {
+		return err
+	}
+	return s.db.Delete(&ca).Error
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func generateTestCertPEM(t *testing.T, cn string, isCA bool) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func setupClientCATest(t *testing.T) (*ClientCAService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ClientCA{}, &models.ProxyHost{}, &models.RedirectionHost{}, &models.StaticSite{}))
+	return NewClientCAService(db), db
+}
+
+func TestClientCAService_Create(t *testing.T) {
+	svc, _ := setupClientCATest(t)
+
+	bundle := generateTestCertPEM(t, "Root CA", true) + generateTestCertPEM(t, "Issuing CA", true)
+	ca, err := svc.Create("corp", bundle)
+	require.NoError(t, err)
+	assert.NotEmpty(t, ca.UUID)
+	assert.Equal(t, "CN=Root CA", ca.Subject)
+	assert.False(t, ca.ExpiresAt.IsZero())
+
+	_, err = svc.Create("leaf", generateTestCertPEM(t, "client", false))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a CA certificate")
+
+	_, err = svc.Create("junk", "not pem")
+	assert.Error(t, err)
+	_, err = svc.Create("", bundle)
+	assert.Error(t, err)
+
+	list, err := svc.List()
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestClientCAService_DeleteInUse(t *testing.T) {
+	svc, db := setupClientCATest(t)
+	ca, err := svc.Create("corp", generateTestCertPEM(t, "Root CA", true))
+	require.NoError(t, err)
+
+	host := models.ProxyHost{UUID: "h", DomainNames: "admin.example.com", ForwardHost: "a", ForwardPort: 80, ClientAuthMode: "require", ClientCAID: &ca.ID}
+	require.NoError(t, db.Create(&host).Error)
+	assert.ErrorIs(t, svc.Delete(ca.ID), ErrClientCAInUse)
+
+	require.NoError(t, db.Model(&host).Updates(map[string]interface{}{"client_ca_id": nil, "client_auth_mode": ""}).Error)
+	require.NoError(t, svc.Delete(ca.ID))
+	assert.ErrorIs(t, svc.Delete(ca.ID), gorm.ErrRecordNotFound)
+}
+
+func TestProxyHostService_ValidateTLSSettings(t *testing.T) {
+	_, db := setupClientCATest(t)
+	svc := NewProxyHostService(db)
+
+	missing := uint(99)
+	host := &models.ProxyHost{UUID: "h", DomainNames: "admin.example.com", ForwardHost: "a", ForwardPort: 80, ClientAuthMode: "require", ClientCAID: &missing}
+	err := svc.Create(host)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "client CA not found")
+
+	host.ClientCAID = nil
+	host.ClientAuthMode = ""
+	host.TLSMinVersion = "tls1.1"
+	assert.Error(t, svc.Create(host))
+
+	host.TLSMinVersion = "tls1.2"
+	host.Compression = "br"
+	assert.ErrorContains(t, svc.Create(host), "unsupported compression")
+
+	host.Compression = "gzip"
+	assert.NoError(t, svc.Create(host))
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrErrorPageNotFound is returned when no error page has the given UUID.
+var ErrErrorPageNotFound = errors.New("error page not found")
+
+// ErrorPageService manages the error pages Caddy serves for hosts and upstream failures.
+type ErrorPageService struct {
+	db *gorm.DB
+}
+
+// NewErrorPageService creates a new error page service.
+func NewErrorPageService(db *gorm.DB) *ErrorPageService {
+	return &ErrorPageService{db: db}
+}
+
+// List returns all error pages, global pages first.
+func (s *ErrorPageService) List() ([]models.ErrorPage, error) {
+	var pages []models.ErrorPage
+	if err := s.db.Order("proxy_host_id IS NOT NULL, proxy_host_id, status_code").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// GetByUUID finds an error page by UUID.
+func (s *ErrorPageService) GetByUUID(id string) (*models.ErrorPage, error) {
+	var page models.ErrorPage
+	if err := s.db.Where("uuid = ?", id).First(&page).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrErrorPageNotFound
+		}
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Create validates and stores a new error page.
+func (s *ErrorPageService) Create(page *models.ErrorPage) error {
+	page.ID = 0
+	page.UUID = uuid.NewString()
+	if err := s.validate(page); err != nil {
+		return err
+	}
+	return s.db.Create(page).Error
+}
+
+// Update validates and saves an existing error page.
+func (s *ErrorPageService) Update(page *models.ErrorPage) error {
+	if err := s.validate(page); err != nil {
+		return err
+	}
+	return s.db.Save(page).Error
+}
+
+// Delete removes an error page.
+func (s *ErrorPageService) Delete(id uint) error {
+	return s.db.Delete(&models.ErrorPage{}, id).Error
+}
+
+// validate checks the page itself, that its proxy host exists and that the
+// host (or the global scope) has no other page for the status code.
+func (s *ErrorPageService) validate(page *models.ErrorPage) error {
+	if err := caddy.ValidateErrorPage(page); err != nil {
+		return err
+	}
+
+	q := s.db.Model(&models.ErrorPage{}).Where("status_code = ? AND id <> ?", page.StatusCode, page.ID)
+	if page.ProxyHostID == nil {
+		q = q.Where("proxy_host_id IS NULL")
+	} else {
+		var count int64
+		if err := s.db.Model(&models.ProxyHost{}).Where("id = ?", *page.ProxyHostID).Count(&count).Error; err != nil {
+			return fmt.Errorf("checking proxy host: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("proxy host %d not found", *page.ProxyHostID)
+		}
+		q = q.Where("proxy_host_id = ?", *page.ProxyHostID)
+	}
+	var existing int64
+	if err := q.Count(&existing).Error; err != nil {
+		return fmt.Errorf("checking for duplicate pages: %w", err)
+	}
+	if existing > 0 {
+		if page.ProxyHostID == nil {
+			return fmt.Errorf("a global error page for %d already exists", page.StatusCode)
+		}
+		return fmt.Errorf("proxy host %d already has an error page for %d", *page.ProxyHostID, page.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrMaintenanceWindowNotFound is returned when no maintenance window has the given UUID.
+var ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+
+// ConfigApplier pushes the current configuration to Caddy.
+type ConfigApplier interface {
+	ApplyConfig(ctx context.Context) error
+}
+
+// MaintenanceService manages maintenance windows and applies the config
+// whenever a window starts or ends.
+type MaintenanceService struct {
+	db      *gorm.DB
+	applier ConfigApplier
+	Cron    *cron.Cron
+	now     func() time.Time
+
+	mu      sync.Mutex
+	applied string // the windows that were active at the last apply
+}
+
+// NewMaintenanceService creates a new maintenance service. applier may be nil,
+// in which case nothing is pushed to Caddy.
+func NewMaintenanceService(db *gorm.DB, applier ConfigApplier) *MaintenanceService {
+	return &MaintenanceService{
+		db:      db,
+		applier: applier,
+		Cron:    cron.New(),
+		now:     time.Now,
+	}
+}
+
+// Start schedules the check for windows that start or end. The windows active
+// now are taken as applied, since the startup config already covers them.
+func (s *MaintenanceService) Start() {
+	s.mu.Lock()
+	if active, err := s.activeKey(); err == nil {
+		s.applied = active
+	}
+	s.mu.Unlock()
+	if _, err := s.Cron.AddFunc("@every 30s", func() {
+		if err := s.Sync(context.Background()); err != nil {
+			logger.Log().WithError(err).Error("MaintenanceService: failed to apply maintenance change")
+		}
+	}); err != nil {
+		logger.Log().WithError(err).Error("Failed to schedule maintenance window check")
+		return
+	}
+	s.Cron.Start()
+}
+
+// Stop halts the scheduler.
+func (s *MaintenanceService) Stop() {
+	s.Cron.Stop()
+}
+
+// List returns all maintenance windows with their current state.
+func (s *MaintenanceService) List() ([]models.MaintenanceWindow, error) {
+	var windows []models.MaintenanceWindow
+	if err := s.db.Order("proxy_host_id, id").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for i := range windows {
+		windows[i].Active, _ = caddy.MaintenanceActive(windows[i], now)
+	}
+	return windows, nil
+}
+
+// GetByUUID finds a maintenance window by UUID.
+func (s *MaintenanceService) GetByUUID(id string) (*models.MaintenanceWindow, error) {
+	var window models.MaintenanceWindow
+	if err := s.db.Where("uuid = ?", id).First(&window).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMaintenanceWindowNotFound
+		}
+		return nil, err
+	}
+	window.Active, _ = caddy.MaintenanceActive(window, s.now())
+	return &window, nil
+}
+
+// Create validates and stores a new window. A one-off window without
+// starts_at starts right away.
+func (s *MaintenanceService) Create(window *models.MaintenanceWindow) error {
+	window.ID = 0
+	window.UUID = uuid.NewString()
+	if window.Schedule == "" && window.StartsAt == nil {
+		now := s.now()
+		window.StartsAt = &now
+	}
+	if err := s.validate(window); err != nil {
+		return err
+	}
+	if err := s.db.Create(window).Error; err != nil {
+		return err
+	}
+	window.Active, _ = caddy.MaintenanceActive(*window, s.now())
+	return nil
+}
+
+// Update validates and saves an existing window.
+func (s *MaintenanceService) Update(window *models.MaintenanceWindow) error {
+	if err := s.validate(window); err != nil {
+		return err
+	}
+	if err := s.db.Save(window).Error; err != nil {
+		return err
+	}
+	window.Active, _ = caddy.MaintenanceActive(*window, s.now())
+	return nil
+}
+
+// Delete removes a window.
+func (s *MaintenanceService) Delete(id uint) error {
+	return s.db.Delete(&models.MaintenanceWindow{}, id).Error
+}
+
+// ApplyConfig pushes the config to Caddy and remembers which windows it covers.
+// Handlers apply through it so that Sync does not push the same change again.
+func (s *MaintenanceService) ApplyConfig(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.activeKey()
+	if err != nil {
+		return err
+	}
+	if s.applier != nil {
+		if err := s.applier.ApplyConfig(ctx); err != nil {
+			return err
+		}
+	}
+	s.applied = active
+	return nil
+}
+
+// Sync applies the config when a window has started or ended since the last apply.
+func (s *MaintenanceService) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	active, err := s.activeKey()
+	changed := err == nil && active != s.applied
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	logger.Log().WithField("active_windows", active).Info("Maintenance windows changed; applying config")
+	return s.ApplyConfig(caddy.WithChangeInfo(ctx, caddy.ChangeInfo{Reason: "maintenance window started or ended"}))
+}
+
+// activeKey identifies the set of windows active now.
+func (s *MaintenanceService) activeKey() (string, error) {
+	var windows []models.MaintenanceWindow
+	if err := s.db.Find(&windows).Error; err != nil {
+		return "", fmt.Errorf("fetch maintenance windows: %w", err)
+	}
+	now := s.now()
+	var ids []string
+	for _, w := range windows {
+		if ok, _ := caddy.MaintenanceActive(w, now); ok {
+			ids = append(ids, w.UUID)
+		}
+	}
+	sort.Strings(ids)
+	return fmt.Sprint(ids), nil
+}
+
+// validate checks the window itself and that its proxy host exists.
+func (s *MaintenanceService) validate(window *models.MaintenanceWindow) error {
+	if err := caddy.ValidateMaintenanceWindow(window); err != nil {
+		return err
+	}
+	var count int64
+	if err := s.db.Model(&models.ProxyHost{}).Where("id = ?", window.ProxyHostID).Count(&count).Error; err != nil {
+		return fmt.Errorf("checking proxy host: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("proxy host %d not found", window.ProxyHostID)
+	}
+	return nil
+}
+
+// proxyHostsInMaintenance returns the IDs of proxy hosts with an active window.
+func proxyHostsInMaintenance(db *gorm.DB, now time.Time) (map[uint]bool, error) {
+	var windows []models.MaintenanceWindow
+	if err := db.Where("enabled = ?", true).Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	hosts := make(map[uint]bool)
+	for _, w := range windows {
+		if ok, _ := caddy.MaintenanceActive(w, now); ok {
+			hosts[w.ProxyHostID] = true
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+type countingApplier struct{ calls int }
+
+func (a *countingApplier) ApplyConfig(context.Context) error {
+	a.calls++
+	return nil
+}
+
+func TestMaintenanceService_Sync(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.MaintenanceWindow{}))
+	host := models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&host).Error)
+
+	applier := &countingApplier{}
+	svc := NewMaintenanceService(db, applier)
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	svc.Start()
+	defer svc.Stop()
+
+	starts, ends := now.Add(time.Hour), now.Add(2*time.Hour)
+	window := models.MaintenanceWindow{ProxyHostID: host.ID, StartsAt: &starts, EndsAt: &ends}
+	require.NoError(t, svc.Create(&window))
+	assert.NotEmpty(t, window.UUID)
+	assert.False(t, window.Active)
+	assert.ErrorContains(t, svc.Create(&models.MaintenanceWindow{ProxyHostID: 99}), "proxy host 99 not found")
+
+	require.NoError(t, svc.Sync(context.Background()))
+	assert.Equal(t, 0, applier.calls, "nothing started yet")
+
+	now = starts.Add(time.Minute)
+	require.NoError(t, svc.Sync(context.Background()))
+	require.NoError(t, svc.Sync(context.Background()))
+	assert.Equal(t, 1, applier.calls, "the start is applied once")
+	windows, err := svc.List()
+	require.NoError(t, err)
+	require.Len(t, windows, 1)
+	assert.True(t, windows[0].Active)
+
+	now = ends
+	require.NoError(t, svc.Sync(context.Background()))
+	assert.Equal(t, 2, applier.calls, "the end is applied")
+
+	// A one-off window without starts_at starts right away
+	immediate := models.MaintenanceWindow{ProxyHostID: host.ID}
+	require.NoError(t, svc.Create(&immediate))
+	assert.Equal(t, now, *immediate.StartsAt)
+	assert.True(t, immediate.Active)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// OnDemandDecision is the answer to one on-demand TLS permission request.
+type OnDemandDecision struct {
+	Domain  string `json:"domain"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// OnDemandTLSService decides whether Caddy may obtain a certificate for a name
+// it has never seen before. A name is approved when it matches a configured
+// pattern or an enabled proxy host, and the hourly issuance budget is not spent.
+type OnDemandTLSService struct {
+	db *gorm.DB
+
+	mu sync.Mutex
+	// approvals holds domains approved in the last hour; re-asks for the same
+	// domain (Caddy retries) don't count against the limit again
+	approvals map[string]time.Time
+
+	// now is a test hook
+	now func() time.Time
+}
+
+// NewOnDemandTLSService creates a new on-demand TLS permission service.
+func NewOnDemandTLSService(db *gorm.DB) *OnDemandTLSService {
+	return &OnDemandTLSService{
+		db:        db,
+		approvals: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Check decides whether a certificate may be issued for domain.
+func (s *OnDemandTLSService) Check(domain string) OnDemandDecision {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	decision := OnDemandDecision{Domain: domain}
+
+	if domain == "" || strings.ContainsAny(domain, "*/: ") || net.ParseIP(domain) != nil {
+		decision.Reason = "invalid domain"
+		return decision
+	}
+	if !strings.EqualFold(s.setting(caddy.OnDemandTLSEnabledSettingKey), "true") {
+		decision.Reason = "on-demand TLS is disabled"
+		return decision
+	}
+	if !s.domainAllowed(domain) {
+		decision.Reason = "domain does not match an allowed pattern or proxy host"
+		return decision
+	}
+
+	limit := caddy.DefaultOnDemandTLSRateLimit
+	if raw := strings.TrimSpace(s.setting(caddy.OnDemandTLSRateLimitSettingKey)); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			limit = n
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for d, at := range s.approvals {
+		if now.Sub(at) >= time.Hour {
+			delete(s.approvals, d)
+		}
+	}
+	if _, ok := s.approvals[domain]; !ok {
+		// 0 means unlimited
+		if limit > 0 && len(s.approvals) >= limit {
+			decision.Reason = "hourly issuance limit reached"
+			return decision
+		}
+		s.approvals[domain] = now
+	}
+
+	decision.Allowed = true
+	return decision
+}
+
+func (s *OnDemandTLSService) domainAllowed(domain string) bool {
+	for _, pattern := range caddy.ParseOnDemandDomains(s.setting(caddy.OnDemandTLSDomainsSettingKey)) {
+		if caddy.MatchDomainPattern(pattern, domain) {
+			return true
+		}
+	}
+
+	var hosts []models.ProxyHost
+	if err := s.db.Select("domain_names").Where("enabled = ?", true).Find(&hosts).Error; err != nil {
+		return false
+	}
+	for _, host := range hosts {
+		for _, d := range strings.Split(host.DomainNames, ",") {
+			d = strings.TrimSpace(d)
+			if d != "" && caddy.MatchDomainPattern(d, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *OnDemandTLSService) setting(key string) string {
+	var setting models.Setting
+	if err := s.db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func setupOnDemandTLSTest(t *testing.T, settings map[string]string) (*OnDemandTLSService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}, &models.ProxyHost{}))
+	for k, v := range settings {
+		require.NoError(t, db.Create(&models.Setting{Key: k, Value: v}).Error)
+	}
+	return NewOnDemandTLSService(db), db
+}
+
+func TestOnDemandTLSService_Disabled(t *testing.T) {
+	svc, _ := setupOnDemandTLSTest(t, map[string]string{
+		caddy.OnDemandTLSDomainsSettingKey: "*.customers.example.com",
+	})
+	d := svc.Check("acme.customers.example.com")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "on-demand TLS is disabled", d.Reason)
+}
+
+func TestOnDemandTLSService_PatternsAndHosts(t *testing.T) {
+	svc, db := setupOnDemandTLSTest(t, map[string]string{
+		caddy.OnDemandTLSEnabledSettingKey: "true",
+		caddy.OnDemandTLSDomainsSettingKey: "*.customers.example.com, shop.example.org",
+	})
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "h1", DomainNames: "app.example.net, *.tenants.example.net", ForwardHost: "app", ForwardPort: 80, Enabled: true}).Error)
+	disabled := models.ProxyHost{UUID: "h2", DomainNames: "old.example.net", ForwardHost: "old", ForwardPort: 80, Enabled: true}
+	require.NoError(t, db.Create(&disabled).Error)
+	require.NoError(t, db.Model(&disabled).Update("enabled", false).Error)
+
+	assert.True(t, svc.Check("ACME.customers.example.com.").Allowed)
+	assert.True(t, svc.Check("shop.example.org").Allowed)
+	assert.True(t, svc.Check("app.example.net").Allowed)
+	assert.True(t, svc.Check("blue.tenants.example.net").Allowed)
+
+	assert.False(t, svc.Check("a.b.customers.example.com").Allowed, "wildcard matches one label only")
+	assert.False(t, svc.Check("customers.example.com").Allowed)
+	assert.False(t, svc.Check("old.example.net").Allowed, "disabled hosts are not approved")
+	assert.False(t, svc.Check("evil.example.com").Allowed)
+	assert.Equal(t, "invalid domain", svc.Check("203.0.113.1").Reason)
+	assert.Equal(t, "invalid domain", svc.Check("").Reason)
+}
+
+func TestOnDemandTLSService_RateLimit(t *testing.T) {
+	svc, _ := setupOnDemandTLSTest(t, map[string]string{
+		caddy.OnDemandTLSEnabledSettingKey:   "true",
+		caddy.OnDemandTLSDomainsSettingKey:   "*.example.com",
+		caddy.OnDemandTLSRateLimitSettingKey: "2",
+	})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	assert.True(t, svc.Check("a.example.com").Allowed)
+	assert.True(t, svc.Check("b.example.com").Allowed)
+	// Retries for an approved name don't consume budget
+	assert.True(t, svc.Check("a.example.com").Allowed)
+
+	d := svc.Check("c.example.com")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "hourly issuance limit reached", d.Reason)
+
+	now = now.Add(61 * time.Minute)
+	assert.True(t, svc.Check("c.example.com").Allowed)
+}
+
+func TestOnDemandTLSService_Unlimited(t *testing.T) {
+	svc, _ := setupOnDemandTLSTest(t, map[string]string{
+		caddy.OnDemandTLSEnabledSettingKey:   "true",
+		caddy.OnDemandTLSDomainsSettingKey:   "*.example.com",
+		caddy.OnDemandTLSRateLimitSettingKey: "0",
+	})
+	for i := 0; i < caddy.DefaultOnDemandTLSRateLimit+5; i++ {
+		assert.True(t, svc.Check(fmt.Sprintf("h%d.example.com", i)).Allowed)
+	}
+}
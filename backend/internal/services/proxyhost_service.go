@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/Wikid82/charon/backend/internal/caddy"
 
 	"gorm.io/gorm"
@@ -25,7 +27,9 @@ func NewProxyHostService(db *gorm.DB) *ProxyHostService {
 	return &ProxyHostService{db: db}
 }
 
-// ValidateUniqueDomain ensures no duplicate domains exist before creation/update.
+// ValidateUniqueDomain ensures no duplicate domains exist before creation/update:
+// no other proxy host has the same domain list, and no redirection host or
+// static site serves any of the domains.
 func (s *ProxyHostService) ValidateUniqueDomain(domainNames string, excludeID uint) error {
 	var count int64
 	query := s.db.Model(&models.ProxyHost{}).Where("domain_names = ?", domainNames)
@@ -42,15 +46,90 @@ func (s *ProxyHostService) ValidateUniqueDomain(domainNames string, excludeID ui
 		return errors.New("domain already exists")
 	}
 
+	// Proxy hosts sharing some domains are left to the config validator, which
+	// rejects the change that introduces the overlap
+	used, err := domainUsers(s.db, excludeID, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, d := range caddy.SplitDomains(domainNames) {
+		if owner, ok := used[d]; ok && owner.kind != "proxy host" {
+			return fmt.Errorf("domain %s is already used by %s", d, owner)
+		}
+	}
+	return nil
+}
+
+// ValidateTLSSettings checks the host's TLS connection policy and that its client CA exists.
+func (s *ProxyHostService) ValidateTLSSettings(host *models.ProxyHost) error {
+	if err := caddy.ValidateTLSPolicy(host); err != nil {
+		return err
+	}
+	if host.ClientCAID != nil {
+		var count int64
+		if err := s.db.Model(&models.ClientCA{}).Where("id = ?", *host.ClientCAID).Count(&count).Error; err != nil {
+			return fmt.Errorf("checking client CA: %w", err)
+		}
+		if count == 0 {
+			return errors.New("client CA not found")
+		}
+	}
 	return nil
 }
 
+// ValidateAll runs the semantic validator over every proxy host. If proposed is
+// set it is validated as if saved: it replaces the host with the same UUID, or
+// is added as the newest host.
+func (s *ProxyHostService) ValidateAll(proposed *models.ProxyHost, opts caddy.ValidationOptions) (caddy.ValidationReport, error) {
+	var hosts []models.ProxyHost
+	if err := s.db.Preload("Locations").Order("id").Find(&hosts).Error; err != nil {
+		return caddy.ValidationReport{}, fmt.Errorf("fetch proxy hosts: %w", err)
+	}
+	if proposed != nil {
+		replaced := false
+		for i := range hosts {
+			if hosts[i].UUID == proposed.UUID {
+				hosts[i] = *proposed
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			hosts = append(hosts, *proposed)
+		}
+	}
+	return caddy.ValidateHosts(hosts, opts), nil
+}
+
 // Create validates and creates a new proxy host.
 func (s *ProxyHostService) Create(host *models.ProxyHost) error {
 	if err := s.ValidateUniqueDomain(host.DomainNames, 0); err != nil {
 		return err
 	}
 
+	if err := s.ValidateTLSSettings(host); err != nil {
+		return err
+	}
+
+	if err := caddy.ValidatePerformance(host); err != nil {
+		return err
+	}
+
+	for i := range host.UpstreamGroups {
+		host.UpstreamGroups[i].ID = 0
+		host.UpstreamGroups[i].UUID = uuid.NewString()
+	}
+	if err := caddy.ValidateTrafficSplit(host.UpstreamGroups); err != nil {
+		return err
+	}
+	for i := range host.RoutingRules {
+		host.RoutingRules[i].ID = 0
+		host.RoutingRules[i].UUID = uuid.NewString()
+		if err := caddy.ValidateRoutingRule(&host.RoutingRules[i]); err != nil {
+			return fmt.Errorf("routing rule %d: %w", i+1, err)
+		}
+	}
+
 	// Normalize and validate advanced config (if present)
 	if host.AdvancedConfig != "" {
 		var parsed interface{}
@@ -74,6 +153,14 @@ func (s *ProxyHostService) Update(host *models.ProxyHost) error {
 		return err
 	}
 
+	if err := s.ValidateTLSSettings(host); err != nil {
+		return err
+	}
+
+	if err := caddy.ValidatePerformance(host); err != nil {
+		return err
+	}
+
 	// Normalize and validate advanced config (if present)
 	if host.AdvancedConfig != "" {
 		var parsed interface{}
@@ -93,7 +180,21 @@ func (s *ProxyHostService) Update(host *models.ProxyHost) error {
 
 // Delete removes a proxy host.
 func (s *ProxyHostService) Delete(id uint) error {
-	return s.db.Delete(&models.ProxyHost{}, id).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("proxy_host_id = ?", id).Delete(&models.UpstreamGroup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("proxy_host_id = ?", id).Delete(&models.RoutingRule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("proxy_host_id = ?", id).Delete(&models.ErrorPage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("proxy_host_id = ?", id).Delete(&models.MaintenanceWindow{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ProxyHost{}, id).Error
+	})
 }
 
 // GetByID retrieves a proxy host by ID.
@@ -125,12 +226,15 @@ func (s *ProxyHostService) List() ([]models.ProxyHost, error) {
 
 // TestConnection attempts to connect to the target host and port.
 func (s *ProxyHostService) TestConnection(host string, port int) error {
-	if host == "" || port <= 0 {
+	network, target := "tcp", net.JoinHostPort(host, strconv.Itoa(port))
+	if path, ok := caddy.UnixSocketPath(host); ok {
+		// Unix sockets have no port
+		network, target = "unix", path
+	} else if host == "" || port <= 0 {
 		return errors.New("invalid host or port")
 	}
 
-	target := net.JoinHostPort(host, strconv.Itoa(port))
-	conn, err := net.DialTimeout("tcp", target, 3*time.Second)
+	conn, err := net.DialTimeout(network, target, 3*time.Second)
 	if err != nil {
 		return fmt.Errorf("connection failed: %w", err)
 	}
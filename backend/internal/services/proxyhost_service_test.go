@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 	"net"
+	"path/filepath"
 	"testing"
 
 	"github.com/Wikid82/charon/backend/internal/models"
@@ -16,7 +17,7 @@ func setupProxyHostTestDB(t *testing.T) *gorm.DB {
 	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
 	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}))
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.Location{}, &models.UpstreamGroup{}, &models.RoutingRule{}, &models.RedirectionHost{}, &models.StaticSite{}, &models.ErrorPage{}, &models.MaintenanceWindow{}))
 	return db
 }
 
@@ -70,6 +71,19 @@ func TestProxyHostService_ValidateUniqueDomain(t *testing.T) {
 	}
 }
 
+func TestProxyHostService_ValidateUniqueDomain_RedirectsAndStaticSites(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+	require.NoError(t, db.Create(&models.RedirectionHost{UUID: "r", Name: "Old blog", DomainNames: "blog.example.com"}).Error)
+	require.NoError(t, db.Create(&models.StaticSite{UUID: "s", DomainNames: "docs.example.com"}).Error)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "p", DomainNames: "app.example.com, www.example.com"}).Error)
+
+	assert.EqualError(t, service.ValidateUniqueDomain("www.example.com, Blog.example.com", 0), "domain blog.example.com is already used by redirection host Old blog")
+	assert.EqualError(t, service.ValidateUniqueDomain("docs.example.com", 0), "domain docs.example.com is already used by static site docs.example.com")
+	// Proxy hosts sharing some domains are reported by the config validator instead
+	assert.NoError(t, service.ValidateUniqueDomain("www.example.com", 0))
+}
+
 func TestProxyHostService_CRUD(t *testing.T) {
 	db := setupProxyHostTestDB(t)
 	service := NewProxyHostService(db)
@@ -140,6 +154,42 @@ func TestProxyHostService_CRUD(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestProxyHostService_DeleteRemovesErrorPages(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{UUID: "uuid-1", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}
+	require.NoError(t, service.Create(host))
+	require.NoError(t, db.Create(&models.ErrorPage{UUID: "host-502", ProxyHostID: &host.ID, StatusCode: 502}).Error)
+	require.NoError(t, db.Create(&models.ErrorPage{UUID: "global-502", StatusCode: 502}).Error)
+
+	require.NoError(t, service.Delete(host.ID))
+
+	var pages []models.ErrorPage
+	require.NoError(t, db.Find(&pages).Error)
+	require.Len(t, pages, 1, "the host's pages go with it")
+	assert.Equal(t, "global-502", pages[0].UUID)
+}
+
+func TestProxyHostService_DeleteRemovesMaintenanceWindows(t *testing.T) {
+	db := setupProxyHostTestDB(t)
+	service := NewProxyHostService(db)
+
+	host := &models.ProxyHost{UUID: "uuid-1", DomainNames: "app.example.com", ForwardHost: "app", ForwardPort: 80}
+	other := &models.ProxyHost{UUID: "uuid-2", DomainNames: "other.example.com", ForwardHost: "other", ForwardPort: 80}
+	require.NoError(t, service.Create(host))
+	require.NoError(t, service.Create(other))
+	require.NoError(t, db.Create(&models.MaintenanceWindow{UUID: "app-upgrade", ProxyHostID: host.ID}).Error)
+	require.NoError(t, db.Create(&models.MaintenanceWindow{UUID: "other-upgrade", ProxyHostID: other.ID}).Error)
+
+	require.NoError(t, service.Delete(host.ID))
+
+	var windows []models.MaintenanceWindow
+	require.NoError(t, db.Find(&windows).Error)
+	require.Len(t, windows, 1)
+	assert.Equal(t, "other-upgrade", windows[0].UUID)
+}
+
 func TestProxyHostService_TestConnection(t *testing.T) {
 	db := setupProxyHostTestDB(t)
 	service := NewProxyHostService(db)
@@ -166,4 +216,14 @@ func TestProxyHostService_TestConnection(t *testing.T) {
 
 	err = service.TestConnection(addr.IP.String(), addr.Port)
 	assert.NoError(t, err)
+
+	// 4. Unix sockets need no port
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	err = service.TestConnection("unix/"+socket, 0)
+	assert.Error(t, err)
+	ul, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer ul.Close()
+	err = service.TestConnection("unix/"+socket, 0)
+	assert.NoError(t, err)
 }
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrRedirectionHostNotFound is returned when no redirection host has the given UUID.
+var ErrRedirectionHostNotFound = errors.New("redirection host not found")
+
+// RedirectionHostService manages hosts that redirect their domains to another URL.
+type RedirectionHostService struct {
+	db *gorm.DB
+}
+
+// NewRedirectionHostService creates a new redirection host service.
+func NewRedirectionHostService(db *gorm.DB) *RedirectionHostService {
+	return &RedirectionHostService{db: db}
+}
+
+// List returns all redirection hosts, newest first.
+func (s *RedirectionHostService) List() ([]models.RedirectionHost, error) {
+	var hosts []models.RedirectionHost
+	if err := s.db.Order("updated_at desc").Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// GetByUUID finds a redirection host by UUID.
+func (s *RedirectionHostService) GetByUUID(id string) (*models.RedirectionHost, error) {
+	var host models.RedirectionHost
+	if err := s.db.Where("uuid = ?", id).First(&host).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRedirectionHostNotFound
+		}
+		return nil, err
+	}
+	return &host, nil
+}
+
+// Create validates and stores a new redirection host.
+func (s *RedirectionHostService) Create(host *models.RedirectionHost) error {
+	host.ID = 0
+	host.UUID = uuid.NewString()
+	if err := s.validate(host); err != nil {
+		return err
+	}
+	return s.db.Create(host).Error
+}
+
+// Update validates and saves an existing redirection host.
+func (s *RedirectionHostService) Update(host *models.RedirectionHost) error {
+	if err := s.validate(host); err != nil {
+		return err
+	}
+	return s.db.Save(host).Error
+}
+
+// Delete removes a redirection host.
+func (s *RedirectionHostService) Delete(id uint) error {
+	return s.db.Delete(&models.RedirectionHost{}, id).Error
+}
+
+// validate checks the redirect itself and that none of its domains is already
+// served by a proxy host, another redirection host or a static site.
+func (s *RedirectionHostService) validate(host *models.RedirectionHost) error {
+	if err := caddy.ValidateRedirectionHost(host); err != nil {
+		return err
+	}
+
+	used, err := domainUsers(s.db, 0, host.ID, 0)
+	if err != nil {
+		return err
+	}
+	for _, d := range caddy.SplitDomains(host.DomainNames) {
+		if owner, ok := used[d]; ok {
+			return fmt.Errorf("domain %s is already used by %s", d, owner)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrRoutingRuleNotFound is returned when a host has no routing rule with the given UUID.
+var ErrRoutingRuleNotFound = errors.New("routing rule not found")
+
+// RoutingRuleService manages the routing rules of proxy hosts.
+type RoutingRuleService struct {
+	db *gorm.DB
+}
+
+// NewRoutingRuleService creates a new routing rule service.
+func NewRoutingRuleService(db *gorm.DB) *RoutingRuleService {
+	return &RoutingRuleService{db: db}
+}
+
+// List returns the routing rules of a host in the order they are matched.
+func (s *RoutingRuleService) List(hostID uint) ([]models.RoutingRule, error) {
+	var rules []models.RoutingRule
+	if err := s.db.Where("proxy_host_id = ?", hostID).Order("priority, id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetByUUID finds a routing rule of a host by UUID.
+func (s *RoutingRuleService) GetByUUID(hostID uint, id string) (*models.RoutingRule, error) {
+	var rule models.RoutingRule
+	if err := s.db.Where("proxy_host_id = ? AND uuid = ?", hostID, id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoutingRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Create validates and stores a new routing rule.
+func (s *RoutingRuleService) Create(rule *models.RoutingRule) error {
+	rule.ID = 0
+	rule.UUID = uuid.NewString()
+	if err := caddy.ValidateRoutingRule(rule); err != nil {
+		return err
+	}
+	return s.db.Create(rule).Error
+}
+
+// Update validates and saves an existing routing rule.
+func (s *RoutingRuleService) Update(rule *models.RoutingRule) error {
+	if err := caddy.ValidateRoutingRule(rule); err != nil {
+		return err
+	}
+	return s.db.Save(rule).Error
+}
+
+// Delete removes a routing rule.
+func (s *RoutingRuleService) Delete(id uint) error {
+	return s.db.Delete(&models.RoutingRule{}, id).Error
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/logger"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// Limits for an uploaded site archive, checked while extracting.
+const (
+	maxSiteArchiveFiles = 20000
+	maxSiteArchiveBytes = 1 << 30 // uncompressed
+)
+
+// ErrStaticSiteNotFound is returned when no static site has the given UUID.
+var ErrStaticSiteNotFound = errors.New("static site not found")
+
+// ErrNoPreviousRelease is returned when a site has no release to roll back to.
+var ErrNoPreviousRelease = errors.New("no previous release to roll back to")
+
+// StaticSiteService manages static sites and deploys their releases. Each site
+// lives in <sitesDir>/<uuid>: releases/<id> holds the deployed versions and
+// the symlink current points at the one being served.
+type StaticSiteService struct {
+	db       *gorm.DB
+	sitesDir string
+	now      func() time.Time
+}
+
+// NewStaticSiteService creates a new static site service.
+func NewStaticSiteService(db *gorm.DB, sitesDir string) *StaticSiteService {
+	return &StaticSiteService{db: db, sitesDir: sitesDir, now: time.Now}
+}
+
+// List returns all static sites, newest first.
+func (s *StaticSiteService) List() ([]models.StaticSite, error) {
+	var sites []models.StaticSite
+	if err := s.db.Order("updated_at desc").Find(&sites).Error; err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
+
+// GetByUUID finds a static site by UUID.
+func (s *StaticSiteService) GetByUUID(id string) (*models.StaticSite, error) {
+	var site models.StaticSite
+	if err := s.db.Where("uuid = ?", id).First(&site).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStaticSiteNotFound
+		}
+		return nil, err
+	}
+	return &site, nil
+}
+
+// Create validates and stores a new static site. It is served once a release is deployed.
+func (s *StaticSiteService) Create(site *models.StaticSite) error {
+	site.ID = 0
+	site.UUID = uuid.NewString()
+	site.CurrentRelease, site.PreviousRelease, site.DeployedAt = "", "", nil
+	if err := s.validate(site); err != nil {
+		return err
+	}
+	return s.db.Create(site).Error
+}
+
+// Update validates and saves an existing static site.
+func (s *StaticSiteService) Update(site *models.StaticSite) error {
+	if err := s.validate(site); err != nil {
+		return err
+	}
+	return s.db.Save(site).Error
+}
+
+// Delete removes a static site and its releases.
+func (s *StaticSiteService) Delete(site *models.StaticSite) error {
+	if err := s.db.Delete(&models.StaticSite{}, site.ID).Error; err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.siteDir(site)); err != nil {
+		logger.Log().WithField("static_site", site.UUID).WithError(err).Warn("Failed to remove static site files")
+	}
+	return nil
+}
+
+// Deploy extracts a .zip, .tar.gz, .tgz or .tar archive into a new release and
+// switches the site to it in one step. An archive holding a single top-level
+// directory (e.g. dist/) is deployed from inside that directory. The previous
+// release is kept for a rollback; older ones are removed.
+func (s *StaticSiteService) Deploy(site *models.StaticSite, archive io.Reader, filename string) error {
+	releasesDir := filepath.Join(s.siteDir(site), "releases")
+	if err := os.MkdirAll(releasesDir, 0o755); err != nil {
+		return fmt.Errorf("create releases directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(releasesDir, ".upload-")
+	if err != nil {
+		return fmt.Errorf("create upload directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = extractZip(archive, tmp)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(archive); err == nil {
+			err = extractTar(gz, tmp)
+		}
+	case strings.HasSuffix(name, ".tar"):
+		err = extractTar(archive, tmp)
+	default:
+		return fmt.Errorf("archive must be a .zip, .tar.gz, .tgz or .tar file")
+	}
+	if err != nil {
+		return fmt.Errorf("extract archive: %w", err)
+	}
+
+	content := tmp
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("archive is empty")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		content = filepath.Join(tmp, entries[0].Name())
+	}
+
+	release := s.now().UTC().Format("20060102T150405Z") + "-" + uuid.NewString()[:8]
+	if err := os.Rename(content, filepath.Join(releasesDir, release)); err != nil {
+		return fmt.Errorf("store release: %w", err)
+	}
+	if err := s.switchRelease(site, release); err != nil {
+		return err
+	}
+
+	now := s.now()
+	site.PreviousRelease, site.CurrentRelease, site.DeployedAt = site.CurrentRelease, release, &now
+	if err := s.saveReleases(site); err != nil {
+		return err
+	}
+	s.pruneReleases(site)
+	return nil
+}
+
+// Rollback switches a site back to its previous release; rolling back again
+// returns to the release it came from.
+func (s *StaticSiteService) Rollback(site *models.StaticSite) error {
+	if site.PreviousRelease == "" {
+		return ErrNoPreviousRelease
+	}
+	if _, err := os.Stat(filepath.Join(s.siteDir(site), "releases", site.PreviousRelease)); err != nil {
+		return ErrNoPreviousRelease
+	}
+	if err := s.switchRelease(site, site.PreviousRelease); err != nil {
+		return err
+	}
+	now := s.now()
+	site.PreviousRelease, site.CurrentRelease, site.DeployedAt = site.CurrentRelease, site.PreviousRelease, &now
+	return s.saveReleases(site)
+}
+
+func (s *StaticSiteService) siteDir(site *models.StaticSite) string {
+	return filepath.Join(s.sitesDir, site.UUID)
+}
+
+// switchRelease points the current symlink at release. The link is replaced by
+// a rename, so requests see either the old or the new release, never neither.
+func (s *StaticSiteService) switchRelease(site *models.StaticSite, release string) error {
+	dir := s.siteDir(site)
+	tmpLink := filepath.Join(dir, ".current-"+release)
+	_ = os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Join("releases", release), tmpLink); err != nil {
+		return fmt.Errorf("link release: %w", err)
+	}
+	if err := os.Rename(tmpLink, caddy.StaticSiteRoot(s.sitesDir, site.UUID)); err != nil {
+		_ = os.Remove(tmpLink)
+		return fmt.Errorf("switch release: %w", err)
+	}
+	return nil
+}
+
+func (s *StaticSiteService) saveReleases(site *models.StaticSite) error {
+	return s.db.Model(&models.StaticSite{}).Where("id = ?", site.ID).Updates(map[string]interface{}{
+		"current_release":  site.CurrentRelease,
+		"previous_release": site.PreviousRelease,
+		"deployed_at":      site.DeployedAt,
+	}).Error
+}
+
+// pruneReleases removes every release but the current and previous one.
+func (s *StaticSiteService) pruneReleases(site *models.StaticSite) {
+	releasesDir := filepath.Join(s.siteDir(site), "releases")
+	entries, err := os.ReadDir(releasesDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() == site.CurrentRelease || e.Name() == site.PreviousRelease || strings.HasPrefix(e.Name(), ".upload-") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(releasesDir, e.Name())); err != nil {
+			logger.Log().WithField("static_site", site.UUID).WithField("release", e.Name()).WithError(err).Warn("Failed to remove old release")
+		}
+	}
+}
+
+// validate checks the site itself and that none of its domains is already in use.
+func (s *StaticSiteService) validate(site *models.StaticSite) error {
+	if err := caddy.ValidateStaticSite(site); err != nil {
+		return err
+	}
+	used, err := domainUsers(s.db, 0, 0, site.ID)
+	if err != nil {
+		return err
+	}
+	for _, d := range caddy.SplitDomains(site.DomainNames) {
+		if owner, ok := used[d]; ok {
+			return fmt.Errorf("domain %s is already used by %s", d, owner)
+		}
+	}
+	return nil
+}
+
+// domainUser is the host a domain belongs to.
+type domainUser struct {
+	kind string // proxy host, redirection host or static site
+	name string
+}
+
+func (u domainUser) String() string {
+	return u.kind + " " + u.name
+}
+
+// domainUsers maps the domains of redirection hosts, static sites and proxy
+// hosts to the host using them, leaving out the proxy host, redirection host
+// and static site with the given IDs.
+func domainUsers(db *gorm.DB, proxyID, redirectID, siteID uint) (map[string]domainUser, error) {
+	sources := []struct {
+		query *gorm.DB
+		kind  string
+	}{
+		{db.Model(&models.RedirectionHost{}).Where("id != ?", redirectID), "redirection host"},
+		{db.Model(&models.StaticSite{}).Where("id != ?", siteID), "static site"},
+		{db.Model(&models.ProxyHost{}).Where("id != ?", proxyID), "proxy host"},
+	}
+	used := make(map[string]domainUser)
+	for _, src := range sources {
+		var rows []struct {
+			Name        string
+			DomainNames string
+		}
+		if err := src.query.Select("name", "domain_names").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("checking domain uniqueness: %w", err)
+		}
+		for _, row := range rows {
+			user := domainUser{kind: src.kind, name: row.Name}
+			if user.name == "" {
+				user.name = row.DomainNames
+			}
+			for _, d := range caddy.SplitDomains(row.DomainNames) {
+				if _, ok := used[d]; !ok {
+					used[d] = user
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+// archiveBudget enforces the file count and size limits across an archive.
+type archiveBudget struct {
+	files int
+	bytes int64
+}
+
+// archivePath maps an archive entry to a path inside dest. It returns "" for
+// entries to skip and an error for entries that would escape dest.
+func archivePath(dest, name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return "", nil
+	}
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if target == dest {
+		return "", nil
+	}
+	if strings.HasPrefix(name, "/") || !strings.HasPrefix(target, dest+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path %q", name)
+	}
+	return target, nil
+}
+
+// writeArchiveFile writes one regular file, readable by Caddy whatever its mode in the archive.
+func writeArchiveFile(target string, r io.Reader, budget *archiveBudget) error {
+	budget.files++
+	if budget.files > maxSiteArchiveFiles {
+		return fmt.Errorf("archive has more than %d files", maxSiteArchiveFiles)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxSiteArchiveBytes-budget.bytes+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	budget.bytes += n
+	if budget.bytes > maxSiteArchiveBytes {
+		return fmt.Errorf("archive is larger than %d bytes uncompressed", int64(maxSiteArchiveBytes))
+	}
+	return nil
+}
+
+// extractTar extracts directories and regular files; links and devices are rejected.
+func extractTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	budget := &archiveBudget{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		target, err := archivePath(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, tr, budget); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry %q: only files and directories are allowed", hdr.Name)
+		}
+	}
+}
+
+// extractZip extracts directories and regular files; zip needs random access,
+// so the upload is buffered to a temporary file first.
+func extractZip(r io.Reader, dest string) error {
+	buf, err := os.CreateTemp(filepath.Dir(dest), ".upload-*.zip")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = buf.Close()
+		_ = os.Remove(buf.Name())
+	}()
+	size, err := io.Copy(buf, r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(buf, size)
+	if err != nil {
+		return err
+	}
+
+	budget := &archiveBudget{}
+	for _, f := range zr.File {
+		target, err := archivePath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+		switch {
+		case f.FileInfo().IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case f.Mode().IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = writeArchiveFile(target, rc, budget)
+			_ = rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry %q: only files and directories are allowed", f.Name)
+		}
+	}
+	return nil
+}
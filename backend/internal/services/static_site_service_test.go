@@ -0,0 +1,138 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+func setupStaticSiteService(t *testing.T) (*StaticSiteService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ProxyHost{}, &models.RedirectionHost{}, &models.StaticSite{}))
+	return NewStaticSiteService(db, t.TempDir()), db
+}
+
+type archiveEntry struct {
+	name, body string
+	link       bool
+}
+
+func tarGz(t *testing.T, entries ...archiveEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o600, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.link {
+			hdr = &tar.Header{Name: e.name, Linkname: e.body, Typeflag: tar.TypeSymlink}
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if !e.link {
+			_, err := tw.Write([]byte(e.body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return &buf
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return &buf
+}
+
+func TestStaticSiteService_DeployAndRollback(t *testing.T) {
+	svc, db := setupStaticSiteService(t)
+	require.NoError(t, db.Create(&models.ProxyHost{UUID: "app", DomainNames: "app.example.com"}).Error)
+
+	assert.ErrorContains(t, svc.Create(&models.StaticSite{DomainNames: "app.example.com"}), "domain app.example.com is already used by proxy host app.example.com")
+	site := models.StaticSite{Name: "Docs", DomainNames: "docs.example.com", CurrentRelease: "forged"}
+	require.NoError(t, svc.Create(&site))
+	assert.Empty(t, site.CurrentRelease)
+	assert.ErrorContains(t, svc.Create(&models.StaticSite{DomainNames: "DOCS.example.com"}), "already used by static site Docs")
+
+	current := filepath.Join(svc.sitesDir, site.UUID, "current")
+	read := func() string {
+		b, err := os.ReadFile(filepath.Join(current, "index.html"))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	// A single top-level directory is deployed from inside it
+	require.NoError(t, svc.Deploy(&site, tarGz(t, archiveEntry{name: "dist/index.html", body: "v1"}, archiveEntry{name: "dist/app.js", body: "js"}), "site.tar.gz"))
+	assert.Equal(t, "v1", read())
+	first := site.CurrentRelease
+	assert.NotEmpty(t, first)
+	assert.Empty(t, site.PreviousRelease)
+	info, err := os.Stat(filepath.Join(current, "app.js"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm(), "files are readable whatever their mode in the archive")
+
+	require.NoError(t, svc.Deploy(&site, zipArchive(t, archiveEntry{name: "index.html", body: "v2"}, archiveEntry{name: "__MACOSX/._index.html", body: "junk"}), "site.zip"))
+	assert.Equal(t, "v2", read())
+	assert.Equal(t, first, site.PreviousRelease)
+
+	require.NoError(t, svc.Rollback(&site))
+	assert.Equal(t, "v1", read())
+	assert.Equal(t, first, site.CurrentRelease)
+	var stored models.StaticSite
+	require.NoError(t, db.First(&stored, site.ID).Error)
+	assert.Equal(t, site.CurrentRelease, stored.CurrentRelease)
+	assert.Equal(t, site.PreviousRelease, stored.PreviousRelease)
+
+	// A third deploy drops the oldest release
+	require.NoError(t, svc.Deploy(&site, tarGz(t, archiveEntry{name: "index.html", body: "v3"}), "site.tgz"))
+	entries, err := os.ReadDir(filepath.Join(svc.sitesDir, site.UUID, "releases"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	require.NoError(t, svc.Delete(&site))
+	_, err = os.Stat(filepath.Join(svc.sitesDir, site.UUID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStaticSiteService_DeployRejectsBadArchives(t *testing.T) {
+	svc, _ := setupStaticSiteService(t)
+	site := models.StaticSite{DomainNames: "docs.example.com"}
+	require.NoError(t, svc.Create(&site))
+
+	assert.ErrorContains(t, svc.Deploy(&site, tarGz(t, archiveEntry{name: "../escape.html", body: "x"}), "a.tar.gz"), "illegal path")
+	assert.ErrorContains(t, svc.Deploy(&site, zipArchive(t, archiveEntry{name: "a/../../escape.html", body: "x"}), "a.zip"), "illegal path")
+	assert.ErrorContains(t, svc.Deploy(&site, tarGz(t, archiveEntry{name: "passwd", body: "/etc/passwd", link: true}), "a.tar.gz"), "only files and directories")
+	assert.ErrorContains(t, svc.Deploy(&site, bytes.NewBufferString("x"), "site.rar"), "archive must be")
+	assert.ErrorContains(t, svc.Deploy(&site, zipArchive(t), "empty.zip"), "archive is empty")
+	assert.ErrorIs(t, svc.Rollback(&site), ErrNoPreviousRelease)
+
+	_, err := os.Stat(filepath.Join(svc.sitesDir, site.UUID, "current"))
+	assert.True(t, os.IsNotExist(err), "failed deploys switch nothing")
+	entries, err := os.ReadDir(filepath.Join(svc.sitesDir, site.UUID, "releases"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "failed uploads are cleaned up")
+	_, err = os.Stat(filepath.Join(svc.sitesDir, "escape.html"))
+	assert.True(t, os.IsNotExist(err))
+}
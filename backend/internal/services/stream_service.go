@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrStreamNotFound is returned when no stream has the given UUID.
+var ErrStreamNotFound = errors.New("stream not found")
+
+// StreamService manages TCP/UDP streams.
+type StreamService struct {
+	db   *gorm.DB
+	opts caddy.ValidationOptions
+}
+
+// NewStreamService creates a new stream service. opts holds the ports Charon
+// and the Caddy admin API listen on, which streams may not use.
+func NewStreamService(db *gorm.DB, opts caddy.ValidationOptions) *StreamService {
+	return &StreamService{db: db, opts: opts}
+}
+
+// List returns all streams ordered by listen port.
+func (s *StreamService) List() ([]models.Stream, error) {
+	var streams []models.Stream
+	if err := s.db.Order("listen_port, id").Find(&streams).Error; err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// GetByUUID finds a stream by UUID.
+func (s *StreamService) GetByUUID(id string) (*models.Stream, error) {
+	var stream models.Stream
+	if err := s.db.Where("uuid = ?", id).First(&stream).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStreamNotFound
+		}
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// Create validates and stores a new stream.
+func (s *StreamService) Create(stream *models.Stream) error {
+	stream.ID = 0
+	stream.UUID = uuid.NewString()
+	if err := s.validate(stream); err != nil {
+		return err
+	}
+	return s.db.Create(stream).Error
+}
+
+// Update validates and saves an existing stream.
+func (s *StreamService) Update(stream *models.Stream) error {
+	if err := s.validate(stream); err != nil {
+		return err
+	}
+	return s.db.Save(stream).Error
+}
+
+// Delete removes a stream and its uptime monitor.
+func (s *StreamService) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var monitorIDs []string
+		if err := tx.Model(&models.UptimeMonitor{}).Where("stream_id = ?", id).Pluck("id", &monitorIDs).Error; err != nil {
+			return err
+		}
+		if len(monitorIDs) > 0 {
+			if err := tx.Where("monitor_id IN ?", monitorIDs).Delete(&models.UptimeHeartbeat{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", monitorIDs).Delete(&models.UptimeMonitor{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.Stream{}, id).Error
+	})
+}
+
+func (s *StreamService) validate(stream *models.Stream) error {
+	if err := caddy.ValidateStream(stream); err != nil {
+		return err
+	}
+	if !stream.Enabled && stream.ID != 0 {
+		// A disabled stream does not listen; new streams are always stored enabled
+		return nil
+	}
+	var others []models.Stream
+	if err := s.db.Find(&others).Error; err != nil {
+		return fmt.Errorf("checking port conflicts: %w", err)
+	}
+	return caddy.CheckStreamConflicts(*stream, others, s.opts)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// ErrUpstreamGroupNotFound is returned when a host has no upstream group with the given UUID.
+var ErrUpstreamGroupNotFound = errors.New("upstream group not found")
+
+// TrafficSplit is how a proxy host's traffic is shared between its own
+// upstream and its upstream groups.
+type TrafficSplit struct {
+	PrimaryWeight  int                    `json:"primary_weight"`
+	StickySessions bool                   `json:"sticky_sessions"`
+	Groups         []models.UpstreamGroup `json:"groups"`
+}
+
+// Weights returns the weight of each group by name.
+func (t *TrafficSplit) Weights() map[string]int {
+	weights := make(map[string]int, len(t.Groups))
+	for _, g := range t.Groups {
+		weights[g.Name] = g.Weight
+	}
+	return weights
+}
+
+// UpstreamGroupService manages the weighted upstream groups of proxy hosts.
+type UpstreamGroupService struct {
+	db *gorm.DB
+}
+
+// NewUpstreamGroupService creates a new upstream group service.
+func NewUpstreamGroupService(db *gorm.DB) *UpstreamGroupService {
+	return &UpstreamGroupService{db: db}
+}
+
+// List returns the upstream groups of a host.
+func (s *UpstreamGroupService) List(hostID uint) ([]models.UpstreamGroup, error) {
+	var groups []models.UpstreamGroup
+	if err := s.db.Where("proxy_host_id = ?", hostID).Order("id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetByUUID finds an upstream group of a host by UUID.
+func (s *UpstreamGroupService) GetByUUID(hostID uint, id string) (*models.UpstreamGroup, error) {
+	var group models.UpstreamGroup
+	if err := s.db.Where("proxy_host_id = ? AND uuid = ?", hostID, id).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUpstreamGroupNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Create validates and stores a new upstream group.
+func (s *UpstreamGroupService) Create(group *models.UpstreamGroup) error {
+	group.ID = 0
+	group.UUID = uuid.NewString()
+	if err := s.validate(group); err != nil {
+		return err
+	}
+	return s.db.Create(group).Error
+}
+
+// Update validates and saves an existing upstream group.
+func (s *UpstreamGroupService) Update(group *models.UpstreamGroup) error {
+	if err := s.validate(group); err != nil {
+		return err
+	}
+	return s.db.Save(group).Error
+}
+
+// Delete removes an upstream group.
+func (s *UpstreamGroupService) Delete(id uint) error {
+	return s.db.Delete(&models.UpstreamGroup{}, id).Error
+}
+
+// Split returns the current traffic split of a host.
+func (s *UpstreamGroupService) Split(host *models.ProxyHost) (*TrafficSplit, error) {
+	groups, err := s.List(host.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TrafficSplit{PrimaryWeight: caddy.PrimaryWeight(groups), StickySessions: host.StickySessions, Groups: groups}, nil
+}
+
+// SetWeights changes the weights of the named groups of a host in one step;
+// groups left out keep their weight. sticky, when set, switches sticky sessions.
+func (s *UpstreamGroupService) SetWeights(host *models.ProxyHost, weights map[string]int, sticky *bool) (*TrafficSplit, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var groups []models.UpstreamGroup
+		if err := tx.Where("proxy_host_id = ?", host.ID).Order("id").Find(&groups).Error; err != nil {
+			return err
+		}
+		changed := make(map[int]bool)
+		for name, weight := range weights {
+			found := false
+			for i := range groups {
+				if groups[i].Name == name {
+					groups[i].Weight = weight
+					changed[i] = true
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("host has no upstream group %q", name)
+			}
+		}
+		if err := caddy.ValidateTrafficSplit(groups); err != nil {
+			return err
+		}
+		for i := range changed {
+			if err := tx.Model(&groups[i]).Update("weight", groups[i].Weight).Error; err != nil {
+				return err
+			}
+		}
+		if sticky != nil && *sticky != host.StickySessions {
+			if err := tx.Model(host).Update("sticky_sessions", *sticky).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Split(host)
+}
+
+// Rollback sends all of a host's traffic back to its own upstream.
+func (s *UpstreamGroupService) Rollback(host *models.ProxyHost) (*TrafficSplit, error) {
+	if err := s.db.Model(&models.UpstreamGroup{}).Where("proxy_host_id = ?", host.ID).Update("weight", 0).Error; err != nil {
+		return nil, err
+	}
+	return s.Split(host)
+}
+
+// validate checks a group together with the other groups of its host.
+func (s *UpstreamGroupService) validate(group *models.UpstreamGroup) error {
+	if err := caddy.ValidateUpstreamGroup(group); err != nil {
+		return err
+	}
+	var others []models.UpstreamGroup
+	if err := s.db.Where("proxy_host_id = ? AND id <> ?", group.ProxyHostID, group.ID).Order("id").Find(&others).Error; err != nil {
+		return fmt.Errorf("checking upstream groups: %w", err)
+	}
+	return caddy.ValidateTrafficSplit(append(others, *group))
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gRPC health checking protocol (grpc.health.v1), spoken without the gRPC
+// library: the request and response messages only have one field each.
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// grpcServingStatus names the values of HealthCheckResponse.ServingStatus.
+var grpcServingStatus = map[uint64]string{0: "UNKNOWN", 1: "SERVING", 2: "NOT_SERVING", 3: "SERVICE_UNKNOWN"}
+
+// checkGRPCHealth calls the gRPC health service at rawURL, which is
+// grpc://host:port for HTTP/2 over cleartext (h2c) or grpcs://host:port for
+// HTTP/2 over TLS. A unix//path host dials a unix socket, as Caddy does. The
+// check passes when service reports SERVING; an empty service asks about the
+// server as a whole.
+func checkGRPCHealth(ctx context.Context, rawURL, service string) (bool, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false, fmt.Sprintf("invalid gRPC URL %q", rawURL)
+	}
+
+	protocols := new(http.Protocols)
+	transport := &http.Transport{Protocols: protocols}
+	target := url.URL{Host: u.Host, Path: grpcHealthPath}
+	if u.Host == "unix" && strings.HasPrefix(u.Path, "//") {
+		socket := u.Path[1:]
+		target.Host = "localhost"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+	}
+	switch u.Scheme {
+	case "grpc":
+		target.Scheme = "http"
+		protocols.SetUnencryptedHTTP2(true)
+	case "grpcs":
+		target.Scheme = "https"
+		protocols.SetHTTP2(true)
+	default:
+		return false, fmt.Sprintf("gRPC URL must start with grpc:// or grpcs://, got %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(grpcFrame(grpcHealthRequest(service))))
+	if err != nil {
+		return false, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport, Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return false, err.Error()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+
+	// Errors come in the trailers, or in the headers of a trailers-only response
+	code := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if code != "0" {
+		if code == "" {
+			return false, "no grpc-status in response"
+		}
+		msg := "gRPC status " + code
+		if message != "" {
+			msg += ": " + message
+		}
+		return false, msg
+	}
+
+	status, err := parseGRPCHealthResponse(body)
+	if err != nil {
+		return false, err.Error()
+	}
+	name, ok := grpcServingStatus[status]
+	if !ok {
+		name = fmt.Sprintf("status %d", status)
+	}
+	return status == 1, name
+}
+
+// grpcHealthRequest encodes HealthCheckRequest{service}.
+func grpcHealthRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{0x0a} // field 1, length-delimited
+	msg = binary.AppendUvarint(msg, uint64(len(service)))
+	return append(msg, service...)
+}
+
+// grpcFrame prefixes a message with the gRPC length-prefixed message header.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// parseGRPCHealthResponse returns the status of a framed HealthCheckResponse.
+func parseGRPCHealthResponse(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, fmt.Errorf("short gRPC response")
+	}
+	if body[0] != 0 {
+		return 0, fmt.Errorf("compressed gRPC responses are not supported")
+	}
+	size := binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)-5) < uint64(size) {
+		return 0, fmt.Errorf("truncated gRPC response")
+	}
+	msg := body[5 : 5+size]
+
+	// Walk the fields; status is field 1 (varint). Other varint and
+	// length-delimited fields are skipped.
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, fmt.Errorf("malformed gRPC health response")
+		}
+		msg = msg[n:]
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, fmt.Errorf("malformed gRPC health response")
+			}
+			msg = msg[n:]
+			if key>>3 == 1 {
+				status = v
+			}
+		case 2:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, fmt.Errorf("malformed gRPC health response")
+			}
+			msg = msg[n+int(l):]
+		default:
+			return 0, fmt.Errorf("malformed gRPC health response")
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Wikid82/charon/backend/internal/caddy"
+	"github.com/Wikid82/charon/backend/internal/models"
+)
+
+// startGRPCHealthServer serves grpc.health.v1 over h2c. Services not in
+// statuses get NOT_FOUND, as real health servers answer.
+func startGRPCHealthServer(t *testing.T, statuses map[string]uint64) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Protocols: protocols,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var service string
+			if len(body) > 7 {
+				service = string(body[7:]) // frame header, tag and a one-byte length
+			}
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			status, ok := statuses[service]
+			if !ok || r.URL.Path != grpcHealthPath || r.ProtoMajor != 2 {
+				w.Header().Set("Grpc-Status", "5")
+				w.Header().Set("Grpc-Message", "unknown service")
+				return
+			}
+			msg := binary.AppendUvarint([]byte{0x08}, status)
+			_, _ = w.Write(grpcFrame(msg))
+			w.Header().Set("Grpc-Status", "0")
+		}),
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return listener.Addr().String()
+}
+
+func TestCheckGRPCHealth(t *testing.T) {
+	addr := startGRPCHealthServer(t, map[string]uint64{"": 1, "orders.v1.Orders": 2})
+	ctx := context.Background()
+
+	ok, msg := checkGRPCHealth(ctx, "grpc://"+addr, "")
+	assert.True(t, ok)
+	assert.Equal(t, "SERVING", msg)
+
+	ok, msg = checkGRPCHealth(ctx, "grpc://"+addr, "orders.v1.Orders")
+	assert.False(t, ok)
+	assert.Equal(t, "NOT_SERVING", msg)
+
+	ok, msg = checkGRPCHealth(ctx, "grpc://"+addr, "billing")
+	assert.False(t, ok)
+	assert.Equal(t, "gRPC status 5: unknown service", msg)
+
+	ok, msg = checkGRPCHealth(ctx, "http://"+addr, "")
+	assert.False(t, ok)
+	assert.Contains(t, msg, "must start with grpc:// or grpcs://")
+}
+
+func TestParseGRPCHealthResponse(t *testing.T) {
+	// An unknown length-delimited field before the status is skipped
+	status, err := parseGRPCHealthResponse(grpcFrame([]byte{0x12, 0x02, 'h', 'i', 0x08, 0x01}))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), status)
+
+	_, err = parseGRPCHealthResponse(grpcFrame(nil)[:3])
+	assert.ErrorContains(t, err, "short")
+	_, err = parseGRPCHealthResponse(append([]byte{1}, grpcFrame([]byte{0x08, 0x01})[1:]...))
+	assert.ErrorContains(t, err, "compressed")
+	_, err = parseGRPCHealthResponse(grpcFrame([]byte{0x12, 0x09}))
+	assert.ErrorContains(t, err, "malformed")
+}
+
+func TestUptimeService_SyncMonitors_GRPC(t *testing.T) {
+	db := setupUptimeTestDB(t)
+	us := NewUptimeService(db, NewNotificationService(db))
+	addr := startGRPCHealthServer(t, map[string]uint64{"": 1})
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	proxyHost := models.ProxyHost{UUID: "grpc", DomainNames: "grpc.example.com", ForwardHost: host, ForwardPort: port, UpstreamProtocol: caddy.UpstreamProtocolH2C, Enabled: true}
+	require.NoError(t, db.Create(&proxyHost).Error)
+	require.NoError(t, us.SyncMonitors())
+
+	var monitor models.UptimeMonitor
+	require.NoError(t, db.Where("proxy_host_id = ?", proxyHost.ID).First(&monitor).Error)
+	assert.Equal(t, "grpc", monitor.Type)
+	assert.Equal(t, "grpc://"+addr, monitor.URL, "without TLS the upstream is checked directly")
+
+	us.CheckMonitor(monitor)
+	require.NoError(t, db.First(&monitor, "id = ?", monitor.ID).Error)
+	assert.Equal(t, "up", monitor.Status)
+	var heartbeat models.UptimeHeartbeat
+	require.NoError(t, db.Where("monitor_id = ?", monitor.ID).First(&heartbeat).Error)
+	assert.Equal(t, "SERVING", heartbeat.Message)
+
+	// Served over TLS by Caddy, the public name is checked
+	require.NoError(t, db.Model(&proxyHost).Update("ssl_forced", true).Error)
+	require.NoError(t, us.SyncMonitors())
+	require.NoError(t, db.First(&monitor, "id = ?", monitor.ID).Error)
+	assert.Equal(t, "grpcs://grpc.example.com", monitor.URL)
+
+	// Back to HTTP/1.1: back to a plain HTTP check
+	require.NoError(t, db.Model(&proxyHost).Update("upstream_protocol", caddy.UpstreamProtocolHTTP1).Error)
+	require.NoError(t, us.SyncMonitors())
+	require.NoError(t, db.First(&monitor, "id = ?", monitor.ID).Error)
+	assert.Equal(t, "http", monitor.Type)
+	assert.Equal(t, "https://grpc.example.com", monitor.URL)
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Wikid82/charon/backend/internal/caddy"
 	"github.com/Wikid82/charon/backend/internal/models"
 	"github.com/Wikid82/charon/backend/internal/util"
 	"gorm.io/gorm"
@@ -60,10 +61,10 @@ func extractPort(urlStr string) string {
 			return port
 		}
 		// Default ports
-		if u.Scheme == "https" {
+		if u.Scheme == "https" || u.Scheme == "grpcs" {
 			return "443"
 		}
-		if u.Scheme == "http" {
+		if u.Scheme == "http" || u.Scheme == "grpc" {
 			return "80"
 		}
 	}
@@ -97,7 +98,7 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%ds", seconds)
 }
 
-// SyncMonitors ensures every ProxyHost and RemoteServer has a corresponding UptimeMonitor
+// SyncMonitors ensures every ProxyHost, RemoteServer and TCP Stream has a corresponding UptimeMonitor
 // and that UptimeHosts are created for grouping
 func (s *UptimeService) SyncMonitors() error {
 	var hosts []models.ProxyHost
@@ -121,11 +122,27 @@ func (s *UptimeService) SyncMonitors() error {
 			scheme = "https"
 		}
 		publicURL := fmt.Sprintf("%s://%s", scheme, firstDomain)
-		internalURL := fmt.Sprintf("%s:%d", host.ForwardHost, host.ForwardPort)
+		internalURL := caddy.UpstreamDial(host.ForwardHost, host.ForwardPort)
 
 		// The upstream host for grouping is the ForwardHost
 		upstreamHost := host.ForwardHost
 
+		// gRPC backends can't answer a plain GET; they are checked with the
+		// gRPC health protocol, through Caddy when it serves TLS and on the
+		// upstream itself otherwise
+		monitorType, monitorURL := "http", publicURL
+		if caddy.IsHTTP2Only(host.UpstreamProtocol) {
+			monitorType = "grpc"
+			switch {
+			case host.SSLForced:
+				monitorURL = "grpcs://" + firstDomain
+			case host.UpstreamProtocol == caddy.UpstreamProtocolH2:
+				monitorURL = "grpcs://" + internalURL
+			default:
+				monitorURL = "grpc://" + internalURL
+			}
+		}
+
 		switch err {
 		case gorm.ErrRecordNotFound:
 			// Create new monitor
@@ -141,8 +158,8 @@ func (s *UptimeService) SyncMonitors() error {
 				ProxyHostID:  &host.ID,
 				UptimeHostID: &uptimeHostID,
 				Name:         name,
-				Type:         "http", // Check public access
-				URL:          publicURL,
+				Type:         monitorType,
+				URL:          monitorURL,
 				UpstreamHost: upstreamHost,
 				Interval:     60,
 				Enabled:      true,
@@ -185,6 +202,16 @@ func (s *UptimeService) SyncMonitors() error {
 				logger.Log().WithField("host_id", host.ID).Infof("Migrated monitor for host %d to check public URL: %s", host.ID, publicURL)
 			}
 
+			// Switch between HTTP and gRPC checks with the host's upstream protocol
+			if monitor.Type != "tcp" && (monitor.Type == "grpc") != (monitorType == "grpc") {
+				monitor.Type = monitorType
+				monitor.URL = monitorURL
+				needsSave = true
+			} else if monitor.Type == "grpc" && monitor.URL != monitorURL {
+				monitor.URL = monitorURL
+				needsSave = true
+			}
+
 			// Upgrade to HTTPS if SSL is forced and we are currently checking HTTP
 			if host.SSLForced && strings.HasPrefix(monitor.URL, "http://") {
 				monitor.URL = strings.Replace(monitor.URL, "http://", "https://", 1)
@@ -275,6 +302,82 @@ func (s *UptimeService) SyncMonitors() error {
 		}
 	}
 
+	return s.syncStreamMonitors()
+}
+
+// syncStreamMonitors keeps a TCP monitor on the first upstream of every TCP
+// stream. UDP has no connection to check, so UDP streams are not monitored and
+// a stream switched to UDP loses its monitor.
+func (s *UptimeService) syncStreamMonitors() error {
+	var streams []models.Stream
+	if err := s.DB.Where("protocol = ?", "tcp").Find(&streams).Error; err != nil {
+		return err
+	}
+
+	tcpIDs := make([]uint, 0, len(streams))
+	for _, stream := range streams {
+		tcpIDs = append(tcpIDs, stream.ID)
+	}
+	stale := s.DB.Model(&models.UptimeMonitor{}).Where("stream_id IS NOT NULL")
+	if len(tcpIDs) > 0 {
+		stale = stale.Where("stream_id NOT IN ?", tcpIDs)
+	}
+	var staleIDs []string
+	if err := stale.Pluck("id", &staleIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range staleIDs {
+		if err := s.DeleteMonitor(id); err != nil {
+			logger.Log().WithError(err).WithField("monitor_id", id).Error("Failed to delete monitor of non-TCP stream")
+		}
+	}
+
+	for _, stream := range streams {
+		upstreams := strings.Split(stream.Upstreams, ",")
+		targetURL := strings.TrimSpace(upstreams[0])
+		upstreamHost, _, err := net.SplitHostPort(targetURL)
+		if err != nil {
+			continue
+		}
+		name := stream.Name
+		if name == "" {
+			name = fmt.Sprintf("Stream :%d", stream.ListenPort)
+		}
+
+		var monitor models.UptimeMonitor
+		err = s.DB.Where("stream_id = ?", stream.ID).First(&monitor).Error
+		switch err {
+		case gorm.ErrRecordNotFound:
+			uptimeHostID := s.ensureUptimeHost(upstreamHost, name)
+			monitor = models.UptimeMonitor{
+				StreamID:     &stream.ID,
+				UptimeHostID: &uptimeHostID,
+				Name:         name,
+				Type:         "tcp",
+				URL:          targetURL,
+				UpstreamHost: upstreamHost,
+				Interval:     60,
+				Enabled:      stream.Enabled,
+				Status:       "pending",
+			}
+			if err := s.DB.Create(&monitor).Error; err != nil {
+				logger.Log().WithError(err).WithField("stream_id", stream.ID).Error("Failed to create monitor for stream")
+			}
+		case nil:
+			if monitor.Name == name && monitor.URL == targetURL && monitor.UpstreamHost == upstreamHost && monitor.Enabled == stream.Enabled {
+				continue
+			}
+			if monitor.UpstreamHost != upstreamHost || monitor.UptimeHostID == nil {
+				uptimeHostID := s.ensureUptimeHost(upstreamHost, name)
+				monitor.UptimeHostID = &uptimeHostID
+			}
+			monitor.Name = name
+			monitor.URL = targetURL
+			monitor.UpstreamHost = upstreamHost
+			monitor.Enabled = stream.Enabled
+			s.DB.Save(&monitor)
+		}
+	}
 	return nil
 }
 
@@ -310,9 +413,19 @@ func (s *UptimeService) CheckAll() {
 		return
 	}
 
+	// Monitors of hosts in a maintenance window are not checked and send no notifications
+	maintenance, err := proxyHostsInMaintenance(s.DB, time.Now())
+	if err != nil {
+		logger.Log().WithError(err).Debug("Failed to fetch maintenance windows")
+	}
+
 	// Group monitors by UptimeHost
 	hostMonitors := make(map[string][]models.UptimeMonitor)
 	for _, monitor := range monitors {
+		if monitor.ProxyHostID != nil && maintenance[*monitor.ProxyHostID] {
+			s.markMaintenance(&monitor)
+			continue
+		}
 		hostID := ""
 		if monitor.UptimeHostID != nil {
 			hostID = *monitor.UptimeHostID
@@ -340,6 +453,17 @@ func (s *UptimeService) CheckAll() {
 	}
 }
 
+// markMaintenance sets a monitor to maintenance, ending any pending failure streak.
+func (s *UptimeService) markMaintenance(monitor *models.UptimeMonitor) {
+	if monitor.Status == "maintenance" {
+		return
+	}
+	monitor.Status = "maintenance"
+	monitor.FailureCount = 0
+	monitor.LastStatusChange = time.Now()
+	s.DB.Save(monitor)
+}
+
 // checkAllHosts performs TCP connectivity check on all UptimeHosts
 func (s *UptimeService) checkAllHosts() {
 	var hosts []models.UptimeHost
@@ -370,6 +494,19 @@ func (s *UptimeService) checkHost(host *models.UptimeHost) {
 	var msg string
 
 	for _, monitor := range monitors {
+		// Upstreams on unix sockets are checked by connecting to the socket
+		if path, ok := caddy.UnixSocketPath(host.Host); ok {
+			conn, err := net.DialTimeout("unix", path, 5*time.Second)
+			if err == nil {
+				_ = conn.Close()
+				success = true
+				msg = fmt.Sprintf("Connection to %s successful", host.Host)
+			} else {
+				msg = err.Error()
+			}
+			break
+		}
+
 		port := extractPort(monitor.URL)
 		if port == "" {
 			continue
@@ -534,6 +671,14 @@ func (s *UptimeService) CheckMonitor(monitor models.UptimeMonitor) {
 }
 
 func (s *UptimeService) checkMonitor(monitor models.UptimeMonitor) {
+	if monitor.Status == "maintenance" {
+		// Back from maintenance: start over as up without announcing a recovery,
+		// so that only an outage after the window is reported
+		monitor.Status = "up"
+		monitor.FailureCount = 0
+		monitor.LastStatusChange = time.Now()
+	}
+
 	start := time.Now()
 	success := false
 	var msg string
@@ -554,6 +699,8 @@ func (s *UptimeService) checkMonitor(monitor models.UptimeMonitor) {
 		} else {
 			msg = err.Error()
 		}
+	case "grpc":
+		success, msg = checkGRPCHealth(context.Background(), monitor.URL, monitor.GRPCService)
 	case "tcp":
 		conn, err := net.DialTimeout("tcp", monitor.URL, 10*time.Second)
 		if err == nil {
@@ -845,6 +992,9 @@ func (s *UptimeService) UpdateMonitor(id string, updates map[string]interface{})
 	if val, ok := updates["enabled"]; ok {
 		allowedUpdates["enabled"] = val
 	}
+	if val, ok := updates["grpc_service"]; ok {
+		allowedUpdates["grpc_service"] = val
+	}
 	// Add other fields as needed, but be careful not to overwrite SyncMonitors logic
 
 	if err := s.DB.Model(&monitor).Updates(allowedUpdates).Error; err != nil {
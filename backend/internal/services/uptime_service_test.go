@@ -30,6 +30,8 @@ func setupUptimeTestDB(t *testing.T) *gorm.DB {
 		&models.UptimeHost{},
 		&models.UptimeNotificationEvent{},
 		&models.RemoteServer{},
+		&models.Stream{},
+		&models.MaintenanceWindow{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
@@ -1201,3 +1203,78 @@ func TestFormatDuration(t *testing.T) {
 		assert.Equal(t, tc.expected, result, "formatDuration(%v)", tc.input)
 	}
 }
+
+func TestUptimeService_SyncMonitors_Streams(t *testing.T) {
+	db := setupUptimeTestDB(t)
+	us := NewUptimeService(db, NewNotificationService(db))
+
+	pg := models.Stream{UUID: "pg", ListenPort: 5432, Protocol: "tcp", Upstreams: "db1:5432, db2:5432", Enabled: true}
+	dns := models.Stream{UUID: "dns", Name: "DNS", ListenPort: 53, Protocol: "udp", Upstreams: "resolver:53", Enabled: true}
+	db.Create(&pg)
+	db.Create(&dns)
+
+	assert.NoError(t, us.SyncMonitors())
+	var monitors []models.UptimeMonitor
+	db.Find(&monitors)
+	if assert.Len(t, monitors, 1, "UDP streams are not monitored") {
+		assert.Equal(t, pg.ID, *monitors[0].StreamID)
+		assert.Equal(t, "Stream :5432", monitors[0].Name)
+		assert.Equal(t, "tcp", monitors[0].Type)
+		assert.Equal(t, "db1:5432", monitors[0].URL)
+		assert.Equal(t, "db1", monitors[0].UpstreamHost)
+		assert.NotNil(t, monitors[0].UptimeHostID)
+	}
+
+	// Changes to the stream are carried over
+	db.Model(&pg).Updates(map[string]interface{}{"name": "Postgres", "upstreams": "db3:5433", "enabled": false})
+	assert.NoError(t, us.SyncMonitors())
+	var monitor models.UptimeMonitor
+	db.Where("stream_id = ?", pg.ID).First(&monitor)
+	assert.Equal(t, "Postgres", monitor.Name)
+	assert.Equal(t, "db3:5433", monitor.URL)
+	assert.Equal(t, "db3", monitor.UpstreamHost)
+	assert.False(t, monitor.Enabled)
+
+	// A stream switched to UDP loses its monitor and heartbeats
+	db.Create(&models.UptimeHeartbeat{MonitorID: monitor.ID, Status: "up"})
+	db.Model(&pg).Update("protocol", "udp")
+	assert.NoError(t, us.SyncMonitors())
+	var count int64
+	db.Model(&models.UptimeMonitor{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.UptimeHeartbeat{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestUptimeService_CheckAll_Maintenance(t *testing.T) {
+	db := setupUptimeTestDB(t)
+	us := NewUptimeService(db, NewNotificationService(db))
+
+	host := models.ProxyHost{UUID: "app", DomainNames: "app.example.com", ForwardHost: "127.0.0.1", ForwardPort: 1, Enabled: true}
+	assert.NoError(t, db.Create(&host).Error)
+	hostID := host.ID
+	monitor := models.UptimeMonitor{ProxyHostID: &hostID, Name: "app", Type: "tcp", URL: "127.0.0.1:1", Enabled: true, Status: "up", MaxRetries: 3}
+	assert.NoError(t, db.Create(&monitor).Error)
+	started := time.Now().Add(-time.Minute)
+	window := models.MaintenanceWindow{UUID: "w", ProxyHostID: hostID, StartsAt: &started, Enabled: true}
+	assert.NoError(t, db.Create(&window).Error)
+
+	us.CheckAll()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, db.First(&monitor, "id = ?", monitor.ID).Error)
+	assert.Equal(t, "maintenance", monitor.Status)
+	var heartbeats int64
+	db.Model(&models.UptimeHeartbeat{}).Count(&heartbeats)
+	assert.Zero(t, heartbeats, "monitors in maintenance are not checked")
+
+	// After the window the failing backend starts over as up, without a recovery notification
+	assert.NoError(t, db.Delete(&window).Error)
+	us.CheckMonitor(monitor)
+	assert.NoError(t, db.First(&monitor, "id = ?", monitor.ID).Error)
+	assert.Equal(t, "up", monitor.Status)
+	assert.Equal(t, 1, monitor.FailureCount)
+	var notifications int64
+	db.Model(&models.Notification{}).Count(&notifications)
+	assert.Zero(t, notifications)
+}
@@ -30,24 +30,31 @@ if [ "$SECURITY_CROWDSEC_MODE" = "local" ]; then
     fi
 fi
 
-# Start Caddy in the background with initial empty config
-echo '{"apps":{}}' > /config/caddy.json
-# Use JSON config directly; no adapter needed
-caddy run --config /config/caddy.json &
-CADDY_PID=$!
-echo "Caddy started (PID: $CADDY_PID)"
+# Start Caddy in the background with initial empty config, unless Charon
+# supervises Caddy itself
+CADDY_SUPERVISE=${CHARON_CADDY_SUPERVISE:-$CPM_CADDY_SUPERVISE}
+CADDY_PID=""
+if [ "$CADDY_SUPERVISE" = "true" ]; then
+    echo "Caddy is supervised by Charon"
+else
+    echo '{"apps":{}}' > /config/caddy.json
+    # Use JSON config directly; no adapter needed
+    caddy run --config /config/caddy.json &
+    CADDY_PID=$!
+    echo "Caddy started (PID: $CADDY_PID)"
 
-# Wait for Caddy to be ready
-echo "Waiting for Caddy admin API..."
-i=1
-while [ "$i" -le 30 ]; do
-    if wget -q -O- http://127.0.0.1:2019/config/ > /dev/null 2>&1; then
-        echo "Caddy is ready!"
-        break
-    fi
-    i=$((i+1))
-    sleep 1
-done
+    # Wait for Caddy to be ready
+    echo "Waiting for Caddy admin API..."
+    i=1
+    while [ "$i" -le 30 ]; do
+        if wget -q -O- http://127.0.0.1:2019/config/ > /dev/null 2>&1; then
+            echo "Caddy is ready!"
+            break
+        fi
+        i=$((i+1))
+        sleep 1
+    done
+fi
 
 # Start Charon management application
 echo "Starting Charon management application..."
@@ -72,14 +79,18 @@ echo "Charon started (PID: $APP_PID)"
 shutdown() {
     echo "Shutting down..."
     kill -TERM "$APP_PID" 2>/dev/null || true
-    kill -TERM "$CADDY_PID" 2>/dev/null || true
+    if [ -n "$CADDY_PID" ]; then
+        kill -TERM "$CADDY_PID" 2>/dev/null || true
+    fi
     if [ -n "$CROWDSEC_PID" ]; then
         echo "Stopping CrowdSec..."
         kill -TERM "$CROWDSEC_PID" 2>/dev/null || true
         wait "$CROWDSEC_PID" 2>/dev/null || true
     fi
     wait "$APP_PID" 2>/dev/null || true
-    wait "$CADDY_PID" 2>/dev/null || true
+    if [ -n "$CADDY_PID" ]; then
+        wait "$CADDY_PID" 2>/dev/null || true
+    fi
     exit 0
 }
 
@@ -92,7 +103,7 @@ echo "  - Caddy Proxy: http://localhost:80, https://localhost:443"
 echo "  - Caddy Admin API: http://localhost:2019"
 
 # Wait loop: exit when either process dies, then shutdown the other
-while kill -0 "$APP_PID" 2>/dev/null && kill -0 "$CADDY_PID" 2>/dev/null; do
+while kill -0 "$APP_PID" 2>/dev/null && { [ -z "$CADDY_PID" ] || kill -0 "$CADDY_PID" 2>/dev/null; }; do
     sleep 1
 done
 